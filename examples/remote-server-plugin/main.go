@@ -31,8 +31,10 @@ type Plugin struct {
 	v1.UnimplementedWatchPluginServer
 	v1.UnimplementedStorageQuerierPluginServer
 	// data is the meshdb database.
-	data   storage.MeshDB
-	closec chan struct{}
+	data storage.MeshDB
+	// streams are the query streams injected into the plugin.
+	streams *rpcdb.QueryStreams
+	closec  chan struct{}
 }
 
 // GetInfo must be implemented by all plugins. It returns information about the plugin
@@ -72,7 +74,12 @@ func (p *Plugin) Emit(ctx context.Context, ev *v1.Event) (*emptypb.Empty, error)
 // It is called after Configure and before any other methods are called. The stream
 // can be used with the plugindb package to open a database connection.
 func (p *Plugin) InjectQuerier(srv v1.StorageQuerierPlugin_InjectQuerierServer) error {
-	p.data = rpcdb.OpenServer(srv)
+	// A new stream is injected if the previous one fails, queries move over to it.
+	if p.data == nil {
+		p.streams = rpcdb.NewQueryStreams()
+		p.data = rpcdb.OpenStreams(p.streams)
+	}
+	p.streams.Inject(srv)
 	select {
 	case <-p.closec:
 	case <-srv.Context().Done():
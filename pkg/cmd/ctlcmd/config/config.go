@@ -144,6 +144,9 @@ type Cluster struct {
 type ClusterConfig struct {
 	// Server is the URL of a discovery node in the cluster.
 	Server string `yaml:"server,omitempty" json:"server,omitempty"`
+	// Servers are the URLs of additional discovery nodes in the cluster. When
+	// joining, Server is tried first followed by each of these in order.
+	Servers []string `yaml:"servers,omitempty" json:"servers,omitempty"`
 	// Insecure controls whether TLS should be disabled for the cluster connection.
 	Insecure bool `yaml:"insecure,omitempty" json:"insecure,omitempty"`
 	// TLSVerifyChainOnly controls whether only the cluster's TLS chain should be verified.
@@ -160,6 +163,24 @@ type ClusterConfig struct {
 	RequestTimeout Duration `yaml:"request-timeout,omitempty" json:"request-timeout,omitempty"`
 }
 
+// JoinServers returns the servers to attempt to join in order. Server is
+// always first if set and duplicates are removed.
+func (c *ClusterConfig) JoinServers() []string {
+	var out []string
+	seen := make(map[string]struct{})
+	for _, server := range append([]string{c.Server}, c.Servers...) {
+		if server == "" {
+			continue
+		}
+		if _, ok := seen[server]; ok {
+			continue
+		}
+		seen[server] = struct{}{}
+		out = append(out, server)
+	}
+	return out
+}
+
 // User is the named configuration for a user.
 type User struct {
 	// Name is the name of the user.
@@ -184,6 +205,8 @@ type UserConfig struct {
 	LDAPPassword string `yaml:"ldap-password,omitempty" json:"ldap-password,omitempty"`
 	// IDAuthPrivateKey is the private key for ID authentication.
 	IDAuthPrivateKey string `yaml:"id-auth-public-key,omitempty" json:"id-auth-public-key,omitempty"`
+	// JoinToken is a pre-shared token to present when joining a mesh.
+	JoinToken string `yaml:"join-token,omitempty" json:"join-token,omitempty"`
 }
 
 // Context is the named configuration for a context.
@@ -17,6 +17,7 @@ limitations under the License.
 package ctlcmd
 
 import (
+	"net"
 	"os"
 	"os/signal"
 	"syscall"
@@ -29,6 +30,7 @@ import (
 	"github.com/webmeshproj/webmesh/pkg/context"
 	"github.com/webmeshproj/webmesh/pkg/crypto"
 	"github.com/webmeshproj/webmesh/pkg/embed"
+	"github.com/webmeshproj/webmesh/pkg/identity"
 	"github.com/webmeshproj/webmesh/pkg/logging"
 )
 
@@ -41,6 +43,13 @@ var (
 	connectLogLevel      string
 	connectLogFormat     string
 	connectTimeout       time.Duration
+	connectJoinServers   []string
+	connectMaxRetries    int
+	connectDNSServer     string
+	connectJoinAsVoter   bool
+	connectNodeID        string
+	connectIDStrategy    string
+	connectJoinToken     string
 )
 
 func init() {
@@ -55,6 +64,16 @@ func init() {
 	connectFlags.StringVar(&connectLogLevel, "log-level", "info", "Log level for the connection")
 	connectFlags.StringVar(&connectLogFormat, "log-format", "text", "Log format for the connection, text or json")
 	connectFlags.DurationVar(&connectTimeout, "timeout", 30*time.Second, "Timeout for connecting to the mesh")
+	connectFlags.StringSliceVar(&connectJoinServers, "join-servers", nil, "Servers to attempt to join in order (default: the servers of the current cluster)")
+	connectFlags.StringVar(&connectDNSServer, "dns-server", "", "DNS server (host:port) to use for resolving join servers (default: the system resolver)")
+	connectFlags.IntVar(&connectMaxRetries, "max-join-retries", 5, "Maximum number of times to retry joining through the list of servers")
+	// Voters take part in raft elections and count towards quorum. An ephemeral
+	// voter that disappears without leaving can stall the cluster, so this should
+	// only be used for bootstrap or otherwise trusted nodes.
+	connectFlags.BoolVar(&connectJoinAsVoter, "join-as-voter", false, "Request voter suffrage when joining (affects cluster quorum, use only for trusted nodes)")
+	connectFlags.StringVar(&connectJoinToken, "join-token", "", "Pre-shared token to present when joining (default: the join token of the current user)")
+	connectFlags.StringVar(&connectNodeID, "node-id", "", "Node ID to use for the connection (default: generated with --node-id-strategy)")
+	connectFlags.StringVar(&connectIDStrategy, "node-id-strategy", "", "Strategy for generating the node ID, one of hostname, uuid, or public-key (default: derived from the configured authentication)")
 	rootCmd.AddCommand(connectCmd)
 }
 
@@ -89,6 +108,14 @@ var connectCmd = &cobra.Command{
 				return err
 			}
 		}
+		nodeID, err := connectNodeIDFor(key)
+		if err != nil {
+			return err
+		}
+		opts := newEmbedOptions(user, cluster, key, nodeID)
+		if err := opts.Config.Auth.ValidateJoinCredentials(opts.Config.TLS.Insecure); err != nil {
+			return err
+		}
 		log := logging.NewLogger(connectLogLevel, connectLogFormat)
 		ctx := context.WithLogger(cmd.Context(), log)
 		cancel := func() {}
@@ -97,7 +124,7 @@ var connectCmd = &cobra.Command{
 		} else {
 			ctx, cancel = context.WithCancel(ctx)
 		}
-		node, err := embed.NewNode(ctx, newEmbedOptions(user, cluster, key))
+		node, err := embed.NewNode(ctx, opts)
 		if err != nil {
 			return err
 		}
@@ -131,7 +158,22 @@ var connectCmd = &cobra.Command{
 	},
 }
 
-func newEmbedOptions(user *cmdconfig.UserConfig, cluster *cmdconfig.ClusterConfig, key crypto.PrivateKey) embed.Options {
+// connectResolver returns a resolver that sends all queries to the configured
+// DNS server, or nil if the system resolver should be used.
+func connectResolver() *net.Resolver {
+	if connectDNSServer == "" {
+		return nil
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, connectDNSServer)
+		},
+	}
+}
+
+func newEmbedOptions(user *cmdconfig.UserConfig, cluster *cmdconfig.ClusterConfig, key crypto.PrivateKey, nodeID string) embed.Options {
 	return embed.Options{
 		Config: &config.Config{
 			Global: config.GlobalOptions{
@@ -156,10 +198,14 @@ func newEmbedOptions(user *cmdconfig.UserConfig, cluster *cmdconfig.ClusterConfi
 					Username: user.LDAPUsername,
 					Password: user.LDAPPassword,
 				},
+				JoinToken: connectJoinTokenFor(user),
 			},
 			Mesh: config.MeshOptions{
-				JoinAddresses:               []string{cluster.Server},
-				MaxJoinRetries:              5,
+				NodeID:                      nodeID,
+				JoinAddresses:               joinServers(cluster),
+				JoinResolver:                connectResolver(),
+				MaxJoinRetries:              connectMaxRetries,
+				RequestVote:                 connectJoinAsVoter,
 				UseMeshDNS:                  connectUseDNS,
 				DisableIPv4:                 connectDisableIPv4,
 				DisableIPv6:                 connectDisableIPv6,
@@ -172,12 +218,7 @@ func newEmbedOptions(user *cmdconfig.UserConfig, cluster *cmdconfig.ClusterConfi
 				InsecureSkipVerify: cluster.TLSSkipVerify,
 				Insecure:           cluster.Insecure,
 			},
-			Storage: config.StorageOptions{
-				InMemory:  true,
-				Provider:  string(config.StorageProviderPassThrough),
-				LogLevel:  connectLogLevel,
-				LogFormat: connectLogFormat,
-			},
+			Storage: connectStorageOptions(),
 			Services: config.ServiceOptions{
 				API: config.APIOptions{Disabled: true},
 			},
@@ -185,3 +226,63 @@ func newEmbedOptions(user *cmdconfig.UserConfig, cluster *cmdconfig.ClusterConfi
 		Key: key,
 	}
 }
+
+// connectNodeIDFor returns the node ID to use for the connection. An empty
+// ID is returned when neither a node ID nor a strategy was given, leaving
+// it to be derived from the authentication options.
+func connectNodeIDFor(key crypto.PrivateKey) (string, error) {
+	if connectNodeID != "" {
+		return connectNodeID, nil
+	}
+	if connectIDStrategy == "" {
+		return "", nil
+	}
+	strategy := identity.Strategy(connectIDStrategy)
+	switch strategy {
+	case identity.StrategyPublicKey:
+		return identity.IDFromKey(key)
+	default:
+		return identity.GenerateID(strategy, "")
+	}
+}
+
+// connectJoinTokenFor returns the join token to present, preferring the
+// one given on the command line over the one in the user's configuration.
+func connectJoinTokenFor(user *cmdconfig.UserConfig) string {
+	if connectJoinToken != "" {
+		return connectJoinToken
+	}
+	return user.JoinToken
+}
+
+func joinServers(cluster *cmdconfig.ClusterConfig) []string {
+	if len(connectJoinServers) > 0 {
+		return connectJoinServers
+	}
+	return cluster.JoinServers()
+}
+
+// connectStorageOptions returns the storage options for the connection. Raft
+// options are only set when the raft provider is used.
+func connectStorageOptions() config.StorageOptions {
+	opts := config.StorageOptions{
+		InMemory:  true,
+		Provider:  string(connectStorageProvider()),
+		LogLevel:  connectLogLevel,
+		LogFormat: connectLogFormat,
+	}
+	if connectStorageProvider() == config.StorageProviderRaft {
+		opts.Raft = config.NewRaftOptions()
+	}
+	return opts
+}
+
+// connectStorageProvider returns the storage provider for the connection.
+// Voters need a local copy of the raft log, everyone else passes through
+// to the cluster.
+func connectStorageProvider() config.StorageProvider {
+	if connectJoinAsVoter {
+		return config.StorageProviderRaft
+	}
+	return config.StorageProviderPassThrough
+}
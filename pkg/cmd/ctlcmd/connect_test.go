@@ -0,0 +1,59 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ctlcmd
+
+import (
+	"testing"
+
+	cmdconfig "github.com/webmeshproj/webmesh/pkg/cmd/ctlcmd/config"
+	"github.com/webmeshproj/webmesh/pkg/config"
+)
+
+func TestNewEmbedOptions(t *testing.T) {
+	// Not parallel, the connect flags are package globals.
+	t.Cleanup(func() { connectJoinAsVoter = false })
+	user := &cmdconfig.UserConfig{}
+	cluster := &cmdconfig.ClusterConfig{Server: "127.0.0.1:8443"}
+
+	t.Run("Passthrough", func(t *testing.T) {
+		connectJoinAsVoter = false
+		opts := newEmbedOptions(user, cluster, nil, "node")
+		if opts.Config.Mesh.RequestVote {
+			t.Error("expected not to request a vote")
+		}
+		if provider := config.StorageProvider(opts.Config.Storage.Provider); provider != config.StorageProviderPassThrough {
+			t.Errorf("expected passthrough storage provider, got %q", provider)
+		}
+		if opts.Config.Storage.Raft != (config.RaftOptions{}) {
+			t.Error("expected raft options to be unset for the passthrough provider")
+		}
+	})
+
+	t.Run("JoinAsVoter", func(t *testing.T) {
+		connectJoinAsVoter = true
+		opts := newEmbedOptions(user, cluster, nil, "node")
+		if !opts.Config.Mesh.RequestVote {
+			t.Error("expected to request a vote")
+		}
+		if provider := config.StorageProvider(opts.Config.Storage.Provider); provider != config.StorageProviderRaft {
+			t.Errorf("expected raft storage provider, got %q", provider)
+		}
+		if opts.Config.Storage.Raft != config.NewRaftOptions() {
+			t.Error("expected default raft options for the raft provider")
+		}
+	})
+}
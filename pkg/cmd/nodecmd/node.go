@@ -34,6 +34,7 @@ import (
 	"github.com/webmeshproj/webmesh/pkg/context"
 	"github.com/webmeshproj/webmesh/pkg/embed"
 	"github.com/webmeshproj/webmesh/pkg/logging"
+	"github.com/webmeshproj/webmesh/pkg/plugins/builtins/debug"
 	"github.com/webmeshproj/webmesh/pkg/version"
 )
 
@@ -164,5 +165,8 @@ func Execute() error {
 		ctx, cancel = context.WithTimeout(context.WithLogger(context.Background(), log), *shutdownTimeout)
 		defer cancel()
 	}
-	return node.Stop(ctx)
+	err = node.Stop(ctx)
+	// Debug servers kept running for diagnostics are closed last.
+	debug.ShutdownLingering()
+	return err
 }
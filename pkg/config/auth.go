@@ -36,6 +36,9 @@ type AuthOptions struct {
 	Basic BasicAuthOptions `koanf:"basic,omitempty"`
 	// LDAP are options for LDAP authentication.
 	LDAP LDAPAuthOptions `koanf:"ldap,omitempty"`
+	// JoinToken is a pre-shared token to present in the metadata of
+	// requests when joining a mesh.
+	JoinToken string `koanf:"join-token,omitempty"`
 }
 
 // NewAuthOptions returns a new empty AuthOptions.
@@ -48,7 +51,7 @@ func (o *AuthOptions) IsEmpty() bool {
 	if o == nil {
 		return true
 	}
-	return o.IDAuth.IsEmpty() && o.MTLS.IsEmpty() && o.Basic.IsEmpty() && o.LDAP.IsEmpty()
+	return o.IDAuth.IsEmpty() && o.MTLS.IsEmpty() && o.Basic.IsEmpty() && o.LDAP.IsEmpty() && o.JoinToken == ""
 }
 
 // MTLSEnabled is true if any mtls fields are set.
@@ -59,6 +62,24 @@ func (o *AuthOptions) MTLSEnabled() bool {
 	return o.MTLS.Enabled()
 }
 
+// ErrNoJoinCredentials is returned when joining a mesh over TLS without
+// any credentials to present.
+var ErrNoJoinCredentials = errors.New("a join token or mtls credentials are required unless insecure is set")
+
+// ValidateJoinCredentials checks that the options carry a credential to
+// present when joining a mesh. A join token or mTLS credentials satisfy the
+// check, as do any of the other authentication methods. When insecure is
+// true no credential is required.
+func (o *AuthOptions) ValidateJoinCredentials(insecure bool) error {
+	if insecure {
+		return nil
+	}
+	if o.IsEmpty() {
+		return ErrNoJoinCredentials
+	}
+	return o.Validate()
+}
+
 // IDAuthOptions are options for ID authentication.
 type IDAuthOptions struct {
 	// Enabled is true if ID authentication is enabled.
@@ -141,6 +162,7 @@ func (o *AuthOptions) BindFlags(prefix string, fl *pflag.FlagSet) {
 	fl.StringVar(&o.MTLS.KeyData, prefix+"mtls.key-data", o.MTLS.KeyData, "Base64 encoded TLS key data for the certificate.")
 	fl.StringVar(&o.LDAP.Username, prefix+"ldap.username", o.LDAP.Username, "LDAP auth username.")
 	fl.StringVar(&o.LDAP.Password, prefix+"ldap.password", o.LDAP.Password, "LDAP auth password.")
+	fl.StringVar(&o.JoinToken, prefix+"join-token", o.JoinToken, "Pre-shared token to present when joining.")
 }
 
 func (o *AuthOptions) Validate() error {
@@ -177,6 +199,9 @@ func (o *AuthOptions) Validate() error {
 		}
 		return nil
 	}
+	if o.JoinToken != "" {
+		return nil
+	}
 	// Something weird happened
 	return fmt.Errorf("auth options are invalid: %+v", o)
 }
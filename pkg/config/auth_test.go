@@ -17,9 +17,18 @@ limitations under the License.
 package config
 
 import (
+	"context"
+	"errors"
+	"net"
 	"testing"
 
 	"github.com/spf13/pflag"
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/webmeshproj/webmesh/pkg/crypto"
 )
 
 func TestAuthConfigValidate(t *testing.T) {
@@ -151,6 +160,13 @@ func TestAuthConfigValidate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "JoinTokenValidOptions",
+			authOpts: &AuthOptions{
+				JoinToken: "token",
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tc {
 		t.Run(tt.name, func(t *testing.T) {
@@ -171,3 +187,113 @@ func TestAuthConfigValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateJoinCredentials(t *testing.T) {
+	t.Parallel()
+	tc := []struct {
+		name     string
+		authOpts *AuthOptions
+		insecure bool
+		wantErr  bool
+	}{
+		{
+			name:     "NoCredentials",
+			authOpts: &AuthOptions{},
+			wantErr:  true,
+		},
+		{
+			name:     "NoCredentialsInsecure",
+			authOpts: &AuthOptions{},
+			insecure: true,
+			wantErr:  false,
+		},
+		{
+			name:     "JoinToken",
+			authOpts: &AuthOptions{JoinToken: "token"},
+			wantErr:  false,
+		},
+		{
+			name: "MTLS",
+			authOpts: &AuthOptions{
+				MTLS: MTLSOptions{
+					CertData: "certdata",
+					KeyData:  "keydata",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "InvalidMTLS",
+			authOpts: &AuthOptions{
+				MTLS: MTLSOptions{
+					CertData: "certdata",
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tc {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := tt.authOpts.ValidateJoinCredentials(tt.insecure)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected an error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+	err := (&AuthOptions{}).ValidateJoinCredentials(false)
+	if !errors.Is(err, ErrNoJoinCredentials) {
+		t.Fatalf("expected ErrNoJoinCredentials, got %v", err)
+	}
+}
+
+func TestJoinTokenCreds(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	tokens := make(chan []string, 1)
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	v1.RegisterMembershipServer(srv, &tokenMembershipServer{tokens: tokens})
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	key := crypto.MustGenerateKey()
+	conf := NewDefaultConfig("test-node")
+	conf.TLS.Insecure = true
+	conf.Auth.JoinToken = "secret-token"
+	creds, err := conf.NewClientCredentials(ctx, key)
+	if err != nil {
+		t.Fatalf("new client credentials: %v", err)
+	}
+	dialer := grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	})
+	conn, err := grpc.DialContext(ctx, "bufnet", append(creds, dialer)...)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	_, err = v1.NewMembershipClient(conn).Join(ctx, &v1.JoinRequest{Id: "test-node"})
+	if err != nil {
+		t.Fatalf("join: %v", err)
+	}
+	got := <-tokens
+	if len(got) != 1 || got[0] != "secret-token" {
+		t.Fatalf("expected join request to carry the token, got %v", got)
+	}
+}
+
+type tokenMembershipServer struct {
+	v1.UnimplementedMembershipServer
+	tokens chan []string
+}
+
+func (s *tokenMembershipServer) Join(ctx context.Context, _ *v1.JoinRequest) (*v1.JoinResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.tokens <- md.Get(JoinTokenHeader)
+	return &v1.JoinResponse{}, nil
+}
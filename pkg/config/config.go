@@ -25,19 +25,19 @@ import (
 	"os"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/spf13/pflag"
 
 	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/identity"
 )
 
 // DefaultNodeID is the default node ID used if no other is configured
 var DefaultNodeID = func() string {
-	hostname, err := os.Hostname()
+	id, err := identity.GenerateID(identity.StrategyHostname, "")
 	if err != nil {
-		return uuid.NewString()
+		id, _ = identity.GenerateID(identity.StrategyUUID, "")
 	}
-	return hostname
+	return id
 }()
 
 // Config are the configuration options for running a webmesh node.
@@ -214,8 +214,12 @@ func (o *Config) NodeID(ctx context.Context) (string, error) {
 		if err != nil {
 			return "", fmt.Errorf("load wireguard key: %w", err)
 		}
-		o.Mesh.NodeID = key.ID()
-		return key.ID(), nil
+		id, err := identity.IDFromKey(key)
+		if err != nil {
+			return "", fmt.Errorf("generate node ID: %w", err)
+		}
+		o.Mesh.NodeID = id
+		return id, nil
 	}
 	// Check if we are using authentication
 	if !o.Auth.MTLS.IsEmpty() {
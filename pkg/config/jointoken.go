@@ -0,0 +1,46 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// JoinTokenHeader is the metadata key a join token is sent in.
+const JoinTokenHeader = "x-webmesh-join-token"
+
+// NewJoinTokenCreds returns a DialOption that presents the given join token
+// in the metadata of every request.
+func NewJoinTokenCreds(token string) grpc.DialOption {
+	return grpc.WithPerRPCCredentials(&joinTokenCreds{token: token})
+}
+
+type joinTokenCreds struct {
+	token string
+}
+
+func (c *joinTokenCreds) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{
+		JoinTokenHeader: c.token,
+	}, nil
+}
+
+func (c *joinTokenCreds) RequireTransportSecurity() bool {
+	return false
+}
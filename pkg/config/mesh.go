@@ -38,10 +38,12 @@ import (
 	"github.com/webmeshproj/webmesh/pkg/context"
 	"github.com/webmeshproj/webmesh/pkg/crypto"
 	"github.com/webmeshproj/webmesh/pkg/meshnet"
+	"github.com/webmeshproj/webmesh/pkg/meshnet/netutil"
 	"github.com/webmeshproj/webmesh/pkg/meshnet/transport"
 	"github.com/webmeshproj/webmesh/pkg/meshnet/transport/libp2p"
 	"github.com/webmeshproj/webmesh/pkg/meshnet/transport/tcp"
 	"github.com/webmeshproj/webmesh/pkg/meshnode"
+	"github.com/webmeshproj/webmesh/pkg/plugins"
 	"github.com/webmeshproj/webmesh/pkg/plugins/builtins/basicauth"
 	"github.com/webmeshproj/webmesh/pkg/plugins/builtins/idauth"
 	"github.com/webmeshproj/webmesh/pkg/plugins/builtins/ldap"
@@ -65,6 +67,9 @@ type MeshOptions struct {
 	// JoinMultiaddrs are multiaddresses to attempt to join over libp2p.
 	// These cannot be used with JoinAddresses.
 	JoinMultiaddrs []string `koanf:"join-multiaddrs,omitempty"`
+	// JoinResolver is an optional resolver to use when looking up JoinAddresses.
+	// If nil, the system resolver is used. This can only be set programmatically.
+	JoinResolver *net.Resolver `koanf:"-"`
 	// MaxJoinRetries is the maximum number of join retries.
 	MaxJoinRetries int `koanf:"max-join-retries,omitempty"`
 	// Routes are additional routes to advertise to the mesh. These routes are advertised to all peers.
@@ -98,6 +103,8 @@ type MeshOptions struct {
 	DisableDefaultIPAM bool `koanf:"disable-default-ipam,omitempty"`
 	// DefaultIPAMStaticIPv4 are static IPv4 assignments to use for the default IPAM.
 	DefaultIPAMStaticIPv4 map[string]string `koanf:"default-ipam-static-ipv4,omitempty"`
+	// DefaultIPAMZonePrefixes are IPv6 prefixes by zone awareness ID to use for the default IPAM.
+	DefaultIPAMZonePrefixes map[string]string `koanf:"default-ipam-zone-prefixes,omitempty"`
 }
 
 // NewMeshOptions returns a new MeshOptions with the default values. If node id
@@ -123,6 +130,7 @@ func NewMeshOptions(nodeID string) MeshOptions {
 		DisableFeatureAdvertisement: false,
 		DisableDefaultIPAM:          false,
 		DefaultIPAMStaticIPv4:       map[string]string{},
+		DefaultIPAMZonePrefixes:     map[string]string{},
 	}
 }
 
@@ -148,6 +156,7 @@ func (o *MeshOptions) BindFlags(prefix string, fs *pflag.FlagSet) {
 	fs.BoolVar(&o.DisableFeatureAdvertisement, prefix+"disable-feature-advertisement", o.DisableFeatureAdvertisement, "Disable feature advertisement.")
 	fs.BoolVar(&o.DisableDefaultIPAM, prefix+"disable-default-ipam", o.DisableDefaultIPAM, "Disable the default IPAM.")
 	fs.StringToStringVar(&o.DefaultIPAMStaticIPv4, prefix+"default-ipam-static-ipv4", o.DefaultIPAMStaticIPv4, "Static IPv4 assignments to use for the default IPAM.")
+	fs.StringToStringVar(&o.DefaultIPAMZonePrefixes, prefix+"default-ipam-zone-prefixes", o.DefaultIPAMZonePrefixes, "IPv6 prefixes by zone awareness ID to use for the default IPAM.")
 }
 
 // Validate validates the options.
@@ -225,9 +234,15 @@ func (o *MeshOptions) Validate() error {
 			if !types.IsValidNodeID(id) {
 				return fmt.Errorf("invalid node ID %s", id)
 			}
-			_, err := netip.ParsePrefix(addr)
+			_, err := plugins.ParseStaticAddress(addr)
 			if err != nil {
-				return fmt.Errorf("invalid IPv4 address %s for node %s", addr, id)
+				return fmt.Errorf("invalid IPv4 address %s for node %s: %w", addr, id, err)
+			}
+		}
+		for zone, prefix := range o.DefaultIPAMZonePrefixes {
+			_, err := plugins.ParseZonePrefix(prefix)
+			if err != nil {
+				return fmt.Errorf("invalid IPv6 prefix %s for zone %s: %w", prefix, zone, err)
 			}
 		}
 	}
@@ -258,6 +273,7 @@ func (o *Config) NewMeshConfig(ctx context.Context, key crypto.PrivateKey) (conf
 		DisableIPv6:             o.Mesh.DisableIPv6,
 		DisableDefaultIPAM:      o.Mesh.DisableDefaultIPAM,
 		DefaultIPAMStaticIPv4:   o.Mesh.DefaultIPAMStaticIPv4,
+		DefaultIPAMZonePrefixes: o.Mesh.DefaultIPAMZonePrefixes,
 	}
 	// Check if we are serving a local DNS server
 	if o.Services.MeshDNS.Enabled {
@@ -372,6 +388,10 @@ func (o *Config) NewClientCredentials(ctx context.Context, key crypto.PrivateKey
 		log.Debug("Configuring LDAP authentication")
 		creds = append(creds, ldap.NewCreds(o.Auth.LDAP.Username, o.Auth.LDAP.Password))
 	}
+	if o.Auth.JoinToken != "" {
+		log.Debug("Configuring join token authentication")
+		creds = append(creds, NewJoinTokenCreds(o.Auth.JoinToken))
+	}
 	if o.Auth.IDAuth.Enabled {
 		log.Debug("Configuring ID authentication")
 		creds = append(creds, idauth.NewCreds(key))
@@ -409,7 +429,7 @@ func (o *Config) NewConnectOptions(ctx context.Context, conn meshnode.Node, prov
 				continue
 			}
 			var addr netip.AddrPort
-			addr, err = netip.ParseAddrPort(ep)
+			addr, err = netutil.ParseEndpoint(ep)
 			if err != nil {
 				return
 			}
@@ -509,23 +529,25 @@ func (o *Config) NewConnectOptions(ctx context.Context, conn meshnode.Node, prov
 		PreferIPv6: o.Mesh.StoragePreferIPv6,
 		Plugins:    plugins,
 		NetworkOptions: meshnet.Options{
-			Modprobe:              o.WireGuard.Modprobe,
-			InterfaceName:         o.WireGuard.InterfaceName,
-			ForceReplace:          o.WireGuard.ForceInterfaceName,
-			ListenPort:            o.WireGuard.ListenPort,
-			PersistentKeepAlive:   o.WireGuard.PersistentKeepAlive,
-			ForceTUN:              o.WireGuard.ForceTUN,
-			MTU:                   o.WireGuard.MTU,
-			RecordMetrics:         o.WireGuard.RecordMetrics,
-			RecordMetricsInterval: o.WireGuard.RecordMetricsInterval,
-			StoragePort:           o.Storage.ListenPort(),
-			GRPCPort:              o.Mesh.GRPCAdvertisePort,
-			ZoneAwarenessID:       o.Mesh.ZoneAwarenessID,
-			Credentials:           conn.Credentials(),
-			LocalDNSAddr:          localDNSAddr,
-			DisableIPv4:           o.Mesh.DisableIPv4,
-			DisableIPv6:           o.Mesh.DisableIPv6,
-			DisableFullTunnel:     o.WireGuard.DisableFullTunnel,
+			Modprobe:                 o.WireGuard.Modprobe,
+			InterfaceName:            o.WireGuard.InterfaceName,
+			ForceReplace:             o.WireGuard.ForceInterfaceName,
+			ListenPort:               o.WireGuard.ListenPort,
+			PersistentKeepAlive:      o.WireGuard.PersistentKeepAlive,
+			ForceTUN:                 o.WireGuard.ForceTUN,
+			MTU:                      o.WireGuard.MTU,
+			RecordMetrics:            o.WireGuard.RecordMetrics,
+			RecordMetricsInterval:    o.WireGuard.RecordMetricsInterval,
+			StoragePort:              o.Storage.ListenPort(),
+			GRPCPort:                 o.Mesh.GRPCAdvertisePort,
+			ZoneAwarenessID:          o.Mesh.ZoneAwarenessID,
+			Credentials:              conn.Credentials(),
+			LocalDNSAddr:             localDNSAddr,
+			DisableIPv4:              o.Mesh.DisableIPv4,
+			DisableIPv6:              o.Mesh.DisableIPv6,
+			DisableFullTunnel:        o.WireGuard.DisableFullTunnel,
+			EndpointFamilyPreference: meshnet.EndpointFamily(o.WireGuard.EndpointFamilyPreference),
+			EnableForwarding:         o.WireGuard.EnableForwarding,
 			Relays: meshnet.RelayOptions{
 				Host: o.Discovery.HostOptions(ctx, conn.Key()),
 			},
@@ -556,6 +578,7 @@ func (o *Config) NewJoinTransport(ctx context.Context, nodeID string, conn meshn
 			Addrs:          o.Mesh.JoinAddresses,
 			Credentials:    conn.Credentials(),
 			AddressTimeout: time.Second * 3,
+			Resolver:       o.Mesh.JoinResolver,
 		}), nil
 	}
 	if len(o.Mesh.JoinMultiaddrs) > 0 {
@@ -242,6 +242,17 @@ func TestMeshConfigValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "InvalidIPAMZonePrefixes",
+			cfg: &MeshOptions{
+				NodeID:                      "test-node",
+				DisableFeatureAdvertisement: true,
+				DefaultIPAMZonePrefixes: map[string]string{
+					"zone-a": "172.16.0.0/24",
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "ValidIPAMPrefixes",
 			cfg: &MeshOptions{
@@ -250,6 +261,9 @@ func TestMeshConfigValidation(t *testing.T) {
 				DefaultIPAMStaticIPv4: map[string]string{
 					"test-node": "172.16.0.1/32",
 				},
+				DefaultIPAMZonePrefixes: map[string]string{
+					"zone-a": "fd00:1234:5678:a00::/56",
+				},
 			},
 			wantErr: false,
 		},
@@ -34,7 +34,8 @@ import (
 type RaftOptions struct {
 	// ListenAddress is the address to listen on.
 	ListenAddress string `koanf:"listen-address,omitempty"`
-	// ConnectionPoolCount is the number of connections to pool. If 0, no connection pooling is used.
+	// ConnectionPoolCount is the number of connections to pool. If 0, no connection pooling
+	// is used and a new connection is dialed for every RPC to a peer.
 	ConnectionPoolCount int `koanf:"connection-pool-count,omitempty"`
 	// ConnectionTimeout is the timeout for connections.
 	ConnectionTimeout time.Duration `koanf:"connection-timeout,omitempty"`
@@ -56,6 +57,8 @@ type RaftOptions struct {
 	SnapshotThreshold uint64 `koanf:"snapshot-threshold,omitempty"`
 	// SnapshotRetention is the number of snapshots to retain.
 	SnapshotRetention uint64 `koanf:"snapshot-retention,omitempty"`
+	// TrailingLogs is the number of logs to retain after a snapshot.
+	TrailingLogs uint64 `koanf:"trailing-logs,omitempty"`
 	// ObserverChanBuffer is the buffer size for the observer channel.
 	ObserverChanBuffer int `koanf:"observer-chan-buffer,omitempty"`
 	// HeartbeatPurgeThreshold is the threshold of failed heartbeats before purging a peer.
@@ -77,6 +80,7 @@ func NewRaftOptions() RaftOptions {
 		SnapshotInterval:        30 * time.Second,
 		SnapshotThreshold:       8192,
 		SnapshotRetention:       2,
+		TrailingLogs:            raftstorage.DefaultTrailingLogs,
 		ObserverChanBuffer:      100,
 		HeartbeatPurgeThreshold: 25,
 	}
@@ -96,6 +100,7 @@ func (o *RaftOptions) BindFlags(prefix string, fs *pflag.FlagSet) {
 	fs.DurationVar(&o.SnapshotInterval, prefix+"snapshot-interval", o.SnapshotInterval, "Raft snapshot interval.")
 	fs.Uint64Var(&o.SnapshotThreshold, prefix+"snapshot-threshold", o.SnapshotThreshold, "Raft snapshot threshold.")
 	fs.Uint64Var(&o.SnapshotRetention, prefix+"snapshot-retention", o.SnapshotRetention, "Raft snapshot retention.")
+	fs.Uint64Var(&o.TrailingLogs, prefix+"trailing-logs", o.TrailingLogs, "Raft logs to retain after a snapshot.")
 	fs.IntVar(&o.ObserverChanBuffer, prefix+"observer-chan-buffer", o.ObserverChanBuffer, "Raft observer channel buffer.")
 	fs.IntVar(&o.HeartbeatPurgeThreshold, prefix+"heartbeat-purge-threshold", o.HeartbeatPurgeThreshold, "Raft heartbeat purge threshold.")
 }
@@ -109,6 +114,12 @@ func (o RaftOptions) Validate(dataDir string, inMemory bool) error {
 	if err != nil {
 		return fmt.Errorf("raft.listen-address is invalid: %w", err)
 	}
+	if o.ConnectionPoolCount < 0 {
+		return fmt.Errorf("raft.connection-pool-count must not be negative")
+	}
+	if o.ConnectionTimeout <= 0 {
+		return fmt.Errorf("raft.connection-timeout must be greater than zero")
+	}
 	if !inMemory && dataDir == "" {
 		return fmt.Errorf("storage.data-dir is required when not running in-memory")
 	}
@@ -183,6 +183,12 @@ type APIOptions struct {
 	MeshEnabled bool `koanf:"mesh-enabled,omitempty"`
 	// AdminEnabled is true if the admin API should be registered.
 	AdminEnabled bool `koanf:"admin-enabled,omitempty"`
+	// AdminMaxACLCIDRs is the maximum number of CIDRs allowed in a network ACL
+	// put through the admin API. Zero uses the default.
+	AdminMaxACLCIDRs int `koanf:"admin-max-acl-cidrs,omitempty"`
+	// AdminMaxACLNodes is the maximum number of nodes allowed in a network ACL
+	// put through the admin API. Zero uses the default.
+	AdminMaxACLNodes int `koanf:"admin-max-acl-nodes,omitempty"`
 }
 
 // LibP2PAPIOptions are options for serving the API over libp2p.
@@ -239,6 +245,8 @@ func (a *APIOptions) BindFlags(prefix string, fl *pflag.FlagSet) {
 	fl.BoolVar(&a.Insecure, prefix+"insecure", a.Insecure, "Disable TLS.")
 	fl.BoolVar(&a.MeshEnabled, prefix+"mesh-enabled", a.MeshEnabled, "Enable and register the MeshAPI.")
 	fl.BoolVar(&a.AdminEnabled, prefix+"admin-enabled", a.AdminEnabled, "Enable and register the AdminAPI.")
+	fl.IntVar(&a.AdminMaxACLCIDRs, prefix+"admin-max-acl-cidrs", a.AdminMaxACLCIDRs, "Maximum number of CIDRs in a network ACL put through the AdminAPI.")
+	fl.IntVar(&a.AdminMaxACLNodes, prefix+"admin-max-acl-nodes", a.AdminMaxACLNodes, "Maximum number of nodes in a network ACL put through the AdminAPI.")
 	a.LibP2P.BindFlags(prefix+"libp2p.", fl)
 }
 
@@ -250,6 +258,12 @@ func (a APIOptions) Validate() error {
 	if a.ListenAddress == "" && !a.LibP2P.Enabled {
 		return fmt.Errorf("services.api.listen-address or services.api.libp2p.enabled must be be set")
 	}
+	if a.AdminMaxACLCIDRs < 0 {
+		return fmt.Errorf("services.api.admin-max-acl-cidrs must not be negative")
+	}
+	if a.AdminMaxACLNodes < 0 {
+		return fmt.Errorf("services.api.admin-max-acl-nodes must not be negative")
+	}
 	if a.ListenAddress != "" {
 		_, err := netip.ParseAddrPort(a.ListenAddress)
 		if err != nil {
@@ -446,7 +460,7 @@ func (t TURNOptions) Validate() error {
 	}
 	_, _, err := netutil.ParsePortRange(t.TURNPortRange)
 	if err != nil {
-		return fmt.Errorf("services.turn.port-range is invalid: %w", err)
+		return fmt.Errorf("services.turn.port-range: %w", err)
 	}
 	return nil
 }
@@ -678,11 +692,17 @@ func (o *ServiceOptions) NewServiceOptions(ctx context.Context, conn meshnode.No
 			unarymiddlewares = append(unarymiddlewares, conn.Plugins().AuthUnaryInterceptor())
 			streammiddlewares = append(streammiddlewares, conn.Plugins().AuthStreamInterceptor())
 		}
+		if o.API.AdminEnabled {
+			unarymiddlewares = append(unarymiddlewares, admin.UnaryServerInterceptor())
+		}
 		if !o.API.DisableLeaderProxy {
-			leaderProxy := leaderproxy.New(conn.ID(), conn.Storage().Consensus(), conn, conn.Network())
+			leaderProxy := leaderproxy.New(conn.ID(), conn.Storage().Consensus(), conn, conn.Network(), conn.Storage().MeshDB().Peers())
 			unarymiddlewares = append(unarymiddlewares, leaderProxy.UnaryInterceptor())
 			streammiddlewares = append(streammiddlewares, leaderProxy.StreamInterceptor())
 		}
+		if o.API.AdminEnabled {
+			unarymiddlewares = append(unarymiddlewares, admin.LeaderUnaryServerInterceptor(conn.Storage().Consensus()))
+		}
 		conf.ServerOptions = append(conf.ServerOptions, grpc.ChainUnaryInterceptor(unarymiddlewares...))
 		conf.ServerOptions = append(conf.ServerOptions, grpc.ChainStreamInterceptor(streammiddlewares...))
 	}
@@ -863,11 +883,14 @@ func (o *ServiceOptions) RegisterAPIs(ctx context.Context, opts APIRegistrationO
 	// Register any other enabled APIs
 	if o.API.MeshEnabled {
 		log.Debug("Registering mesh api")
-		v1.RegisterMeshServer(opts.Server, meshapi.NewServer(opts.Node.Storage().MeshDB()))
+		v1.RegisterMeshServer(opts.Server, meshapi.NewServer(opts.Node.Storage().MeshDB(), opts.Node.Storage().MeshStorage()))
 	}
 	if o.API.AdminEnabled {
 		log.Debug("Registering admin api")
-		v1.RegisterAdminServer(opts.Server, admin.NewServer(opts.Node.Storage(), rbacEvaluator))
+		v1.RegisterAdminServer(opts.Server, admin.NewServerWithLimits(opts.Node.Storage(), rbacEvaluator, admin.Limits{
+			MaxACLCIDRs: o.API.AdminMaxACLCIDRs,
+			MaxACLNodes: o.API.AdminMaxACLNodes,
+		}))
 	}
 	if o.WebRTC.Enabled {
 		log.Debug("Registering WebRTC api")
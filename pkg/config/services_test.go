@@ -171,6 +171,21 @@ func TestServiceOptionsValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "NegativeAdminLimit",
+			opts: &ServiceOptions{
+				API: APIOptions{
+					Insecure:         true,
+					ListenAddress:    services.DefaultGRPCListenAddress,
+					AdminMaxACLCIDRs: -1,
+				},
+				WebRTC:  NewWebRTCOptions(),
+				MeshDNS: NewMeshDNSOptions(),
+				TURN:    NewTURNOptions(),
+				Metrics: NewMetricsOptions(),
+			},
+			wantErr: true,
+		},
 		{
 			name: "TLSFileKeyPair",
 			opts: &ServiceOptions{
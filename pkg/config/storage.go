@@ -186,6 +186,7 @@ func (o StorageOptions) NewRaftOptions(ctx context.Context, node meshnode.Node,
 	opts.SnapshotInterval = o.Raft.SnapshotInterval
 	opts.SnapshotThreshold = o.Raft.SnapshotThreshold
 	opts.SnapshotRetention = o.Raft.SnapshotRetention
+	opts.TrailingLogs = o.Raft.TrailingLogs
 	opts.ObserverChanBuffer = o.Raft.ObserverChanBuffer
 	opts.LogLevel = o.LogLevel
 	opts.LogFormat = o.LogFormat
@@ -27,6 +27,8 @@ import (
 
 	"github.com/webmeshproj/webmesh/pkg/context"
 	"github.com/webmeshproj/webmesh/pkg/crypto"
+	"github.com/webmeshproj/webmesh/pkg/meshnet"
+	"github.com/webmeshproj/webmesh/pkg/meshnet/netutil"
 	"github.com/webmeshproj/webmesh/pkg/meshnet/system"
 	"github.com/webmeshproj/webmesh/pkg/meshnet/wireguard"
 )
@@ -51,7 +53,7 @@ type WireGuardOptions struct {
 	// accessible peers when this instance is behind a NAT. Otherwise, no keep-alive
 	// packets are sent.
 	PersistentKeepAlive time.Duration `koanf:"persistent-keepalive,omitempty"`
-	// MTU is the MTU to use for the interface.
+	// MTU is the MTU to use for the interface. Zero uses the default.
 	MTU int `koanf:"mtu,omitempty"`
 	// Endpoints are additional WireGuard endpoints to broadcast when joining.
 	Endpoints []string `koanf:"endpoints,omitempty"`
@@ -67,6 +69,12 @@ type WireGuardOptions struct {
 	RecordMetricsInterval time.Duration `koanf:"record-metrics-interval,omitempty"`
 	// DisableFullTunnel will ignore routes for a default gateway.
 	DisableFullTunnel bool `koanf:"disable-full-tunnel,omitempty"`
+	// EndpointFamilyPreference is the address family, "ipv4" or "ipv6", to prefer
+	// for peer endpoints when a peer advertises endpoints in both families.
+	EndpointFamilyPreference string `koanf:"endpoint-family-preference,omitempty"`
+	// EnableForwarding enables IPv4 and IPv6 forwarding in the kernel so the node
+	// can act as a gateway. On Linux the previous values are restored on shutdown.
+	EnableForwarding bool `koanf:"enable-forwarding,omitempty"`
 
 	// loaded is an already loaded key from the configuration.
 	loaded crypto.PrivateKey `koanf:"-"`
@@ -107,13 +115,15 @@ func (o *WireGuardOptions) BindFlags(prefix string, fs *pflag.FlagSet) {
 	fs.BoolVar(&o.ForceTUN, prefix+"force-tun", o.ForceTUN, "Force the use of a TUN interface.")
 	fs.BoolVar(&o.Masquerade, prefix+"masquerade", o.Masquerade, "Enable masquerading of traffic from the wireguard interface.")
 	fs.DurationVar(&o.PersistentKeepAlive, prefix+"persistent-keepalive", o.PersistentKeepAlive, "The interval at which to send keepalive packets to peers.")
-	fs.IntVar(&o.MTU, prefix+"mtu", o.MTU, "The MTU to use for the interface.")
+	fs.IntVar(&o.MTU, prefix+"mtu", o.MTU, "The MTU to use for the interface. Zero uses the default.")
 	fs.StringSliceVar(&o.Endpoints, prefix+"endpoints", o.Endpoints, "Additional WireGuard endpoints to broadcast when joining.")
 	fs.StringVar(&o.KeyFile, prefix+"key-file", o.KeyFile, "The path to the WireGuard private key. If it does not exist it will be created.")
 	fs.DurationVar(&o.KeyRotationInterval, prefix+"key-rotation-interval", o.KeyRotationInterval, "The interval to rotate wireguard keys. Set this to 0 to disable key rotation.")
 	fs.BoolVar(&o.RecordMetrics, prefix+"record-metrics", o.RecordMetrics, "Record WireGuard metrics. These are only exposed if the metrics server is enabled.")
 	fs.DurationVar(&o.RecordMetricsInterval, prefix+"record-metrics-interval", o.RecordMetricsInterval, "The interval at which to update WireGuard metrics.")
 	fs.BoolVar(&o.DisableFullTunnel, prefix+"disable-full-tunnel", o.DisableFullTunnel, "Ignore routes for a default gateway.")
+	fs.StringVar(&o.EndpointFamilyPreference, prefix+"endpoint-family-preference", o.EndpointFamilyPreference, "The address family (ipv4 or ipv6) to prefer for dual-stack peer endpoints.")
+	fs.BoolVar(&o.EnableForwarding, prefix+"enable-forwarding", o.EnableForwarding, "Enable IPv4 and IPv6 forwarding in the kernel, restoring the previous values on shutdown.")
 }
 
 // Validate validates the options.
@@ -124,12 +134,20 @@ func (o *WireGuardOptions) Validate() error {
 	if o.InterfaceName == "" {
 		return fmt.Errorf("wireguard.interface-name must be set")
 	}
-	if o.MTU < 1280 {
-		return fmt.Errorf("wireguard.mtu must be greater than 1280")
+	if o.MTU != 0 && (o.MTU < system.MinMTU || o.MTU > system.MaxMTU) {
+		return fmt.Errorf("wireguard.mtu must be 0 for the default or between %d and %d", system.MinMTU, system.MaxMTU)
 	}
 	if o.KeyRotationInterval < 0 {
 		return fmt.Errorf("wireguard.key-rotation-interval must be greater than or equal to 0")
 	}
+	if !meshnet.EndpointFamily(o.EndpointFamilyPreference).IsValid() {
+		return fmt.Errorf("wireguard.endpoint-family-preference must be one of ipv4 or ipv6")
+	}
+	for _, ep := range o.Endpoints {
+		if _, err := netutil.ParseEndpoint(ep); err != nil {
+			return fmt.Errorf("wireguard.endpoints: %w", err)
+		}
+	}
 	if o.RecordMetrics {
 		if o.RecordMetricsInterval < 0 {
 			return fmt.Errorf("wireguard.record-metrics-interval must be greater than 0")
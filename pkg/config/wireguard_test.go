@@ -0,0 +1,55 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+
+	"github.com/webmeshproj/webmesh/pkg/meshnet/system"
+)
+
+func TestWireGuardOptionsValidateMTU(t *testing.T) {
+	t.Parallel()
+	tc := []struct {
+		name    string
+		mtu     int
+		wantErr bool
+	}{
+		{name: "Default", mtu: system.DefaultMTU},
+		{name: "Auto", mtu: 0},
+		{name: "Minimum", mtu: system.MinMTU},
+		{name: "Maximum", mtu: system.MaxMTU},
+		{name: "TooSmall", mtu: system.MinMTU - 1, wantErr: true},
+		{name: "TooLarge", mtu: system.MaxMTU + 1, wantErr: true},
+		{name: "Negative", mtu: -1, wantErr: true},
+	}
+	for _, tt := range tc {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			opts := NewWireGuardOptions()
+			opts.MTU = tt.mtu
+			err := opts.Validate()
+			if tt.wantErr && err == nil {
+				t.Fatalf("expected error for mtu %d", tt.mtu)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error for mtu %d: %v", tt.mtu, err)
+			}
+		})
+	}
+}
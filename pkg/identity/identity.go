@@ -0,0 +1,119 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package identity contains helpers for generating node IDs.
+package identity
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/webmeshproj/webmesh/pkg/crypto"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+// Strategy is a strategy for generating node IDs.
+type Strategy string
+
+const (
+	// StrategyHostname uses the hostname of the machine as the node ID.
+	// The hint, if provided, is used in place of the system hostname.
+	StrategyHostname Strategy = "hostname"
+	// StrategyUUID generates a random UUID for the node ID. The hint is ignored.
+	StrategyUUID Strategy = "uuid"
+	// StrategyPublicKey derives the node ID from a public key. The hint must
+	// be the encoded public key.
+	StrategyPublicKey Strategy = "public-key"
+)
+
+// ErrInvalidID is returned when a strategy produces an ID that is not
+// safe to be saved to storage.
+var ErrInvalidID = errors.New("generated node ID is invalid")
+
+// Strategies returns all supported strategies.
+func Strategies() []Strategy {
+	return []Strategy{StrategyHostname, StrategyUUID, StrategyPublicKey}
+}
+
+// IsValid returns true if the strategy is supported.
+func (s Strategy) IsValid() bool {
+	switch s {
+	case StrategyHostname, StrategyUUID, StrategyPublicKey:
+		return true
+	}
+	return false
+}
+
+// String returns the string representation of the strategy.
+func (s Strategy) String() string { return string(s) }
+
+// GenerateID generates a node ID using the given strategy and hint.
+// The returned ID is guaranteed to pass types.IsValidID.
+func GenerateID(strategy Strategy, hint string) (string, error) {
+	var id string
+	switch strategy {
+	case StrategyHostname:
+		id = hint
+		if id == "" {
+			hostname, err := os.Hostname()
+			if err != nil {
+				return "", fmt.Errorf("get hostname: %w", err)
+			}
+			id = hostname
+		}
+		id = types.TruncateID(strings.TrimSpace(id))
+	case StrategyUUID:
+		id = uuid.NewString()
+	case StrategyPublicKey:
+		if hint == "" {
+			return "", errors.New("public key is required for the public-key strategy")
+		}
+		key, err := crypto.DecodePublicKey(hint)
+		if err != nil {
+			return "", fmt.Errorf("decode public key: %w", err)
+		}
+		id = key.ID()
+	default:
+		return "", fmt.Errorf("unknown node ID strategy: %q", strategy)
+	}
+	if !types.IsValidID(id) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidID, id)
+	}
+	return id, nil
+}
+
+// IDFromKey is a convenience method for generating a node ID from a key
+// using the public-key strategy.
+func IDFromKey(key crypto.Key) (string, error) {
+	var pub crypto.PublicKey
+	switch k := key.(type) {
+	case crypto.PrivateKey:
+		pub = k.PublicKey()
+	case crypto.PublicKey:
+		pub = k
+	default:
+		return "", fmt.Errorf("unsupported key type: %T", key)
+	}
+	encoded, err := pub.Encode()
+	if err != nil {
+		return "", fmt.Errorf("encode public key: %w", err)
+	}
+	return GenerateID(StrategyPublicKey, encoded)
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package identity
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/webmeshproj/webmesh/pkg/crypto"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+func TestGenerateID(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Hostname", func(t *testing.T) {
+		t.Parallel()
+		hostname, err := os.Hostname()
+		if err != nil {
+			t.Skip("hostname not available:", err)
+		}
+		id, err := GenerateID(StrategyHostname, "")
+		if err != nil {
+			t.Fatal("generate ID:", err)
+		}
+		if !types.IsValidID(id) {
+			t.Fatalf("expected valid ID, got %q", id)
+		}
+		if id != types.TruncateID(hostname) {
+			t.Fatalf("expected %q, got %q", hostname, id)
+		}
+		again, err := GenerateID(StrategyHostname, "")
+		if err != nil {
+			t.Fatal("generate ID:", err)
+		}
+		if again != id {
+			t.Fatalf("expected deterministic ID %q, got %q", id, again)
+		}
+	})
+
+	t.Run("HostnameHint", func(t *testing.T) {
+		t.Parallel()
+		id, err := GenerateID(StrategyHostname, "node-a")
+		if err != nil {
+			t.Fatal("generate ID:", err)
+		}
+		if id != "node-a" {
+			t.Fatalf("expected node-a, got %q", id)
+		}
+		long := strings.Repeat("a", types.MaxIDLength+10)
+		id, err = GenerateID(StrategyHostname, long)
+		if err != nil {
+			t.Fatal("generate ID:", err)
+		}
+		if len(id) != types.MaxIDLength || !types.IsValidID(id) {
+			t.Fatalf("expected truncated valid ID, got %q", id)
+		}
+		_, err = GenerateID(StrategyHostname, "bad/host")
+		if !errors.Is(err, ErrInvalidID) {
+			t.Fatalf("expected ErrInvalidID, got %v", err)
+		}
+	})
+
+	t.Run("UUID", func(t *testing.T) {
+		t.Parallel()
+		a, err := GenerateID(StrategyUUID, "")
+		if err != nil {
+			t.Fatal("generate ID:", err)
+		}
+		b, err := GenerateID(StrategyUUID, "")
+		if err != nil {
+			t.Fatal("generate ID:", err)
+		}
+		if !types.IsValidID(a) || !types.IsValidID(b) {
+			t.Fatalf("expected valid IDs, got %q and %q", a, b)
+		}
+		if a == b {
+			t.Fatalf("expected unique IDs, got %q twice", a)
+		}
+	})
+
+	t.Run("PublicKey", func(t *testing.T) {
+		t.Parallel()
+		key := crypto.MustGenerateKey()
+		encoded, err := key.PublicKey().Encode()
+		if err != nil {
+			t.Fatal("encode public key:", err)
+		}
+		id, err := GenerateID(StrategyPublicKey, encoded)
+		if err != nil {
+			t.Fatal("generate ID:", err)
+		}
+		if !types.IsValidID(id) {
+			t.Fatalf("expected valid ID, got %q", id)
+		}
+		if id != key.ID() {
+			t.Fatalf("expected %q, got %q", key.ID(), id)
+		}
+		fromKey, err := IDFromKey(key)
+		if err != nil {
+			t.Fatal("ID from key:", err)
+		}
+		if fromKey != id {
+			t.Fatalf("expected deterministic ID %q, got %q", id, fromKey)
+		}
+		_, err = GenerateID(StrategyPublicKey, "")
+		if err == nil {
+			t.Fatal("expected error for empty public key")
+		}
+		_, err = GenerateID(StrategyPublicKey, "not-a-key")
+		if err == nil {
+			t.Fatal("expected error for invalid public key")
+		}
+	})
+
+	t.Run("UnknownStrategy", func(t *testing.T) {
+		t.Parallel()
+		if Strategy("bogus").IsValid() {
+			t.Fatal("expected bogus strategy to be invalid")
+		}
+		_, err := GenerateID("bogus", "")
+		if err == nil {
+			t.Fatal("expected error for unknown strategy")
+		}
+	})
+}
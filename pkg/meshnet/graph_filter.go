@@ -27,7 +27,8 @@ import (
 )
 
 // FilterGraph filters the adjacency map in the given graph for the given node name according
-// to the current network ACLs. If the ACL list is nil, an empty adjacency map is returned. An
+// to the current network ACLs and the default network ACL action, which applies to flows no ACL
+// matches. If the ACL list is nil and the default action is not ACCEPT, an empty adjacency map is returned. An
 // error is returned on faiure building the initial map or any database error. This implementation
 // needs improvement to be more efficient and to allow edges so long as one of the routes encountered is
 // allowed. Currently if a single route provided by a destination node is not allowed, the entire node
@@ -47,7 +48,11 @@ func FilterGraph(ctx context.Context, db storage.MeshDB, thisNodeID types.NodeID
 	if err != nil {
 		return nil, fmt.Errorf("list network acls: %w", err)
 	}
-	if len(acls) == 0 {
+	defaultAction, err := db.Networking().GetDefaultNetworkACLAction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get default network acl action: %w", err)
+	}
+	if len(acls) == 0 && defaultAction != v1.ACLAction_ACTION_ACCEPT {
 		return nil, nil
 	}
 	err = storage.ExpandACLs(ctx, db.RBAC(), acls)
@@ -75,7 +80,7 @@ Nodes:
 		if err != nil {
 			return nil, fmt.Errorf("get node: %w", err)
 		}
-		if !acls.AllowNodesToCommunicate(ctx, thisNode, node) {
+		if !acls.AllowNodesToCommunicate(ctx, thisNode, node, defaultAction) {
 			log.Debug("Nodes not allowed to communicate", "nodeA", thisNode, "nodeB", node)
 			delete(filtered[thisNode.NodeID()], node.NodeID())
 			continue Nodes
@@ -108,7 +113,7 @@ Nodes:
 						},
 					}
 				}
-				if !acls.Accept(ctx, action) {
+				if !acls.Accept(ctx, action, defaultAction) {
 					log.Debug("filtering node", "node", node, "reason", "route not allowed", "action", action)
 					delete(filtered[thisNode.NodeID()], node.NodeID())
 					continue Nodes
@@ -133,7 +138,7 @@ Nodes:
 			if err != nil {
 				return nil, fmt.Errorf("get peer: %w", err)
 			}
-			if !acls.AllowNodesToCommunicate(ctx, thisNode, peer) {
+			if !acls.AllowNodesToCommunicate(ctx, thisNode, peer, defaultAction) {
 				log.Debug("Nodes not allowed to communicate", "nodeA", thisNode, "nodeB", peer)
 				continue Peers
 			}
@@ -161,7 +166,7 @@ Nodes:
 							DstCIDR: cidr.String(),
 						}
 					}
-					if !acls.Accept(ctx, types.NetworkAction{NetworkAction: &action}) {
+					if !acls.Accept(ctx, types.NetworkAction{NetworkAction: &action}, defaultAction) {
 						log.Debug("filtering peer", "peer", peer, "reason", "route not allowed", "action", &action)
 						continue Peers
 					}
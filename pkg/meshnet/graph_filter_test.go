@@ -27,6 +27,7 @@ import (
 	"github.com/webmeshproj/webmesh/pkg/crypto"
 	"github.com/webmeshproj/webmesh/pkg/storage"
 	"github.com/webmeshproj/webmesh/pkg/storage/meshdb"
+	"github.com/webmeshproj/webmesh/pkg/storage/providers/backends/badgerdb"
 	"github.com/webmeshproj/webmesh/pkg/storage/types"
 )
 
@@ -237,18 +238,108 @@ func TestFilteredGraph(t *testing.T) {
 			t.Fatalf("filtered graphs should be equal")
 		}
 	})
+
+	t.Run("DefaultAccept", func(t *testing.T) {
+		t.Parallel()
+
+		nodes := []types.MeshNode{
+			{
+				MeshNode: &v1.MeshNode{
+					Id:          "node-a",
+					PublicKey:   generateEncodedKey(t),
+					PrivateIPv4: "172.16.0.1/32",
+					PrivateIPv6: "fe80::1/128",
+				},
+			},
+			{
+				MeshNode: &v1.MeshNode{
+					Id:          "node-b",
+					PublicKey:   generateEncodedKey(t),
+					PrivateIPv4: "172.16.0.2/32",
+					PrivateIPv6: "fe80::2/128",
+				},
+			},
+		}
+		edges := []types.MeshEdge{
+			{
+				MeshEdge: &v1.MeshEdge{
+					Source: "node-a",
+					Target: "node-b",
+				},
+			},
+			{
+				MeshEdge: &v1.MeshEdge{
+					Source: "node-b",
+					Target: "node-a",
+				},
+			},
+		}
+		tc := []struct {
+			name string
+			acls []*v1.NetworkACL
+		}{
+			{name: "NoNetworkACLs"},
+			{
+				name: "NoMatchingACL",
+				acls: []*v1.NetworkACL{
+					{
+						Name:             "deny-other",
+						Action:           v1.ACLAction_ACTION_DENY,
+						SourceNodes:      []string{"node-c"},
+						DestinationNodes: []string{"*"},
+						SourceCIDRs:      []string{"*"},
+						DestinationCIDRs: []string{"*"},
+					},
+				},
+			},
+		}
+		for _, tt := range tc {
+			tt := tt
+			t.Run(tt.name, func(t *testing.T) {
+				t.Parallel()
+				db := setupGraphTest(t, graphSetup{
+					acls:          tt.acls,
+					defaultAction: v1.ACLAction_ACTION_ACCEPT,
+					nodes:         nodes,
+					edges:         edges,
+				})
+				for _, id := range []types.NodeID{"node-a", "node-b"} {
+					filtered, err := FilterGraph(context.Background(), db, id)
+					if err != nil {
+						t.Fatalf("filter graph: %v", err)
+					}
+					// Flows no ACL matches are accepted, so both nodes
+					// should be present with their edge.
+					if len(filtered) != 2 {
+						t.Fatalf("filtered graph should contain both nodes, got: %v", filtered)
+					}
+					if len(filtered[id]) != 1 {
+						t.Fatalf("filtered graph should contain one edge, got: %d", len(filtered[id]))
+					}
+				}
+			})
+		}
+	})
 }
 
 type graphSetup struct {
-	acls   []*v1.NetworkACL
-	nodes  []types.MeshNode
-	edges  []types.MeshEdge
-	routes []*v1.Route
+	acls          []*v1.NetworkACL
+	defaultAction v1.ACLAction
+	nodes         []types.MeshNode
+	edges         []types.MeshEdge
+	routes        []*v1.Route
 }
 
 func setupGraphTest(t *testing.T, opts graphSetup) storage.MeshDB {
 	t.Helper()
-	db := meshdb.NewTestDB()
+	st := badgerdb.NewTestStorage(false)
+	t.Cleanup(func() { _ = st.Close() })
+	db := meshdb.NewFromStorage(st)
+	if opts.defaultAction != v1.ACLAction_ACTION_UNKNOWN {
+		if err := storage.PutDefaultNetworkACLAction(context.Background(), st, opts.defaultAction); err != nil {
+			t.Fatalf("put default network ACL action: %v", err)
+		}
+	}
 	nw := db.Networking()
 	for _, acl := range opts.acls {
 		if err := nw.PutNetworkACL(context.Background(), types.NetworkACL{NetworkACL: acl}); err != nil {
@@ -35,6 +35,7 @@ import (
 	"github.com/webmeshproj/webmesh/pkg/meshnet/system"
 	"github.com/webmeshproj/webmesh/pkg/meshnet/system/dns"
 	"github.com/webmeshproj/webmesh/pkg/meshnet/system/firewall"
+	"github.com/webmeshproj/webmesh/pkg/meshnet/system/routes"
 	"github.com/webmeshproj/webmesh/pkg/meshnet/transport"
 	"github.com/webmeshproj/webmesh/pkg/meshnet/transport/libp2p"
 	"github.com/webmeshproj/webmesh/pkg/meshnet/wireguard"
@@ -59,7 +60,8 @@ type Options struct {
 	PersistentKeepAlive time.Duration
 	// ForceTUN is whether to force the use of TUN.
 	ForceTUN bool
-	// MTU is the MTU to use for the wireguard interface.
+	// MTU is the MTU to use for the wireguard interface. Zero uses
+	// the default.
 	MTU int
 	// RecordMetrics is whether to enable metrics recording.
 	RecordMetrics bool
@@ -81,11 +83,18 @@ type Options struct {
 	DisableIPv6 bool
 	// DisableFullTunnel will ignore routes for a default gateway.
 	DisableFullTunnel bool
+	// EndpointFamilyPreference is the address family to prefer when a peer
+	// advertises endpoints in both families. Empty means no preference.
+	EndpointFamilyPreference EndpointFamily
 	// IgnoreRoutes are additional routes to ignore.
 	IgnoreRoutes []netip.Prefix
 	// Relays are options for when presented with the need to negotiate
 	// p2p data channels.
 	Relays RelayOptions
+	// EnableForwarding enables IPv4 and IPv6 forwarding in the kernel so the
+	// node can act as a gateway. On Linux the previous values are restored
+	// when the manager is closed.
+	EnableForwarding bool
 }
 
 func (o *Options) MarshalJSON() ([]byte, error) {
@@ -107,11 +116,46 @@ func (o *Options) MarshalJSON() ([]byte, error) {
 		"disableIPv4":           o.DisableIPv4,
 		"disableIPv6":           o.DisableIPv6,
 		"disableFullTunnel":     o.DisableFullTunnel,
+		"endpointFamily":        o.EndpointFamilyPreference,
 		"ignoreRoutes":          o.IgnoreRoutes,
 		"relays":                o.Relays,
+		"enableForwarding":      o.EnableForwarding,
 	})
 }
 
+// EndpointFamily is an address family for wireguard peer endpoints.
+type EndpointFamily string
+
+const (
+	// EndpointFamilyAny uses the endpoint a peer advertises as primary.
+	EndpointFamilyAny EndpointFamily = ""
+	// EndpointFamilyIPv4 prefers IPv4 endpoints.
+	EndpointFamilyIPv4 EndpointFamily = "ipv4"
+	// EndpointFamilyIPv6 prefers IPv6 endpoints.
+	EndpointFamilyIPv6 EndpointFamily = "ipv6"
+)
+
+// IsValid returns true if the endpoint family is known.
+func (f EndpointFamily) IsValid() bool {
+	switch f {
+	case EndpointFamilyAny, EndpointFamilyIPv4, EndpointFamilyIPv6:
+		return true
+	}
+	return false
+}
+
+// Matches returns true if the address belongs to the family. Every address
+// matches EndpointFamilyAny.
+func (f EndpointFamily) Matches(addr netip.Addr) bool {
+	switch f {
+	case EndpointFamilyIPv4:
+		return addr.Unmap().Is4()
+	case EndpointFamilyIPv6:
+		return addr.Is6() && !addr.Is4In6()
+	}
+	return true
+}
+
 // RelayOptions are options for when presented with the need to negotiate
 // p2p wireguard connections. Empty values mean to use the defaults.
 type RelayOptions struct {
@@ -185,6 +229,7 @@ type manager struct {
 	wg                   wireguard.Interface
 	networkv4, networkv6 netip.Prefix
 	masquerading         bool
+	restoreForwarding    func() error
 	mu                   sync.Mutex
 }
 
@@ -245,28 +290,7 @@ func (m *manager) Start(ctx context.Context, opts StartOptions) error {
 		return err
 	}
 	var err error
-	// TODO: Getting close (if not already there) to just needing to embed
-	// the wireguard options in the manager options.
-	wgopts := &wireguard.Options{
-		NetNs:               m.opts.NetNs,
-		NodeID:              m.nodeID,
-		ListenPort:          m.opts.ListenPort,
-		Name:                m.opts.InterfaceName,
-		ForceName:           m.opts.ForceReplace,
-		ForceTUN:            m.opts.ForceTUN,
-		PersistentKeepAlive: m.opts.PersistentKeepAlive,
-		MTU:                 m.opts.MTU,
-		Metrics:             m.opts.RecordMetrics,
-		MetricsInterval:     m.opts.RecordMetricsInterval,
-		AddressV4:           opts.AddressV4,
-		AddressV6:           opts.AddressV6,
-		NetworkV4:           opts.NetworkV4,
-		NetworkV6:           opts.NetworkV6,
-		IgnoreRoutes:        m.opts.IgnoreRoutes,
-		DisableIPv4:         m.opts.DisableIPv4,
-		DisableIPv6:         m.opts.DisableIPv6,
-		DisableFullTunnel:   m.opts.DisableFullTunnel,
-	}
+	wgopts := m.wireguardOptions(opts)
 	log.Debug("Configuring wireguard", slog.Any("opts", wgopts))
 	m.wg, err = wireguard.New(ctx, wgopts)
 	if err != nil {
@@ -324,14 +348,48 @@ func (m *manager) Start(ctx context.Context, opts StartOptions) error {
 	if err != nil {
 		return handleErr(fmt.Errorf("new firewall manager: %w", err))
 	}
+	log.Debug("Using firewall backend", slog.String("backend", m.fw.Backend()))
 	log.Debug("Configuring forwarding on wireguard interface", slog.String("interface", m.wg.Name()))
 	err = m.fw.AddWireguardForwarding(ctx, m.wg.Name())
 	if err != nil {
 		return handleErr(fmt.Errorf("add wireguard forwarding rule: %w", err))
 	}
+	if m.opts.EnableForwarding && m.restoreForwarding == nil {
+		log.Debug("Enabling kernel IP forwarding")
+		m.restoreForwarding, err = routes.EnableForwarding(nil)
+		if err != nil {
+			return handleErr(fmt.Errorf("enable ip forwarding: %w", err))
+		}
+	}
 	return nil
 }
 
+// wireguardOptions returns the options for the wireguard interface.
+func (m *manager) wireguardOptions(opts StartOptions) *wireguard.Options {
+	// TODO: Getting close (if not already there) to just needing to embed
+	// the wireguard options in the manager options.
+	return &wireguard.Options{
+		NetNs:               m.opts.NetNs,
+		NodeID:              m.nodeID,
+		ListenPort:          m.opts.ListenPort,
+		Name:                m.opts.InterfaceName,
+		ForceName:           m.opts.ForceReplace,
+		ForceTUN:            m.opts.ForceTUN,
+		PersistentKeepAlive: m.opts.PersistentKeepAlive,
+		MTU:                 m.opts.MTU,
+		Metrics:             m.opts.RecordMetrics,
+		MetricsInterval:     m.opts.RecordMetricsInterval,
+		AddressV4:           opts.AddressV4,
+		AddressV6:           opts.AddressV6,
+		NetworkV4:           opts.NetworkV4,
+		NetworkV6:           opts.NetworkV6,
+		IgnoreRoutes:        m.opts.IgnoreRoutes,
+		DisableIPv4:         m.opts.DisableIPv4,
+		DisableIPv6:         m.opts.DisableIPv6,
+		DisableFullTunnel:   m.opts.DisableFullTunnel,
+	}
+}
+
 // Dial behaves like the standard library DialContext, but uses the
 // wireguard interface for all connections. The address can be a nodeID
 // or a network address.
@@ -403,6 +461,16 @@ func (m *manager) Close(ctx context.Context) error {
 	defer m.mu.Unlock()
 	log := context.LoggerFrom(ctx).With("component", "net-manager")
 	defer m.peers.Close(context.WithLogger(ctx, log))
+	if m.restoreForwarding != nil {
+		// Restore the forwarding sysctls after the firewall rules are cleared
+		defer func() {
+			log.Debug("Restoring kernel IP forwarding")
+			if err := m.restoreForwarding(); err != nil {
+				log.Error("error restoring ip forwarding", slog.String("error", err.Error()))
+			}
+			m.restoreForwarding = nil
+		}()
+	}
 	if m.fw != nil {
 		// Clear the firewall rules after wireguard is shutdown
 		defer func() {
@@ -0,0 +1,33 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package meshnet
+
+import "testing"
+
+func TestManagerWireGuardOptions(t *testing.T) {
+	t.Parallel()
+	for _, mtu := range []int{0, 1380} {
+		m := &manager{opts: Options{InterfaceName: "webmesh0", MTU: mtu}}
+		wgopts := m.wireguardOptions(StartOptions{})
+		if wgopts.MTU != mtu {
+			t.Errorf("expected wireguard mtu %d, got %d", mtu, wgopts.MTU)
+		}
+		if wgopts.Name != "webmesh0" {
+			t.Errorf("expected wireguard interface name webmesh0, got %s", wgopts.Name)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netutil
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+)
+
+// CanonicalizeCIDRs parses the given CIDRs and returns the minimal sorted set of
+// prefixes covering the same address space. Prefixes are masked, duplicates are
+// removed, and any prefix contained in another is dropped. IPv4 prefixes sort
+// before IPv6 prefixes.
+func CanonicalizeCIDRs(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("parse cidr %q: %w", cidr, err)
+		}
+		prefixes = append(prefixes, unmapPrefix(prefix).Masked())
+	}
+	// Sorting by address then by prefix length places every prefix
+	// after any prefix that contains it.
+	slices.SortFunc(prefixes, func(a, b netip.Prefix) int {
+		if c := a.Addr().Compare(b.Addr()); c != 0 {
+			return c
+		}
+		return a.Bits() - b.Bits()
+	})
+	out := make([]netip.Prefix, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		if len(out) > 0 && out[len(out)-1].Contains(prefix.Addr()) {
+			continue
+		}
+		out = append(out, prefix)
+	}
+	return out, nil
+}
+
+// SummarizeAddrs returns the smallest sorted set of prefixes that covers exactly
+// the given addresses. Contiguous addresses are aggregated into the widest
+// aligned prefixes that fit the run, so a full aligned block collapses into a
+// single prefix while gaps keep prefixes separate. IPv4 and IPv6 addresses are
+// summarized separately, with IPv4 prefixes sorting first. IPv4-mapped IPv6
+// addresses are treated as IPv4, and duplicate and invalid addresses are ignored.
+func SummarizeAddrs(addrs []netip.Addr) []netip.Prefix {
+	sorted := make([]netip.Addr, 0, len(addrs))
+	for _, addr := range addrs {
+		if addr.IsValid() {
+			sorted = append(sorted, addr.Unmap())
+		}
+	}
+	slices.SortFunc(sorted, netip.Addr.Compare)
+	sorted = slices.Compact(sorted)
+	var out []netip.Prefix
+	for i := 0; i < len(sorted); {
+		// Find the run of consecutive addresses starting at i.
+		start, end := sorted[i], sorted[i]
+		for i++; i < len(sorted) && sorted[i] == end.Next(); i++ {
+			end = sorted[i]
+		}
+		out = appendRangePrefixes(out, start, end)
+	}
+	return out
+}
+
+// appendRangePrefixes appends the fewest prefixes that exactly cover the
+// range from start to end. Both addresses must be of the same family.
+func appendRangePrefixes(out []netip.Prefix, start, end netip.Addr) []netip.Prefix {
+	for start.IsValid() && start.Compare(end) <= 0 {
+		// Take the widest prefix aligned on start that does not run past end.
+		prefix := netip.PrefixFrom(start, start.BitLen())
+		for bits := 0; bits < start.BitLen(); bits++ {
+			candidate := netip.PrefixFrom(start, bits)
+			if candidate.Masked().Addr() == start && lastAddr(candidate).Compare(end) <= 0 {
+				prefix = candidate
+				break
+			}
+		}
+		out = append(out, prefix)
+		start = lastAddr(prefix).Next()
+	}
+	return out
+}
+
+// IsInAnyPrefix returns true if the address is contained in any of the given
+// prefixes. IPv4-mapped IPv6 addresses are treated as IPv4, and prefixes of a
+// different family than the address are ignored.
+func IsInAnyPrefix(addr netip.Addr, prefixes []netip.Prefix) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range prefixes {
+		if unmapPrefix(prefix).Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// NetworkAndBroadcast returns the network address of the given prefix and, for
+// IPv4, its broadcast address. IPv6 has no broadcast, and neither do IPv4 /31
+// point-to-point (RFC 3021) and /32 host prefixes, so hasBroadcast is false for
+// them and broadcast is the zero Addr. IPv4-mapped IPv6 prefixes are treated as
+// IPv4.
+func NetworkAndBroadcast(prefix netip.Prefix) (network netip.Addr, broadcast netip.Addr, hasBroadcast bool) {
+	if !prefix.IsValid() {
+		return netip.Addr{}, netip.Addr{}, false
+	}
+	prefix = unmapPrefix(prefix).Masked()
+	network = prefix.Addr()
+	if !network.Is4() || prefix.Bits() > 30 {
+		return network, netip.Addr{}, false
+	}
+	addr := network.As4()
+	binary.BigEndian.PutUint32(addr[:], binary.BigEndian.Uint32(addr[:])|(1<<(32-prefix.Bits())-1))
+	return network, netip.AddrFrom4(addr), true
+}
+
+// ErrNoAvailablePrefix is returned by NextAvailablePrefix when every candidate
+// prefix is reserved.
+var ErrNoAvailablePrefix = errors.New("no available prefix")
+
+// NextAvailablePrefix returns the lowest prefix of length hostBits within parent
+// that does not overlap any of the reserved prefixes. The candidates containing
+// the network address and, for IPv4, the broadcast address of the parent are
+// skipped. IPv4 /31 and /32 (RFC 3021) and IPv6 /127 and /128 (RFC 6164)
+// parents have no such addresses, so every candidate in them is usable.
+// IPv4-mapped IPv6 prefixes are treated as IPv4, and reserved prefixes of a
+// different family than the parent are ignored. The search stops with the
+// context's error if it is cancelled before a candidate is found.
+func NextAvailablePrefix(ctx context.Context, parent netip.Prefix, hostBits int, reserved map[netip.Prefix]struct{}) (netip.Prefix, error) {
+	if !parent.IsValid() {
+		return netip.Prefix{}, fmt.Errorf("invalid parent prefix %s", parent)
+	}
+	parent = unmapPrefix(parent).Masked()
+	if hostBits < parent.Bits() || hostBits > parent.Addr().BitLen() {
+		return netip.Prefix{}, fmt.Errorf("invalid prefix length %d for %s", hostBits, parent)
+	}
+	// Reserved prefixes of the candidate length are matched exactly, so only
+	// those of other lengths need an overlap check.
+	exact := make(map[netip.Prefix]struct{}, len(reserved))
+	var wider, narrower []netip.Prefix
+	for r := range reserved {
+		r = unmapPrefix(r).Masked()
+		if !r.IsValid() || !r.Overlaps(parent) {
+			continue
+		}
+		switch {
+		case r.Bits() == hostBits:
+			exact[r] = struct{}{}
+		case r.Bits() < hostBits:
+			wider = append(wider, r)
+		default:
+			narrower = append(narrower, r)
+		}
+	}
+	network, broadcast, hasBroadcast := NetworkAndBroadcast(parent)
+	skipNetwork := hasBroadcast || (network.Is6() && parent.Bits() < 127)
+	candidate := netip.PrefixFrom(network, hostBits)
+	for {
+		if err := ctx.Err(); err != nil {
+			return netip.Prefix{}, err
+		}
+		next := candidate
+		switch {
+		case skipNetwork && candidate.Contains(network):
+		case hasBroadcast && candidate.Contains(broadcast):
+		case containsPrefix(exact, candidate):
+		case overlapsAny(candidate, narrower):
+		default:
+			if r, ok := widerContaining(candidate, wider); ok {
+				// Jump past the whole reserved range.
+				next = netip.PrefixFrom(lastAddr(r), hostBits)
+				break
+			}
+			return candidate, nil
+		}
+		addr := lastAddr(next).Next()
+		if !addr.IsValid() || !parent.Contains(addr) {
+			return netip.Prefix{}, fmt.Errorf("%w of length %d in %s", ErrNoAvailablePrefix, hostBits, parent)
+		}
+		candidate = netip.PrefixFrom(addr, hostBits)
+	}
+}
+
+func containsPrefix(set map[netip.Prefix]struct{}, prefix netip.Prefix) bool {
+	_, ok := set[prefix]
+	return ok
+}
+
+func overlapsAny(prefix netip.Prefix, prefixes []netip.Prefix) bool {
+	for _, p := range prefixes {
+		if prefix.Overlaps(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func widerContaining(prefix netip.Prefix, prefixes []netip.Prefix) (netip.Prefix, bool) {
+	for _, p := range prefixes {
+		if p.Contains(prefix.Addr()) {
+			return p, true
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+// lastAddr returns the last address in the given prefix.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Masked().Addr().AsSlice()
+	for i := prefix.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// unmapPrefix converts an IPv4-mapped IPv6 prefix to its IPv4 equivalent.
+func unmapPrefix(prefix netip.Prefix) netip.Prefix {
+	if !prefix.Addr().Is4In6() {
+		return prefix
+	}
+	return netip.PrefixFrom(prefix.Addr().Unmap(), max(prefix.Bits()-96, 0))
+}
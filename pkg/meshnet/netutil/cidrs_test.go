@@ -0,0 +1,319 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netutil
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestCanonicalizeCIDRs(t *testing.T) {
+	t.Parallel()
+	tc := []struct {
+		name    string
+		in      []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "Empty",
+			in:   nil,
+			want: []string{},
+		},
+		{
+			name: "Duplicates",
+			in:   []string{"10.0.0.1/32", "10.0.0.1/32", "fd00::1/128", "fd00::1/128"},
+			want: []string{"10.0.0.1/32", "fd00::1/128"},
+		},
+		{
+			name: "Overlapping",
+			in:   []string{"10.0.1.0/24", "10.0.0.0/16", "10.0.0.5/32", "192.168.1.1/32", "fd00::/48", "fd00:0:0:1::/64"},
+			want: []string{"10.0.0.0/16", "192.168.1.1/32", "fd00::/48"},
+		},
+		{
+			name: "Unmasked",
+			in:   []string{"10.0.0.5/24", "10.0.0.0/24"},
+			want: []string{"10.0.0.0/24"},
+		},
+		{
+			name: "Sorted",
+			in:   []string{"fd00::/64", "192.168.0.0/24", "10.0.0.0/24", "172.16.0.0/12"},
+			want: []string{"10.0.0.0/24", "172.16.0.0/12", "192.168.0.0/24", "fd00::/64"},
+		},
+		{
+			name:    "Invalid",
+			in:      []string{"10.0.0.0/24", "not-a-cidr"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CanonicalizeCIDRs(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			want := make([]netip.Prefix, len(tt.want))
+			for i, w := range tt.want {
+				want[i] = netip.MustParsePrefix(w)
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("expected %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestSummarizeAddrs(t *testing.T) {
+	t.Parallel()
+	tc := []struct {
+		name string
+		in   []string
+		want []string
+	}{
+		{
+			name: "Empty",
+			in:   nil,
+			want: []string{},
+		},
+		{
+			name: "Single",
+			in:   []string{"10.0.0.1"},
+			want: []string{"10.0.0.1/32"},
+		},
+		{
+			name: "ContiguousBlock",
+			in:   []string{"10.0.0.3", "10.0.0.0", "10.0.0.2", "10.0.0.1"},
+			want: []string{"10.0.0.0/30"},
+		},
+		{
+			name: "ContiguousBlockV6",
+			in:   []string{"fd00::4", "fd00::5", "fd00::6", "fd00::7"},
+			want: []string{"fd00::4/126"},
+		},
+		{
+			name: "NonContiguous",
+			in:   []string{"10.0.0.1", "10.0.0.3", "10.0.0.5"},
+			want: []string{"10.0.0.1/32", "10.0.0.3/32", "10.0.0.5/32"},
+		},
+		{
+			name: "UnalignedRun",
+			in:   []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"},
+			want: []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/32"},
+		},
+		{
+			name: "FullOctet",
+			in: func() []string {
+				var out []string
+				for i := 0; i < 256; i++ {
+					out = append(out, netip.AddrFrom4([4]byte{192, 168, 1, byte(i)}).String())
+				}
+				return out
+			}(),
+			want: []string{"192.168.1.0/24"},
+		},
+		{
+			name: "MixedFamilies",
+			in:   []string{"fd00::1", "10.0.0.1", "fd00::", "10.0.0.0", "::ffff:10.0.0.2"},
+			want: []string{"10.0.0.0/31", "10.0.0.2/32", "fd00::/127"},
+		},
+		{
+			name: "Duplicates",
+			in:   []string{"10.0.0.2", "10.0.0.2", "10.0.0.3"},
+			want: []string{"10.0.0.2/31"},
+		},
+		{
+			name: "AddressSpaceEdges",
+			in:   []string{"255.255.255.254", "255.255.255.255", "0.0.0.0"},
+			want: []string{"0.0.0.0/32", "255.255.255.254/31"},
+		},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			addrs := make([]netip.Addr, len(tt.in))
+			for i, in := range tt.in {
+				addrs[i] = netip.MustParseAddr(in)
+			}
+			got := SummarizeAddrs(addrs)
+			want := make([]netip.Prefix, len(tt.want))
+			for i, w := range tt.want {
+				want[i] = netip.MustParsePrefix(w)
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("expected %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestIsInAnyPrefix(t *testing.T) {
+	t.Parallel()
+	mixed := []netip.Prefix{
+		netip.MustParsePrefix("172.16.0.0/12"),
+		netip.MustParsePrefix("fd00:dead:beef::/48"),
+	}
+	tc := []struct {
+		name     string
+		addr     netip.Addr
+		prefixes []netip.Prefix
+		want     bool
+	}{
+		{"V4InMixed", netip.MustParseAddr("172.16.0.1"), mixed, true},
+		{"V4NotInMixed", netip.MustParseAddr("10.0.0.1"), mixed, false},
+		{"V6InMixed", netip.MustParseAddr("fd00:dead:beef::1"), mixed, true},
+		{"V6NotInMixed", netip.MustParseAddr("fd00:dead:cafe::1"), mixed, false},
+		{"MappedV4InMixed", netip.MustParseAddr("::ffff:172.16.0.1"), mixed, true},
+		{"V4AgainstV6Only", netip.MustParseAddr("172.16.0.1"), mixed[1:], false},
+		{"V6AgainstV4Only", netip.MustParseAddr("fd00:dead:beef::1"), mixed[:1], false},
+		{"V4AgainstMappedPrefix", netip.MustParseAddr("10.1.2.3"), []netip.Prefix{netip.MustParsePrefix("::ffff:10.0.0.0/104")}, true},
+		{"NoPrefixes", netip.MustParseAddr("172.16.0.1"), nil, false},
+		{"InvalidAddr", netip.Addr{}, mixed, false},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsInAnyPrefix(tt.addr, tt.prefixes); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNetworkAndBroadcast(t *testing.T) {
+	t.Parallel()
+	tc := []struct {
+		name         string
+		prefix       string
+		network      string
+		broadcast    string
+		hasBroadcast bool
+	}{
+		{name: "Slash24", prefix: "10.0.0.17/24", network: "10.0.0.0", broadcast: "10.0.0.255", hasBroadcast: true},
+		{name: "Slash20", prefix: "172.16.5.4/20", network: "172.16.0.0", broadcast: "172.16.15.255", hasBroadcast: true},
+		{name: "Slash30", prefix: "192.168.1.5/30", network: "192.168.1.4", broadcast: "192.168.1.7", hasBroadcast: true},
+		{name: "Slash31", prefix: "192.168.1.5/31", network: "192.168.1.4"},
+		{name: "Slash32", prefix: "192.168.1.5/32", network: "192.168.1.5"},
+		{name: "IPv4Mapped", prefix: "::ffff:10.0.0.17/120", network: "10.0.0.0", broadcast: "10.0.0.255", hasBroadcast: true},
+		{name: "IPv6Slash64", prefix: "fd00:dead:beef:1::5/64", network: "fd00:dead:beef:1::"},
+	}
+	for _, tt := range tc {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			network, broadcast, hasBroadcast := NetworkAndBroadcast(netip.MustParsePrefix(tt.prefix))
+			if network.String() != tt.network {
+				t.Errorf("expected network %s, got %s", tt.network, network)
+			}
+			if hasBroadcast != tt.hasBroadcast {
+				t.Fatalf("expected hasBroadcast %v, got %v", tt.hasBroadcast, hasBroadcast)
+			}
+			if !tt.hasBroadcast {
+				if broadcast.IsValid() {
+					t.Errorf("expected no broadcast address, got %s", broadcast)
+				}
+				return
+			}
+			if broadcast.String() != tt.broadcast {
+				t.Errorf("expected broadcast %s, got %s", tt.broadcast, broadcast)
+			}
+		})
+	}
+	t.Run("Invalid", func(t *testing.T) {
+		t.Parallel()
+		network, broadcast, hasBroadcast := NetworkAndBroadcast(netip.Prefix{})
+		if network.IsValid() || broadcast.IsValid() || hasBroadcast {
+			t.Fatalf("expected zero values, got %s %s %v", network, broadcast, hasBroadcast)
+		}
+	})
+}
+
+func TestNextAvailablePrefix(t *testing.T) {
+	t.Parallel()
+	tc := []struct {
+		name     string
+		parent   string
+		hostBits int
+		reserved []string
+		want     string
+		wantErr  bool
+	}{
+		{name: "EmptySlash29", parent: "10.0.0.0/29", hostBits: 32, want: "10.0.0.1/32"},
+		{name: "SkipsReserved", parent: "10.0.0.0/29", hostBits: 32, reserved: []string{"10.0.0.1/32", "10.0.0.2/32", "10.0.0.4/32"}, want: "10.0.0.3/32"},
+		{name: "SkipsBroadcast", parent: "10.0.0.0/30", hostBits: 32, reserved: []string{"10.0.0.1/32", "10.0.0.2/32"}, wantErr: true},
+		{name: "Slash31", parent: "10.0.0.0/31", hostBits: 32, want: "10.0.0.0/32"},
+		{name: "Slash32", parent: "10.0.0.1/32", hostBits: 32, want: "10.0.0.1/32"},
+		{name: "Full", parent: "10.0.0.0/29", hostBits: 32, reserved: []string{"10.0.0.1/32", "10.0.0.2/32", "10.0.0.3/32", "10.0.0.4/32", "10.0.0.5/32", "10.0.0.6/32"}, wantErr: true},
+		{name: "WiderReservation", parent: "10.0.0.0/28", hostBits: 32, reserved: []string{"10.0.0.0/29"}, want: "10.0.0.8/32"},
+		{name: "NarrowerReservation", parent: "10.0.0.0/24", hostBits: 26, reserved: []string{"10.0.0.70/32"}, want: "10.0.0.128/26"},
+		{name: "CarveSubnets", parent: "10.0.0.0/24", hostBits: 26, want: "10.0.0.64/26"},
+		{name: "ReservedOutsideParent", parent: "10.0.0.0/29", hostBits: 32, reserved: []string{"10.0.1.1/32", "fd00::1/128"}, want: "10.0.0.1/32"},
+		{name: "IPv4Mapped", parent: "::ffff:10.0.0.0/125", hostBits: 32, reserved: []string{"::ffff:10.0.0.1/128"}, want: "10.0.0.2/32"},
+		{name: "IPv6", parent: "fd00::/126", hostBits: 128, reserved: []string{"fd00::1/128"}, want: "fd00::2/128"},
+		{name: "IPv6Slash127", parent: "fd00::/127", hostBits: 128, want: "fd00::/128"},
+		{name: "HostBitsTooShort", parent: "10.0.0.0/24", hostBits: 16, wantErr: true},
+		{name: "HostBitsTooLong", parent: "10.0.0.0/24", hostBits: 33, wantErr: true},
+	}
+	for _, tt := range tc {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			reserved := make(map[netip.Prefix]struct{}, len(tt.reserved))
+			for _, r := range tt.reserved {
+				reserved[netip.MustParsePrefix(r)] = struct{}{}
+			}
+			got, err := NextAvailablePrefix(context.Background(), netip.MustParsePrefix(tt.parent), tt.hostBits, reserved)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("ErrNoAvailablePrefix", func(t *testing.T) {
+		t.Parallel()
+		reserved := map[netip.Prefix]struct{}{netip.MustParsePrefix("10.0.0.0/24"): {}}
+		_, err := NextAvailablePrefix(context.Background(), netip.MustParsePrefix("10.0.0.0/24"), 32, reserved)
+		if !errors.Is(err, ErrNoAvailablePrefix) {
+			t.Fatalf("expected ErrNoAvailablePrefix, got %v", err)
+		}
+	})
+
+	t.Run("CancelledContext", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := NextAvailablePrefix(ctx, netip.MustParsePrefix("10.0.0.0/24"), 32, nil)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	})
+}
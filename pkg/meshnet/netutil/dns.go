@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net"
+	"net/netip"
 	"time"
 
 	"github.com/webmeshproj/webmesh/pkg/context"
@@ -27,25 +28,58 @@ import (
 
 // ResolveTCPAddr resolves a TCP address with retries and context.
 func ResolveTCPAddr(ctx context.Context, lookup string, maxRetries int) (net.Addr, error) {
+	return ResolveTCPAddrWith(ctx, nil, lookup, maxRetries)
+}
+
+// ResolveTCPAddrWith resolves a TCP address with retries and context using the given
+// resolver. If resolver is nil, the system resolver is used.
+func ResolveTCPAddrWith(ctx context.Context, resolver *net.Resolver, lookup string, maxRetries int) (net.Addr, error) {
 	var addr net.Addr
-	var err error
-	var tries int
-	for tries < maxRetries {
-		addr, err = net.ResolveTCPAddr("tcp", lookup)
+	err := Retry(ctx, maxRetries, ConstantBackoff(time.Second), func() error {
+		var err error
+		addr, err = resolveTCPAddr(ctx, resolver, lookup)
 		if err != nil {
-			tries++
 			err = fmt.Errorf("resolve tcp address: %w", err)
 			context.LoggerFrom(ctx).Error("failed to resolve advertise address", slog.String("error", err.Error()))
-			if tries < maxRetries {
-				select {
-				case <-ctx.Done():
-					return nil, fmt.Errorf("%w: %w", err, ctx.Err())
-				case <-time.After(time.Second * 1):
-					continue
-				}
-			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return addr, nil
+}
+
+func resolveTCPAddr(ctx context.Context, resolver *net.Resolver, lookup string) (*net.TCPAddr, error) {
+	if resolver == nil {
+		return net.ResolveTCPAddr("tcp", lookup)
+	}
+	host, port, err := net.SplitHostPort(lookup)
+	if err != nil {
+		return nil, err
+	}
+	portnum, err := resolver.LookupPort(ctx, "tcp", port)
+	if err != nil {
+		return nil, err
+	}
+	if host == "" {
+		return &net.TCPAddr{Port: portnum}, nil
+	}
+	ips, err := resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, &net.AddrError{Err: "no suitable address found", Addr: host}
+	}
+	// Prefer IPv4 addresses like the system resolver does.
+	ip := ips[0].Unmap()
+	for _, candidate := range ips {
+		if candidate.Unmap().Is4() {
+			ip = candidate.Unmap()
+			break
 		}
-		break
 	}
-	return addr, err
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, uint16(portnum))), nil
 }
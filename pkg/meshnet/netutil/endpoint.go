@@ -0,0 +1,115 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netutil
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// ParseEndpoint parses a wireguard endpoint of the form ip:port. IPv6
+// addresses must be enclosed in brackets, as in [fd00::1]:51820, and
+// IPv4-mapped IPv6 addresses are returned in their IPv4 form. Hostnames are
+// rejected, see ParseHostEndpoint for a variant that accepts them.
+func ParseEndpoint(s string) (netip.AddrPort, error) {
+	host, port, err := splitEndpoint(s)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.AddrPort{}, fmt.Errorf("invalid endpoint %q: %q is not an IP address", s, host)
+	}
+	if addr.Zone() != "" {
+		return netip.AddrPort{}, fmt.Errorf("invalid endpoint %q: zoned addresses are not supported", s)
+	}
+	return netip.AddrPortFrom(addr.Unmap(), port), nil
+}
+
+// ParseHostEndpoint is like ParseEndpoint but also accepts a DNS name in place
+// of the IP address, as in vpn.example.com:51820. The host is returned in its
+// canonical form, which for IP addresses is the unbracketed address.
+func ParseHostEndpoint(s string) (host string, port uint16, err error) {
+	host, port, err = splitEndpoint(s)
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		addrport, err := ParseEndpoint(s)
+		if err != nil {
+			return "", 0, err
+		}
+		return addrport.Addr().String(), port, nil
+	}
+	if err := validateHostname(host); err != nil {
+		return "", 0, fmt.Errorf("invalid endpoint %q: %w", s, err)
+	}
+	return strings.TrimSuffix(strings.ToLower(host), "."), port, nil
+}
+
+// splitEndpoint splits an endpoint into its host and a non-zero port.
+func splitEndpoint(s string) (string, uint16, error) {
+	if s == "" {
+		return "", 0, fmt.Errorf("endpoint is empty")
+	}
+	if strings.Count(s, ":") > 1 && !strings.HasPrefix(s, "[") {
+		return "", 0, fmt.Errorf("invalid endpoint %q: IPv6 addresses must be enclosed in brackets, as in [::1]:51820", s)
+	}
+	host, portstr, err := net.SplitHostPort(s)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid endpoint %q: %w", s, err)
+	}
+	if host == "" {
+		return "", 0, fmt.Errorf("invalid endpoint %q: missing host", s)
+	}
+	if portstr == "" {
+		return "", 0, fmt.Errorf("invalid endpoint %q: missing port", s)
+	}
+	port, err := strconv.ParseUint(portstr, 10, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid endpoint %q: invalid port %q", s, portstr)
+	}
+	if port == 0 {
+		return "", 0, fmt.Errorf("invalid endpoint %q: port must not be zero", s)
+	}
+	return host, uint16(port), nil
+}
+
+// validateHostname checks that the host is a syntactically valid DNS name.
+func validateHostname(host string) error {
+	name := strings.TrimSuffix(host, ".")
+	if name == "" || len(name) > 253 {
+		return fmt.Errorf("invalid hostname %q", host)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 {
+			return fmt.Errorf("invalid hostname %q: labels must be 1 to 63 characters", host)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("invalid hostname %q: labels must not start or end with a hyphen", host)
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return fmt.Errorf("invalid hostname %q: invalid character %q", host, c)
+			}
+		}
+	}
+	return nil
+}
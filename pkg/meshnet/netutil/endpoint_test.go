@@ -0,0 +1,107 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netutil
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestParseEndpoint(t *testing.T) {
+	t.Parallel()
+	tc := []struct {
+		name    string
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{name: "IPv4", in: "10.0.0.1:51820", want: "10.0.0.1:51820"},
+		{name: "BracketedIPv6", in: "[fd00::1]:51820", want: "[fd00::1]:51820"},
+		{name: "MappedIPv4", in: "[::ffff:10.0.0.1]:51820", want: "10.0.0.1:51820"},
+		{name: "Hostname", in: "vpn.example.com:51820", wantErr: true},
+		{name: "UnbracketedIPv6", in: "fd00::1:51820", wantErr: true},
+		{name: "MissingPort", in: "10.0.0.1", wantErr: true},
+		{name: "EmptyPort", in: "10.0.0.1:", wantErr: true},
+		{name: "ZeroPort", in: "10.0.0.1:0", wantErr: true},
+		{name: "PortOutOfRange", in: "10.0.0.1:65536", wantErr: true},
+		{name: "NamedPort", in: "10.0.0.1:wireguard", wantErr: true},
+		{name: "MissingHost", in: ":51820", wantErr: true},
+		{name: "Zoned", in: "[fe80::1%eth0]:51820", wantErr: true},
+		{name: "Empty", in: "", wantErr: true},
+	}
+	for _, tt := range tc {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseEndpoint(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := netip.MustParseAddrPort(tt.want); got != want {
+				t.Fatalf("expected %s, got %s", want, got)
+			}
+		})
+	}
+}
+
+func TestParseHostEndpoint(t *testing.T) {
+	t.Parallel()
+	tc := []struct {
+		name     string
+		in       string
+		wantHost string
+		wantPort uint16
+		wantErr  bool
+	}{
+		{name: "IPv4", in: "10.0.0.1:51820", wantHost: "10.0.0.1", wantPort: 51820},
+		{name: "BracketedIPv6", in: "[fd00::1]:51820", wantHost: "fd00::1", wantPort: 51820},
+		{name: "Hostname", in: "vpn.example.com:51820", wantHost: "vpn.example.com", wantPort: 51820},
+		{name: "HostnameCanonical", in: "VPN.Example.com.:51820", wantHost: "vpn.example.com", wantPort: 51820},
+		{name: "SingleLabel", in: "gateway:51820", wantHost: "gateway", wantPort: 51820},
+		{name: "InvalidCharacter", in: "vpn_example.com:51820", wantErr: true},
+		{name: "LeadingHyphen", in: "-vpn.example.com:51820", wantErr: true},
+		{name: "EmptyLabel", in: "vpn..example.com:51820", wantErr: true},
+		{name: "UnbracketedIPv6", in: "fd00::1:51820", wantErr: true},
+		{name: "MissingPort", in: "vpn.example.com", wantErr: true},
+		{name: "ZeroPort", in: "vpn.example.com:0", wantErr: true},
+	}
+	for _, tt := range tc {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			host, port, err := ParseHostEndpoint(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %s:%d", host, port)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if host != tt.wantHost || port != tt.wantPort {
+				t.Fatalf("expected %s:%d, got %s:%d", tt.wantHost, tt.wantPort, host, port)
+			}
+		})
+	}
+}
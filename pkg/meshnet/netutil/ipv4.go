@@ -0,0 +1,48 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netutil
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+)
+
+// PrivateV4Network is the RFC 1918 network used by GeneratePrivateV4.
+var PrivateV4Network = netip.MustParsePrefix("10.0.0.0/8")
+
+// GeneratePrivateV4 returns a random prefix of the given size from the
+// 10.0.0.0/8 private network. The all-zeros and all-ones subnets are
+// never returned, so bits must be between 10 and 30 inclusive.
+func GeneratePrivateV4(bits int) (netip.Prefix, error) {
+	if bits < 10 || bits > 30 {
+		return netip.Prefix{}, fmt.Errorf("invalid prefix length %d: must be between 10 and 30", bits)
+	}
+	subnetBits := bits - PrivateV4Network.Bits()
+	// Number of usable subnets excluding all-zeros and all-ones.
+	usable := uint32(1)<<subnetBits - 2
+	var buf [4]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return netip.Prefix{}, fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	subnet := binary.BigEndian.Uint32(buf[:])%usable + 1
+	base := PrivateV4Network.Addr().As4()
+	addr := binary.BigEndian.Uint32(base[:]) | subnet<<(32-bits)
+	binary.BigEndian.PutUint32(buf[:], addr)
+	return netip.PrefixFrom(netip.AddrFrom4(buf), bits), nil
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netutil
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestGeneratePrivateV4(t *testing.T) {
+	t.Parallel()
+	for _, bits := range []int{10, 16, 24, 30} {
+		for i := 0; i < 100; i++ {
+			prefix, err := GeneratePrivateV4(bits)
+			if err != nil {
+				t.Fatalf("unexpected error for /%d: %v", bits, err)
+			}
+			if prefix.Bits() != bits {
+				t.Fatalf("expected prefix length %d, got %d", bits, prefix.Bits())
+			}
+			if prefix != prefix.Masked() {
+				t.Fatalf("expected masked prefix, got %s", prefix)
+			}
+			if !PrivateV4Network.Contains(prefix.Addr()) {
+				t.Fatalf("expected %s to be within %s", prefix, PrivateV4Network)
+			}
+			a4 := prefix.Addr().As4()
+			subnet := binary.BigEndian.Uint32(a4[:]) << 8 >> (32 - bits + 8)
+			if subnet == 0 || subnet == uint32(1)<<(bits-8)-1 {
+				t.Fatalf("expected %s to not be the all-zeros or all-ones subnet", prefix)
+			}
+		}
+	}
+	for _, bits := range []int{0, 8, 9, 31, 32} {
+		if _, err := GeneratePrivateV4(bits); err == nil {
+			t.Errorf("expected error for /%d", bits)
+		}
+	}
+}
@@ -17,9 +17,11 @@ limitations under the License.
 package netutil
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"log/slog"
 	mrand "math/rand"
@@ -49,6 +51,39 @@ func GenerateULA() (netip.Prefix, error) {
 	return GenerateULAWithSeed(secret), nil
 }
 
+// ErrULAConflict is returned by GenerateUniqueULA when every generated
+// prefix was already in use.
+var ErrULAConflict = errors.New("no unique ULA could be generated")
+
+// DefaultUniqueULAAttempts is the number of prefixes GenerateUniqueULA
+// generates before giving up.
+const DefaultUniqueULAAttempts = 5
+
+// GenerateUniqueULA generates a ULA like GenerateULA, but consults exists for
+// every generated prefix and regenerates when it reports a conflict. This
+// guards against independently bootstrapped meshes drawing the same prefix.
+// The exists function may also record the prefix in a shared store so later
+// callers see it as taken. An error returned from exists is returned as is.
+func GenerateUniqueULA(ctx context.Context, exists func(netip.Prefix) (bool, error)) (netip.Prefix, error) {
+	for i := 0; i < DefaultUniqueULAAttempts; i++ {
+		if err := ctx.Err(); err != nil {
+			return netip.Prefix{}, err
+		}
+		prefix, err := GenerateULA()
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		taken, err := exists(prefix)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("check ULA %s: %w", prefix, err)
+		}
+		if !taken {
+			return prefix, nil
+		}
+	}
+	return netip.Prefix{}, fmt.Errorf("%w after %d attempts", ErrULAConflict, DefaultUniqueULAAttempts)
+}
+
 // GenerateULAWithSeed generates a unique local address with a /48 prefix
 // using a seed value. The network is returned as a netip.Prefix.
 func GenerateULAWithSeed(psk []byte) netip.Prefix {
@@ -92,6 +127,56 @@ func AssignToPrefix(prefix netip.Prefix, publicKey crypto.PublicKey) netip.Prefi
 	return netip.PrefixFrom(addr, DefaultNodeBits)
 }
 
+// AssignToSubPrefix assigns a /112 prefix within the given prefix using a
+// public key. It derives the same bits from the key as AssignToPrefix, but
+// keeps every bit of the given prefix instead of only the first 48, so the
+// result always falls within it. For a /48 the result matches AssignToPrefix.
+// The prefix must be an IPv6 prefix between a /48 and a /112.
+func AssignToSubPrefix(prefix netip.Prefix, publicKey crypto.PublicKey) (netip.Prefix, error) {
+	if !prefix.IsValid() || !prefix.Addr().Is6() || prefix.Addr().Is4In6() {
+		return netip.Prefix{}, fmt.Errorf("invalid IPv6 prefix: %s", prefix)
+	}
+	if prefix.Bits() < DefaultULABits || prefix.Bits() > DefaultNodeBits {
+		return netip.Prefix{}, fmt.Errorf("prefix %s must be between a /%d and a /%d", prefix, DefaultULABits, DefaultNodeBits)
+	}
+	prefix = prefix.Masked()
+	ip := AssignToPrefix(prefix, publicKey).Addr().As16()
+	base := prefix.Addr().As16()
+	for i := 0; i < prefix.Bits(); i++ {
+		mask := byte(0x80 >> (i % 8))
+		ip[i/8] = ip[i/8]&^mask | base[i/8]&mask
+	}
+	return netip.PrefixFrom(netip.AddrFrom16(ip), DefaultNodeBits), nil
+}
+
+// DeriveNodeAddress derives a stable address for a node from its public key.
+// If the given prefix is a /48, the node's /64 subnet is derived from the key
+// as well. If it is a /64, the address is derived within it. The interface
+// identifier is taken from a hash of the key with the universal/local bit
+// cleared as required for non-EUI-64 identifiers (RFC 4291), so the same key
+// always yields the same address.
+func DeriveNodeAddress(ula netip.Prefix, nodeKey []byte) (netip.Addr, error) {
+	if !ula.IsValid() || !ula.Addr().Is6() || ula.Addr().Is4In6() {
+		return netip.Addr{}, fmt.Errorf("invalid IPv6 prefix: %s", ula)
+	}
+	if ula.Bits() != DefaultULABits && ula.Bits() != 64 {
+		return netip.Addr{}, fmt.Errorf("prefix %s must be a /%d or /64", ula, DefaultULABits)
+	}
+	if len(nodeKey) == 0 {
+		return netip.Addr{}, fmt.Errorf("node key must not be empty")
+	}
+	ip := ula.Masked().Addr().As16()
+	sum := sha256.Sum256(nodeKey)
+	if ula.Bits() == DefaultULABits {
+		// Derive the 16-bit subnet ID from the key
+		copy(ip[6:8], sum[:2])
+	}
+	// Derive the 64-bit interface ID from the rest of the hash
+	copy(ip[8:], sum[2:10])
+	ip[8] &^= 0x02
+	return netip.AddrFrom16(ip), nil
+}
+
 func generateLocalSecret() ([]byte, error) {
 	b := make([]byte, 8)
 	binary.BigEndian.PutUint64(b, timeToNTP(time.Now().UTC()))
@@ -17,6 +17,8 @@ limitations under the License.
 package netutil
 
 import (
+	"context"
+	"errors"
 	"io"
 	"net/netip"
 	"os"
@@ -144,6 +146,126 @@ func FuzzAssignToPrefix(f *testing.F) {
 	})
 }
 
+func TestAssignToSubPrefix(t *testing.T) {
+	t.Parallel()
+	ula := netip.MustParsePrefix("fd12:3456:789a::/48")
+
+	t.Run("InvalidPrefix", func(t *testing.T) {
+		key := mustGenerateKey(t)
+		for _, prefix := range []netip.Prefix{
+			{},
+			netip.MustParsePrefix("10.0.0.0/8"),
+			netip.MustParsePrefix("fd12::/32"),
+			netip.MustParsePrefix("fd12:3456:789a::/120"),
+		} {
+			if _, err := AssignToSubPrefix(prefix, key); err == nil {
+				t.Errorf("expected error for prefix %q", prefix)
+			}
+		}
+	})
+
+	t.Run("MatchesAssignToPrefix", func(t *testing.T) {
+		key := mustGenerateKey(t)
+		got, err := AssignToSubPrefix(ula, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := AssignToPrefix(ula, key); got != want {
+			t.Fatalf("expected %s, got %s", want, got)
+		}
+	})
+
+	t.Run("WithinSubPrefix", func(t *testing.T) {
+		for _, prefix := range []netip.Prefix{
+			netip.MustParsePrefix("fd12:3456:789a:ab00::/56"),
+			netip.MustParsePrefix("fd12:3456:789a:1::/64"),
+			netip.MustParsePrefix("fd12:3456:789a:1:2:3::/97"),
+		} {
+			key := mustGenerateKey(t)
+			got, err := AssignToSubPrefix(prefix, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Bits() != DefaultNodeBits {
+				t.Fatalf("expected a /%d, got %s", DefaultNodeBits, got)
+			}
+			if !prefix.Contains(got.Addr()) {
+				t.Fatalf("assigned prefix %s not contained in %s", got, prefix)
+			}
+			again, err := AssignToSubPrefix(prefix, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again != got {
+				t.Fatalf("assigned different prefixes for the same key: %s != %s", got, again)
+			}
+		}
+	})
+}
+
+func TestDeriveNodeAddress(t *testing.T) {
+	t.Parallel()
+	ula := netip.MustParsePrefix("fd12:3456:789a::/48")
+	subnet := netip.MustParsePrefix("fd12:3456:789a:1::/64")
+
+	t.Run("InvalidPrefix", func(t *testing.T) {
+		key := mustGenerateKey(t).Bytes()
+		for _, prefix := range []netip.Prefix{
+			{},
+			netip.MustParsePrefix("10.0.0.0/8"),
+			netip.MustParsePrefix("fd12:3456:789a::/56"),
+			netip.MustParsePrefix("fd12:3456:789a::/112"),
+		} {
+			if _, err := DeriveNodeAddress(prefix, key); err == nil {
+				t.Errorf("expected error for prefix %q", prefix)
+			}
+		}
+		if _, err := DeriveNodeAddress(ula, nil); err == nil {
+			t.Error("expected error for empty key")
+		}
+	})
+
+	t.Run("Deterministic", func(t *testing.T) {
+		key := mustGenerateKey(t).Bytes()
+		for _, prefix := range []netip.Prefix{ula, subnet} {
+			addr, err := DeriveNodeAddress(prefix, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !prefix.Contains(addr) {
+				t.Fatalf("address %s not contained in %s", addr, prefix)
+			}
+			again, err := DeriveNodeAddress(prefix, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if addr != again {
+				t.Fatalf("derived different addresses for the same key: %s != %s", addr, again)
+			}
+		}
+	})
+
+	t.Run("UniquePerKey", func(t *testing.T) {
+		seen := make(map[[8]byte]struct{})
+		for i := 0; i < 100; i++ {
+			addr, err := DeriveNodeAddress(subnet, mustGenerateKey(t).Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !subnet.Contains(addr) {
+				t.Fatalf("address %s not contained in %s", addr, subnet)
+			}
+			ip := addr.As16()
+			var host [8]byte
+			copy(host[:], ip[8:])
+			if _, ok := seen[host]; ok {
+				t.Fatalf("derived duplicate host bits for address %s", addr)
+			}
+			seen[host] = struct{}{}
+		}
+	})
+}
+
 func mustGenerateULA(t *testing.F) netip.Prefix {
 	t.Helper()
 	ula, err := GenerateULA()
@@ -171,3 +293,81 @@ func mustGenerateSeedKey(t *testing.F) []byte {
 	pubkey := key.PublicKey().Bytes()
 	return pubkey[:]
 }
+
+func TestGenerateUniqueULA(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("RegeneratesOnConflict", func(t *testing.T) {
+		t.Parallel()
+		// The first draw is registered by another mesh, so the second
+		// draw should be returned and recorded.
+		registered := map[netip.Prefix]struct{}{}
+		var draws []netip.Prefix
+		exists := func(prefix netip.Prefix) (bool, error) {
+			draws = append(draws, prefix)
+			if len(draws) == 1 {
+				return true, nil
+			}
+			if _, ok := registered[prefix]; ok {
+				return true, nil
+			}
+			registered[prefix] = struct{}{}
+			return false, nil
+		}
+		ula, err := GenerateUniqueULA(ctx, exists)
+		if err != nil {
+			t.Fatalf("failed to generate unique ULA: %s", err)
+		}
+		if len(draws) != 2 {
+			t.Fatalf("expected 2 draws, got %d", len(draws))
+		}
+		if ula != draws[1] {
+			t.Fatalf("expected second draw %s, got %s", draws[1], ula)
+		}
+		if !ula.IsValid() || ula.Bits() != DefaultULABits {
+			t.Fatalf("generated invalid ULA: %s", ula)
+		}
+		if _, ok := registered[ula]; !ok {
+			t.Fatalf("expected %s to be registered", ula)
+		}
+	})
+
+	t.Run("AllConflict", func(t *testing.T) {
+		t.Parallel()
+		var draws int
+		_, err := GenerateUniqueULA(ctx, func(netip.Prefix) (bool, error) {
+			draws++
+			return true, nil
+		})
+		if !errors.Is(err, ErrULAConflict) {
+			t.Fatalf("expected ErrULAConflict, got %v", err)
+		}
+		if draws != DefaultUniqueULAAttempts {
+			t.Fatalf("expected %d draws, got %d", DefaultUniqueULAAttempts, draws)
+		}
+	})
+
+	t.Run("ExistsError", func(t *testing.T) {
+		t.Parallel()
+		storeErr := errors.New("store unavailable")
+		_, err := GenerateUniqueULA(ctx, func(netip.Prefix) (bool, error) {
+			return false, storeErr
+		})
+		if !errors.Is(err, storeErr) {
+			t.Fatalf("expected store error, got %v", err)
+		}
+	})
+
+	t.Run("CancelledContext", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := GenerateUniqueULA(ctx, func(netip.Prefix) (bool, error) {
+			return false, nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// ErrUnsupported is returned when an operation is not supported on the
+// current platform.
+var ErrUnsupported = errors.New("unsupported on this platform")
+
+// DiscoverPathMTU probes the path MTU to the given target and returns the
+// largest IP packet size, including headers, that can be sent without
+// fragmentation.
+//
+// Probes are UDP datagrams sent with the don't-fragment bit set. The result
+// reflects the kernel's view of the path MTU, which is only lowered below the
+// outgoing interface MTU when a router on the path returns an ICMP
+// fragmentation-needed (or IPv6 packet-too-big) message. Paths that silently
+// drop oversized packets will therefore report the interface MTU. Discovery is
+// currently only implemented on Linux and ErrUnsupported is returned on other
+// platforms.
+func DiscoverPathMTU(ctx context.Context, target netip.Addr) (int, error) {
+	if !target.IsValid() {
+		return 0, fmt.Errorf("invalid target address")
+	}
+	if target.IsUnspecified() || target.IsMulticast() {
+		return 0, fmt.Errorf("target %s is not a unicast address", target)
+	}
+	return discoverPathMTU(ctx, target.Unmap())
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// mtuProbePort is the port probes are sent to. The discard port is used
+	// since the payload is never expected to be read.
+	mtuProbePort = 9
+	// mtuProbeWait is how long to wait after a probe for ICMP feedback to
+	// update the kernel's path MTU.
+	mtuProbeWait = 50 * time.Millisecond
+)
+
+func discoverPathMTU(ctx context.Context, target netip.Addr) (int, error) {
+	network := "udp4"
+	level, discoverOpt, discoverVal, mtuOpt := unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_DO, unix.IP_MTU
+	// Minimum MTU and IP + UDP header overhead
+	minMTU, overhead := 576, 28
+	if target.Is6() {
+		network = "udp6"
+		level, discoverOpt, discoverVal, mtuOpt = unix.IPPROTO_IPV6, unix.IPV6_MTU_DISCOVER, unix.IPV6_PMTUDISC_DO, unix.IPV6_MTU
+		minMTU, overhead = 1280, 48
+	}
+	dialer := net.Dialer{
+		Control: func(_, _ string, c syscall.RawConn) error {
+			var sockErr error
+			err := c.Control(func(fd uintptr) {
+				sockErr = unix.SetsockoptInt(int(fd), level, discoverOpt, discoverVal)
+			})
+			if err != nil {
+				return err
+			}
+			return sockErr
+		},
+	}
+	c, err := dialer.DialContext(ctx, network, netip.AddrPortFrom(target, mtuProbePort).String())
+	if err != nil {
+		return 0, fmt.Errorf("dial %s: %w", target, err)
+	}
+	defer c.Close()
+	conn := c.(*net.UDPConn)
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0, fmt.Errorf("get raw connection: %w", err)
+	}
+	kernelMTU := func() (int, error) {
+		var mtu int
+		var sockErr error
+		err := raw.Control(func(fd uintptr) {
+			mtu, sockErr = unix.GetsockoptInt(int(fd), level, mtuOpt)
+		})
+		if err != nil {
+			return 0, err
+		}
+		return mtu, sockErr
+	}
+	probe := func(size int) (bool, error) {
+		_, err := conn.Write(make([]byte, size-overhead))
+		switch {
+		case errors.Is(err, unix.EMSGSIZE):
+			return false, nil
+		case err != nil && !errors.Is(err, unix.ECONNREFUSED):
+			return false, err
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(mtuProbeWait):
+		}
+		mtu, err := kernelMTU()
+		if err != nil {
+			return false, err
+		}
+		return mtu >= size, nil
+	}
+	hi, err := kernelMTU()
+	if err != nil {
+		return 0, fmt.Errorf("get route mtu to %s: %w", target, err)
+	}
+	lo := minMTU
+	if hi < lo {
+		return hi, nil
+	}
+	// The minimum MTU must always work, so this doubles as a reachability check.
+	ok, err := probe(lo)
+	if err != nil {
+		return 0, fmt.Errorf("probe %s: %w", target, err)
+	}
+	if !ok {
+		return 0, fmt.Errorf("probe %s: minimum mtu %d rejected", target, lo)
+	}
+	for lo < hi {
+		mid := lo + (hi-lo+1)/2
+		ok, err := probe(mid)
+		if err != nil {
+			return 0, fmt.Errorf("probe %s: %w", target, err)
+		}
+		if ok {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return lo, nil
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netutil
+
+import (
+	"context"
+	"net/netip"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestDiscoverPathMTU(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("path mtu discovery is only supported on linux")
+	}
+	t.Parallel()
+
+	t.Run("Loopback", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		mtu, err := DiscoverPathMTU(ctx, netip.MustParseAddr("127.0.0.1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mtu < 576 || mtu > 65536 {
+			t.Fatalf("implausible loopback mtu %d", mtu)
+		}
+	})
+
+	t.Run("Unreachable", func(t *testing.T) {
+		tc := []struct {
+			name   string
+			target netip.Addr
+		}{
+			{"Invalid", netip.Addr{}},
+			{"Unspecified", netip.IPv4Unspecified()},
+			{"Multicast", netip.MustParseAddr("224.0.0.1")},
+			// Link-local addresses cannot be routed without a zone.
+			{"LinkLocalNoZone", netip.MustParseAddr("fe80::1")},
+		}
+		for _, tt := range tc {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				mtu, err := DiscoverPathMTU(ctx, tt.target)
+				if err == nil {
+					t.Fatalf("expected error, got mtu %d", mtu)
+				}
+			})
+		}
+	})
+}
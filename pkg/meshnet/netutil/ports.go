@@ -17,27 +17,47 @@ limitations under the License.
 package netutil
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 )
 
-// ParsePortRange parses a port range string.
+// ErrInvalidPortRange is returned when a port range cannot be parsed.
+var ErrInvalidPortRange = errors.New("invalid port range")
+
+// ParsePortRange parses a port range string. The range may be a single
+// port or a start and end port separated by a dash. All errors returned
+// wrap ErrInvalidPortRange.
 func ParsePortRange(s string) (start int, end int, err error) {
 	spl := strings.Split(s, "-")
 	if len(spl) > 2 {
-		return 0, 0, fmt.Errorf("invalid port range: %s", s)
+		return 0, 0, fmt.Errorf("%w: %q: too many separators", ErrInvalidPortRange, s)
 	}
-	start, err = strconv.Atoi(spl[0])
+	start, err = parsePort(spl[0])
 	if err != nil {
-		return 0, 0, fmt.Errorf("invalid port range: %s", s)
+		return 0, 0, fmt.Errorf("%w: %q: start port: %w", ErrInvalidPortRange, s, err)
 	}
 	end = start
 	if len(spl) == 2 {
-		end, err = strconv.Atoi(spl[1])
+		end, err = parsePort(spl[1])
 		if err != nil {
-			return 0, 0, fmt.Errorf("invalid port range: %s", s)
+			return 0, 0, fmt.Errorf("%w: %q: end port: %w", ErrInvalidPortRange, s, err)
 		}
 	}
+	if start > end {
+		return 0, 0, fmt.Errorf("%w: %q: start port is greater than end port", ErrInvalidPortRange, s)
+	}
 	return start, end, nil
 }
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	if port < 0 || port > 65535 {
+		return 0, fmt.Errorf("port %d out of range", port)
+	}
+	return port, nil
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netutil
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParsePortRange(t *testing.T) {
+	t.Parallel()
+	tc := []struct {
+		name      string
+		in        string
+		wantStart int
+		wantEnd   int
+		wantErr   bool
+	}{
+		{name: "SinglePort", in: "8080", wantStart: 8080, wantEnd: 8080},
+		{name: "Range", in: "49152-65535", wantStart: 49152, wantEnd: 65535},
+		{name: "Empty", in: "", wantErr: true},
+		{name: "TooManySeparators", in: "1-2-3", wantErr: true},
+		{name: "InvalidStart", in: "a-10", wantErr: true},
+		{name: "InvalidEnd", in: "10-b", wantErr: true},
+		{name: "MissingEnd", in: "10-", wantErr: true},
+		{name: "StartOutOfRange", in: "70000", wantErr: true},
+		{name: "EndOutOfRange", in: "10-70000", wantErr: true},
+		{name: "NegativePort", in: "-1", wantErr: true},
+		{name: "StartGreaterThanEnd", in: "20-10", wantErr: true},
+	}
+	for _, tt := range tc {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			start, end, err := ParsePortRange(tt.in)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidPortRange) {
+					t.Fatalf("expected ErrInvalidPortRange, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if start != tt.wantStart || end != tt.wantEnd {
+				t.Fatalf("expected %d-%d, got %d-%d", tt.wantStart, tt.wantEnd, start, end)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netutil
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+)
+
+// BackoffConfig configures the delay between attempts in Retry.
+type BackoffConfig struct {
+	// Initial is the delay after the first failed attempt.
+	Initial time.Duration
+	// Max is the maximum delay between attempts. Zero means no maximum.
+	Max time.Duration
+	// Multiplier is the factor the delay grows by after each failed attempt.
+	// Values less than 1 are treated as 1, i.e. a constant delay.
+	Multiplier float64
+	// Jitter is the fraction of the delay to randomly add or subtract, from
+	// 0 to 1. The jittered delay never exceeds Max.
+	Jitter float64
+}
+
+// DefaultBackoff is a sensible default backoff configuration.
+var DefaultBackoff = BackoffConfig{
+	Initial:    100 * time.Millisecond,
+	Max:        5 * time.Second,
+	Multiplier: 2,
+	Jitter:     0.2,
+}
+
+// ConstantBackoff returns a BackoffConfig that always waits the given delay.
+func ConstantBackoff(delay time.Duration) BackoffConfig {
+	return BackoffConfig{Initial: delay, Max: delay, Multiplier: 1}
+}
+
+// Delay returns the delay to wait after the given zero-indexed failed attempt.
+func (b BackoffConfig) Delay(attempt int) time.Duration {
+	multiplier := math.Max(b.Multiplier, 1)
+	delay := float64(b.Initial) * math.Pow(multiplier, float64(attempt))
+	if b.Max > 0 && delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+	if jitter := math.Min(math.Max(b.Jitter, 0), 1); jitter > 0 {
+		delay += delay * jitter * (2*rand.Float64() - 1)
+		if b.Max > 0 && delay > float64(b.Max) {
+			delay = float64(b.Max)
+		}
+	}
+	return time.Duration(delay)
+}
+
+// Retry calls fn until it succeeds, it has been called the given number of
+// attempts, or the context is done. Between attempts it waits according to
+// the given backoff. The last error returned by fn is returned if all attempts
+// fail. If the context is done while waiting, the last error is returned
+// wrapped with the context error.
+func Retry(ctx context.Context, attempts int, backoff BackoffConfig, fn func() error) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		err = fn()
+		if err == nil {
+			return nil
+		}
+		if attempt == attempts-1 {
+			break
+		}
+		timer := time.NewTimer(backoff.Delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w: %w", err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+	return err
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	t.Parallel()
+	errTest := errors.New("test error")
+	backoff := BackoffConfig{Initial: time.Millisecond, Max: 5 * time.Millisecond, Multiplier: 2}
+
+	t.Run("SucceedsAfterFailures", func(t *testing.T) {
+		var calls int
+		err := Retry(context.Background(), 5, backoff, func() error {
+			calls++
+			if calls < 3 {
+				return errTest
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calls != 3 {
+			t.Fatalf("expected 3 calls, got %d", calls)
+		}
+	})
+
+	t.Run("ExhaustsAttempts", func(t *testing.T) {
+		var calls int
+		err := Retry(context.Background(), 4, backoff, func() error {
+			calls++
+			return errTest
+		})
+		if !errors.Is(err, errTest) {
+			t.Fatalf("expected last error, got %v", err)
+		}
+		if calls != 4 {
+			t.Fatalf("expected 4 calls, got %d", calls)
+		}
+	})
+
+	t.Run("ZeroAttempts", func(t *testing.T) {
+		var calls int
+		_ = Retry(context.Background(), 0, backoff, func() error {
+			calls++
+			return errTest
+		})
+		if calls != 1 {
+			t.Fatalf("expected 1 call, got %d", calls)
+		}
+	})
+
+	t.Run("Cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var calls int
+		start := time.Now()
+		err := Retry(ctx, 10, ConstantBackoff(time.Hour), func() error {
+			calls++
+			cancel()
+			return errTest
+		})
+		if !errors.Is(err, context.Canceled) || !errors.Is(err, errTest) {
+			t.Fatalf("expected error wrapping both the last error and context.Canceled, got %v", err)
+		}
+		if calls != 1 {
+			t.Fatalf("expected 1 call, got %d", calls)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("expected retry to return on cancellation, took %s", elapsed)
+		}
+	})
+
+	t.Run("CancelledBeforeStart", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		var calls int
+		err := Retry(ctx, 3, backoff, func() error {
+			calls++
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+		if calls != 0 {
+			t.Fatalf("expected no calls, got %d", calls)
+		}
+	})
+}
+
+func TestBackoffDelay(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Growth", func(t *testing.T) {
+		b := BackoffConfig{Initial: 10 * time.Millisecond, Max: 50 * time.Millisecond, Multiplier: 2}
+		want := []time.Duration{
+			10 * time.Millisecond,
+			20 * time.Millisecond,
+			40 * time.Millisecond,
+			50 * time.Millisecond,
+			50 * time.Millisecond,
+		}
+		for attempt, expected := range want {
+			if got := b.Delay(attempt); got != expected {
+				t.Errorf("attempt %d: expected delay %s, got %s", attempt, expected, got)
+			}
+		}
+	})
+
+	t.Run("Constant", func(t *testing.T) {
+		b := ConstantBackoff(time.Second)
+		for attempt := 0; attempt < 5; attempt++ {
+			if got := b.Delay(attempt); got != time.Second {
+				t.Errorf("attempt %d: expected delay %s, got %s", attempt, time.Second, got)
+			}
+		}
+	})
+
+	t.Run("Jitter", func(t *testing.T) {
+		b := BackoffConfig{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 2, Jitter: 0.5}
+		for attempt := 0; attempt < 100; attempt++ {
+			base := BackoffConfig{Initial: b.Initial, Max: b.Max, Multiplier: b.Multiplier}.Delay(attempt % 5)
+			got := b.Delay(attempt % 5)
+			if got < base/2 || got > base+base/2 || got > b.Max {
+				t.Fatalf("attempt %d: jittered delay %s out of range for base %s", attempt%5, got, base)
+			}
+		}
+	})
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netutil
+
+import (
+	"math/bits"
+	"net/netip"
+)
+
+// SelectSourceAddr selects the address from candidates to use as the source
+// when sending to dst. Only candidates of the same family as dst are eligible,
+// with IPv4-mapped IPv6 addresses treated as IPv4. Among those, a candidate of
+// the same scope as dst is preferred, then the one sharing the longest common
+// prefix with dst. Remaining ties go to the candidate that appears first. The
+// returned address is in its unmapped form, and false is returned if no
+// candidate is eligible.
+func SelectSourceAddr(dst netip.Addr, candidates []netip.Addr) (netip.Addr, bool) {
+	if !dst.IsValid() {
+		return netip.Addr{}, false
+	}
+	dst = dst.Unmap()
+	var best netip.Addr
+	var bestScope bool
+	bestLen := -1
+	for _, candidate := range candidates {
+		if !candidate.IsValid() {
+			continue
+		}
+		candidate = candidate.Unmap()
+		if candidate.Is4() != dst.Is4() {
+			continue
+		}
+		sameScope := addrScope(candidate) == addrScope(dst)
+		if bestLen >= 0 && bestScope && !sameScope {
+			continue
+		}
+		plen := commonPrefixLen(candidate, dst)
+		if bestLen >= 0 && sameScope == bestScope && plen <= bestLen {
+			continue
+		}
+		best, bestScope, bestLen = candidate, sameScope, plen
+	}
+	return best, best.IsValid()
+}
+
+// addrScope returns the scope of the address, ordered from the narrowest.
+// Private addresses share the global scope as described in RFC 6724.
+func addrScope(addr netip.Addr) int {
+	switch {
+	case addr.IsLoopback():
+		return 0
+	case addr.IsLinkLocalUnicast(), addr.IsLinkLocalMulticast():
+		return 1
+	default:
+		return 2
+	}
+}
+
+// commonPrefixLen returns the number of leading bits shared by two addresses
+// of the same family.
+func commonPrefixLen(a, b netip.Addr) int {
+	a16, b16 := a.As16(), b.As16()
+	start := 0
+	if a.Is4() {
+		// Skip the IPv4-mapped prefix so lengths are in IPv4 bits.
+		start = 12
+	}
+	var n int
+	for i := start; i < len(a16); i++ {
+		if x := a16[i] ^ b16[i]; x != 0 {
+			return n + bits.LeadingZeros8(x)
+		}
+		n += 8
+	}
+	return n
+}
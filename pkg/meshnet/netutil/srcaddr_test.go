@@ -0,0 +1,113 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netutil
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestSelectSourceAddr(t *testing.T) {
+	t.Parallel()
+	tc := []struct {
+		name       string
+		dst        string
+		candidates []string
+		want       string
+	}{
+		{
+			name:       "IPv4Destination",
+			dst:        "172.16.0.10",
+			candidates: []string{"fd00::1", "10.0.0.1", "172.16.0.1"},
+			want:       "172.16.0.1",
+		},
+		{
+			name:       "IPv6Destination",
+			dst:        "fd00:1::10",
+			candidates: []string{"172.16.0.1", "fd00:2::1", "fd00:1::1"},
+			want:       "fd00:1::1",
+		},
+		{
+			name:       "LongestPrefix",
+			dst:        "10.1.2.3",
+			candidates: []string{"10.0.0.1", "10.1.0.1", "10.1.2.1"},
+			want:       "10.1.2.1",
+		},
+		{
+			name:       "MappedCandidate",
+			dst:        "10.1.2.3",
+			candidates: []string{"fd00::1", "::ffff:10.1.2.1"},
+			want:       "10.1.2.1",
+		},
+		{
+			name:       "MappedDestination",
+			dst:        "::ffff:10.1.2.3",
+			candidates: []string{"fd00::1", "10.1.2.1"},
+			want:       "10.1.2.1",
+		},
+		{
+			name:       "SameScopePreferred",
+			dst:        "fe80::10",
+			candidates: []string{"fe00::1", "fe80::1"},
+			want:       "fe80::1",
+		},
+		{
+			name:       "ScopeOverPrefix",
+			dst:        "fd00::10",
+			candidates: []string{"fe80::1", "2001:db8::1"},
+			want:       "2001:db8::1",
+		},
+		{
+			name:       "TieKeepsOrder",
+			dst:        "10.0.0.128",
+			candidates: []string{"10.0.0.1", "10.0.0.2"},
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "NoSameFamily",
+			dst:        "10.0.0.1",
+			candidates: []string{"fd00::1", "fd00::2"},
+		},
+		{
+			name: "NoCandidates",
+			dst:  "fd00::1",
+		},
+	}
+	for _, tt := range tc {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			candidates := make([]netip.Addr, len(tt.candidates))
+			for i, c := range tt.candidates {
+				candidates[i] = netip.MustParseAddr(c)
+			}
+			got, ok := SelectSourceAddr(netip.MustParseAddr(tt.dst), candidates)
+			if tt.want == "" {
+				if ok {
+					t.Fatalf("expected no source address, got %s", got)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("expected source address %s, got none", tt.want)
+			}
+			if want := netip.MustParseAddr(tt.want); got != want {
+				t.Fatalf("expected source address %s, got %s", want, got)
+			}
+		})
+	}
+}
@@ -198,12 +198,12 @@ func (m *peerManager) addPeer(ctx context.Context, peer *v1.WireGuardPeer, iceSe
 		// to connect to us.
 		log.Warn("Error determining peer endpoint, will wait for incoming connection", "error", err.Error())
 	}
-	allowedIPs := make([]netip.Prefix, 0)
-	for _, ip := range peer.GetAllowedIPs() {
-		prefix, err := netip.ParsePrefix(ip)
-		if err != nil {
-			return fmt.Errorf("parse peer allowed ip: %w", err)
-		}
+	canonicalIPs, err := netutil.CanonicalizeCIDRs(peer.GetAllowedIPs())
+	if err != nil {
+		return fmt.Errorf("parse peer allowed ips: %w", err)
+	}
+	allowedIPs := make([]netip.Prefix, 0, len(canonicalIPs))
+	for _, prefix := range canonicalIPs {
 		if m.net.opts.DisableIPv4 && prefix.Addr().Is4() {
 			continue
 		}
@@ -295,7 +295,6 @@ func (m *peerManager) determinePeerEndpoint(ctx context.Context, peer *v1.WireGu
 	if peer.GetProto() == v1.ConnectProtocol_CONNECT_LIBP2P {
 		return m.negotiateP2PRelay(ctx, peer)
 	}
-	// TODO: We don't honor ipv4/ipv6 preferences currently in this function
 	if peer.GetNode().GetPrimaryEndpoint() != "" {
 		addr, err := net.ResolveUDPAddr("udp", peer.GetNode().GetPrimaryEndpoint())
 		if err != nil {
@@ -312,6 +311,15 @@ func (m *peerManager) determinePeerEndpoint(ctx context.Context, peer *v1.WireGu
 		}
 		endpoint = addr.AddrPort()
 	}
+	// Honor the endpoint family preference for dual-stack peers
+	if pref := m.net.opts.EndpointFamilyPreference; endpoint.IsValid() && !pref.Matches(endpoint.Addr()) {
+		if preferred, ok := preferredEndpoint(ctx, pref, peer.GetNode().GetWireguardEndpoints()); ok {
+			log.Debug("Using endpoint matching family preference",
+				slog.String("endpoint", preferred.String()),
+				slog.String("family", string(pref)))
+			endpoint = preferred
+		}
+	}
 	// Check if we are using zone awareness and the peer is in the same zone
 	if m.net.opts.ZoneAwarenessID != "" && peer.GetNode().GetZoneAwarenessID() == m.net.opts.ZoneAwarenessID {
 		log.Debug("Using zone awareness, collecting local CIDRs")
@@ -357,6 +365,24 @@ func (m *peerManager) determinePeerEndpoint(ctx context.Context, peer *v1.WireGu
 	return endpoint, nil
 }
 
+// preferredEndpoint returns the first of the given endpoints that resolves to
+// an address in the given family.
+func preferredEndpoint(ctx context.Context, family EndpointFamily, endpoints []string) (netip.AddrPort, bool) {
+	for _, endpoint := range endpoints {
+		addr, err := net.ResolveUDPAddr("udp", endpoint)
+		if err != nil {
+			context.LoggerFrom(ctx).Debug("Could not resolve peer endpoint", slog.String("endpoint", endpoint), slog.String("error", err.Error()))
+			continue
+		}
+		ep := addr.AddrPort()
+		ep = netip.AddrPortFrom(ep.Addr().Unmap(), ep.Port())
+		if family.Matches(ep.Addr()) {
+			return ep, true
+		}
+	}
+	return netip.AddrPort{}, false
+}
+
 func (m *peerManager) negotiateP2PRelay(ctx context.Context, peer *v1.WireGuardPeer) (netip.AddrPort, error) {
 	log := context.LoggerFrom(ctx)
 	m.p2pmu.Lock()
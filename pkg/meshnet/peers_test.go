@@ -0,0 +1,80 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package meshnet
+
+import (
+	"testing"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+)
+
+func TestDeterminePeerEndpointFamilyPreference(t *testing.T) {
+	t.Parallel()
+
+	peer := &v1.WireGuardPeer{
+		Node: &v1.MeshNode{
+			Id:              "dual-stack",
+			PrimaryEndpoint: "203.0.113.10:51820",
+			WireguardEndpoints: []string{
+				"203.0.113.10:51820",
+				"[2001:db8::10]:51820",
+			},
+		},
+	}
+	tc := []struct {
+		name   string
+		family EndpointFamily
+		want   string
+	}{
+		{name: "NoPreference", family: EndpointFamilyAny, want: "203.0.113.10:51820"},
+		{name: "PreferIPv4", family: EndpointFamilyIPv4, want: "203.0.113.10:51820"},
+		{name: "PreferIPv6", family: EndpointFamilyIPv6, want: "[2001:db8::10]:51820"},
+	}
+	for _, tt := range tc {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			pm := &peerManager{net: &manager{opts: Options{EndpointFamilyPreference: tt.family}}}
+			endpoint, err := pm.determinePeerEndpoint(context.Background(), peer, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if endpoint.String() != tt.want {
+				t.Fatalf("expected endpoint %s, got %s", tt.want, endpoint)
+			}
+		})
+	}
+
+	t.Run("NoMatchingEndpoint", func(t *testing.T) {
+		t.Parallel()
+		v4only := &v1.WireGuardPeer{Node: &v1.MeshNode{
+			Id:                 "v4-only",
+			PrimaryEndpoint:    "203.0.113.20:51820",
+			WireguardEndpoints: []string{"203.0.113.20:51820"},
+		}}
+		pm := &peerManager{net: &manager{opts: Options{EndpointFamilyPreference: EndpointFamilyIPv6}}}
+		endpoint, err := pm.determinePeerEndpoint(context.Background(), v4only, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if endpoint.String() != "203.0.113.20:51820" {
+			t.Fatalf("expected fallback to the primary endpoint, got %s", endpoint)
+		}
+	})
+}
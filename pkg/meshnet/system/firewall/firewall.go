@@ -19,6 +19,8 @@ package firewall
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/netip"
 )
 
@@ -32,8 +34,59 @@ type Firewall interface {
 	Clear(ctx context.Context) error
 	// Close should close any resources used by the firewall. It should also perform a Clear.
 	Close(ctx context.Context) error
+	// Backend returns the name of the backend in use by the firewall.
+	Backend() string
+	// ListRules returns the rules currently applied by the firewall.
+	ListRules(ctx context.Context) ([]Rule, error)
+	// Reconcile converges the firewall to the desired rules. Only rules that
+	// differ from the current ones are added or removed, so it is safe to call
+	// repeatedly.
+	Reconcile(ctx context.Context, desired []Rule) error
 }
 
+// RuleType is the type of a firewall rule.
+type RuleType string
+
+const (
+	// RuleTypeWireguardForwarding allows forwarding traffic on an interface.
+	// It is the declarative form of AddWireguardForwarding.
+	RuleTypeWireguardForwarding RuleType = "wireguard-forwarding"
+	// RuleTypeMasquerade masquerades traffic on an interface. It is the
+	// declarative form of AddMasquerade.
+	RuleTypeMasquerade RuleType = "masquerade"
+)
+
+// Rule is a firewall rule managed by webmesh.
+type Rule struct {
+	// Type is the type of the rule.
+	Type RuleType
+	// Interface is the interface the rule applies to.
+	Interface string
+}
+
+// String returns a string representation of the rule.
+func (r Rule) String() string {
+	return fmt.Sprintf("%s on %s", r.Type, r.Interface)
+}
+
+const (
+	// BackendAuto detects the best available backend for the system.
+	BackendAuto = ""
+	// BackendNFTables is the nftables backend. It is only available on Linux.
+	BackendNFTables = "nftables"
+	// BackendIPTables is the iptables backend. It is only available on Linux
+	// and is used as a fallback when nftables is not supported.
+	BackendIPTables = "iptables"
+	// BackendPF is the packet filter backend used on Darwin and FreeBSD.
+	BackendPF = "pf"
+	// BackendNetsh is the netsh advfirewall backend used on Windows.
+	BackendNetsh = "netsh"
+)
+
+// ErrBackendUnsupported is returned when a firewall backend is not supported
+// on the current system.
+var ErrBackendUnsupported = errors.New("firewall backend not supported")
+
 // Policy is a firewall policy.
 type Policy string
 
@@ -61,13 +114,53 @@ type Options struct {
 	StoragePort uint16
 	// GRPCPort is the port to allow for grpc traffic.
 	GRPCPort uint16
+	// Backend is the firewall backend to use. If empty, the best available
+	// backend for the system is detected.
+	Backend string
 }
 
-// New returns a new firewall manager for the given options.
+// New returns a new firewall manager for the given options. The selected
+// backend can be retrieved with the Backend method of the returned firewall.
 func New(ctx context.Context, opts *Options) (Firewall, error) {
 	return newFirewall(ctx, opts)
 }
 
+// backendFactory creates a firewall using a specific backend.
+type backendFactory struct {
+	name string
+	new  func(ctx context.Context, opts *Options) (Firewall, error)
+}
+
+// newFromBackends creates a firewall from the given candidate backends in order
+// of preference. When a backend is requested explicitly only that backend is
+// tried. Otherwise candidates failing with ErrBackendUnsupported are skipped in
+// favor of the next one.
+func newFromBackends(ctx context.Context, opts *Options, candidates []backendFactory) (Firewall, error) {
+	if opts.Backend != BackendAuto {
+		for _, candidate := range candidates {
+			if candidate.name == opts.Backend {
+				return candidate.new(ctx, opts)
+			}
+		}
+		return nil, fmt.Errorf("%w: %s", ErrBackendUnsupported, opts.Backend)
+	}
+	var errs []error
+	for _, candidate := range candidates {
+		fw, err := candidate.new(ctx, opts)
+		if err == nil {
+			return fw, nil
+		}
+		if !errors.Is(err, ErrBackendUnsupported) {
+			return nil, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", candidate.name, err))
+	}
+	if len(errs) == 0 {
+		return nil, ErrBackendUnsupported
+	}
+	return nil, errors.Join(errs...)
+}
+
 // DNATOptions are options for configuring a postrouting rule.
 type DNATOptions struct {
 	// Protocol is the protocol to apply the rule to.
@@ -28,6 +28,13 @@ import (
 const anchorFile = "/etc/pf.anchors/com.webmesh"
 
 func newFirewall(ctx context.Context, opts *Options) (Firewall, error) {
+	return newFromBackends(ctx, opts, []backendFactory{
+		{name: BackendPF, new: newPFFirewall},
+	})
+}
+
+// newPFFirewall returns a new packet filter firewall manager.
+func newPFFirewall(ctx context.Context, opts *Options) (Firewall, error) {
 	// Make sure we can touch the anchor file
 	afile := anchorFile
 	if opts.ID != "" {
@@ -57,6 +64,12 @@ func newFirewall(ctx context.Context, opts *Options) (Firewall, error) {
 type pfctlFirewall struct {
 	enabledAtStart bool
 	anchorFile     string
+	rules          ruleSet
+}
+
+// Backend returns the name of the backend in use by the firewall.
+func (pf *pfctlFirewall) Backend() string {
+	return BackendPF
 }
 
 // AddWireguardForwarding should configure the firewall to allow forwarding traffic on the wireguard interface.
@@ -72,7 +85,11 @@ func (pf *pfctlFirewall) AddWireguardForwarding(ctx context.Context, ifaceName s
 	}
 	// Reload pfctl
 	err = common.Exec(ctx, "pfctl", "-f", anchorFile)
-	return err
+	if err != nil {
+		return err
+	}
+	pf.rules.add(Rule{Type: RuleTypeWireguardForwarding, Interface: ifaceName})
+	return nil
 }
 
 // AddMasquerade should configure the firewall to masquerade outbound traffic on the wireguard interface.
@@ -88,7 +105,22 @@ func (pf *pfctlFirewall) AddMasquerade(ctx context.Context, ifaceName string) er
 	}
 	// Reload pfctl
 	err = common.Exec(ctx, "pfctl", "-f", anchorFile)
-	return err
+	if err != nil {
+		return err
+	}
+	pf.rules.add(Rule{Type: RuleTypeMasquerade, Interface: ifaceName})
+	return nil
+}
+
+// ListRules returns the rules currently applied by the firewall.
+func (pf *pfctlFirewall) ListRules(ctx context.Context) ([]Rule, error) {
+	return pf.rules.list(), nil
+}
+
+// Reconcile converges the firewall to the desired rules. Rules are appended to
+// the anchor file, so it is rewritten whenever a rule must be removed.
+func (pf *pfctlFirewall) Reconcile(ctx context.Context, desired []Rule) error {
+	return reconcileRules(ctx, pf, desired, nil)
 }
 
 // Clear should clear any changes made to the firewall.
@@ -100,7 +132,11 @@ func (pf *pfctlFirewall) Clear(ctx context.Context) error {
 	}
 	// Reload pfctl
 	err = common.Exec(ctx, "pfctl", "-f", anchorFile)
-	return err
+	if err != nil {
+		return err
+	}
+	pf.rules.reset()
+	return nil
 }
 
 // Close should close any resources used by the firewall. It should also perform a Clear.
@@ -17,6 +17,7 @@ limitations under the License.
 package firewall
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"os/exec"
@@ -25,75 +26,163 @@ import (
 	"github.com/webmeshproj/webmesh/pkg/context"
 )
 
-// newIPTablesFirewall returns a new iptables firewall manager. This firewall manager
-// is technically not safe for use with multiple interfaces. The Close method may restore
-// rules from another interface. But documentation should push people to use nftables instead.
-// This is just a fallback.
+const (
+	// iptablesForwardChain is the chain holding webmesh forwarding rules.
+	iptablesForwardChain = "WEBMESH-FORWARD"
+	// iptablesPostroutingChain is the nat chain holding webmesh masquerade rules.
+	iptablesPostroutingChain = "WEBMESH-POSTROUTING"
+)
+
+// iptablesRunner runs iptables with the given arguments and returns its output.
+type iptablesRunner func(ctx context.Context, args ...string) ([]byte, error)
+
+// newIPTablesFirewall returns a new iptables firewall manager. Rules are kept in
+// dedicated chains that are jumped to from the built-in chains, so clearing them
+// does not disturb rules from other tooling. The chains are shared, so this
+// firewall manager is not safe for use with multiple interfaces. Documentation
+// should push people to use nftables instead. This is just a fallback.
 func newIPTablesFirewall(ctx context.Context, _ *Options) (Firewall, error) {
+	return newIPTablesFirewallWithRunner(ctx, execIPTables)
+}
+
+func newIPTablesFirewallWithRunner(ctx context.Context, run iptablesRunner) (Firewall, error) {
 	fw := &iptablesFirewall{
 		log: context.LoggerFrom(ctx).With(slog.String("component", "iptables-firewall")),
+		run: run,
 	}
-	var initialRules []string
-	rules, err := fw.execOutput(context.Background(), "-S")
-	if err != nil {
-		return nil, fmt.Errorf("iptables -S: %v", err)
+	for _, chain := range fw.chains() {
+		err := fw.ensureChain(ctx, chain)
+		if err != nil {
+			if errors.Is(err, exec.ErrNotFound) {
+				return nil, fmt.Errorf("%w: %w", ErrBackendUnsupported, err)
+			}
+			return nil, err
+		}
 	}
-	initialRules = append(initialRules, strings.Split(string(rules), "\n")...)
-	fw.initialRules = initialRules
 	return fw, nil
 }
 
 type iptablesFirewall struct {
-	log          *slog.Logger
-	initialRules []string
+	log   *slog.Logger
+	run   iptablesRunner
+	rules ruleSet
+}
+
+// iptablesChain is a webmesh chain and the built-in chain that jumps to it.
+type iptablesChain struct {
+	table   string
+	builtin string
+	name    string
+}
+
+func (fw *iptablesFirewall) chains() []iptablesChain {
+	return []iptablesChain{
+		{table: "filter", builtin: "FORWARD", name: iptablesForwardChain},
+		{table: "nat", builtin: "POSTROUTING", name: iptablesPostroutingChain},
+	}
+}
+
+// ensureChain creates the given chain, or flushes it if it already exists,
+// and makes sure the built-in chain jumps to it.
+func (fw *iptablesFirewall) ensureChain(ctx context.Context, chain iptablesChain) error {
+	if _, err := fw.run(ctx, "-t", chain.table, "-S", chain.name); err == nil {
+		if err := fw.exec(ctx, "-t", chain.table, "-F", chain.name); err != nil {
+			return err
+		}
+	} else if err := fw.exec(ctx, "-t", chain.table, "-N", chain.name); err != nil {
+		return err
+	}
+	if _, err := fw.run(ctx, "-t", chain.table, "-C", chain.builtin, "-j", chain.name); err == nil {
+		return nil
+	}
+	return fw.exec(ctx, "-t", chain.table, "-I", chain.builtin, "-j", chain.name)
+}
+
+// Backend returns the name of the backend in use by the firewall.
+func (fw *iptablesFirewall) Backend() string {
+	return BackendIPTables
 }
 
 // AddWireguardForwarding should configure the firewall to allow forwarding traffic on the wireguard interface.
 func (fw *iptablesFirewall) AddWireguardForwarding(ctx context.Context, ifaceName string) error {
-	return fw.exec(ctx, "-A", "FORWARD", "-i", ifaceName, "-j", "ACCEPT")
+	return fw.applyRule(ctx, "-A", Rule{Type: RuleTypeWireguardForwarding, Interface: ifaceName})
 }
 
 // AddMasquerade should configure the firewall to masquerade outbound traffic on the wireguard interface.
 func (fw *iptablesFirewall) AddMasquerade(ctx context.Context, ifaceName string) error {
-	return fw.exec(ctx, "-t", "nat", "-A", "POSTROUTING", "-o", ifaceName, "-j", "MASQUERADE")
+	return fw.applyRule(ctx, "-A", Rule{Type: RuleTypeMasquerade, Interface: ifaceName})
 }
 
-// Clear should clear any changes made to the firewall.
-func (fw *iptablesFirewall) Clear(ctx context.Context) error {
-	err := fw.exec(ctx, "-F")
-	if err != nil {
+// ListRules returns the rules currently applied by the firewall.
+func (fw *iptablesFirewall) ListRules(ctx context.Context) ([]Rule, error) {
+	return fw.rules.list(), nil
+}
+
+// Reconcile converges the firewall to the desired rules.
+func (fw *iptablesFirewall) Reconcile(ctx context.Context, desired []Rule) error {
+	return reconcileRules(ctx, fw, desired, func(ctx context.Context, rule Rule) error {
+		return fw.applyRule(ctx, "-D", rule)
+	})
+}
+
+// applyRule appends (-A) or deletes (-D) the iptables rule for the given rule.
+func (fw *iptablesFirewall) applyRule(ctx context.Context, op string, rule Rule) error {
+	var args []string
+	switch rule.Type {
+	case RuleTypeWireguardForwarding:
+		args = []string{"-t", "filter", op, iptablesForwardChain, "-i", rule.Interface, "-j", "ACCEPT"}
+	case RuleTypeMasquerade:
+		args = []string{"-t", "nat", op, iptablesPostroutingChain, "-o", rule.Interface, "-j", "MASQUERADE"}
+	default:
+		return fmt.Errorf("unknown rule type: %q", rule.Type)
+	}
+	if err := fw.exec(ctx, args...); err != nil {
 		return err
 	}
-	// Restore initial rules
-	for _, rule := range fw.initialRules {
-		if strings.HasPrefix(rule, "#") {
-			// Comment, skip
-			continue
-		}
-		err = fw.exec(ctx, strings.Fields(rule)...)
-		if err != nil {
+	if op == "-D" {
+		fw.rules.remove(rule)
+	} else {
+		fw.rules.add(rule)
+	}
+	return nil
+}
+
+// Clear should clear any changes made to the firewall.
+func (fw *iptablesFirewall) Clear(ctx context.Context) error {
+	for _, chain := range fw.chains() {
+		if err := fw.exec(ctx, "-t", chain.table, "-F", chain.name); err != nil {
 			return err
 		}
 	}
+	fw.rules.reset()
 	return nil
 }
 
 // Close should close any resources used by the firewall. It should also perform a Clear.
+// The jumps from the built-in chains and the webmesh chains are removed.
 func (fw *iptablesFirewall) Close(ctx context.Context) error {
-	return fw.Clear(ctx)
+	if err := fw.Clear(ctx); err != nil {
+		return err
+	}
+	for _, chain := range fw.chains() {
+		if err := fw.exec(ctx, "-t", chain.table, "-D", chain.builtin, "-j", chain.name); err != nil {
+			return err
+		}
+		if err := fw.exec(ctx, "-t", chain.table, "-X", chain.name); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (fw *iptablesFirewall) exec(ctx context.Context, args ...string) error {
-	cmd := exec.CommandContext(ctx, "iptables", args...)
 	fw.log.Debug("iptables", slog.String("args", strings.Join(args, " ")))
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("iptables %v: %v: %s", args, err, out)
+	if out, err := fw.run(ctx, args...); err != nil {
+		return fmt.Errorf("iptables %v: %w: %s", args, err, out)
 	}
 	return nil
 }
 
-func (rw *iptablesFirewall) execOutput(ctx context.Context, args ...string) ([]byte, error) {
-	cmd := exec.CommandContext(ctx, "iptables", args...)
-	rw.log.Debug("iptables", slog.String("args", strings.Join(args, " ")))
-	return cmd.CombinedOutput()
+func execIPTables(ctx context.Context, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, "iptables", args...).CombinedOutput()
 }
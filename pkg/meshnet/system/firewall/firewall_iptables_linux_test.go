@@ -0,0 +1,226 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package firewall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeIPTables is an in-memory iptables supporting the subset of commands
+// used by the iptables firewall.
+type fakeIPTables struct {
+	// tables maps a table to its chains and their rules.
+	tables map[string]map[string][]string
+}
+
+func newFakeIPTables() *fakeIPTables {
+	return &fakeIPTables{
+		tables: map[string]map[string][]string{
+			"filter": {"INPUT": nil, "FORWARD": {"-j DOCKER-USER"}, "OUTPUT": nil},
+			"nat":    {"PREROUTING": nil, "POSTROUTING": {"-o docker0 -j MASQUERADE"}, "OUTPUT": nil},
+		},
+	}
+}
+
+func (f *fakeIPTables) run(_ context.Context, args ...string) ([]byte, error) {
+	table := "filter"
+	if len(args) >= 2 && args[0] == "-t" {
+		table, args = args[1], args[2:]
+	}
+	if len(args) < 2 {
+		return nil, fmt.Errorf("unsupported command: %v", args)
+	}
+	chains := f.tables[table]
+	op, chain, rule := args[0], args[1], strings.Join(args[2:], " ")
+	rules, exists := chains[chain]
+	if op != "-N" && !exists {
+		return nil, fmt.Errorf("no chain %s in table %s", chain, table)
+	}
+	switch op {
+	case "-N":
+		if exists {
+			return nil, fmt.Errorf("chain %s already exists", chain)
+		}
+		chains[chain] = nil
+	case "-X":
+		if len(rules) > 0 {
+			return nil, fmt.Errorf("chain %s is not empty", chain)
+		}
+		for _, rules := range chains {
+			if slices.Contains(rules, "-j "+chain) {
+				return nil, fmt.Errorf("chain %s is still referenced", chain)
+			}
+		}
+		delete(chains, chain)
+	case "-F":
+		chains[chain] = nil
+	case "-S":
+		return []byte(strings.Join(rules, "\n")), nil
+	case "-C":
+		if !slices.Contains(rules, rule) {
+			return nil, errors.New("rule does not exist")
+		}
+	case "-I":
+		chains[chain] = append([]string{rule}, rules...)
+	case "-A":
+		chains[chain] = append(rules, rule)
+	case "-D":
+		idx := slices.Index(rules, rule)
+		if idx < 0 {
+			return nil, errors.New("rule does not exist")
+		}
+		chains[chain] = slices.Delete(rules, idx, idx+1)
+	default:
+		return nil, fmt.Errorf("unsupported command: %v", args)
+	}
+	return nil, nil
+}
+
+func TestIPTablesFirewallChains(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ipt := newFakeIPTables()
+	fw, err := newIPTablesFirewallWithRunner(ctx, ipt.run)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The chains should be created and jumped to.
+	for _, tc := range []struct{ table, builtin, chain string }{
+		{"filter", "FORWARD", iptablesForwardChain},
+		{"nat", "POSTROUTING", iptablesPostroutingChain},
+	} {
+		if _, ok := ipt.tables[tc.table][tc.chain]; !ok {
+			t.Fatalf("expected chain %s to be created in table %s", tc.chain, tc.table)
+		}
+		if got := ipt.tables[tc.table][tc.builtin][0]; got != "-j "+tc.chain {
+			t.Fatalf("expected %s to jump to %s first, got %q", tc.builtin, tc.chain, got)
+		}
+	}
+
+	// Rules should be placed in the webmesh chains.
+	if err := fw.AddWireguardForwarding(ctx, "webmesh0"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := fw.AddMasquerade(ctx, "webmesh0"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := ipt.tables["filter"][iptablesForwardChain]; !slices.Equal(got, []string{"-i webmesh0 -j ACCEPT"}) {
+		t.Fatalf("unexpected forward rules: %v", got)
+	}
+	if got := ipt.tables["nat"][iptablesPostroutingChain]; !slices.Equal(got, []string{"-o webmesh0 -j MASQUERADE"}) {
+		t.Fatalf("unexpected postrouting rules: %v", got)
+	}
+
+	// Creating the firewall again should reuse the chains without duplicating jumps.
+	if _, err := newIPTablesFirewallWithRunner(ctx, ipt.run); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := ipt.tables["filter"]["FORWARD"]; !slices.Equal(got, []string{"-j " + iptablesForwardChain, "-j DOCKER-USER"}) {
+		t.Fatalf("unexpected forward chain after reopening: %v", got)
+	}
+
+	// Close should remove only the webmesh chains and jumps.
+	if err := fw.Close(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := ipt.tables["filter"][iptablesForwardChain]; ok {
+		t.Fatal("expected forward chain to be removed")
+	}
+	if _, ok := ipt.tables["nat"][iptablesPostroutingChain]; ok {
+		t.Fatal("expected postrouting chain to be removed")
+	}
+	if got := ipt.tables["filter"]["FORWARD"]; !slices.Equal(got, []string{"-j DOCKER-USER"}) {
+		t.Fatalf("expected other forward rules to be preserved, got %v", got)
+	}
+	if got := ipt.tables["nat"]["POSTROUTING"]; !slices.Equal(got, []string{"-o docker0 -j MASQUERADE"}) {
+		t.Fatalf("expected other postrouting rules to be preserved, got %v", got)
+	}
+}
+
+func TestIPTablesFirewallReconcile(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ipt := newFakeIPTables()
+	// Record every command that modifies a rule.
+	var changes []string
+	run := func(ctx context.Context, args ...string) ([]byte, error) {
+		for _, arg := range args {
+			if arg == "-A" || arg == "-D" {
+				changes = append(changes, strings.Join(args, " "))
+				break
+			}
+		}
+		return ipt.run(ctx, args...)
+	}
+	fw, err := newIPTablesFirewallWithRunner(ctx, run)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reconcile := func(t *testing.T, desired ...Rule) []string {
+		t.Helper()
+		changes = nil
+		if err := fw.Reconcile(ctx, desired); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		rules, err := fw.ListRules(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, rule := range desired {
+			if !slices.Contains(rules, rule) {
+				t.Fatalf("expected rule %s to be listed, got %v", rule, rules)
+			}
+		}
+		if len(rules) != len(desired) {
+			t.Fatalf("expected %d rules, got %v", len(desired), rules)
+		}
+		return changes
+	}
+	forward0 := Rule{Type: RuleTypeWireguardForwarding, Interface: "webmesh0"}
+	masq0 := Rule{Type: RuleTypeMasquerade, Interface: "webmesh0"}
+	forward1 := Rule{Type: RuleTypeWireguardForwarding, Interface: "webmesh1"}
+
+	if got := reconcile(t, forward0, masq0); len(got) != 2 {
+		t.Fatalf("expected 2 changes on first reconcile, got %v", got)
+	}
+	if got := reconcile(t, forward0, masq0); len(got) != 0 {
+		t.Fatalf("expected no changes when converged, got %v", got)
+	}
+	got := reconcile(t, forward0, forward1)
+	want := []string{
+		"-t nat -D " + iptablesPostroutingChain + " -o webmesh0 -j MASQUERADE",
+		"-t filter -A " + iptablesForwardChain + " -i webmesh1 -j ACCEPT",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected minimal changes %v, got %v", want, got)
+	}
+	if got := ipt.tables["filter"][iptablesForwardChain]; !slices.Equal(got, []string{"-i webmesh0 -j ACCEPT", "-i webmesh1 -j ACCEPT"}) {
+		t.Fatalf("unexpected forward rules: %v", got)
+	}
+	if got := ipt.tables["nat"][iptablesPostroutingChain]; len(got) != 0 {
+		t.Fatalf("unexpected postrouting rules: %v", got)
+	}
+	if got := reconcile(t); len(got) != 2 {
+		t.Fatalf("expected 2 changes when removing all rules, got %v", got)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/containernetworking/plugins/pkg/ns"
 	"github.com/google/nftables"
@@ -46,11 +47,30 @@ type firewall struct {
 	forward nftableslib.RulesInterface
 	// raw chains
 	rawprerouting nftableslib.RulesInterface
+	// applied rules and their handles
+	rules   ruleSet
+	handles map[Rule][]nftablesHandle
+	mu      sync.Mutex
 }
 
-// newFirewall returns a new nftables firewall manager.
+// nftablesHandle is the handle of an nftables rule in a chain.
+type nftablesHandle struct {
+	chain  nftableslib.RulesInterface
+	handle uint64
+}
+
+// newFirewall returns a new firewall manager, preferring nftables and falling
+// back to iptables when nftables is not supported.
 func newFirewall(ctx context.Context, opts *Options) (Firewall, error) {
-	fw := &firewall{opts: opts}
+	return newFromBackends(ctx, opts, []backendFactory{
+		{name: BackendNFTables, new: newNFTablesFirewall},
+		{name: BackendIPTables, new: newIPTablesFirewall},
+	})
+}
+
+// newNFTablesFirewall returns a new nftables firewall manager.
+func newNFTablesFirewall(ctx context.Context, opts *Options) (Firewall, error) {
+	fw := &firewall{opts: opts, handles: make(map[Rule][]nftablesHandle)}
 	// Initialize a long lasting connection to the nftables library
 	var netns []int
 	if opts.NetNs != "" {
@@ -66,16 +86,21 @@ func newFirewall(ctx context.Context, opts *Options) (Firewall, error) {
 	err := fw.initialize(opts)
 	if err != nil {
 		if strings.Contains(err.Error(), "not supported") || strings.Contains(err.Error(), "no such file") {
-			// Try to fallback to iptables
-			return newIPTablesFirewall(ctx, opts)
+			return nil, fmt.Errorf("%w: %w", ErrBackendUnsupported, err)
 		}
 		return nil, err
 	}
 	return fw, nil
 }
 
+// Backend returns the name of the backend in use by the firewall.
+func (fw *firewall) Backend() string {
+	return BackendNFTables
+}
+
 // AddWireguardForwarding should configure the firewall to allow forwarding traffic on the wireguard interface.
 func (fw *firewall) AddWireguardForwarding(ctx context.Context, ifaceName string) error {
+	rule := Rule{Type: RuleTypeWireguardForwarding, Interface: ifaceName}
 	if len(ifaceName) > 15 {
 		ifaceName = ifaceName[:15]
 	}
@@ -83,7 +108,7 @@ func (fw *firewall) AddWireguardForwarding(ctx context.Context, ifaceName string
 	if err != nil {
 		return fmt.Errorf("failed to create accept verdict: %w", err)
 	}
-	_, err = fw.forward.Rules().InsertImm(&nftableslib.Rule{
+	handle, err := fw.forward.Rules().InsertImm(&nftableslib.Rule{
 		Meta: &nftableslib.Meta{
 			Expr: []nftableslib.MetaExpr{
 				{
@@ -98,11 +123,13 @@ func (fw *firewall) AddWireguardForwarding(ctx context.Context, ifaceName string
 	if err != nil {
 		return fmt.Errorf("failed to create wireguard forwarding rule: %w", err)
 	}
+	fw.track(rule, nftablesHandle{fw.forward, handle})
 	return fw.conn.Flush()
 }
 
 // AddMasquerade should configure the firewall to masquerade outbound traffic on the wireguard interface.
 func (fw *firewall) AddMasquerade(ctx context.Context, ifaceName string) error {
+	rule := Rule{Type: RuleTypeMasquerade, Interface: ifaceName}
 	if len(ifaceName) > 15 {
 		ifaceName = ifaceName[:15]
 	}
@@ -111,7 +138,7 @@ func (fw *firewall) AddMasquerade(ctx context.Context, ifaceName string) error {
 	if err != nil {
 		return fmt.Errorf("failed to create masquerade verdict: %w", err)
 	}
-	_, err = fw.postrouting.Rules().InsertImm(&nftableslib.Rule{
+	outbound, err := fw.postrouting.Rules().InsertImm(&nftableslib.Rule{
 		Meta: &nftableslib.Meta{
 			Expr: []nftableslib.MetaExpr{
 				{
@@ -127,7 +154,7 @@ func (fw *firewall) AddMasquerade(ctx context.Context, ifaceName string) error {
 		return fmt.Errorf("failed to create outbound wireguard masquerade rule: %w", err)
 	}
 	// Masquearade inbound traffic from the wireguard interface
-	_, err = fw.postrouting.Rules().InsertImm(&nftableslib.Rule{
+	inbound, err := fw.postrouting.Rules().InsertImm(&nftableslib.Rule{
 		Meta: &nftableslib.Meta{
 			Expr: []nftableslib.MetaExpr{
 				{
@@ -142,6 +169,39 @@ func (fw *firewall) AddMasquerade(ctx context.Context, ifaceName string) error {
 	if err != nil {
 		return fmt.Errorf("failed to create inbound wireguard masquerade rule: %w", err)
 	}
+	fw.track(rule, nftablesHandle{fw.postrouting, outbound}, nftablesHandle{fw.postrouting, inbound})
+	return fw.conn.Flush()
+}
+
+// ListRules returns the rules currently applied by the firewall.
+func (fw *firewall) ListRules(ctx context.Context) ([]Rule, error) {
+	return fw.rules.list(), nil
+}
+
+// Reconcile converges the firewall to the desired rules.
+func (fw *firewall) Reconcile(ctx context.Context, desired []Rule) error {
+	return reconcileRules(ctx, fw, desired, fw.removeRule)
+}
+
+// track records the handles of an applied rule.
+func (fw *firewall) track(rule Rule, handles ...nftablesHandle) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.handles[rule] = append(fw.handles[rule], handles...)
+	fw.rules.add(rule)
+}
+
+// removeRule deletes every nftables rule created for the given rule.
+func (fw *firewall) removeRule(ctx context.Context, rule Rule) error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	for _, h := range fw.handles[rule] {
+		if err := h.chain.Rules().DeleteImm(h.handle); err != nil {
+			return fmt.Errorf("failed to delete rule: %w", err)
+		}
+	}
+	delete(fw.handles, rule)
+	fw.rules.remove(rule)
 	return fw.conn.Flush()
 }
 
@@ -153,6 +213,10 @@ func (fw *firewall) Clear(ctx context.Context) error {
 			return fmt.Errorf("failed to delete inet %s table: %w", table, err)
 		}
 	}
+	fw.mu.Lock()
+	fw.handles = make(map[Rule][]nftablesHandle)
+	fw.mu.Unlock()
+	fw.rules.reset()
 	return fw.conn.Flush()
 }
 
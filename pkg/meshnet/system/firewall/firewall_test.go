@@ -0,0 +1,110 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package firewall
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type fakeFirewall struct {
+	Firewall
+	backend string
+}
+
+func (f *fakeFirewall) Backend() string { return f.backend }
+
+// fakeBackend returns a backendFactory whose capability probe succeeds
+// when supported is true.
+func fakeBackend(name string, supported bool) backendFactory {
+	return backendFactory{
+		name: name,
+		new: func(context.Context, *Options) (Firewall, error) {
+			if !supported {
+				return nil, ErrBackendUnsupported
+			}
+			return &fakeFirewall{backend: name}, nil
+		},
+	}
+}
+
+func TestNewFromBackends(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	tc := []struct {
+		name       string
+		backend    string
+		candidates []backendFactory
+		want       string
+		wantErr    error
+	}{
+		{
+			name:       "AutoPrefersFirstSupported",
+			backend:    BackendAuto,
+			candidates: []backendFactory{fakeBackend(BackendNFTables, true), fakeBackend(BackendIPTables, true)},
+			want:       BackendNFTables,
+		},
+		{
+			name:       "AutoFallsBack",
+			backend:    BackendAuto,
+			candidates: []backendFactory{fakeBackend(BackendNFTables, false), fakeBackend(BackendIPTables, true)},
+			want:       BackendIPTables,
+		},
+		{
+			name:       "AutoNoneSupported",
+			backend:    BackendAuto,
+			candidates: []backendFactory{fakeBackend(BackendNFTables, false), fakeBackend(BackendIPTables, false)},
+			wantErr:    ErrBackendUnsupported,
+		},
+		{
+			name:       "ExplicitOverride",
+			backend:    BackendIPTables,
+			candidates: []backendFactory{fakeBackend(BackendNFTables, true), fakeBackend(BackendIPTables, true)},
+			want:       BackendIPTables,
+		},
+		{
+			name:       "ExplicitUnsupported",
+			backend:    BackendNFTables,
+			candidates: []backendFactory{fakeBackend(BackendNFTables, false), fakeBackend(BackendIPTables, true)},
+			wantErr:    ErrBackendUnsupported,
+		},
+		{
+			name:       "ExplicitUnknown",
+			backend:    BackendPF,
+			candidates: []backendFactory{fakeBackend(BackendNFTables, true)},
+			wantErr:    ErrBackendUnsupported,
+		},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			fw, err := newFromBackends(ctx, &Options{Backend: tt.backend}, tt.candidates)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if fw.Backend() != tt.want {
+				t.Fatalf("expected backend %s, got %s", tt.want, fw.Backend())
+			}
+		})
+	}
+}
@@ -25,10 +25,20 @@ import (
 )
 
 func newFirewall(ctx context.Context, opts *Options) (Firewall, error) {
-	return &winFirewall{}, nil
+	return newFromBackends(ctx, opts, []backendFactory{
+		{name: BackendNetsh, new: func(context.Context, *Options) (Firewall, error) {
+			return &winFirewall{}, nil
+		}},
+	})
 }
 
 type winFirewall struct {
+	rules ruleSet
+}
+
+// Backend returns the name of the backend in use by the firewall.
+func (wf *winFirewall) Backend() string {
+	return BackendNetsh
 }
 
 // AddWireguardForwarding should configure the firewall to allow forwarding traffic on the wireguard interface.
@@ -54,6 +64,7 @@ func (wf *winFirewall) AddWireguardForwarding(ctx context.Context, ifaceName str
 			return err
 		}
 	}
+	wf.rules.add(Rule{Type: RuleTypeWireguardForwarding, Interface: ifaceName})
 	return nil
 }
 
@@ -80,9 +91,22 @@ func (wf *winFirewall) AddMasquerade(ctx context.Context, ifaceName string) erro
 			return err
 		}
 	}
+	wf.rules.add(Rule{Type: RuleTypeMasquerade, Interface: ifaceName})
 	return nil
 }
 
+// ListRules returns the rules currently applied by the firewall.
+func (wf *winFirewall) ListRules(ctx context.Context) ([]Rule, error) {
+	return wf.rules.list(), nil
+}
+
+// Reconcile converges the firewall to the desired rules. Rules are named by
+// direction rather than interface, so they are rebuilt whenever a rule must
+// be removed.
+func (wf *winFirewall) Reconcile(ctx context.Context, desired []Rule) error {
+	return reconcileRules(ctx, wf, desired, nil)
+}
+
 // Clear should clear any changes made to the firewall.
 func (wf *winFirewall) Clear(ctx context.Context) error {
 	for _, name := range []string{"webmesh-forward-inbound", "webmesh-forward-outbound"} {
@@ -91,6 +115,7 @@ func (wf *winFirewall) Clear(ctx context.Context) error {
 			context.LoggerFrom(ctx).Debug("Failed to delete firewall rule", "error", err.Error())
 		}
 	}
+	wf.rules.reset()
 	return nil
 }
 
@@ -0,0 +1,117 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// ruleSet tracks the rules applied by a firewall so they can be listed
+// and reconciled.
+type ruleSet struct {
+	mu    sync.Mutex
+	rules []Rule
+}
+
+// add records that the given rule was applied.
+func (s *ruleSet) add(rule Rule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.Contains(s.rules, rule) {
+		s.rules = append(s.rules, rule)
+	}
+}
+
+// remove records that the given rule was removed.
+func (s *ruleSet) remove(rule Rule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = slices.DeleteFunc(s.rules, func(r Rule) bool { return r == rule })
+}
+
+// reset forgets all applied rules.
+func (s *ruleSet) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = nil
+}
+
+// list returns a copy of the applied rules.
+func (s *ruleSet) list() []Rule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.rules)
+}
+
+// diffRules returns the rules that need to be added to and removed from
+// current to converge on desired. Duplicate desired rules are ignored.
+func diffRules(current, desired []Rule) (add, remove []Rule) {
+	for _, rule := range desired {
+		if !slices.Contains(current, rule) && !slices.Contains(add, rule) {
+			add = append(add, rule)
+		}
+	}
+	for _, rule := range current {
+		if !slices.Contains(desired, rule) {
+			remove = append(remove, rule)
+		}
+	}
+	return add, remove
+}
+
+// reconcileRules converges the firewall on the desired rules. The remove function
+// deletes a single rule. Backends that cannot delete individual rules pass nil, in
+// which case the firewall is cleared and rebuilt whenever a rule must be removed.
+func reconcileRules(ctx context.Context, fw Firewall, desired []Rule, remove func(context.Context, Rule) error) error {
+	current, err := fw.ListRules(ctx)
+	if err != nil {
+		return fmt.Errorf("list rules: %w", err)
+	}
+	add, del := diffRules(current, desired)
+	if len(del) > 0 && remove == nil {
+		if err := fw.Clear(ctx); err != nil {
+			return fmt.Errorf("clear firewall: %w", err)
+		}
+		add, del = diffRules(nil, desired)
+	}
+	for _, rule := range del {
+		if err := remove(ctx, rule); err != nil {
+			return fmt.Errorf("remove rule %s: %w", rule, err)
+		}
+	}
+	for _, rule := range add {
+		if err := addRule(ctx, fw, rule); err != nil {
+			return fmt.Errorf("add rule %s: %w", rule, err)
+		}
+	}
+	return nil
+}
+
+// addRule applies a single rule to the firewall.
+func addRule(ctx context.Context, fw Firewall, rule Rule) error {
+	switch rule.Type {
+	case RuleTypeWireguardForwarding:
+		return fw.AddWireguardForwarding(ctx, rule.Interface)
+	case RuleTypeMasquerade:
+		return fw.AddMasquerade(ctx, rule.Interface)
+	default:
+		return fmt.Errorf("unknown rule type: %q", rule.Type)
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package firewall
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+// recordingFirewall is a firewall that cannot remove individual rules and
+// records every change made to it.
+type recordingFirewall struct {
+	Firewall
+	rules   ruleSet
+	changes []string
+}
+
+func (f *recordingFirewall) AddWireguardForwarding(ctx context.Context, ifaceName string) error {
+	f.changes = append(f.changes, "add "+ifaceName)
+	f.rules.add(Rule{Type: RuleTypeWireguardForwarding, Interface: ifaceName})
+	return nil
+}
+
+func (f *recordingFirewall) AddMasquerade(ctx context.Context, ifaceName string) error {
+	f.changes = append(f.changes, "masquerade "+ifaceName)
+	f.rules.add(Rule{Type: RuleTypeMasquerade, Interface: ifaceName})
+	return nil
+}
+
+func (f *recordingFirewall) Clear(ctx context.Context) error {
+	f.changes = append(f.changes, "clear")
+	f.rules.reset()
+	return nil
+}
+
+func (f *recordingFirewall) ListRules(ctx context.Context) ([]Rule, error) {
+	return f.rules.list(), nil
+}
+
+func (f *recordingFirewall) Reconcile(ctx context.Context, desired []Rule) error {
+	return reconcileRules(ctx, f, desired, nil)
+}
+
+func TestDiffRules(t *testing.T) {
+	t.Parallel()
+	a := Rule{Type: RuleTypeWireguardForwarding, Interface: "a"}
+	b := Rule{Type: RuleTypeMasquerade, Interface: "b"}
+	c := Rule{Type: RuleTypeWireguardForwarding, Interface: "c"}
+	tc := []struct {
+		name       string
+		current    []Rule
+		desired    []Rule
+		wantAdd    []Rule
+		wantRemove []Rule
+	}{
+		{name: "Converged", current: []Rule{a, b}, desired: []Rule{b, a}},
+		{name: "FromEmpty", desired: []Rule{a, b}, wantAdd: []Rule{a, b}},
+		{name: "ToEmpty", current: []Rule{a, b}, wantRemove: []Rule{a, b}},
+		{name: "Changed", current: []Rule{a, b}, desired: []Rule{a, c}, wantAdd: []Rule{c}, wantRemove: []Rule{b}},
+		{name: "DuplicateDesired", desired: []Rule{a, a}, wantAdd: []Rule{a}},
+	}
+	for _, tt := range tc {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			add, remove := diffRules(tt.current, tt.desired)
+			if !slices.Equal(add, tt.wantAdd) {
+				t.Fatalf("expected to add %v, got %v", tt.wantAdd, add)
+			}
+			if !slices.Equal(remove, tt.wantRemove) {
+				t.Fatalf("expected to remove %v, got %v", tt.wantRemove, remove)
+			}
+		})
+	}
+}
+
+func TestReconcileRulesRebuild(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	fw := &recordingFirewall{}
+	reconcile := func(t *testing.T, desired ...Rule) []string {
+		t.Helper()
+		fw.changes = nil
+		if err := fw.Reconcile(ctx, desired); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return fw.changes
+	}
+	a := Rule{Type: RuleTypeWireguardForwarding, Interface: "a"}
+	b := Rule{Type: RuleTypeMasquerade, Interface: "b"}
+
+	if got := reconcile(t, a); !slices.Equal(got, []string{"add a"}) {
+		t.Fatalf("unexpected changes: %v", got)
+	}
+	if got := reconcile(t, a); len(got) != 0 {
+		t.Fatalf("expected no changes when converged, got %v", got)
+	}
+	// Adding a rule does not need a rebuild.
+	if got := reconcile(t, a, b); !slices.Equal(got, []string{"masquerade b"}) {
+		t.Fatalf("unexpected changes: %v", got)
+	}
+	// Removing a rule rebuilds the firewall without it.
+	if got := reconcile(t, b); !slices.Equal(got, []string{"clear", "masquerade b"}) {
+		t.Fatalf("unexpected changes: %v", got)
+	}
+	if _, err := fw.ListRules(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := fw.Reconcile(ctx, []Rule{{Type: "unknown", Interface: "a"}}); err == nil {
+		t.Fatal("expected error for unknown rule type")
+	}
+}
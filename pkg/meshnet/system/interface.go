@@ -35,6 +35,14 @@ import (
 // TODO: Try to determine this automatically.
 const DefaultMTU = 1420
 
+// MinMTU is the smallest MTU accepted for wireguard interfaces. It is the
+// minimum link MTU required by IPv6.
+const MinMTU = 1280
+
+// MaxMTU is the largest MTU accepted for wireguard interfaces. It is the
+// size of a jumbo frame.
+const MaxMTU = 9000
+
 // Interface represents an underlying machine network interface for
 // use with WireGuard.
 type Interface interface {
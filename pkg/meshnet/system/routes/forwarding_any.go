@@ -0,0 +1,25 @@
+//go:build !linux
+
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routes
+
+// EnableForwarding enables IP forwarding. Parameters are only restored on
+// Linux, so the returned function does nothing and sysctls is ignored.
+func EnableForwarding(sysctls Sysctls) (restore func() error, err error) {
+	return func() error { return nil }, EnableIPForwarding()
+}
@@ -19,6 +19,7 @@ package routes
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/containernetworking/plugins/pkg/utils/sysctl"
 )
@@ -44,3 +45,61 @@ func EnableIPForwarding() error {
 	}
 	return nil
 }
+
+// ForwardingSysctls are the parameters set by EnableForwarding.
+var ForwardingSysctls = []string{
+	"net/ipv4/ip_forward",
+	"net/ipv6/conf/all/forwarding",
+}
+
+// systemSysctls accesses the parameters of the running kernel.
+type systemSysctls struct{}
+
+func (systemSysctls) Get(name string) (string, error) {
+	return sysctl.Sysctl(name)
+}
+
+func (systemSysctls) Set(name, value string) error {
+	_, err := sysctl.Sysctl(name, value)
+	return err
+}
+
+// EnableForwarding enables IPv4 and IPv6 forwarding and returns a function
+// that restores the parameters it changed to their previous values. If
+// sysctls is nil the parameters of the running kernel are used.
+func EnableForwarding(sysctls Sysctls) (restore func() error, err error) {
+	if sysctls == nil {
+		sysctls = systemSysctls{}
+	}
+	type saved struct{ name, value string }
+	var changed []saved
+	restore = func() error {
+		var errs []error
+		for i := len(changed) - 1; i >= 0; i-- {
+			if err := sysctls.Set(changed[i].name, changed[i].value); err != nil {
+				errs = append(errs, fmt.Errorf("restore %s: %w", sysctlName(changed[i].name), err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+	for _, name := range ForwardingSysctls {
+		value, err := sysctls.Get(name)
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("read %s: %w", sysctlName(name), err), restore())
+		}
+		value = strings.TrimSpace(value)
+		if value == "1" {
+			continue
+		}
+		if err := sysctls.Set(name, "1"); err != nil {
+			return nil, errors.Join(fmt.Errorf("write %s: %w", sysctlName(name), err), restore())
+		}
+		changed = append(changed, saved{name, value})
+	}
+	return restore, nil
+}
+
+// sysctlName returns the dotted form of a sysctl path.
+func sysctlName(name string) string {
+	return strings.ReplaceAll(name, "/", ".")
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routes
+
+import (
+	"errors"
+	"maps"
+	"testing"
+)
+
+// fakeSysctls is an in-memory set of kernel parameters.
+type fakeSysctls struct {
+	values map[string]string
+	// failSet is a parameter that cannot be written.
+	failSet string
+}
+
+func (f *fakeSysctls) Get(name string) (string, error) {
+	value, ok := f.values[name]
+	if !ok {
+		return "", errors.New("no such parameter")
+	}
+	return value, nil
+}
+
+func (f *fakeSysctls) Set(name, value string) error {
+	if name == f.failSet {
+		return errors.New("permission denied")
+	}
+	f.values[name] = value
+	return nil
+}
+
+func TestEnableForwarding(t *testing.T) {
+	t.Parallel()
+
+	t.Run("SetAndRestore", func(t *testing.T) {
+		t.Parallel()
+		sysctls := &fakeSysctls{values: map[string]string{
+			"net/ipv4/ip_forward":          "0\n",
+			"net/ipv6/conf/all/forwarding": "0\n",
+		}}
+		restore, err := EnableForwarding(sysctls)
+		if err != nil {
+			t.Fatalf("enable forwarding: %v", err)
+		}
+		for _, name := range ForwardingSysctls {
+			if sysctls.values[name] != "1" {
+				t.Errorf("expected %s to be 1, got %q", name, sysctls.values[name])
+			}
+		}
+		if err := restore(); err != nil {
+			t.Fatalf("restore forwarding: %v", err)
+		}
+		for _, name := range ForwardingSysctls {
+			if sysctls.values[name] != "0" {
+				t.Errorf("expected %s to be restored to 0, got %q", name, sysctls.values[name])
+			}
+		}
+	})
+
+	t.Run("AlreadyEnabled", func(t *testing.T) {
+		t.Parallel()
+		sysctls := &fakeSysctls{values: map[string]string{
+			"net/ipv4/ip_forward":          "1\n",
+			"net/ipv6/conf/all/forwarding": "0\n",
+		}}
+		restore, err := EnableForwarding(sysctls)
+		if err != nil {
+			t.Fatalf("enable forwarding: %v", err)
+		}
+		if err := restore(); err != nil {
+			t.Fatalf("restore forwarding: %v", err)
+		}
+		// Parameters that were already enabled are left alone.
+		if sysctls.values["net/ipv4/ip_forward"] != "1\n" {
+			t.Errorf("expected ipv4 forwarding to be untouched, got %q", sysctls.values["net/ipv4/ip_forward"])
+		}
+		if sysctls.values["net/ipv6/conf/all/forwarding"] != "0" {
+			t.Errorf("expected ipv6 forwarding to be restored to 0, got %q", sysctls.values["net/ipv6/conf/all/forwarding"])
+		}
+	})
+
+	t.Run("RollbackOnError", func(t *testing.T) {
+		t.Parallel()
+		initial := map[string]string{
+			"net/ipv4/ip_forward":          "0",
+			"net/ipv6/conf/all/forwarding": "0",
+		}
+		sysctls := &fakeSysctls{values: maps.Clone(initial), failSet: "net/ipv6/conf/all/forwarding"}
+		_, err := EnableForwarding(sysctls)
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		if !maps.Equal(sysctls.values, initial) {
+			t.Errorf("expected parameters to be rolled back to %v, got %v", initial, sysctls.values)
+		}
+	})
+}
@@ -24,6 +24,15 @@ import (
 // ErrRouteExists is returned when a route already exists.
 var ErrRouteExists = errors.New("route already exists")
 
+// Sysctls reads and writes kernel parameters. Names are slash-separated
+// paths relative to /proc/sys, such as "net/ipv4/ip_forward".
+type Sysctls interface {
+	// Get returns the current value of the named parameter.
+	Get(name string) (string, error)
+	// Set sets the named parameter to the given value.
+	Set(name, value string) error
+}
+
 // Gateway represents a gateway route. It contains the name and IP address
 // of a gateway interface.
 type Gateway struct {
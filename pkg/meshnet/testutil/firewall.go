@@ -16,7 +16,11 @@ limitations under the License.
 
 package testutil
 
-import "context"
+import (
+	"context"
+
+	"github.com/webmeshproj/webmesh/pkg/meshnet/system/firewall"
+)
 
 // Firewall is a mock firewall.
 type Firewall struct{}
@@ -40,3 +44,18 @@ func (fw *Firewall) Clear(ctx context.Context) error {
 func (fw *Firewall) Close(ctx context.Context) error {
 	return nil
 }
+
+// Backend returns the name of the backend in use by the firewall.
+func (fw *Firewall) Backend() string {
+	return "test"
+}
+
+// ListRules returns the rules currently applied by the firewall.
+func (fw *Firewall) ListRules(ctx context.Context) ([]firewall.Rule, error) {
+	return nil, nil
+}
+
+// Reconcile converges the firewall to the desired rules.
+func (fw *Firewall) Reconcile(ctx context.Context, desired []firewall.Rule) error {
+	return nil
+}
@@ -31,6 +31,9 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
 	"github.com/libp2p/go-libp2p/core/record"
+	quic "github.com/libp2p/go-libp2p/p2p/transport/quic"
+	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
+	ws "github.com/libp2p/go-libp2p/p2p/transport/websocket"
 	"github.com/multiformats/go-multiaddr"
 	mnet "github.com/multiformats/go-multiaddr/net"
 
@@ -81,8 +84,22 @@ type HostOptions struct {
 	// NoFallbackDefaults disables the use of fallback defaults when creating
 	// the host. This is useful for testing.
 	NoFallbackDefaults bool
+	// Transports is a list of transports to build the host with. Valid values
+	// are "tcp", "quic", and "ws". If empty or nil, the default libp2p transports
+	// are used. When set and no LocalAddrs are given, the host listens on all
+	// interfaces for each of the requested transports.
+	Transports []string
 }
 
+const (
+	// TransportTCP is the TCP libp2p transport.
+	TransportTCP = "tcp"
+	// TransportQUIC is the QUIC libp2p transport.
+	TransportQUIC = "quic"
+	// TransportWebsocket is the websocket libp2p transport.
+	TransportWebsocket = "ws"
+)
+
 // MarshalJSON implements json.Marshaler.
 func (o HostOptions) MarshalJSON() ([]byte, error) {
 	return json.Marshal(map[string]any{
@@ -90,6 +107,7 @@ func (o HostOptions) MarshalJSON() ([]byte, error) {
 		"bootstrapPeers": o.BootstrapPeers,
 		"localAddrs":     o.LocalAddrs,
 		"connectTimeout": o.ConnectTimeout,
+		"transports":     o.Transports,
 	})
 }
 
@@ -98,6 +116,16 @@ func NewHost(ctx context.Context, opts HostOptions) (Host, error) {
 	if opts.Key != nil {
 		opts.Options = append(opts.Options, libp2p.Identity(opts.Key.AsIdentity()))
 	}
+	if len(opts.Transports) > 0 {
+		transports, listenAddrs, err := transportOptions(opts.Transports)
+		if err != nil {
+			return nil, err
+		}
+		opts.Options = append(opts.Options, transports...)
+		if len(opts.LocalAddrs) == 0 {
+			opts.LocalAddrs = listenAddrs
+		}
+	}
 	if len(opts.LocalAddrs) > 0 {
 		opts.Options = append(opts.Options, libp2p.ListenAddrs(opts.LocalAddrs...))
 	}
@@ -121,6 +149,37 @@ func NewHost(ctx context.Context, opts HostOptions) (Host, error) {
 	return wrapHost(host), nil
 }
 
+// transportOptions returns the libp2p options and default listen addresses
+// for the given transports.
+func transportOptions(transports []string) ([]config.Option, []multiaddr.Multiaddr, error) {
+	var opts []config.Option
+	var addrs []string
+	for _, t := range transports {
+		switch t {
+		case TransportTCP:
+			opts = append(opts, libp2p.Transport(tcp.NewTCPTransport))
+			addrs = append(addrs, "/ip4/0.0.0.0/tcp/0", "/ip6/::/tcp/0")
+		case TransportQUIC:
+			opts = append(opts, libp2p.Transport(quic.NewTransport))
+			addrs = append(addrs, "/ip4/0.0.0.0/udp/0/quic-v1", "/ip6/::/udp/0/quic-v1")
+		case TransportWebsocket:
+			opts = append(opts, libp2p.Transport(ws.New))
+			addrs = append(addrs, "/ip4/0.0.0.0/tcp/0/ws", "/ip6/::/tcp/0/ws")
+		default:
+			return nil, nil, fmt.Errorf("unsupported libp2p transport: %q", t)
+		}
+	}
+	listenAddrs := make([]multiaddr.Multiaddr, 0, len(addrs))
+	for _, addr := range addrs {
+		ma, err := multiaddr.NewMultiaddr(addr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parse listen address: %w", err)
+		}
+		listenAddrs = append(listenAddrs, ma)
+	}
+	return opts, listenAddrs, nil
+}
+
 type libp2pHost struct {
 	host      host.Host
 	liscancel func()
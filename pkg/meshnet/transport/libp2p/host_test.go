@@ -0,0 +1,61 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package libp2p
+
+import (
+	"testing"
+
+	"github.com/multiformats/go-multiaddr"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+)
+
+func TestHostTransports(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("QUIC", func(t *testing.T) {
+		t.Parallel()
+		host, err := NewHost(ctx, HostOptions{
+			Transports: []string{TransportQUIC},
+			LocalAddrs: []multiaddr.Multiaddr{multiaddr.StringCast("/ip4/127.0.0.1/udp/0/quic-v1")},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer host.Close()
+		addrs := host.Host().Addrs()
+		if len(addrs) == 0 {
+			t.Fatal("expected host to listen on at least one address")
+		}
+		for _, addr := range addrs {
+			if _, err := addr.ValueForProtocol(multiaddr.P_QUIC_V1); err != nil {
+				t.Errorf("expected a quic multiaddr, got %s", addr)
+			}
+		}
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewHost(ctx, HostOptions{
+			Transports: []string{"carrier-pigeon"},
+		})
+		if err == nil {
+			t.Fatal("expected error for unsupported transport")
+		}
+	})
+}
@@ -40,7 +40,11 @@ type RaftTransportOptions struct {
 }
 
 // NewRaftTransport creates a new TCP transport listening on the given address.
+// If leaderDialer is nil, dialing the leader always fails.
 func NewRaftTransport(leaderDialer transport.LeaderDialer, opts RaftTransportOptions) (transport.RaftTransport, error) {
+	if leaderDialer == nil {
+		leaderDialer = transport.NewNoOpLeaderDialer()
+	}
 	sl, err := newTCPStreamLayer(opts.Addr)
 	if err != nil {
 		return nil, fmt.Errorf("create TCP stream layer: %w", err)
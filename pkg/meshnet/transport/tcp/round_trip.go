@@ -18,12 +18,15 @@ package tcp
 
 import (
 	"errors"
+	"net"
+	"slices"
 	"time"
 
 	v1 "github.com/webmeshproj/api/go/v1"
 	"google.golang.org/grpc"
 
 	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/meshnet/netutil"
 	"github.com/webmeshproj/webmesh/pkg/meshnet/transport"
 )
 
@@ -37,6 +40,9 @@ type RoundTripOptions struct {
 	// AddressTimeout is the timeout for dialing each address. If not set
 	// any timeout on the context will be used.
 	AddressTimeout time.Duration
+	// Resolver is the resolver to use for looking up join addresses.
+	// If nil, the system resolver is used.
+	Resolver *net.Resolver
 }
 
 // NewJoinRoundTripper creates a new gRPC round tripper for issuing a Join Request.
@@ -63,7 +69,11 @@ func (rt *grpcRoundTripper[REQ, RESP]) RoundTrip(ctx context.Context, req *REQ)
 	var dialCtx context.Context
 	var cancel context.CancelFunc
 	var err error
-	t := NewGRPCTransport(TransportOptions{Credentials: rt.Credentials})
+	creds := rt.Credentials
+	if rt.Resolver != nil {
+		creds = append(slices.Clone(creds), grpc.WithContextDialer(rt.dialWithResolver))
+	}
+	t := NewGRPCTransport(TransportOptions{Credentials: creds})
 	for _, addr := range rt.Addrs {
 		if ctx.Err() != nil {
 			return nil, ctx.Err()
@@ -73,7 +83,7 @@ func (rt *grpcRoundTripper[REQ, RESP]) RoundTrip(ctx context.Context, req *REQ)
 		if rt.AddressTimeout > 0 {
 			dialCtx, cancel = context.WithTimeout(ctx, rt.AddressTimeout)
 		} else {
-			dialCtx = ctx
+			dialCtx, cancel = context.WithCancel(ctx)
 		}
 		var conn transport.RPCClientConn
 		conn, err = t.Dial(dialCtx, "", addr)
@@ -97,6 +107,7 @@ func (rt *grpcRoundTripper[REQ, RESP]) RoundTrip(ctx context.Context, req *REQ)
 			log.Debug("Invoke request failed", "error", err)
 			continue
 		}
+		log.Info("Request accepted by node")
 		return &resp, nil
 	}
 	if err != nil {
@@ -106,3 +117,12 @@ func (rt *grpcRoundTripper[REQ, RESP]) RoundTrip(ctx context.Context, req *REQ)
 	// We should never get here.
 	return nil, errors.New("no addresses to dial")
 }
+
+func (rt *grpcRoundTripper[REQ, RESP]) dialWithResolver(ctx context.Context, addr string) (net.Conn, error) {
+	resolved, err := netutil.ResolveTCPAddrWith(ctx, rt.Resolver, addr, 1)
+	if err != nil {
+		return nil, err
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", resolved.String())
+}
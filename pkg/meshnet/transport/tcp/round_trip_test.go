@@ -0,0 +1,75 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tcp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/logging"
+)
+
+func TestJoinRoundTripperFallthrough(t *testing.T) {
+	t.Parallel()
+	ctx := context.WithLogger(context.Background(), logging.NewLogger("", ""))
+
+	// An address that refuses connections.
+	refused, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refusedAddr := refused.Addr().String()
+	_ = refused.Close()
+
+	// A healthy server.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	v1.RegisterMembershipServer(srv, &testMembershipServer{})
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(srv.Stop)
+
+	rt := NewJoinRoundTripper(RoundTripOptions{
+		Addrs:          []string{refusedAddr, ln.Addr().String()},
+		Credentials:    []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+		AddressTimeout: time.Second,
+	})
+	defer rt.Close()
+	resp, err := rt.RoundTrip(ctx, &v1.JoinRequest{Id: "test-node"})
+	if err != nil {
+		t.Fatalf("expected join to fall through to the healthy server: %v", err)
+	}
+	if resp.GetMeshDomain() != "test-node.webmesh.internal." {
+		t.Fatalf("unexpected join response: %v", resp)
+	}
+}
+
+type testMembershipServer struct {
+	v1.UnimplementedMembershipServer
+}
+
+func (s *testMembershipServer) Join(ctx context.Context, req *v1.JoinRequest) (*v1.JoinResponse, error) {
+	return &v1.JoinResponse{MeshDomain: req.GetId() + ".webmesh.internal."}, nil
+}
@@ -79,6 +79,17 @@ type ConnectOptions struct {
 	PreferIPv6 bool
 	// Multiaddrs are the multiaddrs to advertise for this node.
 	Multiaddrs []multiaddr.Multiaddr
+	// ExpectedDomain is the mesh domain the node is expected to join. If set
+	// and the join response contains a different domain, connecting fails.
+	ExpectedDomain string
+	// ExpectedIPv4 is an IPv4 network in CIDR notation that the node's assigned
+	// IPv4 address is expected to fall within. If set and the assigned address
+	// is outside of it, connecting fails.
+	ExpectedIPv4 string
+	// OnInterfaceReady is an optional callback invoked with the resolved
+	// wireguard configuration once the connection has been established.
+	// It is purely observational and is not invoked for test nodes.
+	OnInterfaceReady func(cfg WireGuardConfig)
 }
 
 func (c ConnectOptions) MarshalJSON() ([]byte, error) {
@@ -104,6 +115,8 @@ func (c ConnectOptions) MarshalJSON() ([]byte, error) {
 		"bootstrap":          c.Bootstrap,
 		"preferIPv6":         c.PreferIPv6,
 		"multiaddrs":         c.Multiaddrs,
+		"expectedDomain":     c.ExpectedDomain,
+		"expectedIPv4":       c.ExpectedIPv4,
 	})
 }
 
@@ -219,10 +232,11 @@ func (s *meshStore) Connect(ctx context.Context, opts ConnectOptions) (err error
 	}
 	// Create the plugin manager
 	pluginopts := plugins.Options{
-		Storage:               s.Storage(),
-		Plugins:               opts.Plugins,
-		DisableDefaultIPAM:    s.opts.DisableDefaultIPAM,
-		DefaultIPAMStaticIPv4: s.opts.DefaultIPAMStaticIPv4,
+		Storage:                 s.Storage(),
+		Plugins:                 opts.Plugins,
+		DisableDefaultIPAM:      s.opts.DisableDefaultIPAM,
+		DefaultIPAMStaticIPv4:   s.opts.DefaultIPAMStaticIPv4,
+		DefaultIPAMZonePrefixes: s.opts.DefaultIPAMZonePrefixes,
 		Node: plugins.NodeConfig{
 			NodeID:      s.ID(),
 			NetworkIPv4: s.nw.NetworkV4(),
@@ -310,6 +324,9 @@ func (s *meshStore) Connect(ctx context.Context, opts ConnectOptions) (err error
 			}
 		}()
 	}
+	if opts.OnInterfaceReady != nil {
+		opts.OnInterfaceReady(newWireGuardConfig(log, s.nw.WireGuard(), s.nw.NetworkV4(), s.nw.NetworkV6()))
+	}
 	return nil
 }
 
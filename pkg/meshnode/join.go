@@ -72,12 +72,15 @@ func (s *meshStore) join(ctx context.Context, opts ConnectOptions) error {
 func (s *meshStore) handleJoinResponse(ctx context.Context, opts ConnectOptions, resp *v1.JoinResponse) error {
 	log := context.LoggerFrom(ctx)
 	log.Debug("Received join response", slog.Any("resp", resp))
+	err := checkJoinExpectations(opts, resp)
+	if err != nil {
+		return err
+	}
 	s.meshDomain = resp.GetMeshDomain()
 	if !strings.HasSuffix(s.meshDomain, ".") {
 		s.meshDomain += "."
 	}
 	var addressv4, addressv6, networkv4, networkv6 netip.Prefix
-	var err error
 	// We always parse addresses and let the net manager decide what to use
 	if resp.GetAddressIPv4() != "" {
 		addressv4, err = netip.ParsePrefix(resp.GetAddressIPv4())
@@ -152,6 +155,35 @@ func (s *meshStore) handleJoinResponse(ctx context.Context, opts ConnectOptions,
 	return nil
 }
 
+// checkJoinExpectations verifies a join response against the expectations
+// set in the connect options.
+func checkJoinExpectations(opts ConnectOptions, resp *v1.JoinResponse) error {
+	if opts.ExpectedDomain != "" {
+		expected := strings.TrimSuffix(opts.ExpectedDomain, ".")
+		joined := strings.TrimSuffix(resp.GetMeshDomain(), ".")
+		if expected != joined {
+			return fmt.Errorf("joined mesh domain %q does not match expected domain %q", joined, expected)
+		}
+	}
+	if opts.ExpectedIPv4 != "" {
+		expected, err := netip.ParsePrefix(opts.ExpectedIPv4)
+		if err != nil {
+			return fmt.Errorf("parse expected ipv4 network: %w", err)
+		}
+		if resp.GetAddressIPv4() == "" {
+			return fmt.Errorf("expected an ipv4 address in %s but none was assigned", expected)
+		}
+		assigned, err := netip.ParsePrefix(resp.GetAddressIPv4())
+		if err != nil {
+			return fmt.Errorf("parse ipv4 address: %w", err)
+		}
+		if !expected.Contains(assigned.Addr()) {
+			return fmt.Errorf("assigned ipv4 address %s is not in expected network %s", assigned.Addr(), expected)
+		}
+	}
+	return nil
+}
+
 func (s *meshStore) newJoinRequest(opts ConnectOptions, encodedKey string) *v1.JoinRequest {
 	if opts.GRPCAdvertisePort <= 0 {
 		// Assume the default port.
@@ -0,0 +1,125 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package meshnode
+
+import (
+	"testing"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+)
+
+func TestCheckJoinExpectations(t *testing.T) {
+	t.Parallel()
+	resp := &v1.JoinResponse{
+		MeshDomain:  "webmesh.internal.",
+		AddressIPv4: "172.16.0.2/32",
+		NetworkIPv4: "172.16.0.0/12",
+	}
+	tc := []struct {
+		name    string
+		opts    ConnectOptions
+		resp    *v1.JoinResponse
+		wantErr bool
+	}{
+		{
+			name: "NoExpectations",
+			opts: ConnectOptions{},
+			resp: resp,
+		},
+		{
+			name: "MatchingDomain",
+			opts: ConnectOptions{ExpectedDomain: "webmesh.internal"},
+			resp: resp,
+		},
+		{
+			name:    "MismatchedDomain",
+			opts:    ConnectOptions{ExpectedDomain: "other.internal"},
+			resp:    resp,
+			wantErr: true,
+		},
+		{
+			name: "MatchingIPv4",
+			opts: ConnectOptions{ExpectedIPv4: "172.16.0.0/24"},
+			resp: resp,
+		},
+		{
+			name:    "MismatchedIPv4",
+			opts:    ConnectOptions{ExpectedIPv4: "10.0.0.0/8"},
+			resp:    resp,
+			wantErr: true,
+		},
+		{
+			name:    "MissingIPv4",
+			opts:    ConnectOptions{ExpectedIPv4: "172.16.0.0/12"},
+			resp:    &v1.JoinResponse{MeshDomain: "webmesh.internal."},
+			wantErr: true,
+		},
+		{
+			name:    "InvalidExpectedIPv4",
+			opts:    ConnectOptions{ExpectedIPv4: "not-a-network"},
+			resp:    resp,
+			wantErr: true,
+		},
+		{
+			name: "MatchingBoth",
+			opts: ConnectOptions{ExpectedDomain: "webmesh.internal.", ExpectedIPv4: "172.16.0.0/12"},
+			resp: resp,
+		},
+	}
+	for _, tt := range tc {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := checkJoinExpectations(tt.opts, tt.resp)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewJoinRequestSuffrage(t *testing.T) {
+	t.Parallel()
+	st := New(Config{NodeID: "test-node"}).(*meshStore)
+	tc := []struct {
+		name         string
+		opts         ConnectOptions
+		wantVoter    bool
+		wantObserver bool
+	}{
+		{name: "Default", opts: ConnectOptions{}},
+		{name: "Voter", opts: ConnectOptions{RequestVote: true}, wantVoter: true},
+		{name: "Observer", opts: ConnectOptions{RequestObserver: true}, wantObserver: true},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			req := st.newJoinRequest(tt.opts, "key")
+			if req.GetId() != "test-node" {
+				t.Errorf("expected id test-node, got %s", req.GetId())
+			}
+			if req.GetAsVoter() != tt.wantVoter {
+				t.Errorf("expected as voter %v, got %v", tt.wantVoter, req.GetAsVoter())
+			}
+			if req.GetAsObserver() != tt.wantObserver {
+				t.Errorf("expected as observer %v, got %v", tt.wantObserver, req.GetAsObserver())
+			}
+		})
+	}
+}
@@ -72,7 +72,12 @@ type Node interface {
 	Connect(ctx context.Context, opts ConnectOptions) error
 	// Ready returns a channel that will be closed when the mesh is ready.
 	// Ready is defined as having a leader and knowing its address.
+	// The channel is also closed if the mesh is closed before becoming
+	// ready, so callers should check ReadyErr after it closes.
 	Ready() <-chan struct{}
+	// ReadyErr returns nil if the mesh is ready, otherwise the reason
+	// it is not.
+	ReadyErr() error
 	// Close closes the connection to the mesh and shuts down the storage.
 	Close(ctx context.Context) error
 	// Credentials returns the gRPC credentials to use for dialing the mesh.
@@ -123,6 +128,8 @@ type Config struct {
 	DisableDefaultIPAM bool
 	// DefaultIPAMStaticIPv4 is a map of node names to IPv4 addresses.
 	DefaultIPAMStaticIPv4 map[string]string
+	// DefaultIPAMZonePrefixes is a map of zone awareness IDs to IPv6 prefixes.
+	DefaultIPAMZonePrefixes map[string]string
 }
 
 // New creates a new Mesh. You must call Open() on the returned mesh
@@ -211,31 +218,42 @@ func (s *meshStore) Plugins() plugins.Manager {
 }
 
 // Ready returns a channel that will be closed when the mesh is ready.
-// Ready is defined as having a leader and knowing its address.
+// Ready is defined as having a leader and knowing its address. The
+// channel is also closed if the mesh is closed before becoming ready.
 func (s *meshStore) Ready() <-chan struct{} {
 	ch := make(chan struct{})
 	go func() {
 		defer close(ch)
 		for {
-			ctx, cancel := context.WithTimeout(context.WithLogger(context.Background(), s.log), 5*time.Second)
-			leader, err := s.getLeader(ctx)
-			cancel()
-			if err != nil {
-				s.log.Debug("Unable to fetch current leader", slog.String("leader", leader.String()), slog.String("error", err.Error()))
-				time.Sleep(time.Millisecond * 500)
-				continue
+			err := s.ReadyErr()
+			if err == nil {
+				return
 			}
-			if leader.GetId() == "" || leader.GetAddress() == "" {
-				s.log.Debug("Leader not ready", slog.String("leader", leader.String()))
-				time.Sleep(time.Millisecond * 500)
-				continue
+			s.log.Debug("Mesh not ready", slog.String("error", err.Error()))
+			select {
+			case <-s.closec:
+				return
+			case <-time.After(time.Millisecond * 500):
 			}
-			return
 		}
 	}()
 	return ch
 }
 
+// ReadyErr returns nil if the mesh is ready, otherwise the reason it is not.
+func (s *meshStore) ReadyErr() error {
+	ctx, cancel := context.WithTimeout(context.WithLogger(context.Background(), s.log), 5*time.Second)
+	defer cancel()
+	leader, err := s.getLeader(ctx)
+	if err != nil {
+		return err
+	}
+	if leader.GetId() == "" || leader.GetAddress() == "" {
+		return fmt.Errorf("%w: leader not ready: %s", ErrNoLeader, leader.String())
+	}
+	return nil
+}
+
 // Leader returns the current network leader.
 func (s *meshStore) LeaderID() (types.NodeID, error) {
 	leader, err := s.getLeader(context.WithLogger(context.Background(), s.log))
@@ -0,0 +1,55 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package meshnode
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReady(t *testing.T) {
+	t.Parallel()
+
+	t.Run("NotOpen", func(t *testing.T) {
+		t.Parallel()
+		st := New(Config{NodeID: "not-open"})
+		if err := st.ReadyErr(); !errors.Is(err, ErrNotOpen) {
+			t.Fatalf("expected ErrNotOpen, got %v", err)
+		}
+	})
+
+	t.Run("SingleNode", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		st, err := NewSingleNodeTestMesh(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer st.Close(context.Background())
+		select {
+		case <-st.Ready():
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for the mesh to be ready")
+		}
+		if err := st.ReadyErr(); err != nil {
+			t.Fatalf("expected no ready error, got %v", err)
+		}
+	})
+}
@@ -215,6 +215,9 @@ func (t *TestNode) Connect(ctx context.Context, opts ConnectOptions) error {
 	if err != nil {
 		return fmt.Errorf("mock node join request: %w", err)
 	}
+	if err := checkJoinExpectations(opts, resp); err != nil {
+		return fmt.Errorf("mock node join request: %w", err)
+	}
 	var addrv4, addrv6, netv4, netv6 netip.Prefix
 	if !t.cfg.DisableIPv4 {
 		if resp.GetAddressIPv4() != "" {
@@ -284,6 +287,14 @@ func (t *TestNode) Ready() <-chan struct{} {
 	return ch
 }
 
+// ReadyErr returns nil if the mesh is ready, otherwise the reason it is not.
+func (t *TestNode) ReadyErr() error {
+	if !t.Started() {
+		return ErrNotOpen
+	}
+	return nil
+}
+
 // Close closes the connection to the mesh and shuts down the storage.
 func (t *TestNode) Close(ctx context.Context) error {
 	t.mu.Lock()
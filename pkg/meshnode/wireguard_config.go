@@ -0,0 +1,68 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package meshnode
+
+import (
+	"log/slog"
+	"net/netip"
+	"sort"
+
+	"github.com/webmeshproj/webmesh/pkg/meshnet/wireguard"
+)
+
+// WireGuardConfig is the resolved configuration of the wireguard interface
+// after connecting to the mesh. It is purely informational and modifying it
+// has no effect on the interface.
+type WireGuardConfig struct {
+	// InterfaceName is the real name of the wireguard interface.
+	InterfaceName string
+	// ListenPort is the port the wireguard interface is listening on.
+	ListenPort int
+	// AddressV4 is the private IPv4 address of the interface.
+	AddressV4 netip.Prefix
+	// AddressV6 is the private IPv6 address of the interface.
+	AddressV6 netip.Prefix
+	// NetworkV4 is the IPv4 network of the mesh.
+	NetworkV4 netip.Prefix
+	// NetworkV6 is the IPv6 network of the mesh.
+	NetworkV6 netip.Prefix
+	// Peers are the peers configured on the interface sorted by ID.
+	Peers []wireguard.Peer
+}
+
+// newWireGuardConfig builds a WireGuardConfig from the given interface and networks.
+func newWireGuardConfig(log *slog.Logger, wg wireguard.Interface, networkV4, networkV6 netip.Prefix) WireGuardConfig {
+	cfg := WireGuardConfig{
+		InterfaceName: wg.Name(),
+		AddressV4:     wg.AddressV4(),
+		AddressV6:     wg.AddressV6(),
+		NetworkV4:     networkV4,
+		NetworkV6:     networkV6,
+	}
+	port, err := wg.ListenPort()
+	if err != nil {
+		log.Warn("Failed to get wireguard listen port", slog.String("error", err.Error()))
+	}
+	cfg.ListenPort = port
+	for _, peer := range wg.Peers() {
+		cfg.Peers = append(cfg.Peers, peer)
+	}
+	sort.Slice(cfg.Peers, func(i, j int) bool {
+		return cfg.Peers[i].ID < cfg.Peers[j].ID
+	})
+	return cfg
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package meshnode
+
+import (
+	"io"
+	"log/slog"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/webmeshproj/webmesh/pkg/meshnet/wireguard"
+)
+
+type stubWireGuard struct {
+	wireguard.Interface
+	name       string
+	addrv4     netip.Prefix
+	addrv6     netip.Prefix
+	listenPort int
+	peers      map[string]wireguard.Peer
+}
+
+func (s *stubWireGuard) Name() string                     { return s.name }
+func (s *stubWireGuard) AddressV4() netip.Prefix          { return s.addrv4 }
+func (s *stubWireGuard) AddressV6() netip.Prefix          { return s.addrv6 }
+func (s *stubWireGuard) ListenPort() (int, error)         { return s.listenPort, nil }
+func (s *stubWireGuard) Peers() map[string]wireguard.Peer { return s.peers }
+
+func TestOnInterfaceReady(t *testing.T) {
+	t.Parallel()
+	wg := &stubWireGuard{
+		name:       "webmesh0",
+		addrv4:     netip.MustParsePrefix("172.16.0.1/32"),
+		addrv6:     netip.MustParsePrefix("fd00:dead:beef::1/112"),
+		listenPort: 51820,
+		peers: map[string]wireguard.Peer{
+			"peer-b": {ID: "peer-b", AllowedIPs: []netip.Prefix{netip.MustParsePrefix("172.16.0.3/32")}},
+			"peer-a": {ID: "peer-a", AllowedIPs: []netip.Prefix{netip.MustParsePrefix("172.16.0.2/32")}},
+		},
+	}
+	networkV4 := netip.MustParsePrefix("172.16.0.0/12")
+	networkV6 := netip.MustParsePrefix("fd00:dead:beef::/48")
+	var got WireGuardConfig
+	opts := ConnectOptions{
+		OnInterfaceReady: func(cfg WireGuardConfig) { got = cfg },
+	}
+	opts.OnInterfaceReady(newWireGuardConfig(slog.New(slog.NewTextHandler(io.Discard, nil)), wg, networkV4, networkV6))
+	want := WireGuardConfig{
+		InterfaceName: "webmesh0",
+		ListenPort:    51820,
+		AddressV4:     wg.addrv4,
+		AddressV6:     wg.addrv6,
+		NetworkV4:     networkV4,
+		NetworkV6:     networkV6,
+		Peers:         []wireguard.Peer{wg.peers["peer-a"], wg.peers["peer-b"]},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected config %+v, got %+v", want, got)
+	}
+}
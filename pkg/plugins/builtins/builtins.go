@@ -18,8 +18,11 @@ limitations under the License.
 package builtins
 
 import (
+	"fmt"
+
 	"github.com/spf13/pflag"
 
+	"github.com/webmeshproj/webmesh/pkg/plugins"
 	"github.com/webmeshproj/webmesh/pkg/plugins/builtins/basicauth"
 	"github.com/webmeshproj/webmesh/pkg/plugins/builtins/debug"
 	"github.com/webmeshproj/webmesh/pkg/plugins/builtins/idauth"
@@ -62,6 +65,32 @@ func IsBuiltIn(pluginName string) bool {
 	return ok
 }
 
+// IPAMPluginName is the name used to validate configurations for the
+// built-in IPAM plugin.
+const IPAMPluginName = "ipam"
+
+// ValidateConfig validates the configuration for the named built-in plugin
+// without running it. Unknown keys are rejected and any plugin specific
+// validation is performed. No querier or other mesh resources are required.
+func ValidateConfig(name string, cfg map[string]any) error {
+	if name == IPAMPluginName {
+		return plugins.ValidateIPAMConfig(cfg)
+	}
+	conf, ok := NewPluginConfigs()[name]
+	if !ok {
+		return fmt.Errorf("unknown built-in plugin: %s", name)
+	}
+	if err := plugins.DecodeConfig(cfg, conf); err != nil {
+		return fmt.Errorf("failed to decode %s configuration: %w", name, err)
+	}
+	if v, ok := conf.(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("invalid %s configuration: %w", name, err)
+		}
+	}
+	return nil
+}
+
 // FlagBinder is an interface implemented by the built-in plugin
 // option sets to bind them to the given flag set.
 type FlagBinder interface {
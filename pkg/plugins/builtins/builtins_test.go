@@ -0,0 +1,88 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builtins
+
+import "testing"
+
+func TestValidateConfig(t *testing.T) {
+	t.Parallel()
+	tc := []struct {
+		name    string
+		plugin  string
+		config  map[string]any
+		wantErr bool
+	}{
+		{
+			name:   "ValidDebug",
+			plugin: "debug",
+			config: map[string]any{
+				"listen-address":    "localhost:6060",
+				"enable-db-querier": true,
+			},
+		},
+		{
+			name:    "DebugUnknownKey",
+			plugin:  "debug",
+			config:  map[string]any{"listen-adress": "localhost:6060"},
+			wantErr: true,
+		},
+		{
+			name:    "DebugNothingEnabled",
+			plugin:  "debug",
+			config:  map[string]any{"disable-pprof": true},
+			wantErr: true,
+		},
+		{
+			name:   "ValidIPAM",
+			plugin: IPAMPluginName,
+			config: map[string]any{
+				"static-ipv4": map[string]any{"foo": "10.0.0.5", "bar": "10.0.0.6/32"},
+			},
+		},
+		{
+			name:    "IPAMUnknownKey",
+			plugin:  IPAMPluginName,
+			config:  map[string]any{"static-ip": map[string]any{"foo": "10.0.0.5"}},
+			wantErr: true,
+		},
+		{
+			name:   "IPAMDuplicateStaticAddress",
+			plugin: IPAMPluginName,
+			config: map[string]any{
+				"static-ipv4": map[string]any{"foo": "10.0.0.5", "bar": "10.0.0.5/32"},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "UnknownPlugin",
+			plugin:  "not-a-plugin",
+			config:  map[string]any{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConfig(tt.plugin, tt.config)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
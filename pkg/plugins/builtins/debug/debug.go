@@ -20,13 +20,23 @@ package debug
 
 import (
 	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
 	"net/http/pprof"
+	"net/netip"
+	"path"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/pflag"
@@ -34,20 +44,60 @@ import (
 	"google.golang.org/protobuf/types/known/emptypb"
 
 	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/plugins"
 	"github.com/webmeshproj/webmesh/pkg/storage"
+	"github.com/webmeshproj/webmesh/pkg/storage/meshdb/networking"
+	"github.com/webmeshproj/webmesh/pkg/storage/meshdb/rbac"
 	"github.com/webmeshproj/webmesh/pkg/storage/rpcdb"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
 	"github.com/webmeshproj/webmesh/pkg/version"
 )
 
+const (
+	// listenRetryTimeout is how long to keep retrying to bind the listen
+	// address when it is in use.
+	listenRetryTimeout = 30 * time.Second
+	// listenRetryMaxBackoff is the maximum time to wait between attempts
+	// to bind the listen address.
+	listenRetryMaxBackoff = 2 * time.Second
+	// DefaultMaxDBValueSize is the default maximum size of a value returned
+	// by the database querier.
+	DefaultMaxDBValueSize = 1024 * 1024
+	// dbValueChunkSize is the size of the chunks values are written in, with
+	// a flush after each one.
+	dbValueChunkSize = 32 * 1024
+	// dbListFlushInterval is the number of keys written between flushes
+	// when streaming key listings.
+	dbListFlushInterval = 256
+	// DefaultMaxConcurrentQueries is the default maximum number of database
+	// querier requests served at once.
+	DefaultMaxConcurrentQueries = 8
+	// DefaultMaxProfileSeconds is the default maximum duration in seconds a
+	// client may request for a pprof profile or trace.
+	DefaultMaxProfileSeconds = 30
+	// DefaultShutdownTimeout is the default time to wait for in-flight
+	// requests to finish before the debug server is forcibly closed.
+	DefaultShutdownTimeout = 10 * time.Second
+	// defaultQuerierCloseTimeout is how long Close waits for the database
+	// querier to close before giving up on it.
+	defaultQuerierCloseTimeout = 5 * time.Second
+	// redactedValue is returned in place of values under a redacted prefix.
+	redactedValue = "<redacted>"
+)
+
 // Plugin is the debug plugin.
 type Plugin struct {
 	v1.UnimplementedPluginServer
 	v1.UnimplementedStorageQuerierPluginServer
 
-	data    storage.MeshStorage
-	datamux sync.Mutex
-	closec  chan struct{}
-	servec  chan struct{}
+	data      storage.MeshStorage
+	streams   *rpcdb.QueryStreams
+	consensus storage.Consensus
+	datamux   sync.Mutex
+	closec    chan struct{}
+	servec    chan struct{}
+	// querierCloseTimeout overrides defaultQuerierCloseTimeout when set.
+	querierCloseTimeout time.Duration
 }
 
 // Config are the options for the debug plugin.
@@ -60,28 +110,142 @@ type Config struct {
 	// DisablePProf disables pprof.
 	DisablePProf bool `mapstructure:"disable-pprof" koanf:"disable-pprof"`
 	// PProfProfiles is the list of profiles to enable for pprof.
-	// An empty list enables all profiles. Each will be available at
-	// /<path-prefix>/pprof/<profile>.
+	// An empty list enables all named profiles. Each will be available at
+	// /<path-prefix>/pprof/<profile>. The CPU profile ("profile") and execution
+	// trace ("trace") are only enabled when listed explicitly.
 	PprofProfiles string `mapstructure:"pprof-profiles" koanf:"pprof-profiles"`
+	// PprofProfileTokens maps pprof profiles to a bearer token required to
+	// access them. Profiles without an entry remain open.
+	PprofProfileTokens map[string]string `mapstructure:"pprof-profile-tokens" koanf:"pprof-profile-tokens"`
 	// EnableDBQuerier enables the database querier.
 	EnableDBQuerier bool `mapstructure:"enable-db-querier" koanf:"enable-db-querier"`
+	// MaxDBValueSize is the maximum size of a value returned by the database
+	// querier. Larger values are rejected unless raw=true is requested. A value
+	// less than or equal to zero disables the limit.
+	MaxDBValueSize int `mapstructure:"max-db-value-size" koanf:"max-db-value-size"`
+	// BindToMeshOnly binds the debug server exclusively to the node's mesh
+	// address, using the port from ListenAddress, so that only peers in the
+	// mesh can reach it.
+	BindToMeshOnly bool `mapstructure:"bind-to-mesh-only" koanf:"bind-to-mesh-only"`
+	// MaxConcurrentQueries is the maximum number of database querier requests
+	// served at once. Requests over the limit are rejected with a 429 instead
+	// of queueing. A value less than or equal to zero disables the limit.
+	MaxConcurrentQueries int `mapstructure:"max-concurrent-queries" koanf:"max-concurrent-queries"`
+	// MaxProfileSeconds is the maximum duration in seconds a client may request
+	// with the seconds parameter of a pprof endpoint. Longer requests are clamped
+	// so a profile cannot hold the server open indefinitely. A value less than or
+	// equal to zero disables the limit.
+	MaxProfileSeconds int `mapstructure:"max-profile-seconds" koanf:"max-profile-seconds"`
+	// RedactPrefixes is a list of key prefixes whose values are replaced with
+	// "<redacted>" by the database querier. The keys themselves are still listed.
+	RedactPrefixes []string `mapstructure:"redact-prefixes" koanf:"redact-prefixes"`
+	// ShutdownTimeout is how long to wait for in-flight requests to finish
+	// when the plugin is closed. Remaining connections are forcibly closed
+	// once it elapses. A value less than or equal to zero waits indefinitely.
+	ShutdownTimeout time.Duration `mapstructure:"shutdown-timeout" koanf:"shutdown-timeout"`
+	// EnableGC enables an endpoint at /<path-prefix>/gc that forces a garbage
+	// collection and returns memory statistics from before and after it.
+	EnableGC bool `mapstructure:"enable-gc" koanf:"enable-gc"`
+	// GCToken is the bearer token required to access the gc endpoint. It must
+	// be set when EnableGC is true.
+	GCToken string `mapstructure:"gc-token" koanf:"gc-token"`
+	// EnableACLEval enables an endpoint at /<path-prefix>/acl/eval that evaluates
+	// a flow against the network ACLs. It requires the database querier.
+	EnableACLEval bool `mapstructure:"enable-acl-eval" koanf:"enable-acl-eval"`
+	// ACLEvalToken is the bearer token required to access the acl eval endpoint.
+	// It must be set when EnableACLEval is true.
+	ACLEvalToken string `mapstructure:"acl-eval-token" koanf:"acl-eval-token"`
+	// LingerOnClose keeps the debug server running after the plugin is closed
+	// so it stays available for diagnostics until the process exits. The
+	// database querier is still closed. Lingering servers are shut down by
+	// ShutdownLingering.
+	LingerOnClose bool `mapstructure:"linger-on-close" koanf:"linger-on-close"`
 }
 
 // DefaultOptions returns the default options for the plugin.
 func (c *Config) DefaultOptions() *Config {
 	return &Config{
-		ListenAddress: "localhost:6060",
-		PathPrefix:    "/debug",
+		ListenAddress:        "localhost:6060",
+		PathPrefix:           "/debug",
+		MaxDBValueSize:       DefaultMaxDBValueSize,
+		MaxConcurrentQueries: DefaultMaxConcurrentQueries,
+		MaxProfileSeconds:    DefaultMaxProfileSeconds,
+		ShutdownTimeout:      DefaultShutdownTimeout,
+	}
+}
+
+// Validate checks that the options describe a usable debug server.
+func (c *Config) Validate() error {
+	if c.DisablePProf && !c.EnableDBQuerier && !c.EnableGC {
+		return fmt.Errorf("pprof, db querier, and gc are all disabled")
+	}
+	if c.EnableGC && c.GCToken == "" {
+		return fmt.Errorf("gc-token is required when gc is enabled")
+	}
+	if c.EnableACLEval && !c.EnableDBQuerier {
+		return fmt.Errorf("enable-acl-eval requires the db querier to be enabled")
+	}
+	if c.EnableACLEval && c.ACLEvalToken == "" {
+		return fmt.Errorf("acl-eval-token is required when acl eval is enabled")
+	}
+	if err := validatePathPrefix(c.PathPrefix); err != nil {
+		return fmt.Errorf("invalid path-prefix: %w", err)
+	}
+	return nil
+}
+
+// ErrQuerierNotGranted is returned by Configure when the database querier is
+// enabled but the manager did not grant the STORAGE_QUERIER capability.
+var ErrQuerierNotGranted = errors.New("enable-db-querier requires the STORAGE_QUERIER capability, which was not granted")
+
+// reservedPathSegments are the path segments used by the routes registered
+// under the path prefix. The prefix may not contain them, or it would overlap
+// with those routes.
+var reservedPathSegments = []string{"pprof", "db", "raft", "gc", "acl"}
+
+// validatePathPrefix checks that the path prefix is a clean absolute path that
+// is safe to register routes under. An empty prefix serves from the root.
+func validatePathPrefix(prefix string) error {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return nil
+	}
+	if !strings.HasPrefix(prefix, "/") {
+		return fmt.Errorf("%q must be an absolute path", prefix)
+	}
+	if path.Clean(prefix) != prefix {
+		return fmt.Errorf("%q is not a clean path", prefix)
+	}
+	if strings.ContainsAny(prefix, "{}?#* \t") {
+		return fmt.Errorf("%q contains characters not allowed in a route", prefix)
 	}
+	for _, segment := range strings.Split(prefix[1:], "/") {
+		if slices.Contains(reservedPathSegments, segment) {
+			return fmt.Errorf("%q contains the reserved segment %q", prefix, segment)
+		}
+	}
+	return nil
 }
 
 func (c *Config) AsMapStructure() map[string]any {
 	return map[string]any{
-		"listen-address":    c.ListenAddress,
-		"path-prefix":       c.PathPrefix,
-		"disable-pprof":     c.DisablePProf,
-		"pprof-profiles":    c.PprofProfiles,
-		"enable-db-querier": c.EnableDBQuerier,
+		"listen-address":         c.ListenAddress,
+		"path-prefix":            c.PathPrefix,
+		"disable-pprof":          c.DisablePProf,
+		"pprof-profiles":         c.PprofProfiles,
+		"pprof-profile-tokens":   c.PprofProfileTokens,
+		"enable-db-querier":      c.EnableDBQuerier,
+		"max-db-value-size":      c.MaxDBValueSize,
+		"bind-to-mesh-only":      c.BindToMeshOnly,
+		"max-concurrent-queries": c.MaxConcurrentQueries,
+		"max-profile-seconds":    c.MaxProfileSeconds,
+		"redact-prefixes":        c.RedactPrefixes,
+		"shutdown-timeout":       c.ShutdownTimeout,
+		"enable-gc":              c.EnableGC,
+		"gc-token":               c.GCToken,
+		"enable-acl-eval":        c.EnableACLEval,
+		"acl-eval-token":         c.ACLEvalToken,
+		"linger-on-close":        c.LingerOnClose,
 	}
 }
 
@@ -95,14 +259,30 @@ func (o *Config) BindFlags(prefix string, fs *pflag.FlagSet) {
 	fs.StringVar(&o.PathPrefix, prefix+"path-prefix", "/debug", "Path prefix to use for the debug server")
 	fs.BoolVar(&o.DisablePProf, prefix+"disable-pprof", o.DisablePProf, "Disable pprof")
 	fs.StringVar(&o.PprofProfiles, prefix+"pprof-profiles", "", "Pprof profiles to enable (default: all)")
+	fs.StringToStringVar(&o.PprofProfileTokens, prefix+"pprof-profile-tokens", nil, "Bearer tokens required to access individual pprof profiles (profile=token)")
 	fs.BoolVar(&o.EnableDBQuerier, prefix+"enable-db-querier", o.EnableDBQuerier, "Enable database querier")
+	fs.IntVar(&o.MaxDBValueSize, prefix+"max-db-value-size", DefaultMaxDBValueSize, "Maximum size of a database value to return unless raw=true is requested (0 for no limit)")
+	fs.BoolVar(&o.BindToMeshOnly, prefix+"bind-to-mesh-only", o.BindToMeshOnly, "Bind the debug server only to the node's mesh address")
+	fs.IntVar(&o.MaxConcurrentQueries, prefix+"max-concurrent-queries", DefaultMaxConcurrentQueries, "Maximum number of concurrent database querier requests (0 for no limit)")
+	fs.IntVar(&o.MaxProfileSeconds, prefix+"max-profile-seconds", DefaultMaxProfileSeconds, "Maximum duration in seconds of a requested pprof profile or trace (0 for no limit)")
+	fs.StringSliceVar(&o.RedactPrefixes, prefix+"redact-prefixes", nil, "Key prefixes whose values are redacted by the database querier")
+	fs.BoolVar(&o.EnableGC, prefix+"enable-gc", o.EnableGC, "Enable the endpoint that forces a garbage collection and returns memory stats")
+	fs.StringVar(&o.GCToken, prefix+"gc-token", "", "Bearer token required to access the gc endpoint")
+	fs.BoolVar(&o.EnableACLEval, prefix+"enable-acl-eval", o.EnableACLEval, "Enable the endpoint that evaluates a flow against the network ACLs (requires the db querier)")
+	fs.StringVar(&o.ACLEvalToken, prefix+"acl-eval-token", "", "Bearer token required to access the acl eval endpoint")
+	fs.BoolVar(&o.LingerOnClose, prefix+"linger-on-close", o.LingerOnClose, "Keep the debug server running after the plugin is closed until the process exits")
+	fs.DurationVar(&o.ShutdownTimeout, prefix+"shutdown-timeout", DefaultShutdownTimeout, "Time to wait for in-flight requests before forcibly closing the debug server (0 to wait indefinitely)")
 }
 
 // NewDefaultOptions returns the default options for the debug plugin.
 func NewDefaultOptions() Config {
 	return Config{
-		ListenAddress: "localhost:6060",
-		PathPrefix:    "/debug",
+		ListenAddress:        "localhost:6060",
+		PathPrefix:           "/debug",
+		MaxDBValueSize:       DefaultMaxDBValueSize,
+		MaxConcurrentQueries: DefaultMaxConcurrentQueries,
+		MaxProfileSeconds:    DefaultMaxProfileSeconds,
+		ShutdownTimeout:      DefaultShutdownTimeout,
 	}
 }
 
@@ -120,27 +300,47 @@ func (p *Plugin) GetInfo(context.Context, *emptypb.Empty) (*v1.PluginInfo, error
 
 // Configure configures the plugin.
 func (p *Plugin) Configure(ctx context.Context, req *v1.PluginConfiguration) (*emptypb.Empty, error) {
-	p.closec = make(chan struct{})
-	p.servec = make(chan struct{})
 	opts := NewDefaultOptions()
-	cfg := req.GetConfig().AsMap()
+	cfg, granted, negotiated, err := plugins.SplitConfig(req)
+	if err != nil {
+		return nil, err
+	}
 	if len(cfg) > 0 {
-		err := mapstructure.Decode(cfg, &opts)
+		err := plugins.DecodeConfig(cfg, &opts)
 		if err != nil {
 			return nil, fmt.Errorf("failed to decode configuration: %w", err)
 		}
 	}
-	if opts.DisablePProf && !opts.EnableDBQuerier {
-		return nil, fmt.Errorf("both pprof and db querier are disabled")
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if negotiated && opts.EnableDBQuerier && !granted.Has(v1.PluginInfo_STORAGE_QUERIER) {
+		return nil, ErrQuerierNotGranted
+	}
+	if opts.BindToMeshOnly {
+		addr, err := meshListenAddress(opts.ListenAddress, req.GetNodeConfig())
+		if err != nil {
+			return nil, err
+		}
+		opts.ListenAddress = addr
 	}
+	// A server left running by a previous configuration is replaced.
+	stopLingering(p)
+	p.closec = make(chan struct{})
+	p.servec = make(chan struct{})
 	go p.serve(opts)
 	return &emptypb.Empty{}, nil
 }
 
-// InjectQuerier injects the querier.
+// InjectQuerier injects the querier. The manager injects a new stream when
+// the previous one fails, and queries move over to it.
 func (p *Plugin) InjectQuerier(srv v1.StorageQuerierPlugin_InjectQuerierServer) error {
 	p.datamux.Lock()
-	p.data = rpcdb.OpenKVServer(srv)
+	if p.data == nil {
+		p.streams = rpcdb.NewQueryStreams()
+		p.data = rpcdb.OpenKVStreams(p.streams)
+	}
+	p.streams.Inject(srv)
 	p.datamux.Unlock()
 	select {
 	case <-p.closec:
@@ -150,21 +350,178 @@ func (p *Plugin) InjectQuerier(srv v1.StorageQuerierPlugin_InjectQuerierServer)
 	}
 }
 
-// Close closes the plugin.
+// InjectConsensus injects the consensus of the storage provider. It is only
+// available when the plugin runs in-process with the node.
+func (p *Plugin) InjectConsensus(consensus storage.Consensus) {
+	p.datamux.Lock()
+	defer p.datamux.Unlock()
+	p.consensus = consensus
+}
+
+// Close closes the plugin. It is safe to call more than once and before the
+// plugin was configured. If the database querier does not close in time it is
+// abandoned with a warning so shutdown is not held up. When LingerOnClose is
+// set the debug server is left running, see ShutdownLingering.
 func (p *Plugin) Close(ctx context.Context, req *emptypb.Empty) (*emptypb.Empty, error) {
 	p.datamux.Lock()
 	defer p.datamux.Unlock()
-	close(p.closec)
-	<-p.servec
-	if p.data != nil {
-		return &emptypb.Empty{}, p.data.Close()
+	if p.closec != nil {
+		select {
+		case <-p.closec:
+		default:
+			close(p.closec)
+			<-p.servec
+		}
+	}
+	if p.data == nil {
+		return &emptypb.Empty{}, nil
+	}
+	data := p.data
+	p.data = nil
+	return &emptypb.Empty{}, p.closeQuerier(ctx, data)
+}
+
+// closeQuerier closes the database querier, returning early with a warning
+// if it does not close within the querier close timeout.
+func (p *Plugin) closeQuerier(ctx context.Context, data storage.MeshStorage) error {
+	timeout := p.querierCloseTimeout
+	if timeout <= 0 {
+		timeout = defaultQuerierCloseTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	errs := make(chan error, 1)
+	go func() { errs <- data.Close() }()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		slog.Default().With("plugin", "debug").Warn("Timed out closing the database querier", "timeout", timeout.String())
+		return nil
 	}
-	return &emptypb.Empty{}, nil
 }
 
 func (p *Plugin) serve(opts Config) {
 	defer close(p.servec)
 	log := slog.Default().With("plugin", "debug")
+	server := &http.Server{
+		Addr:    opts.ListenAddress,
+		Handler: p.newHandler(log, opts),
+		BaseContext: func(_ net.Listener) context.Context {
+			return context.WithLogger(context.Background(), log)
+		},
+	}
+	go func() {
+		log.Info("Starting debug server", "listen-address", opts.ListenAddress)
+		ln, err := p.listen(log, opts.ListenAddress)
+		if err != nil {
+			log.Error("Error binding debug server", "error", err.Error())
+			return
+		}
+		if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Error("Error running debug server", "error", err.Error())
+		}
+	}()
+	<-p.closec
+	if opts.LingerOnClose {
+		log.Info("Leaving debug server running until the process exits")
+		lingerServer(p, server, opts.ShutdownTimeout)
+		return
+	}
+	log.Info("Shutting down debug server")
+	shutdownServer(log, server, opts.ShutdownTimeout)
+}
+
+// lingering is the registry of debug servers left running by plugins closed
+// with LingerOnClose, keyed by the plugin that started them.
+var lingering = struct {
+	sync.Mutex
+	servers map[*Plugin]lingeringServer
+}{servers: make(map[*Plugin]lingeringServer)}
+
+// lingeringServer is a debug server that outlived its plugin.
+type lingeringServer struct {
+	server          *http.Server
+	shutdownTimeout time.Duration
+}
+
+// lingerServer registers the server of a closed plugin to be shut down by
+// ShutdownLingering.
+func lingerServer(p *Plugin, server *http.Server, shutdownTimeout time.Duration) {
+	lingering.Lock()
+	defer lingering.Unlock()
+	lingering.servers[p] = lingeringServer{server: server, shutdownTimeout: shutdownTimeout}
+}
+
+// stopLingering shuts down the server left running by the given plugin, if any.
+func stopLingering(p *Plugin) {
+	lingering.Lock()
+	srv, ok := lingering.servers[p]
+	delete(lingering.servers, p)
+	lingering.Unlock()
+	if ok {
+		log := slog.Default().With("plugin", "debug")
+		log.Info("Shutting down lingering debug server")
+		shutdownServer(log, srv.server, srv.shutdownTimeout)
+	}
+}
+
+// ShutdownLingering shuts down every debug server left running by a plugin
+// closed with LingerOnClose. It should be called when the process exits.
+func ShutdownLingering() {
+	lingering.Lock()
+	owners := make([]*Plugin, 0, len(lingering.servers))
+	for p := range lingering.servers {
+		owners = append(owners, p)
+	}
+	lingering.Unlock()
+	for _, p := range owners {
+		stopLingering(p)
+	}
+}
+
+// shutdownServer gracefully shuts down the server, forcibly closing any remaining
+// connections if it does not complete within timeout. A timeout less than or equal
+// to zero waits indefinitely.
+func shutdownServer(log *slog.Logger, server *http.Server, timeout time.Duration) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	err := server.Shutdown(ctx)
+	if err != nil && ctx.Err() != nil {
+		log.Warn("Debug server shutdown timed out, closing remaining connections", "timeout", timeout.String())
+		err = server.Close()
+	}
+	if err != nil {
+		log.Error("Error closing debug server", "error", err.Error())
+	}
+}
+
+// meshListenAddress returns the listen address with the host replaced by the
+// node's mesh address. The IPv4 address is preferred if the node has one.
+func meshListenAddress(listenAddress string, node *v1.NodeConfiguration) (string, error) {
+	_, port, err := net.SplitHostPort(listenAddress)
+	if err != nil {
+		return "", fmt.Errorf("parse listen address: %w", err)
+	}
+	for _, addr := range []string{node.GetAddressIPv4(), node.GetAddressIPv6()} {
+		if addr == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(addr)
+		if err != nil {
+			return "", fmt.Errorf("parse mesh address: %w", err)
+		}
+		return net.JoinHostPort(prefix.Addr().String(), port), nil
+	}
+	return "", fmt.Errorf("bind to mesh only requested but the node has no mesh address")
+}
+
+// newHandler builds the HTTP handler for the debug server.
+func (p *Plugin) newHandler(log *slog.Logger, opts Config) http.Handler {
 	mux := http.NewServeMux()
 	pathPrefix := strings.TrimSuffix(opts.PathPrefix, "/")
 	if !opts.DisablePProf {
@@ -175,40 +532,75 @@ func (p *Plugin) serve(opts Config) {
 		}
 		log.Info("Enabling pprof", "profiles", profiles)
 		for _, profile := range profiles {
-			mux.Handle(fmt.Sprintf("%s/pprof/%s", pathPrefix, profile), pprof.Handler(profile))
+			var handler http.Handler
+			switch profile {
+			case "profile":
+				handler = http.HandlerFunc(pprof.Profile)
+			case "trace":
+				handler = http.HandlerFunc(pprof.Trace)
+			default:
+				handler = pprof.Handler(profile)
+			}
+			handler = clampProfileSeconds(opts.MaxProfileSeconds, handler)
+			if token, ok := opts.PprofProfileTokens[profile]; ok && token != "" {
+				handler = requireBearerToken(token, handler)
+			}
+			mux.Handle(fmt.Sprintf("%s/pprof/%s", pathPrefix, profile), handler)
 		}
 	}
 	if opts.EnableDBQuerier {
 		log.Info("Enabling database querier")
-		mux.HandleFunc(fmt.Sprintf("%s/db/list", pathPrefix), p.handleDBList)
-		mux.HandleFunc(fmt.Sprintf("%s/db/get", pathPrefix), p.handleDBGet)
-		mux.HandleFunc(fmt.Sprintf("%s/db/iter-prefix", pathPrefix), p.handleDBIterPrefix)
+		limit := limitConcurrency(opts.MaxConcurrentQueries)
+		mux.Handle(fmt.Sprintf("%s/db/list", pathPrefix), limit(http.HandlerFunc(p.handleDBList)))
+		mux.Handle(fmt.Sprintf("%s/db/get", pathPrefix), limit(p.handleDBGet(opts.MaxDBValueSize, opts.RedactPrefixes)))
+		mux.Handle(fmt.Sprintf("%s/db/iter-prefix", pathPrefix), limit(http.HandlerFunc(p.handleDBIterPrefix)))
+		mux.Handle(fmt.Sprintf("%s/raft/config", pathPrefix), limit(http.HandlerFunc(p.handleRaftConfig)))
+		if opts.EnableACLEval {
+			log.Info("Enabling acl eval endpoint")
+			mux.Handle(fmt.Sprintf("%s/acl/eval", pathPrefix), limit(requireBearerToken(opts.ACLEvalToken, http.HandlerFunc(p.handleACLEval))))
+		}
 	}
-	server := &http.Server{
-		Addr:    opts.ListenAddress,
-		Handler: logRequest(mux),
-		BaseContext: func(_ net.Listener) context.Context {
-			return context.WithLogger(context.Background(), log)
-		},
+	if opts.EnableGC {
+		log.Info("Enabling gc endpoint")
+		mux.Handle(fmt.Sprintf("%s/gc", pathPrefix), requireBearerToken(opts.GCToken, http.HandlerFunc(handleGC)))
 	}
-	go func() {
-		log.Info("Starting debug server", "listen-address", opts.ListenAddress)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Error("Error running debug server", "error", err.Error())
+	return logRequest(mux)
+}
+
+// listen binds the given address. If the address is in use, it retries with
+// an increasing backoff until listenRetryTimeout elapses or the plugin is closed.
+func (p *Plugin) listen(log *slog.Logger, addr string) (net.Listener, error) {
+	deadline := time.Now().Add(listenRetryTimeout)
+	backoff := 100 * time.Millisecond
+	for {
+		ln, err := net.Listen("tcp", addr)
+		if err == nil {
+			return ln, nil
+		}
+		if !errors.Is(err, syscall.EADDRINUSE) || time.Now().Add(backoff).After(deadline) {
+			return nil, err
+		}
+		log.Warn("Debug server listen address in use, retrying", "listen-address", addr, "backoff", backoff.String())
+		select {
+		case <-p.closec:
+			return nil, fmt.Errorf("plugin closed before binding %s: %w", addr, err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > listenRetryMaxBackoff {
+			backoff = listenRetryMaxBackoff
 		}
-	}()
-	<-p.closec
-	log.Info("Shutting down debug server")
-	if err := server.Shutdown(context.Background()); err != nil {
-		log.Error("Error closing debug server", "error", err.Error())
 	}
 }
 
 func (p *Plugin) handleDBList(w http.ResponseWriter, r *http.Request) {
+	// Only hold the lock long enough to grab the current storage so
+	// large listings do not block other handlers.
 	p.datamux.Lock()
-	defer p.datamux.Unlock()
+	data := p.data
+	p.datamux.Unlock()
 	defer r.Body.Close()
-	if p.data == nil {
+	if data == nil {
 		http.Error(w, "plugin not configured", http.StatusInternalServerError)
 		return
 	}
@@ -216,16 +608,110 @@ func (p *Plugin) handleDBList(w http.ResponseWriter, r *http.Request) {
 	prefix := r.URL.Query().Get("q")
 	// We are okay with empty prefix, will return all keys
 	log.Info("Listing keys for prefix from database", "prefix", prefix)
-	resp, err := p.data.ListKeys(r.Context(), []byte(prefix))
+	iter, ok := data.(storage.KeyIterStorage)
+	if !ok {
+		resp, err := data.ListKeys(r.Context(), []byte(prefix))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Debug("got keys", "resp", resp)
+		fmt.Fprint(w, string(bytes.Join(resp, []byte("\n"))))
+		return
+	}
+	flusher, _ := w.(http.Flusher)
+	var count int
+	err := iter.IterKeys(r.Context(), []byte(prefix), func(key []byte) error {
+		if count > 0 {
+			if _, err := w.Write([]byte("\n")); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(key); err != nil {
+			return err
+		}
+		count++
+		if flusher != nil && count%dbListFlushInterval == 0 {
+			flusher.Flush()
+		}
+		return nil
+	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if count == 0 {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// Headers have already been sent, all we can do is log it.
+		log.Error("Error streaming keys", "prefix", prefix, "error", err.Error())
 		return
 	}
-	log.Debug("got keys", "resp", resp)
-	fmt.Fprint(w, string(bytes.Join(resp, []byte("\n"))))
+	log.Debug("Streamed keys", "prefix", prefix, "count", count)
+}
+
+func (p *Plugin) handleDBGet(maxSize int, redactPrefixes []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only hold the lock long enough to grab the current storage so
+		// slow clients do not block other handlers.
+		p.datamux.Lock()
+		data := p.data
+		p.datamux.Unlock()
+		defer r.Body.Close()
+		if data == nil {
+			http.Error(w, "plugin not configured", http.StatusInternalServerError)
+			return
+		}
+		log := context.LoggerFrom(r.Context())
+		key := r.URL.Query().Get("q")
+		if key == "" {
+			log.Error("Missing key parameter in request")
+			http.Error(w, "missing key", http.StatusBadRequest)
+			return
+		}
+		log.Info("Getting key from database", "key", key)
+		resp, err := data.GetValue(r.Context(), []byte(key))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp = bytes.TrimSpace(resp)
+		if isRedacted(redactPrefixes, key) {
+			log.Debug("Redacting value", "key", key)
+			resp = []byte(redactedValue)
+		}
+		raw := r.URL.Query().Get("raw") == "true"
+		if maxSize > 0 && len(resp) > maxSize && !raw {
+			log.Warn("Value exceeds maximum size", "key", key, "size", len(resp), "max-size", maxSize)
+			http.Error(w, fmt.Sprintf("value size %d exceeds maximum of %d, use raw=true to stream it", len(resp), maxSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		log.Debug("Got key", "key", key, "size", len(resp))
+		w.Header().Set("Content-Length", strconv.Itoa(len(resp)))
+		flusher, _ := w.(http.Flusher)
+		for len(resp) > 0 {
+			n := min(len(resp), dbValueChunkSize)
+			if _, err := w.Write(resp[:n]); err != nil {
+				log.Error("Error writing value", "key", key, "error", err.Error())
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			resp = resp[n:]
+		}
+	}
+}
+
+// isRedacted returns true if the key falls under one of the redacted prefixes.
+func isRedacted(prefixes []string, key string) bool {
+	for _, prefix := range prefixes {
+		if prefix != "" && strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
 }
 
-func (p *Plugin) handleDBGet(w http.ResponseWriter, r *http.Request) {
+func (p *Plugin) handleDBIterPrefix(w http.ResponseWriter, r *http.Request) {
 	p.datamux.Lock()
 	defer p.datamux.Unlock()
 	defer r.Body.Close()
@@ -233,34 +719,257 @@ func (p *Plugin) handleDBGet(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "plugin not configured", http.StatusInternalServerError)
 		return
 	}
-	log := context.LoggerFrom(r.Context())
-	key := r.URL.Query().Get("q")
-	if key == "" {
-		log.Error("Missing key parameter in request")
-		http.Error(w, "missing key", http.StatusBadRequest)
+	// TODO: may be pointless to implement this
+	http.Error(w, "not implemented", http.StatusNotImplemented)
+}
+
+// raftConfiguration is the JSON representation of the raft configuration
+// returned by the raft config endpoint.
+type raftConfiguration struct {
+	Leader  string       `json:"leader"`
+	Servers []raftServer `json:"servers"`
+	// AppliedIndex and AppliedTerm are the index and term of the last
+	// log entry applied by this node.
+	AppliedIndex uint64 `json:"appliedIndex"`
+	AppliedTerm  uint64 `json:"appliedTerm"`
+}
+
+// raftServer is a server in the raft configuration.
+type raftServer struct {
+	ID       string `json:"id"`
+	Address  string `json:"address"`
+	Suffrage string `json:"suffrage"`
+	Leader   bool   `json:"leader"`
+}
+
+func (p *Plugin) handleRaftConfig(w http.ResponseWriter, r *http.Request) {
+	p.datamux.Lock()
+	consensus := p.consensus
+	p.datamux.Unlock()
+	defer r.Body.Close()
+	if consensus == nil {
+		http.Error(w, "consensus not available to plugin", http.StatusServiceUnavailable)
 		return
 	}
-	log.Info("Getting key from database", "key", key)
-	resp, err := p.data.GetValue(r.Context(), []byte(key))
+	peers, err := consensus.GetPeers(r.Context())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	resp = bytes.TrimSpace(resp)
-	log.Debug("Got key", "key", key, "resp", string(resp))
-	fmt.Fprint(w, string(resp))
+	config := raftConfiguration{Servers: make([]raftServer, 0, len(peers))}
+	config.AppliedIndex, config.AppliedTerm = consensus.AppliedIndex()
+	for _, peer := range peers {
+		server := raftServer{
+			ID:      peer.GetId(),
+			Address: peer.GetAddress(),
+		}
+		switch peer.GetClusterStatus() {
+		case v1.ClusterStatus_CLUSTER_LEADER:
+			server.Suffrage = "voter"
+			server.Leader = true
+			config.Leader = server.ID
+		case v1.ClusterStatus_CLUSTER_VOTER:
+			server.Suffrage = "voter"
+		case v1.ClusterStatus_CLUSTER_OBSERVER:
+			server.Suffrage = "nonvoter"
+		default:
+			server.Suffrage = "unknown"
+		}
+		config.Servers = append(config.Servers, server)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(config); err != nil {
+		context.LoggerFrom(r.Context()).Error("Failed to encode raft configuration", "error", err.Error())
+	}
 }
 
-func (p *Plugin) handleDBIterPrefix(w http.ResponseWriter, r *http.Request) {
+// aclEvaluation is the JSON representation of the result of evaluating a
+// flow against the network ACLs.
+type aclEvaluation struct {
+	// Action is the action applied to the flow.
+	Action string `json:"action"`
+	// ACL is the name of the matching ACL. It is empty when the default
+	// action was applied.
+	ACL string `json:"acl,omitempty"`
+	// Default is true when no ACL matched and the default action was applied.
+	Default bool `json:"default"`
+	// Proto and Port echo the requested protocol and port. Network ACLs do
+	// not match on them, so they do not affect the decision.
+	Proto string `json:"proto,omitempty"`
+	Port  uint16 `json:"port,omitempty"`
+}
+
+// handleACLEval evaluates the flow described by the src, dst, proto, and port
+// query parameters against the network ACLs. The source and destination may
+// each be a node ID, an IP address, or a CIDR.
+func (p *Plugin) handleACLEval(w http.ResponseWriter, r *http.Request) {
 	p.datamux.Lock()
-	defer p.datamux.Unlock()
+	data := p.data
+	p.datamux.Unlock()
 	defer r.Body.Close()
-	if p.data == nil {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if data == nil {
 		http.Error(w, "plugin not configured", http.StatusInternalServerError)
 		return
 	}
-	// TODO: may be pointless to implement this
-	http.Error(w, "not implemented", http.StatusNotImplemented)
+	query := r.URL.Query()
+	action := &v1.NetworkAction{}
+	var err error
+	action.SrcNode, action.SrcCIDR, err = parseFlowEndpoint(query.Get("src"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid src: %v", err), http.StatusBadRequest)
+		return
+	}
+	action.DstNode, action.DstCIDR, err = parseFlowEndpoint(query.Get("dst"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid dst: %v", err), http.StatusBadRequest)
+		return
+	}
+	result := aclEvaluation{Proto: strings.ToLower(query.Get("proto"))}
+	switch result.Proto {
+	case "", "tcp", "udp", "icmp":
+	default:
+		http.Error(w, fmt.Sprintf("invalid proto %q: must be one of tcp, udp, or icmp", result.Proto), http.StatusBadRequest)
+		return
+	}
+	if port := query.Get("port"); port != "" {
+		n, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid port %q", port), http.StatusBadRequest)
+			return
+		}
+		result.Port = uint16(n)
+	}
+	ctx := r.Context()
+	acls, err := networking.New(data).ListNetworkACLs(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := storage.ExpandACLs(ctx, rbac.New(data), acls); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	acls.Sort(types.SortDescending)
+	if acl, ok := acls.Match(ctx, types.NetworkAction{NetworkAction: action}); ok {
+		result.Action, result.ACL = acl.GetAction().String(), acl.GetName()
+	} else {
+		defaultAction, err := storage.GetDefaultNetworkACLAction(ctx, data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result.Action, result.Default = defaultAction.String(), true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		context.LoggerFrom(ctx).Error("Failed to encode acl evaluation", "error", err.Error())
+	}
+}
+
+// parseFlowEndpoint parses one side of a flow. IP addresses and CIDRs are
+// returned as a CIDR, anything else must be a valid node ID.
+func parseFlowEndpoint(s string) (node string, cidr string, err error) {
+	if s == "" {
+		return "", "", fmt.Errorf("a node ID, address, or CIDR is required")
+	}
+	if addr, err := netip.ParseAddr(s); err == nil {
+		addr = addr.Unmap()
+		return "", netip.PrefixFrom(addr, addr.BitLen()).String(), nil
+	}
+	if prefix, err := netip.ParsePrefix(s); err == nil {
+		return "", prefix.Masked().String(), nil
+	}
+	if !types.IsValidID(s) {
+		return "", "", fmt.Errorf("%q is not a node ID, address, or CIDR", s)
+	}
+	return s, "", nil
+}
+
+// gcResult is the JSON representation of the memory statistics returned
+// by the gc endpoint.
+type gcResult struct {
+	Before runtime.MemStats `json:"before"`
+	After  runtime.MemStats `json:"after"`
+}
+
+func handleGC(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var result gcResult
+	runtime.ReadMemStats(&result.Before)
+	runtime.GC()
+	runtime.ReadMemStats(&result.After)
+	context.LoggerFrom(r.Context()).Info("Forced garbage collection",
+		"heap-before", result.Before.HeapAlloc,
+		"heap-after", result.After.HeapAlloc)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		context.LoggerFrom(r.Context()).Error("Failed to encode memory stats", "error", err.Error())
+	}
+}
+
+// clampProfileSeconds wraps a pprof handler and lowers the seconds parameter
+// of requests to at most maxSeconds. A maxSeconds less than or equal to zero
+// disables the limit.
+func clampProfileSeconds(maxSeconds int, next http.Handler) http.Handler {
+	if maxSeconds <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if seconds, err := strconv.ParseFloat(query.Get("seconds"), 64); err == nil && seconds > float64(maxSeconds) {
+			context.LoggerFrom(r.Context()).Warn("Clamping requested profile duration", "requested", seconds, "max", maxSeconds)
+			query.Set("seconds", strconv.Itoa(maxSeconds))
+			r.URL.RawQuery = query.Encode()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitConcurrency returns a middleware that allows at most maxRequests to be
+// served at once by the handlers it wraps. Requests over the limit are rejected
+// immediately with a 429. The limit is shared between all wrapped handlers. A
+// value less than or equal to zero disables the limit.
+func limitConcurrency(maxRequests int) func(http.Handler) http.Handler {
+	if maxRequests <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	sem := make(chan struct{}, maxRequests)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+				next.ServeHTTP(w, r)
+			default:
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "too many concurrent queries", http.StatusTooManyRequests)
+			}
+		})
+	}
+}
+
+// requireBearerToken wraps the given handler and rejects requests that do not
+// present the given bearer token in the Authorization header.
+func requireBearerToken(token string, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
 }
 
 func logRequest(next http.Handler) http.HandlerFunc {
@@ -0,0 +1,1108 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package debug
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/webmeshproj/webmesh/pkg/plugins"
+	"github.com/webmeshproj/webmesh/pkg/storage"
+	"github.com/webmeshproj/webmesh/pkg/storage/meshdb/networking"
+	"github.com/webmeshproj/webmesh/pkg/storage/providers/backends/badgerdb"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+func TestListenRetry(t *testing.T) {
+	t.Parallel()
+	// Occupy a port and start the plugin on it.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	newTestPlugin(t, map[string]any{
+		"listen-address": addr,
+	})
+	// Give the plugin a chance to fail its first attempt, then free the port.
+	time.Sleep(250 * time.Millisecond)
+	if err := ln.Close(); err != nil {
+		t.Fatal(err)
+	}
+	url := fmt.Sprintf("http://%s/debug/pprof/heap", addr)
+	ok := eventually(t, 10*time.Second, func() bool {
+		resp, err := http.Get(url)
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	})
+	if !ok {
+		t.Fatal("debug server never bound the listen address")
+	}
+}
+
+func newTestPlugin(t *testing.T, config map[string]any) *Plugin {
+	t.Helper()
+	conf, err := structpb.NewStruct(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Plugin{}
+	_, err = p.Configure(context.Background(), &v1.PluginConfiguration{Config: conf})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_, _ = p.Close(context.Background(), &emptypb.Empty{})
+	})
+	return p
+}
+
+func eventually(t *testing.T, timeout time.Duration, fn func() bool) bool {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if fn() {
+			return true
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return false
+}
+
+func TestPprofProfileTokens(t *testing.T) {
+	t.Parallel()
+	p := &Plugin{}
+	opts := NewDefaultOptions()
+	opts.PprofProfiles = "heap,goroutine"
+	opts.PprofProfileTokens = map[string]string{
+		"goroutine": "secret",
+	}
+	srv := httptest.NewServer(p.newHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), opts))
+	t.Cleanup(srv.Close)
+
+	get := func(t *testing.T, profile, token string) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/debug/pprof/%s", srv.URL, profile), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	tc := []struct {
+		name    string
+		profile string
+		token   string
+		want    int
+	}{
+		{"OpenProfile", "heap", "", http.StatusOK},
+		{"GatedProfileNoToken", "goroutine", "", http.StatusUnauthorized},
+		{"GatedProfileWrongToken", "goroutine", "wrong", http.StatusUnauthorized},
+		{"GatedProfileValidToken", "goroutine", "secret", http.StatusOK},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := get(t, tt.profile, tt.token); got != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHandleDBGetMaxSize(t *testing.T) {
+	t.Parallel()
+	db := badgerdb.NewTestStorage(false)
+	t.Cleanup(func() { _ = db.Close() })
+	ctx := context.Background()
+	small := []byte("small-value")
+	large := bytes.Repeat([]byte("x"), 4*dbValueChunkSize+17)
+	if err := db.PutValue(ctx, []byte("/small"), small, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.PutValue(ctx, []byte("/large"), large, 0); err != nil {
+		t.Fatal(err)
+	}
+	p := &Plugin{data: db}
+	opts := NewDefaultOptions()
+	opts.DisablePProf = true
+	opts.EnableDBQuerier = true
+	opts.MaxDBValueSize = dbValueChunkSize
+	srv := httptest.NewServer(p.newHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), opts))
+	t.Cleanup(srv.Close)
+
+	tc := []struct {
+		name  string
+		query string
+		code  int
+		body  []byte
+	}{
+		{"UnderLimit", "q=/small", http.StatusOK, small},
+		{"OverLimit", "q=/large", http.StatusRequestEntityTooLarge, nil},
+		{"OverLimitRaw", "q=/large&raw=true", http.StatusOK, large},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := srv.Client().Get(fmt.Sprintf("%s/debug/db/get?%s", srv.URL, tt.query))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.code {
+				t.Fatalf("expected status %d, got %d", tt.code, resp.StatusCode)
+			}
+			if tt.body == nil {
+				return
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(body, tt.body) {
+				t.Fatalf("expected body of length %d, got %d", len(tt.body), len(body))
+			}
+		})
+	}
+}
+
+// chunkRecorder records the size of each write and the number of flushes.
+type chunkRecorder struct {
+	*httptest.ResponseRecorder
+	writes  []int
+	flushes int
+}
+
+func (c *chunkRecorder) Write(b []byte) (int, error) {
+	c.writes = append(c.writes, len(b))
+	return c.ResponseRecorder.Write(b)
+}
+
+func (c *chunkRecorder) Flush() {
+	c.flushes++
+	c.ResponseRecorder.Flush()
+}
+
+func TestHandleDBGetChunked(t *testing.T) {
+	t.Parallel()
+	db := badgerdb.NewTestStorage(false)
+	t.Cleanup(func() { _ = db.Close() })
+	large := bytes.Repeat([]byte("x"), 2*dbValueChunkSize+17)
+	if err := db.PutValue(context.Background(), []byte("/large"), large, 0); err != nil {
+		t.Fatal(err)
+	}
+	p := &Plugin{data: db}
+	rec := &chunkRecorder{ResponseRecorder: httptest.NewRecorder()}
+	req := httptest.NewRequest(http.MethodGet, "/debug/db/get?q=/large", nil)
+	p.handleDBGet(0, nil)(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if want := []int{dbValueChunkSize, dbValueChunkSize, 17}; !reflect.DeepEqual(rec.writes, want) {
+		t.Fatalf("expected writes of %v bytes, got %v", want, rec.writes)
+	}
+	if rec.flushes != 3 {
+		t.Fatalf("expected a flush after each chunk, got %d flushes", rec.flushes)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), large) {
+		t.Fatalf("expected body of length %d, got %d", len(large), rec.Body.Len())
+	}
+}
+
+// blockingGetStorage blocks GetValue until released.
+type blockingGetStorage struct {
+	storage.MeshStorage
+	blocked chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingGetStorage) GetValue(ctx context.Context, key []byte) ([]byte, error) {
+	close(s.blocked)
+	<-s.release
+	return s.MeshStorage.GetValue(ctx, key)
+}
+
+func TestHandleDBGetReleasesLock(t *testing.T) {
+	t.Parallel()
+	db := badgerdb.NewTestStorage(false)
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.PutValue(context.Background(), []byte("/key"), []byte("value"), 0); err != nil {
+		t.Fatal(err)
+	}
+	data := &blockingGetStorage{
+		MeshStorage: db,
+		blocked:     make(chan struct{}),
+		release:     make(chan struct{}),
+	}
+	p := &Plugin{data: data}
+	opts := NewDefaultOptions()
+	opts.DisablePProf = true
+	opts.EnableDBQuerier = true
+	srv := httptest.NewServer(p.newHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), opts))
+	t.Cleanup(srv.Close)
+
+	type result struct {
+		body []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := srv.Client().Get(srv.URL + "/debug/db/get?q=/key")
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		done <- result{body: body, err: err}
+	}()
+	select {
+	case <-data.blocked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the value to be read")
+	}
+	if !p.datamux.TryLock() {
+		t.Fatal("expected storage lock to be released while reading the value")
+	}
+	p.datamux.Unlock()
+	close(data.release)
+	res := <-done
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	if string(res.body) != "value" {
+		t.Fatalf("expected body %q, got %q", "value", res.body)
+	}
+}
+
+func TestHandleDBGetRedactPrefixes(t *testing.T) {
+	t.Parallel()
+	db := badgerdb.NewTestStorage(false)
+	t.Cleanup(func() { _ = db.Close() })
+	ctx := context.Background()
+	if err := db.PutValue(ctx, []byte("/registry/secrets/token"), []byte("hunter2"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.PutValue(ctx, []byte("/registry/nodes/node-a"), []byte("node-a"), 0); err != nil {
+		t.Fatal(err)
+	}
+	p := &Plugin{data: db}
+	opts := NewDefaultOptions()
+	opts.DisablePProf = true
+	opts.EnableDBQuerier = true
+	opts.RedactPrefixes = []string{"/registry/secrets"}
+	srv := httptest.NewServer(p.newHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), opts))
+	t.Cleanup(srv.Close)
+
+	get := func(t *testing.T, path string) string {
+		t.Helper()
+		resp, err := srv.Client().Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+	if got := get(t, "/debug/db/get?q=/registry/secrets/token"); got != redactedValue {
+		t.Fatalf("expected redacted value, got %q", got)
+	}
+	if got := get(t, "/debug/db/get?q=/registry/secrets/token&raw=true"); got != redactedValue {
+		t.Fatalf("expected redacted raw value, got %q", got)
+	}
+	if got := get(t, "/debug/db/get?q=/registry/nodes/node-a"); got != "node-a" {
+		t.Fatalf("expected value to be shown, got %q", got)
+	}
+	// Redacted keys are still listed.
+	if got := get(t, "/debug/db/list?q=/registry/secrets"); got != "/registry/secrets/token" {
+		t.Fatalf("expected redacted key to be listed, got %q", got)
+	}
+}
+
+func TestConfigureUnknownKey(t *testing.T) {
+	t.Parallel()
+	conf, err := structpb.NewStruct(map[string]any{
+		"listen-adress": "127.0.0.1:0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Plugin{}
+	_, err = p.Configure(context.Background(), &v1.PluginConfiguration{Config: conf})
+	if err == nil {
+		_, _ = p.Close(context.Background(), &emptypb.Empty{})
+		t.Fatal("expected error for unknown configuration key")
+	}
+	if !strings.Contains(err.Error(), "listen-adress") {
+		t.Fatalf("expected error to name the unknown key, got %q", err.Error())
+	}
+}
+
+func TestBindToMeshOnly(t *testing.T) {
+	t.Parallel()
+	// Reserve a free port and use a secondary loopback address as the mesh address.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	_ = ln.Close()
+	conf, err := structpb.NewStruct(map[string]any{
+		"listen-address":    fmt.Sprintf("localhost:%d", port),
+		"bind-to-mesh-only": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Plugin{}
+	_, err = p.Configure(context.Background(), &v1.PluginConfiguration{
+		Config: conf,
+		NodeConfig: &v1.NodeConfiguration{
+			AddressIPv4: "127.0.0.2/32",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_, _ = p.Close(context.Background(), &emptypb.Empty{})
+	})
+	meshAddr := net.JoinHostPort("127.0.0.2", fmt.Sprint(port))
+	ok := eventually(t, 5*time.Second, func() bool {
+		conn, err := net.Dial("tcp", meshAddr)
+		if err != nil {
+			return false
+		}
+		_ = conn.Close()
+		return true
+	})
+	if !ok {
+		t.Fatalf("debug server never listened on mesh address %s", meshAddr)
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", fmt.Sprint(port)), time.Second)
+	if err == nil {
+		_ = conn.Close()
+		t.Fatal("expected off-mesh connection to be refused")
+	}
+}
+
+func TestBindToMeshOnlyNoAddress(t *testing.T) {
+	t.Parallel()
+	conf, err := structpb.NewStruct(map[string]any{
+		"bind-to-mesh-only": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Plugin{}
+	_, err = p.Configure(context.Background(), &v1.PluginConfiguration{Config: conf})
+	if err == nil {
+		_, _ = p.Close(context.Background(), &emptypb.Empty{})
+		t.Fatal("expected error when the node has no mesh address")
+	}
+}
+
+func TestMaxConcurrentQueries(t *testing.T) {
+	t.Parallel()
+	db := badgerdb.NewTestStorage(false)
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.PutValue(context.Background(), []byte("/key"), []byte("value"), 0); err != nil {
+		t.Fatal(err)
+	}
+	p := &Plugin{data: db}
+	opts := NewDefaultOptions()
+	opts.DisablePProf = true
+	opts.EnableDBQuerier = true
+	opts.MaxConcurrentQueries = 2
+	srv := httptest.NewServer(p.newHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), opts))
+	t.Cleanup(srv.Close)
+
+	// Hold the storage lock so admitted requests stay in flight.
+	p.datamux.Lock()
+	const requests = 6
+	codes := make(chan int, requests)
+	for i := 0; i < requests; i++ {
+		go func() {
+			resp, err := srv.Client().Get(srv.URL + "/debug/db/get?q=/key")
+			if err != nil {
+				codes <- 0
+				return
+			}
+			resp.Body.Close()
+			codes <- resp.StatusCode
+		}()
+	}
+	var rejected int
+	for i := 0; i < requests-opts.MaxConcurrentQueries; i++ {
+		select {
+		case code := <-codes:
+			if code != http.StatusTooManyRequests {
+				t.Errorf("expected status %d, got %d", http.StatusTooManyRequests, code)
+			}
+			rejected++
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for rejected requests")
+		}
+	}
+	p.datamux.Unlock()
+	for i := 0; i < opts.MaxConcurrentQueries; i++ {
+		select {
+		case code := <-codes:
+			if code != http.StatusOK {
+				t.Errorf("expected status %d, got %d", http.StatusOK, code)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for admitted requests")
+		}
+	}
+	if rejected == 0 {
+		t.Fatal("expected some requests to be rejected")
+	}
+}
+
+func TestMaxProfileSeconds(t *testing.T) {
+	t.Parallel()
+	p := &Plugin{}
+	opts := NewDefaultOptions()
+	opts.PprofProfiles = "profile"
+	opts.MaxProfileSeconds = 1
+	srv := httptest.NewServer(p.newHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), opts))
+	t.Cleanup(srv.Close)
+
+	// Request a ten minute CPU profile, it should be clamped to one second.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/debug/pprof/profile?seconds=600", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("profile request was not clamped: %v", err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected profile to be clamped to 1s, took %s", elapsed)
+	}
+}
+
+type fakeConsensus struct {
+	storage.Consensus
+	peers        []types.StoragePeer
+	appliedIndex uint64
+	appliedTerm  uint64
+}
+
+func (f *fakeConsensus) AppliedIndex() (uint64, uint64) {
+	return f.appliedIndex, f.appliedTerm
+}
+
+func (f *fakeConsensus) GetPeers(context.Context) ([]types.StoragePeer, error) {
+	return f.peers, nil
+}
+
+func TestRaftConfig(t *testing.T) {
+	t.Parallel()
+	p := &Plugin{}
+	opts := NewDefaultOptions()
+	opts.EnableDBQuerier = true
+	srv := httptest.NewServer(p.newHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), opts))
+	t.Cleanup(srv.Close)
+	url := srv.URL + "/debug/raft/config"
+
+	// Without consensus the endpoint is unavailable.
+	resp, err := srv.Client().Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+
+	p.InjectConsensus(&fakeConsensus{peers: []types.StoragePeer{
+		{StoragePeer: &v1.StoragePeer{Id: "node-a", Address: "10.0.0.1:9000", ClusterStatus: v1.ClusterStatus_CLUSTER_LEADER}},
+		{StoragePeer: &v1.StoragePeer{Id: "node-b", Address: "10.0.0.2:9000", ClusterStatus: v1.ClusterStatus_CLUSTER_VOTER}},
+		{StoragePeer: &v1.StoragePeer{Id: "node-c", Address: "10.0.0.3:9000", ClusterStatus: v1.ClusterStatus_CLUSTER_OBSERVER}},
+	}, appliedIndex: 42, appliedTerm: 3})
+	resp, err = srv.Client().Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	var got raftConfiguration
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := raftConfiguration{
+		Leader: "node-a",
+		Servers: []raftServer{
+			{ID: "node-a", Address: "10.0.0.1:9000", Suffrage: "voter", Leader: true},
+			{ID: "node-b", Address: "10.0.0.2:9000", Suffrage: "voter"},
+			{ID: "node-c", Address: "10.0.0.3:9000", Suffrage: "nonvoter"},
+		},
+		AppliedIndex: 42,
+		AppliedTerm:  3,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected raft configuration %+v, got %+v", want, got)
+	}
+}
+
+// pausingKeyStorage pauses key iteration after a number of keys until resumed.
+type pausingKeyStorage struct {
+	storage.MeshStorage
+	pauseAfter int
+	paused     chan struct{}
+	resume     chan struct{}
+}
+
+func (s *pausingKeyStorage) IterKeys(ctx context.Context, prefix []byte, fn storage.KeyIterator) error {
+	var seen int
+	return s.MeshStorage.(storage.KeyIterStorage).IterKeys(ctx, prefix, func(key []byte) error {
+		if seen == s.pauseAfter {
+			close(s.paused)
+			<-s.resume
+		}
+		seen++
+		return fn(key)
+	})
+}
+
+func TestHandleDBListStreaming(t *testing.T) {
+	t.Parallel()
+	db := badgerdb.NewTestStorage(false)
+	t.Cleanup(func() { _ = db.Close() })
+	ctx := context.Background()
+	const numKeys = 5000
+	for i := 0; i < numKeys; i++ {
+		if err := db.PutValue(ctx, []byte(fmt.Sprintf("/keys/%05d", i)), []byte("value"), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := &pausingKeyStorage{
+		MeshStorage: db,
+		pauseAfter:  2 * dbListFlushInterval,
+		paused:      make(chan struct{}),
+		resume:      make(chan struct{}),
+	}
+	p := &Plugin{data: data}
+	opts := NewDefaultOptions()
+	opts.DisablePProf = true
+	opts.EnableDBQuerier = true
+	srv := httptest.NewServer(p.newHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), opts))
+	t.Cleanup(srv.Close)
+
+	resp, err := srv.Client().Get(srv.URL + "/debug/db/list?q=/keys/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	select {
+	case <-data.paused:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for iteration to pause")
+	}
+	// Keys written before the pause should already be readable
+	// and the storage lock should not be held.
+	reader := bufio.NewReader(resp.Body)
+	first, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != "/keys/00000\n" {
+		t.Fatalf("expected first key /keys/00000, got %q", first)
+	}
+	if !p.datamux.TryLock() {
+		t.Fatal("expected storage lock to be released while streaming")
+	}
+	p.datamux.Unlock()
+	close(data.resume)
+	rest, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := append([]string{strings.TrimSuffix(first, "\n")}, strings.Split(string(rest), "\n")...)
+	if len(keys) != numKeys {
+		t.Fatalf("expected %d keys, got %d", numKeys, len(keys))
+	}
+	for i, key := range keys {
+		if want := fmt.Sprintf("/keys/%05d", i); key != want {
+			t.Fatalf("expected key %q at index %d, got %q", want, i, key)
+		}
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	t.Parallel()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}),
+	}
+	go func() { _ = server.Serve(ln) }()
+	reqErr := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(fmt.Sprintf("http://%s/", ln.Addr().String()))
+		if err == nil {
+			resp.Body.Close()
+		}
+		reqErr <- err
+	}()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request never reached the handler")
+	}
+	timeout := 250 * time.Millisecond
+	start := time.Now()
+	shutdownServer(slog.Default(), server, timeout)
+	if elapsed := time.Since(start); elapsed > timeout+2*time.Second {
+		t.Fatalf("shutdown took %s, expected it to complete shortly after %s", elapsed, timeout)
+	}
+	select {
+	case err := <-reqErr:
+		if err == nil {
+			t.Fatal("expected in-flight request to be aborted by the forced close")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("in-flight request was never closed")
+	}
+}
+
+func TestGCEndpoint(t *testing.T) {
+	t.Parallel()
+	get := func(t *testing.T, srv *httptest.Server, token string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/debug/gc", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		t.Parallel()
+		p := &Plugin{}
+		srv := httptest.NewServer(p.newHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), NewDefaultOptions()))
+		t.Cleanup(srv.Close)
+		if resp := get(t, srv, "secret"); resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, resp.StatusCode)
+		}
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		t.Parallel()
+		p := &Plugin{}
+		opts := NewDefaultOptions()
+		opts.EnableGC = true
+		opts.GCToken = "secret"
+		srv := httptest.NewServer(p.newHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), opts))
+		t.Cleanup(srv.Close)
+		if resp := get(t, srv, ""); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected status %d without a token, got %d", http.StatusUnauthorized, resp.StatusCode)
+		}
+		resp := get(t, srv, "secret")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+		var got map[string]map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatalf("expected valid JSON: %v", err)
+		}
+		for _, key := range []string{"before", "after"} {
+			stats, ok := got[key]
+			if !ok {
+				t.Fatalf("expected %q memory stats in response", key)
+			}
+			for _, field := range []string{"HeapAlloc", "NumGC", "Sys"} {
+				if _, ok := stats[field]; !ok {
+					t.Fatalf("expected %q in %s memory stats", field, key)
+				}
+			}
+		}
+		if got["after"]["NumGC"].(float64) <= got["before"]["NumGC"].(float64) {
+			t.Fatalf("expected a garbage collection to run, NumGC before %v after %v", got["before"]["NumGC"], got["after"]["NumGC"])
+		}
+	})
+
+	t.Run("RequiresToken", func(t *testing.T) {
+		t.Parallel()
+		opts := NewDefaultOptions()
+		opts.EnableGC = true
+		if err := opts.Validate(); err == nil {
+			t.Fatal("expected error enabling gc without a token")
+		}
+	})
+}
+
+func TestPathPrefixValidation(t *testing.T) {
+	t.Parallel()
+	tc := []struct {
+		prefix  string
+		wantErr bool
+	}{
+		{prefix: "/debug", wantErr: false},
+		{prefix: "/debug/", wantErr: false},
+		{prefix: "/internal/debug", wantErr: false},
+		{prefix: "", wantErr: false},
+		{prefix: "/debug/pprof", wantErr: true},
+		{prefix: "/db", wantErr: true},
+		{prefix: "/debug/../pprof", wantErr: true},
+		{prefix: "debug", wantErr: true},
+		{prefix: "//debug", wantErr: true},
+		{prefix: "/debug/{name}", wantErr: true},
+	}
+	for _, tt := range tc {
+		tt := tt
+		t.Run(tt.prefix, func(t *testing.T) {
+			t.Parallel()
+			conf, err := structpb.NewStruct(map[string]any{
+				"listen-address": "127.0.0.1:0",
+				"path-prefix":    tt.prefix,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := &Plugin{}
+			_, err = p.Configure(context.Background(), &v1.PluginConfiguration{Config: conf})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error for path prefix %q", tt.prefix)
+				}
+				if !strings.Contains(err.Error(), "invalid path-prefix") {
+					t.Fatalf("expected a descriptive error, got %q", err.Error())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			_, _ = p.Close(context.Background(), &emptypb.Empty{})
+		})
+	}
+}
+
+func TestConfigureGrantedCapabilities(t *testing.T) {
+	t.Parallel()
+	tc := []struct {
+		name    string
+		granted []any
+		wantErr bool
+	}{
+		{
+			name:    "NotNegotiated",
+			granted: nil,
+		},
+		{
+			name:    "Granted",
+			granted: []any{v1.PluginInfo_STORAGE_QUERIER.String()},
+		},
+		{
+			name:    "NotGranted",
+			granted: []any{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tc {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := map[string]any{
+				"listen-address":    "127.0.0.1:0",
+				"enable-db-querier": true,
+			}
+			if tt.granted != nil {
+				cfg[plugins.CapabilitiesConfigKey] = tt.granted
+			}
+			conf, err := structpb.NewStruct(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := &Plugin{}
+			_, err = p.Configure(context.Background(), &v1.PluginConfiguration{Config: conf})
+			if tt.wantErr {
+				if !errors.Is(err, ErrQuerierNotGranted) {
+					t.Fatalf("expected ErrQuerierNotGranted, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			_, _ = p.Close(context.Background(), &emptypb.Empty{})
+		})
+	}
+}
+
+func TestCloseBeforeConfigure(t *testing.T) {
+	t.Parallel()
+	p := &Plugin{}
+	if _, err := p.Close(context.Background(), &emptypb.Empty{}); err != nil {
+		t.Fatalf("unexpected error closing an unconfigured plugin: %v", err)
+	}
+	if _, err := p.Close(context.Background(), &emptypb.Empty{}); err != nil {
+		t.Fatalf("unexpected error closing the plugin twice: %v", err)
+	}
+}
+
+func TestCloseSlowQuerier(t *testing.T) {
+	t.Parallel()
+	db := badgerdb.NewTestStorage(false)
+	release := make(chan struct{})
+	t.Cleanup(func() {
+		close(release)
+	})
+	conf, err := structpb.NewStruct(map[string]any{
+		"listen-address":    "127.0.0.1:0",
+		"enable-db-querier": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Plugin{
+		data:                &slowCloseStorage{MeshStorage: db, release: release},
+		querierCloseTimeout: 50 * time.Millisecond,
+	}
+	if _, err := p.Configure(context.Background(), &v1.PluginConfiguration{Config: conf}); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.Close(context.Background(), &emptypb.Empty{})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error closing the plugin: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("close did not return after the querier close timeout")
+	}
+	if _, err := p.Close(context.Background(), &emptypb.Empty{}); err != nil {
+		t.Fatalf("unexpected error closing the plugin twice: %v", err)
+	}
+}
+
+// slowCloseStorage is a storage whose Close blocks until released.
+type slowCloseStorage struct {
+	storage.MeshStorage
+	release chan struct{}
+}
+
+func (s *slowCloseStorage) Close() error {
+	<-s.release
+	return s.MeshStorage.Close()
+}
+
+func TestACLEvalEndpoint(t *testing.T) {
+	t.Parallel()
+	db := badgerdb.NewTestStorage(false)
+	t.Cleanup(func() { _ = db.Close() })
+	ctx := context.Background()
+	err := networking.New(db).PutNetworkACL(ctx, types.NetworkACL{NetworkACL: &v1.NetworkACL{
+		Name:             "allow-web",
+		Priority:         10,
+		Action:           v1.ACLAction_ACTION_ACCEPT,
+		SourceCIDRs:      []string{"10.0.0.0/24"},
+		DestinationNodes: []string{"web"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Plugin{data: db}
+	opts := NewDefaultOptions()
+	opts.DisablePProf = true
+	opts.EnableDBQuerier = true
+	opts.EnableACLEval = true
+	opts.ACLEvalToken = "secret"
+	if err := opts.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(p.newHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), opts))
+	t.Cleanup(srv.Close)
+
+	tc := []struct {
+		name  string
+		token string
+		query string
+		code  int
+		want  aclEvaluation
+	}{
+		{"NoToken", "", "src=10.0.0.5&dst=web", http.StatusUnauthorized, aclEvaluation{}},
+		{"MissingSrc", "secret", "dst=web", http.StatusBadRequest, aclEvaluation{}},
+		{"InvalidPort", "secret", "src=10.0.0.5&dst=web&port=70000", http.StatusBadRequest, aclEvaluation{}},
+		{"InvalidProto", "secret", "src=10.0.0.5&dst=web&proto=sctp", http.StatusBadRequest, aclEvaluation{}},
+		{"Match", "secret", "src=10.0.0.5&dst=web&proto=tcp&port=443", http.StatusOK, aclEvaluation{
+			Action: v1.ACLAction_ACTION_ACCEPT.String(),
+			ACL:    "allow-web",
+			Proto:  "tcp",
+			Port:   443,
+		}},
+		{"Default", "secret", "src=10.1.0.5&dst=web", http.StatusOK, aclEvaluation{
+			Action:  v1.ACLAction_ACTION_DENY.String(),
+			Default: true,
+		}},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/debug/acl/eval?%s", srv.URL, tt.query), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.code {
+				t.Fatalf("expected status %d, got %d", tt.code, resp.StatusCode)
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			var got aclEvaluation
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("expected valid JSON: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("RequiresQuerierAndToken", func(t *testing.T) {
+		opts := NewDefaultOptions()
+		opts.EnableACLEval = true
+		opts.ACLEvalToken = "secret"
+		if err := opts.Validate(); err == nil {
+			t.Fatal("expected error enabling acl eval without the db querier")
+		}
+		opts.EnableDBQuerier = true
+		opts.ACLEvalToken = ""
+		if err := opts.Validate(); err == nil {
+			t.Fatal("expected error enabling acl eval without a token")
+		}
+	})
+}
+
+func TestLingerOnClose(t *testing.T) {
+	t.Parallel()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	if err := ln.Close(); err != nil {
+		t.Fatal(err)
+	}
+	p := newTestPlugin(t, map[string]any{
+		"listen-address":  addr,
+		"linger-on-close": true,
+	})
+	t.Cleanup(func() { stopLingering(p) })
+	url := fmt.Sprintf("http://%s/debug/pprof/heap", addr)
+	reachable := func() bool {
+		resp, err := http.Get(url)
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}
+	if !eventually(t, 10*time.Second, reachable) {
+		t.Fatal("debug server never became reachable")
+	}
+	if _, err := p.Close(context.Background(), &emptypb.Empty{}); err != nil {
+		t.Fatal(err)
+	}
+	if !reachable() {
+		t.Fatal("expected pprof to remain reachable after close")
+	}
+	ShutdownLingering()
+	if !eventually(t, 10*time.Second, func() bool { return !reachable() }) {
+		t.Fatal("expected the lingering debug server to shut down")
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugins
+
+import (
+	"fmt"
+	"slices"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+)
+
+// CapabilitiesConfigKey is the reserved configuration key under which the
+// manager passes the capabilities it granted to a plugin. The plugin
+// configuration message has no dedicated field for them, so they travel
+// alongside the plugin specific configuration.
+const CapabilitiesConfigKey = "granted-capabilities"
+
+// Capabilities is a set of plugin capabilities.
+type Capabilities []v1.PluginInfo_PluginCapability
+
+// Has returns true if the set contains the given capability.
+func (c Capabilities) Has(capability v1.PluginInfo_PluginCapability) bool {
+	return slices.Contains(c, capability)
+}
+
+// SplitConfig separates the plugin specific configuration in the request from
+// the capabilities granted by the manager. The returned configuration never
+// contains CapabilitiesConfigKey. negotiated is false when the request carries
+// no granted capabilities, such as when the plugin is configured by a node
+// that predates negotiation. Plugins should then behave as if every capability
+// they advertise was granted.
+func SplitConfig(req *v1.PluginConfiguration) (cfg map[string]any, granted Capabilities, negotiated bool, err error) {
+	cfg = req.GetConfig().AsMap()
+	raw, negotiated := cfg[CapabilitiesConfigKey]
+	if !negotiated {
+		return cfg, nil, false, nil
+	}
+	delete(cfg, CapabilitiesConfigKey)
+	names, ok := raw.([]any)
+	if !ok {
+		return nil, nil, false, fmt.Errorf("invalid %s: expected a list, got %T", CapabilitiesConfigKey, raw)
+	}
+	granted = make(Capabilities, 0, len(names))
+	for _, name := range names {
+		s, ok := name.(string)
+		if !ok {
+			return nil, nil, false, fmt.Errorf("invalid %s: expected capability names, got %T", CapabilitiesConfigKey, name)
+		}
+		capability, ok := v1.PluginInfo_PluginCapability_value[s]
+		if !ok {
+			return nil, nil, false, fmt.Errorf("invalid %s: unknown capability %q", CapabilitiesConfigKey, s)
+		}
+		granted = append(granted, v1.PluginInfo_PluginCapability(capability))
+	}
+	return cfg, granted, true, nil
+}
+
+// grantCapabilities returns the capabilities advertised by a plugin that the
+// manager is able to serve. Storage providers are configured separately from
+// the plugin manager and are never granted here.
+func grantCapabilities(advertised Capabilities, opts Options) Capabilities {
+	granted := make(Capabilities, 0, len(advertised))
+	for _, capability := range advertised {
+		switch capability {
+		case v1.PluginInfo_AUTH, v1.PluginInfo_WATCH, v1.PluginInfo_IPAMV4:
+			granted = append(granted, capability)
+		case v1.PluginInfo_STORAGE_QUERIER:
+			if opts.Storage != nil {
+				granted = append(granted, capability)
+			}
+		}
+	}
+	return granted
+}
+
+// withGrantedCapabilities returns a copy of the plugin configuration with the
+// granted capabilities set under CapabilitiesConfigKey.
+func withGrantedCapabilities(cfg map[string]any, granted Capabilities) map[string]any {
+	out := make(map[string]any, len(cfg)+1)
+	for k, v := range cfg {
+		out[k] = v
+	}
+	names := make([]any, len(granted))
+	for i, capability := range granted {
+		names[i] = capability.String()
+	}
+	out[CapabilitiesConfigKey] = names
+	return out
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugins
+
+import (
+	"slices"
+	"testing"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestGrantCapabilities(t *testing.T) {
+	t.Parallel()
+	advertised := Capabilities{
+		v1.PluginInfo_STORAGE_PROVIDER,
+		v1.PluginInfo_AUTH,
+		v1.PluginInfo_STORAGE_QUERIER,
+	}
+	granted := grantCapabilities(advertised, Options{})
+	if want := (Capabilities{v1.PluginInfo_AUTH}); !slices.Equal(granted, want) {
+		t.Fatalf("expected %v to be granted without storage, got %v", want, granted)
+	}
+	conf, err := structpb.NewStruct(withGrantedCapabilities(map[string]any{"key": "value"}, granted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, got, negotiated, err := SplitConfig(&v1.PluginConfiguration{Config: conf})
+	if err != nil {
+		t.Fatalf("split config: %v", err)
+	}
+	if !negotiated {
+		t.Fatal("expected capabilities to be negotiated")
+	}
+	if !slices.Equal(got, granted) {
+		t.Fatalf("expected granted capabilities %v, got %v", granted, got)
+	}
+	if _, ok := cfg[CapabilitiesConfigKey]; ok || cfg["key"] != "value" {
+		t.Fatalf("expected only the plugin configuration to remain, got %v", cfg)
+	}
+	_, _, negotiated, err = SplitConfig(&v1.PluginConfiguration{})
+	if err != nil || negotiated {
+		t.Fatalf("expected no negotiation for an empty configuration, got %v, %v", negotiated, err)
+	}
+}
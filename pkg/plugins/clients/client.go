@@ -19,6 +19,8 @@ package clients
 
 import (
 	v1 "github.com/webmeshproj/api/go/v1"
+
+	"github.com/webmeshproj/webmesh/pkg/storage"
 )
 
 // PluginClient is an extension of the interface for a plugin client.
@@ -35,3 +37,11 @@ type PluginClient interface {
 	// IPAM returns an IPAM client.
 	IPAM() v1.IPAMPluginClient
 }
+
+// ConsensusInjector is implemented by plugins that can be given direct access
+// to the consensus of the storage provider. Only in-process plugins can be
+// given this access.
+type ConsensusInjector interface {
+	// InjectConsensus injects the consensus interface into the plugin.
+	InjectConsensus(storage.Consensus)
+}
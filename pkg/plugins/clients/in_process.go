@@ -22,6 +22,7 @@ import (
 	"google.golang.org/protobuf/types/known/emptypb"
 
 	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/storage"
 )
 
 // NewInProcessClient creates a plugin client from a built-in plugin server.
@@ -52,6 +53,14 @@ func (p *inProcessPlugin) Close(ctx context.Context, in *emptypb.Empty, opts ...
 	return p.server.Close(ctx, &emptypb.Empty{})
 }
 
+// InjectConsensus injects the consensus interface into the plugin server
+// if it implements ConsensusInjector.
+func (p *inProcessPlugin) InjectConsensus(consensus storage.Consensus) {
+	if srv, ok := p.server.(ConsensusInjector); ok {
+		srv.InjectConsensus(consensus)
+	}
+}
+
 func (p *inProcessPlugin) Storage() v1.StorageQuerierPluginClient {
 	_, ok := p.server.(v1.StorageQuerierPluginServer)
 	if !ok {
@@ -0,0 +1,92 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugins
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// DecodeConfig decodes the given plugin configuration into out. Unlike a plain
+// mapstructure.Decode, keys that do not map to a field in out are rejected with
+// an error listing them, so typos in configurations are not silently dropped.
+// The reserved CapabilitiesConfigKey is ignored, see SplitConfig for reading it.
+func DecodeConfig(in map[string]any, out any) error {
+	var md mapstructure.Metadata
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Metadata: &md,
+		Result:   out,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			StringToStringMapHookFunc(),
+		),
+	})
+	if err != nil {
+		return fmt.Errorf("create config decoder: %w", err)
+	}
+	if err := dec.Decode(in); err != nil {
+		return err
+	}
+	md.Unused = slices.DeleteFunc(md.Unused, func(key string) bool {
+		return key == CapabilitiesConfigKey
+	})
+	if len(md.Unused) > 0 {
+		sort.Strings(md.Unused)
+		return fmt.Errorf("unknown configuration keys: %s", strings.Join(md.Unused, ", "))
+	}
+	return nil
+}
+
+// StringToStringMapHookFunc returns a mapstructure.DecodeHookFunc that decodes
+// a flat "key=value,key=value" string into a map[string]string. This allows
+// map options to be given by configuration sources that only support strings.
+// Whitespace around entries is ignored, and empty keys or duplicate keys are
+// rejected.
+func StringToStringMapHookFunc() mapstructure.DecodeHookFuncType {
+	return func(from reflect.Type, to reflect.Type, data any) (any, error) {
+		if from.Kind() != reflect.String || to != reflect.TypeOf(map[string]string{}) {
+			return data, nil
+		}
+		return ParseStringMap(data.(string))
+	}
+}
+
+// ParseStringMap parses a flat "key=value,key=value" string into a map.
+func ParseStringMap(in string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, entry := range strings.Split(in, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(entry, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid map entry %q, expected key=value", entry)
+		}
+		if _, ok := out[key]; ok {
+			return nil, fmt.Errorf("duplicate map key %q", key)
+		}
+		out[key] = value
+	}
+	return out, nil
+}
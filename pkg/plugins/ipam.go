@@ -19,15 +19,23 @@ package plugins
 
 import (
 	"fmt"
+	"io"
 	"net/netip"
 	"sync"
+	"time"
 
 	v1 "github.com/webmeshproj/api/go/v1"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 
 	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/crypto"
+	"github.com/webmeshproj/webmesh/pkg/meshnet/netutil"
 	"github.com/webmeshproj/webmesh/pkg/storage"
+	"github.com/webmeshproj/webmesh/pkg/storage/errors"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
 )
 
 // BuiltinIPAM is the built-in IPAM plugin that uses the mesh database
@@ -37,85 +45,832 @@ type BuiltinIPAM struct {
 
 	IPAMConfig
 	mu sync.Mutex
+	// notGranted is true if the manager negotiated capabilities and did not
+	// grant IPAMV4, so another plugin is responsible for allocations.
+	notGranted bool
+	// disconnected is true if the storage returned an error signalling that
+	// it is closed. It is cleared when storage is attached with SetStorage.
+	disconnected bool
+	// pending are addresses handed out by Allocate that are not yet stored
+	// as the private address of their node, keyed by node ID.
+	pending map[string]pendingLease
+}
+
+// PendingLeaseTTL is how long an address handed out by Allocate is held for
+// a node before it must be stored as the node's private address. Until then
+// the address is not handed out to any other node.
+const PendingLeaseTTL = time.Minute
+
+// pendingLease is an address handed out by Allocate that has not been
+// written to the node's record yet.
+type pendingLease struct {
+	prefix  netip.Prefix
+	expires time.Time
 }
 
 // IPAMConfig contains static address assignments for nodes.
 type IPAMConfig struct {
 	// Storage is the storage plugin to use for IPAM.
-	Storage storage.MeshDB
-	// StaticIPv4 is a map of node names to IPv4 addresses.
-	StaticIPv4 map[string]string
+	Storage storage.MeshDB `mapstructure:"-"`
+	// MeshStorage is the raw storage used to check if allocations are
+	// paused. When nil, allocations can never be paused.
+	MeshStorage storage.MeshStorage `mapstructure:"-"`
+	// StaticIPv4 is a map of node names to IPv4 addresses. Addresses may be
+	// given as bare IPs or as /32 prefixes.
+	StaticIPv4 map[string]string `mapstructure:"static-ipv4"`
+	// ReserveGateway reserves the first usable address of each subnet as the
+	// gateway. It is never assigned to a node and is returned alongside
+	// allocations made with AllocateWithGateway.
+	ReserveGateway bool `mapstructure:"reserve-gateway"`
+	// ZonePrefixes is a map of zone awareness IDs to IPv6 prefixes. Nodes in
+	// a zone are assigned their IPv6 address from the zone's prefix instead
+	// of the mesh prefix, so routes to a zone can be summarized. Each prefix
+	// must be within the mesh IPv6 prefix.
+	ZonePrefixes map[string]string `mapstructure:"zone-prefixes"`
+}
+
+// ErrAllocationsPaused is returned by Allocate while new allocations are paused.
+var ErrAllocationsPaused = status.Error(codes.FailedPrecondition, "ipam allocations are paused")
+
+// ErrNotConfigured is returned when the plugin has no storage to allocate from.
+// This is the case when the storage it was configured with disconnected and no
+// storage has been attached since.
+var ErrNotConfigured = status.Error(codes.FailedPrecondition, "ipam plugin is not configured")
+
+// ErrNotGranted is returned when the manager did not grant the plugin the
+// IPAMV4 capability.
+var ErrNotGranted = status.Error(codes.FailedPrecondition, "ipam plugin was not granted the IPAMV4 capability")
+
+// AllocatedIPWithGateway is an allocated IP along with the gateway of the
+// subnet it was allocated from.
+type AllocatedIPWithGateway struct {
+	*v1.AllocatedIP
+	// Gateway is the gateway address of the subnet. It is empty if the
+	// gateway is not reserved.
+	Gateway string `json:"gateway,omitempty"`
+}
+
+// AllocationConflictError is returned when every candidate address in a subnet
+// collides with an existing or static allocation. A high number of static
+// collisions usually means the static assignments are misconfigured for the
+// subnet, otherwise the subnet is too small for the mesh.
+type AllocationConflictError struct {
+	// Subnet is the subnet allocation was attempted in.
+	Subnet netip.Prefix
+	// Collisions is the number of candidate addresses that were already in use.
+	Collisions int
+	// StaticCollisions is the number of collisions with static assignments.
+	StaticCollisions int
+}
+
+// Error implements error.
+func (e *AllocationConflictError) Error() string {
+	return fmt.Sprintf("no free addresses in %s: %d collisions (%d with static assignments)", e.Subnet, e.Collisions, e.StaticCollisions)
+}
+
+// GatewayFor returns the gateway address for the given subnet, which is
+// the first usable host in the prefix.
+func GatewayFor(subnet netip.Prefix) netip.Addr {
+	return subnet.Masked().Addr().Next()
 }
 
 // NewBuiltinIPAM returns a new ipam plugin with the given database.
 func NewBuiltinIPAM(opts IPAMConfig) *BuiltinIPAM {
 	return &BuiltinIPAM{
 		IPAMConfig: opts,
+		pending:    make(map[string]pendingLease),
+	}
+}
+
+// SetStorage attaches the plugin to the given storage, replacing the storage it
+// was configured with. Attaching storage clears the disconnected state, so
+// allocations resume after the storage reconnects.
+func (p *BuiltinIPAM) SetStorage(db storage.MeshDB, st storage.MeshStorage) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Storage = db
+	p.MeshStorage = st
+	p.disconnected = false
+}
+
+// Configure configures the static assignments of the plugin. It may be called
+// again to reload the configuration. The new configuration is validated in full
+// before it is applied, and when static-ipv4 is present it replaces the current
+// static assignments entirely. The same holds for zone-prefixes, which are
+// also checked against the mesh IPv6 prefix when the mesh state can be read.
+// The reserve-gateway setting is only changed when it is present.
+// If the manager negotiated capabilities without
+// granting IPAMV4, the plugin refuses to allocate or release addresses.
+func (p *BuiltinIPAM) Configure(ctx context.Context, req *v1.PluginConfiguration) (*emptypb.Empty, error) {
+	cfg, granted, negotiated, err := SplitConfig(req)
+	if err != nil {
+		return nil, err
+	}
+	config, err := decodeIPAMConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.notGranted = negotiated && !granted.Has(v1.PluginInfo_IPAMV4)
+	if config.StaticIPv4 != nil {
+		p.StaticIPv4 = config.StaticIPv4
+	}
+	if config.ZonePrefixes != nil {
+		if p.Storage != nil && !p.disconnected {
+			state, err := p.Storage.MeshState().GetMeshState(ctx)
+			if err == nil && state.NetworkV6().IsValid() {
+				if err := ValidateZonePrefixes(config.ZonePrefixes, state.NetworkV6()); err != nil {
+					return nil, fmt.Errorf("invalid configuration: %w", err)
+				}
+			}
+		}
+		p.ZonePrefixes = config.ZonePrefixes
+	}
+	if _, ok := cfg["reserve-gateway"]; ok {
+		p.ReserveGateway = config.ReserveGateway
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ValidateIPAMConfig validates a configuration for the built-in IPAM plugin
+// without applying it.
+func ValidateIPAMConfig(cfg map[string]any) error {
+	_, err := decodeIPAMConfig(cfg)
+	return err
+}
+
+// decodeIPAMConfig decodes and validates a configuration for the built-in IPAM plugin.
+func decodeIPAMConfig(cfg map[string]any) (IPAMConfig, error) {
+	var config IPAMConfig
+	err := DecodeConfig(cfg, &config)
+	if err != nil {
+		return config, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+	if err := validateStaticIPv4(config.StaticIPv4); err != nil {
+		return config, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := validateZonePrefixes(config.ZonePrefixes); err != nil {
+		return config, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return config, nil
+}
+
+// ParseZonePrefix parses the IPv6 prefix of a zone. The prefix must be between
+// a /48 and a /112 so that node addresses can be derived within it.
+func ParseZonePrefix(prefix string) (netip.Prefix, error) {
+	zone, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	if !zone.Addr().Is6() || zone.Addr().Is4In6() {
+		return netip.Prefix{}, fmt.Errorf("%s is not an IPv6 prefix", prefix)
+	}
+	if zone.Bits() < netutil.DefaultULABits || zone.Bits() > netutil.DefaultNodeBits {
+		return netip.Prefix{}, fmt.Errorf("%s must be between a /%d and a /%d", prefix, netutil.DefaultULABits, netutil.DefaultNodeBits)
+	}
+	return zone.Masked(), nil
+}
+
+// ValidateZonePrefixes checks that every zone prefix is valid and within the
+// given mesh IPv6 prefix.
+func ValidateZonePrefixes(zones map[string]string, network netip.Prefix) error {
+	if err := validateZonePrefixes(zones); err != nil {
+		return err
+	}
+	for zoneID, prefix := range zones {
+		zone, _ := ParseZonePrefix(prefix)
+		if !prefixWithin(zone, network) {
+			return fmt.Errorf("prefix %s for zone %s is not within the mesh prefix %s", prefix, zoneID, network)
+		}
+	}
+	return nil
+}
+
+// validateZonePrefixes checks that every zone prefix is valid and that no two
+// zones overlap.
+func validateZonePrefixes(zones map[string]string) error {
+	parsed := make(map[string]netip.Prefix, len(zones))
+	for zoneID, prefix := range zones {
+		if zoneID == "" {
+			return fmt.Errorf("zone ID is required for prefix %s", prefix)
+		}
+		zone, err := ParseZonePrefix(prefix)
+		if err != nil {
+			return fmt.Errorf("parse prefix for zone %s: %w", zoneID, err)
+		}
+		for otherID, other := range parsed {
+			if zone.Overlaps(other) {
+				return fmt.Errorf("prefix %s for zone %s overlaps zone %s", prefix, zoneID, otherID)
+			}
+		}
+		parsed[zoneID] = zone
 	}
+	return nil
+}
+
+// prefixWithin reports whether prefix is contained entirely in network.
+func prefixWithin(prefix, network netip.Prefix) bool {
+	return network.Bits() <= prefix.Bits() && network.Contains(prefix.Addr())
+}
+
+// ParseStaticAddress parses a static address assignment into its canonical
+// host prefix. The address may be a bare IP or a prefix covering exactly one
+// address, such as a /32 for IPv4 or a /128 for IPv6.
+func ParseStaticAddress(addr string) (netip.Prefix, error) {
+	if ip, err := netip.ParseAddr(addr); err == nil {
+		ip = ip.Unmap()
+		return netip.PrefixFrom(ip, ip.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(addr)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	ip := prefix.Addr().Unmap()
+	if prefix.Bits() != prefix.Addr().BitLen() {
+		return netip.Prefix{}, fmt.Errorf("%s is not a host address", addr)
+	}
+	return netip.PrefixFrom(ip, ip.BitLen()), nil
+}
+
+// parseSubnetV4 parses the IPv4 subnet to allocate from. An IPv4-mapped IPv6
+// subnet, such as ::ffff:10.0.0.0/120, is normalized to its IPv4 form.
+func parseSubnetV4(subnet string) (netip.Prefix, error) {
+	prefix, err := netip.ParsePrefix(subnet)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("parse subnet: %w", err)
+	}
+	prefix = types.UnmapPrefix(prefix)
+	if !prefix.Addr().Is4() {
+		return netip.Prefix{}, fmt.Errorf("parse subnet: %s is not an IPv4 subnet", subnet)
+	}
+	return prefix, nil
+}
+
+// validateStaticIPv4 checks that every static assignment is an IPv4 host
+// address and that no address is assigned to more than one node.
+func validateStaticIPv4(static map[string]string) error {
+	seen := make(map[netip.Prefix]string, len(static))
+	for node, addr := range static {
+		prefix, err := ParseStaticAddress(addr)
+		if err != nil {
+			return fmt.Errorf("parse static address for %s: %w", node, err)
+		}
+		if !prefix.Addr().Is4() {
+			return fmt.Errorf("static address for %s is not IPv4: %s", node, addr)
+		}
+		if other, ok := seen[prefix]; ok {
+			return fmt.Errorf("static address %s assigned to both %s and %s", addr, other, node)
+		}
+		seen[prefix] = node
+	}
+	return nil
 }
 
 func (p *BuiltinIPAM) Allocate(ctx context.Context, r *v1.AllocateIPRequest, opts ...grpc.CallOption) (*v1.AllocatedIP, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if p.Storage == nil || p.disconnected {
+		return nil, ErrNotConfigured
+	}
+	if p.notGranted {
+		return nil, ErrNotGranted
+	}
+	if err := p.checkPaused(ctx); err != nil {
+		return nil, err
+	}
 	if addr, ok := p.StaticIPv4[r.GetNodeID()]; ok {
+		prefix, err := ParseStaticAddress(addr)
+		if err != nil {
+			return nil, fmt.Errorf("parse static address for %s: %w", r.GetNodeID(), err)
+		}
 		return &v1.AllocatedIP{
-			Ip: addr,
+			Ip: prefix.String(),
 		}, nil
 	}
 	return p.allocateV4(ctx, r)
 }
 
+// AllocateV6 assigns an IPv6 address to a node from the given mesh prefix,
+// derived from the node's public key. If the node is in a zone with a
+// configured prefix, the address is assigned from the zone's prefix instead.
+// An error is returned if the zone's prefix is not within the mesh prefix.
+func (p *BuiltinIPAM) AllocateV6(ctx context.Context, network netip.Prefix, zoneID string, publicKey crypto.PublicKey) (netip.Prefix, error) {
+	if err := ctx.Err(); err != nil {
+		return netip.Prefix{}, err
+	}
+	p.mu.Lock()
+	prefix, ok := p.ZonePrefixes[zoneID]
+	p.mu.Unlock()
+	if zoneID == "" || !ok {
+		return netutil.AssignToPrefix(network, publicKey), nil
+	}
+	zone, err := ParseZonePrefix(prefix)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("parse prefix for zone %s: %w", zoneID, err)
+	}
+	if !prefixWithin(zone, network) {
+		return netip.Prefix{}, fmt.Errorf("prefix %s for zone %s is not within the mesh prefix %s", prefix, zoneID, network)
+	}
+	return netutil.AssignToSubPrefix(zone, publicKey)
+}
+
+// AllocateBulk allocates an IPv4 address from the subnet for each of the given
+// nodes under a single hold of the allocation lock, so no other allocation can
+// interleave with the batch. Each address is reserved for its node in the IPAM
+// keyspace until the node is stored with it or the address is released. Until
+// then it is not handed out to any other node, and Allocate returns it to the
+// node it is reserved for. The reservations are written in a single transaction,
+// or one at a time if the storage does not support transactions, in which case
+// the written reservations are rolled back on failure. Nodes with static
+// assignments receive their static address. If any step fails none of the
+// addresses are returned. The allocations are returned in the order of nodeIDs.
+func (p *BuiltinIPAM) AllocateBulk(ctx context.Context, subnet string, nodeIDs []string) ([]*v1.AllocatedIP, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(nodeIDs) == 0 {
+		return nil, fmt.Errorf("at least one node ID is required")
+	}
+	seen := make(map[string]struct{}, len(nodeIDs))
+	for _, id := range nodeIDs {
+		if !types.IsValidNodeID(id) {
+			return nil, fmt.Errorf("%w: %s", errors.ErrInvalidNodeID, id)
+		}
+		if _, ok := seen[id]; ok {
+			return nil, fmt.Errorf("duplicate node ID %s", id)
+		}
+		seen[id] = struct{}{}
+	}
+	if p.Storage == nil || p.MeshStorage == nil || p.disconnected {
+		return nil, ErrNotConfigured
+	}
+	if p.notGranted {
+		return nil, ErrNotGranted
+	}
+	if err := p.checkPaused(ctx); err != nil {
+		return nil, err
+	}
+	globalPrefix, err := parseSubnetV4(subnet)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := p.Storage.Peers().List(ctx)
+	if err != nil {
+		if p.checkDisconnected(ctx, err) {
+			return nil, ErrNotConfigured
+		}
+		return nil, fmt.Errorf("list nodes: %w", err)
+	}
+	reservations, stale, err := p.reservations(ctx, nodes)
+	if err != nil {
+		return nil, err
+	}
+	allocated := make(map[netip.Prefix]struct{}, len(nodes)+len(nodeIDs)+len(reservations)+len(p.pending))
+	for _, node := range nodes {
+		if node.PrivateAddrV4().IsValid() {
+			allocated[node.PrivateAddrV4()] = struct{}{}
+		}
+	}
+	p.prunePending(nodes, time.Now())
+	// Addresses handed out to or reserved for other nodes are in use. The
+	// nodes in the batch replace their own.
+	for id, lease := range p.pending {
+		if _, ok := seen[id]; !ok {
+			allocated[lease.prefix] = struct{}{}
+		}
+	}
+	for id, prefix := range reservations {
+		if _, ok := seen[id]; !ok {
+			allocated[prefix] = struct{}{}
+		}
+	}
+	batch := make([]ipamReservation, 0, len(nodeIDs))
+	out := make([]*v1.AllocatedIP, 0, len(nodeIDs))
+	for _, id := range nodeIDs {
+		if addr, ok := p.StaticIPv4[id]; ok {
+			prefix, err := ParseStaticAddress(addr)
+			if err != nil {
+				return nil, fmt.Errorf("parse static address for %s: %w", id, err)
+			}
+			out = append(out, &v1.AllocatedIP{Ip: prefix.String()})
+			continue
+		}
+		prefix, err := p.next32(ctx, globalPrefix, allocated)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("find next available IPv4: %w", err)
+		}
+		allocated[prefix] = struct{}{}
+		batch = append(batch, ipamReservation{nodeID: id, prefix: prefix})
+		out = append(out, &v1.AllocatedIP{Ip: prefix.String()})
+	}
+	if err := p.storeReservations(ctx, batch, reservations, stale); err != nil {
+		if p.checkDisconnected(ctx, err) {
+			return nil, ErrNotConfigured
+		}
+		return nil, err
+	}
+	// The batch stored its reservations, so any address previously handed
+	// out to its nodes is no longer in flight.
+	for _, id := range nodeIDs {
+		delete(p.pending, id)
+	}
+	return out, nil
+}
+
+// ipamReservation is an address reserved for a node by AllocateBulk.
+type ipamReservation struct {
+	nodeID string
+	prefix netip.Prefix
+}
+
+// reservations returns the addresses reserved by AllocateBulk that are not yet
+// stored as the private address of their node, keyed by node ID, and the IDs of
+// the nodes whose reservations were stored and can be removed. It must be
+// called with the lock held.
+func (p *BuiltinIPAM) reservations(ctx context.Context, nodes []types.MeshNode) (map[string]netip.Prefix, []string, error) {
+	if p.MeshStorage == nil {
+		return nil, nil, nil
+	}
+	reserved, err := storage.ListIPAMReservations(ctx, p.MeshStorage)
+	if err != nil {
+		if p.checkDisconnected(ctx, err) {
+			return nil, nil, ErrNotConfigured
+		}
+		return nil, nil, err
+	}
+	current := make(map[string]netip.Prefix, len(nodes))
+	for _, node := range nodes {
+		current[node.GetId()] = node.PrivateAddrV4()
+	}
+	out := make(map[string]netip.Prefix, len(reserved))
+	var stale []string
+	for id, prefix := range reserved {
+		if current[id.String()] == prefix {
+			stale = append(stale, id.String())
+			continue
+		}
+		out[id.String()] = prefix
+	}
+	return out, stale, nil
+}
+
+// storeReservations writes the reservations made by a batch and removes the
+// stale ones in a single transaction. Each write is checked against the
+// reservation previously read for the node, so the batch fails if another
+// writer changed it. If the storage does not support transactions the
+// reservations are written one at a time and restored to their previous
+// values on failure. It must be called with the lock held.
+func (p *BuiltinIPAM) storeReservations(ctx context.Context, batch []ipamReservation, previous map[string]netip.Prefix, stale []string) error {
+	txn, err := storage.Begin(ctx, p.MeshStorage)
+	if err != nil {
+		if errors.Is(err, errors.ErrNotImplemented) {
+			return p.putReservations(ctx, batch, previous, stale)
+		}
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	err = func() error {
+		for _, r := range batch {
+			key := storage.IPAMReservationKey(types.NodeID(r.nodeID))
+			var expected []byte
+			if prefix, ok := previous[r.nodeID]; ok {
+				expected = []byte(prefix.String())
+			}
+			if err := txn.Check(key, expected); err != nil {
+				return err
+			}
+			if err := txn.PutValue(key, []byte(r.prefix.String()), 0); err != nil {
+				return err
+			}
+		}
+		for _, id := range stale {
+			if err := txn.Delete(storage.IPAMReservationKey(types.NodeID(id))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		_ = txn.Rollback()
+		return fmt.Errorf("reserve addresses: %w", err)
+	}
+	if err := txn.Commit(ctx); err != nil {
+		return fmt.Errorf("reserve addresses: %w", err)
+	}
+	return nil
+}
+
+// putReservations writes the reservations made by a batch one at a time for
+// storage without transactions. It must be called with the lock held.
+func (p *BuiltinIPAM) putReservations(ctx context.Context, batch []ipamReservation, previous map[string]netip.Prefix, stale []string) error {
+	for i, r := range batch {
+		err := p.MeshStorage.PutValue(ctx, storage.IPAMReservationKey(types.NodeID(r.nodeID)), []byte(r.prefix.String()), 0)
+		if err == nil {
+			continue
+		}
+		// Roll back on a fresh context so a cancelled request still cleans up.
+		rctx := context.WithLogger(context.Background(), context.LoggerFrom(ctx))
+		errs := []error{fmt.Errorf("reserve %s for %s: %w", r.prefix, r.nodeID, err)}
+		for j := i - 1; j >= 0; j-- {
+			id := batch[j].nodeID
+			var err error
+			if prefix, ok := previous[id]; ok {
+				err = p.MeshStorage.PutValue(rctx, storage.IPAMReservationKey(types.NodeID(id)), []byte(prefix.String()), 0)
+			} else {
+				err = storage.DeleteIPAMReservation(rctx, p.MeshStorage, types.NodeID(id))
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("roll back reservation for %s: %w", id, err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+	// Stale reservations only hold addresses their nodes already store, so
+	// failing to remove them is not fatal.
+	for _, id := range stale {
+		if err := storage.DeleteIPAMReservation(ctx, p.MeshStorage, types.NodeID(id)); err != nil {
+			context.LoggerFrom(ctx).Warn("Failed to remove stale IPAM reservation", "node", id, "error", err.Error())
+		}
+	}
+	return nil
+}
+
+// AllocateWithGateway allocates an IP like Allocate and includes the gateway
+// of the subnet in the response when ReserveGateway is enabled.
+func (p *BuiltinIPAM) AllocateWithGateway(ctx context.Context, r *v1.AllocateIPRequest) (*AllocatedIPWithGateway, error) {
+	alloc, err := p.Allocate(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	out := &AllocatedIPWithGateway{AllocatedIP: alloc}
+	p.mu.Lock()
+	reserveGateway := p.ReserveGateway
+	p.mu.Unlock()
+	if reserveGateway {
+		subnet, err := parseSubnetV4(r.GetSubnet())
+		if err != nil {
+			return nil, err
+		}
+		out.Gateway = GatewayFor(subnet).String()
+	}
+	return out, nil
+}
+
+// Release releases the IPv4 address assigned to the node in the request so it
+// can be allocated again. The address stored for the node, handed out to it and
+// not yet stored, or reserved for it by AllocateBulk, is cleared if it matches
+// the requested IP, or unconditionally if no IP is given. Static
+// assignments are part of the configuration and are never released. Releasing
+// the address of an unknown node is a no-op.
 func (p *BuiltinIPAM) Release(ctx context.Context, req *v1.ReleaseIPRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	return nil, ErrUnsupported
+	if req.GetNodeID() == "" {
+		return nil, fmt.Errorf("node ID is required")
+	}
+	if _, ok := p.StaticIPv4[req.GetNodeID()]; ok {
+		return &emptypb.Empty{}, nil
+	}
+	if p.Storage == nil || p.disconnected {
+		return nil, ErrNotConfigured
+	}
+	if p.notGranted {
+		return nil, ErrNotGranted
+	}
+	var ip netip.Prefix
+	if req.GetIp() != "" {
+		var err error
+		ip, err = ParseStaticAddress(req.GetIp())
+		if err != nil {
+			return nil, fmt.Errorf("parse IP: %w", err)
+		}
+	}
+	if lease, ok := p.pending[req.GetNodeID()]; ok && (!ip.IsValid() || ip == lease.prefix) {
+		delete(p.pending, req.GetNodeID())
+	}
+	if err := p.releaseReservation(ctx, req.GetNodeID(), ip); err != nil {
+		return nil, err
+	}
+	err := p.Storage.Peers().Update(ctx, types.NodeID(req.GetNodeID()), func(node *types.MeshNode) error {
+		current := node.PrivateAddrV4()
+		if !current.IsValid() || (ip.IsValid() && ip != current) {
+			return nil
+		}
+		node.PrivateIPv4 = ""
+		return nil
+	})
+	if err != nil {
+		if errors.IsNodeNotFound(err) {
+			return &emptypb.Empty{}, nil
+		}
+		if p.checkDisconnected(ctx, err) {
+			return nil, ErrNotConfigured
+		}
+		return nil, fmt.Errorf("update node: %w", err)
+	}
+	return &emptypb.Empty{}, nil
 }
 
 func (p *BuiltinIPAM) allocateV4(ctx context.Context, r *v1.AllocateIPRequest) (*v1.AllocatedIP, error) {
-	globalPrefix, err := netip.ParsePrefix(r.GetSubnet())
+	globalPrefix, err := parseSubnetV4(r.GetSubnet())
 	if err != nil {
-		return nil, fmt.Errorf("parse subnet: %w", err)
+		return nil, err
 	}
 	nodes, err := p.Storage.Peers().List(ctx)
 	if err != nil {
+		if p.checkDisconnected(ctx, err) {
+			return nil, ErrNotConfigured
+		}
 		return nil, fmt.Errorf("list nodes: %w", err)
 	}
-	allocated := make(map[netip.Prefix]struct{}, len(nodes))
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	reservations, _, err := p.reservations(ctx, nodes)
+	if err != nil {
+		return nil, err
+	}
+	// A node with an address reserved by AllocateBulk receives it.
+	if prefix, ok := reservations[r.GetNodeID()]; ok && globalPrefix.Contains(prefix.Addr()) {
+		delete(p.pending, r.GetNodeID())
+		return &v1.AllocatedIP{
+			Ip: prefix.String(),
+		}, nil
+	}
+	allocated := make(map[netip.Prefix]struct{}, len(nodes)+len(reservations)+len(p.pending))
 	for _, node := range nodes {
 		n := node
 		if n.PrivateAddrV4().IsValid() {
 			allocated[n.PrivateAddrV4()] = struct{}{}
 		}
 	}
-	prefix, err := p.next32(globalPrefix, allocated)
+	for _, prefix := range reservations {
+		allocated[prefix] = struct{}{}
+	}
+	now := time.Now()
+	p.prunePending(nodes, now)
+	// Addresses handed out to other nodes are in use until their records are
+	// written. A node asking again replaces its own pending lease.
+	for id, lease := range p.pending {
+		if id != r.GetNodeID() {
+			allocated[lease.prefix] = struct{}{}
+		}
+	}
+	prefix, err := p.next32(ctx, globalPrefix, allocated)
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
 		return nil, fmt.Errorf("find next available IPv4: %w", err)
 	}
+	p.pending[r.GetNodeID()] = pendingLease{prefix: prefix, expires: now.Add(PendingLeaseTTL)}
 	return &v1.AllocatedIP{
 		Ip: prefix.String(),
 	}, nil
 }
 
-func (p *BuiltinIPAM) next32(cidr netip.Prefix, set map[netip.Prefix]struct{}) (netip.Prefix, error) {
-	ip := cidr.Addr().Next()
-	for cidr.Contains(ip) {
-		prefix := netip.PrefixFrom(ip, 32)
-		if _, ok := set[prefix]; !ok && !p.isStaticAllocation(prefix) {
-			return prefix, nil
+// releaseReservation removes the address reserved for the node by AllocateBulk
+// if it matches the given IP, or unconditionally if the IP is not valid. It must
+// be called with the lock held.
+func (p *BuiltinIPAM) releaseReservation(ctx context.Context, nodeID string, ip netip.Prefix) error {
+	if p.MeshStorage == nil {
+		return nil
+	}
+	key := storage.IPAMReservationKey(types.NodeID(nodeID))
+	val, err := p.MeshStorage.GetValue(ctx, key)
+	if err != nil {
+		if errors.IsKeyNotFound(err) {
+			return nil
 		}
-		ip = ip.Next()
+		if p.checkDisconnected(ctx, err) {
+			return ErrNotConfigured
+		}
+		return fmt.Errorf("get ipam reservation: %w", err)
+	}
+	if ip.IsValid() && string(val) != ip.String() {
+		return nil
+	}
+	if err := storage.DeleteIPAMReservation(ctx, p.MeshStorage, types.NodeID(nodeID)); err != nil {
+		if p.checkDisconnected(ctx, err) {
+			return ErrNotConfigured
+		}
+		return err
 	}
-	return netip.Prefix{}, fmt.Errorf("no more addresses in %s", cidr)
+	return nil
 }
 
-func (p *BuiltinIPAM) isStaticAllocation(ip netip.Prefix) bool {
-	if ip.Addr().Is4() {
-		for _, addr := range p.StaticIPv4 {
-			if addr == ip.String() {
-				return true
-			}
+// prunePending removes pending leases that expired or that were written to
+// their node's record. It must be called with the lock held.
+func (p *BuiltinIPAM) prunePending(nodes []types.MeshNode, now time.Time) {
+	if len(p.pending) == 0 {
+		return
+	}
+	stored := make(map[string]netip.Prefix, len(nodes))
+	for _, node := range nodes {
+		stored[node.GetId()] = node.PrivateAddrV4()
+	}
+	for id, lease := range p.pending {
+		if now.After(lease.expires) || stored[id] == lease.prefix {
+			delete(p.pending, id)
+		}
+	}
+}
+
+// checkPaused returns ErrAllocationsPaused if allocations are currently paused.
+// It must be called with the lock held.
+func (p *BuiltinIPAM) checkPaused(ctx context.Context) error {
+	if p.MeshStorage == nil {
+		return nil
+	}
+	paused, err := storage.IsIPAMPaused(ctx, p.MeshStorage)
+	if err != nil {
+		if p.checkDisconnected(ctx, err) {
+			return ErrNotConfigured
 		}
+		return fmt.Errorf("check if allocations are paused: %w", err)
+	}
+	if paused {
+		return ErrAllocationsPaused
+	}
+	return nil
+}
+
+// checkDisconnected reports whether the given storage error means the storage
+// has disconnected. If so, the plugin is marked disconnected so subsequent calls
+// fail fast with ErrNotConfigured instead of using stale storage, until storage
+// is attached again with SetStorage. It must be called with the lock held.
+func (p *BuiltinIPAM) checkDisconnected(ctx context.Context, err error) bool {
+	if !isDisconnectedError(err) {
 		return false
 	}
-	return false
+	context.LoggerFrom(ctx).Error("IPAM storage disconnected, marking plugin as unconfigured", "error", err.Error())
+	p.disconnected = true
+	return true
+}
+
+// isDisconnectedError returns true if the error signals that the storage
+// or the stream used to query it is closed.
+func isDisconnectedError(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, errors.ErrClosed) {
+		return true
+	}
+	return status.Code(err) == codes.Unavailable
+}
+
+// next32 returns the next free /32 in the given subnet, skipping the given
+// allocations, the static assignments, and the gateway if it is reserved.
+func (p *BuiltinIPAM) next32(ctx context.Context, cidr netip.Prefix, set map[netip.Prefix]struct{}) (netip.Prefix, error) {
+	static := p.staticAllocations()
+	reserved := make(map[netip.Prefix]struct{}, len(set)+len(static)+1)
+	for prefix := range set {
+		reserved[prefix] = struct{}{}
+	}
+	for prefix := range static {
+		reserved[prefix] = struct{}{}
+	}
+	gateway := GatewayFor(cidr)
+	if p.ReserveGateway {
+		reserved[netip.PrefixFrom(gateway, 32)] = struct{}{}
+	}
+	prefix, err := netutil.NextAvailablePrefix(ctx, cidr, 32, reserved)
+	if err == nil {
+		return prefix, nil
+	}
+	if !errors.Is(err, netutil.ErrNoAvailablePrefix) {
+		return netip.Prefix{}, err
+	}
+	// Every candidate is taken, count what they collided with.
+	conflict := &AllocationConflictError{Subnet: cidr}
+	network, broadcast, _ := netutil.NetworkAndBroadcast(cidr)
+	for prefix := range reserved {
+		addr := prefix.Addr()
+		if !cidr.Contains(addr) || addr == network || addr == broadcast || (p.ReserveGateway && addr == gateway) {
+			continue
+		}
+		conflict.Collisions++
+		if _, ok := static[prefix]; ok {
+			conflict.StaticCollisions++
+		}
+	}
+	return netip.Prefix{}, conflict
+}
+
+// staticAllocations returns the set of valid static IPv4 assignments.
+func (p *BuiltinIPAM) staticAllocations() map[netip.Prefix]struct{} {
+	out := make(map[netip.Prefix]struct{}, len(p.StaticIPv4))
+	for _, addr := range p.StaticIPv4 {
+		static, err := ParseStaticAddress(addr)
+		if err == nil && static.Addr().Is4() {
+			out[static] = struct{}{}
+		}
+	}
+	return out
 }
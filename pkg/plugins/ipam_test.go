@@ -0,0 +1,982 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/webmeshproj/webmesh/pkg/crypto"
+	"github.com/webmeshproj/webmesh/pkg/meshnet/netutil"
+	"github.com/webmeshproj/webmesh/pkg/storage"
+	"github.com/webmeshproj/webmesh/pkg/storage/meshdb"
+	"github.com/webmeshproj/webmesh/pkg/storage/providers/backends/badgerdb"
+	"github.com/webmeshproj/webmesh/pkg/storage/rpcdb"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+func TestBuiltinIPAMAllocate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("CancelledContext", func(t *testing.T) {
+		ipam := newTestIPAM(t, IPAMConfig{})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		start := time.Now()
+		_, err := ipam.Allocate(ctx, &v1.AllocateIPRequest{
+			NodeID: "foo",
+			Subnet: "10.0.0.0/8",
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("expected allocate to return immediately, took %s", elapsed)
+		}
+	})
+}
+
+func TestBuiltinIPAMAllocateBareStaticAddress(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ipam := newTestIPAM(t, IPAMConfig{
+		StaticIPv4: map[string]string{
+			"static": "10.0.0.5",
+		},
+	})
+	for i, addr := range []string{"10.0.0.1/32", "10.0.0.2/32", "10.0.0.3/32", "10.0.0.4/32"} {
+		putTestNode(t, ipam, fmt.Sprintf("node-%d", i), addr)
+	}
+	alloc, err := ipam.Allocate(ctx, &v1.AllocateIPRequest{NodeID: "new-node", Subnet: "10.0.0.0/24"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if alloc.GetIp() != "10.0.0.6/32" {
+		t.Fatalf("expected static address to be skipped and 10.0.0.6/32 allocated, got %s", alloc.GetIp())
+	}
+	alloc, err = ipam.Allocate(ctx, &v1.AllocateIPRequest{NodeID: "static", Subnet: "10.0.0.0/24"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if alloc.GetIp() != "10.0.0.5/32" {
+		t.Fatalf("expected static node to be allocated 10.0.0.5/32, got %s", alloc.GetIp())
+	}
+}
+
+func TestBuiltinIPAMAllocateMappedAddresses(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("MappedNodeAddress", func(t *testing.T) {
+		t.Parallel()
+		ipam := newTestIPAM(t, IPAMConfig{})
+		putTestNode(t, ipam, "mapped", "::ffff:10.0.0.1/128")
+		alloc, err := ipam.Allocate(ctx, &v1.AllocateIPRequest{NodeID: "new-node", Subnet: "10.0.0.0/24"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if alloc.GetIp() != "10.0.0.2/32" {
+			t.Fatalf("expected mapped address to be treated as allocated and 10.0.0.2/32 returned, got %s", alloc.GetIp())
+		}
+	})
+
+	t.Run("MappedSubnet", func(t *testing.T) {
+		t.Parallel()
+		ipam := newTestIPAM(t, IPAMConfig{})
+		putTestNode(t, ipam, "existing", "10.0.0.1/32")
+		alloc, err := ipam.Allocate(ctx, &v1.AllocateIPRequest{NodeID: "new-node", Subnet: "::ffff:10.0.0.0/120"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if alloc.GetIp() != "10.0.0.2/32" {
+			t.Fatalf("expected 10.0.0.2/32 to be allocated from the mapped subnet, got %s", alloc.GetIp())
+		}
+		allocs, err := ipam.AllocateBulk(ctx, "::ffff:10.0.0.0/120", []string{"bulk-node"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// 10.0.0.2 is still held for new-node until its record is written.
+		if len(allocs) != 1 || allocs[0].GetIp() != "10.0.0.3/32" {
+			t.Fatalf("expected bulk allocation of 10.0.0.3/32 from the mapped subnet, got %v", allocs)
+		}
+	})
+
+	t.Run("IPv6Subnet", func(t *testing.T) {
+		t.Parallel()
+		ipam := newTestIPAM(t, IPAMConfig{})
+		_, err := ipam.Allocate(ctx, &v1.AllocateIPRequest{NodeID: "new-node", Subnet: "fd00::/64"})
+		if err == nil {
+			t.Fatal("expected an error allocating from an IPv6 subnet")
+		}
+	})
+}
+
+func TestBuiltinIPAMGrantedCapabilities(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	tc := []struct {
+		name    string
+		granted []any
+		wantErr error
+	}{
+		{
+			name:    "NotNegotiated",
+			granted: nil,
+		},
+		{
+			name:    "Granted",
+			granted: []any{v1.PluginInfo_IPAMV4.String()},
+		},
+		{
+			name:    "NotGranted",
+			granted: []any{v1.PluginInfo_STORAGE_QUERIER.String()},
+			wantErr: ErrNotGranted,
+		},
+	}
+	for _, tt := range tc {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ipam := newTestIPAM(t, IPAMConfig{})
+			cfg := map[string]any{"reserve-gateway": false}
+			if tt.granted != nil {
+				cfg[CapabilitiesConfigKey] = tt.granted
+			}
+			conf, err := structpb.NewStruct(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ipam.Configure(ctx, &v1.PluginConfiguration{Config: conf}); err != nil {
+				t.Fatalf("configure: %v", err)
+			}
+			_, err = ipam.Allocate(ctx, &v1.AllocateIPRequest{NodeID: "node", Subnet: "10.0.0.0/24"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected allocate error %v, got %v", tt.wantErr, err)
+			}
+			_, err = ipam.AllocateBulk(ctx, "10.0.0.0/24", []string{"bulk-node"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected bulk allocate error %v, got %v", tt.wantErr, err)
+			}
+			_, err = ipam.Release(ctx, &v1.ReleaseIPRequest{NodeID: "node"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected release error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("InvalidCapability", func(t *testing.T) {
+		t.Parallel()
+		ipam := newTestIPAM(t, IPAMConfig{})
+		conf, err := structpb.NewStruct(map[string]any{
+			CapabilitiesConfigKey: []any{"NOT_A_CAPABILITY"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ipam.Configure(ctx, &v1.PluginConfiguration{Config: conf}); err == nil {
+			t.Fatal("expected an error for an unknown capability")
+		}
+	})
+}
+
+func TestBuiltinIPAMRelease(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ipam := newTestIPAM(t, IPAMConfig{
+		StaticIPv4: map[string]string{"static": "10.0.0.3"},
+	})
+	putTestNode(t, ipam, "node-0", "10.0.0.1/32")
+	putTestNode(t, ipam, "node-1", "10.0.0.2/32")
+	allocate := func(t *testing.T) string {
+		t.Helper()
+		alloc, err := ipam.Allocate(ctx, &v1.AllocateIPRequest{NodeID: "new-node", Subnet: "10.0.0.0/24"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return alloc.GetIp()
+	}
+	release := func(t *testing.T, node, ip string) {
+		t.Helper()
+		if _, err := ipam.Release(ctx, &v1.ReleaseIPRequest{NodeID: node, Ip: ip}); err != nil {
+			t.Fatalf("unexpected error releasing %s: %v", node, err)
+		}
+	}
+
+	// Releasing a different address or an unknown node is a no-op.
+	release(t, "node-0", "10.0.0.9/32")
+	release(t, "unknown-node", "")
+	release(t, "static", "")
+	if got := allocate(t); got != "10.0.0.4/32" {
+		t.Fatalf("expected 10.0.0.4/32 before release, got %s", got)
+	}
+	// Releasing the node's address makes it allocatable again.
+	release(t, "node-0", "10.0.0.1/32")
+	if got := allocate(t); got != "10.0.0.1/32" {
+		t.Fatalf("expected released address 10.0.0.1/32, got %s", got)
+	}
+	if _, err := ipam.Release(ctx, &v1.ReleaseIPRequest{}); err == nil {
+		t.Fatal("expected error releasing without a node ID")
+	}
+}
+
+func TestBuiltinIPAMPause(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	st := badgerdb.NewTestStorage(false)
+	t.Cleanup(func() { _ = st.Close() })
+	ipam := newTestIPAM(t, IPAMConfig{
+		Storage:     meshdb.NewFromStorage(st),
+		MeshStorage: st,
+	})
+	allocate := func() (*v1.AllocatedIP, error) {
+		return ipam.Allocate(ctx, &v1.AllocateIPRequest{NodeID: "new-node", Subnet: "10.0.0.0/24"})
+	}
+	if _, err := allocate(); err != nil {
+		t.Fatalf("unexpected error before pausing: %v", err)
+	}
+	if err := storage.SetIPAMPaused(ctx, st, true); err != nil {
+		t.Fatal(err)
+	}
+	_, err := allocate()
+	if !errors.Is(err, ErrAllocationsPaused) {
+		t.Fatalf("expected ErrAllocationsPaused, got %v", err)
+	}
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %s", status.Code(err))
+	}
+	if err := storage.SetIPAMPaused(ctx, st, false); err != nil {
+		t.Fatal(err)
+	}
+	alloc, err := allocate()
+	if err != nil {
+		t.Fatalf("unexpected error after resuming: %v", err)
+	}
+	if alloc.GetIp() != "10.0.0.1/32" {
+		t.Fatalf("expected 10.0.0.1/32 after resuming, got %s", alloc.GetIp())
+	}
+}
+
+func TestBuiltinIPAMDisconnected(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tc := []struct {
+		name string
+		err  error
+	}{
+		{name: "StreamClosed", err: io.EOF},
+		{name: "Unavailable", err: status.Error(codes.Unavailable, "transport is closing")},
+	}
+	for _, tt := range tc {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var queries atomic.Int32
+			querier := rpcdb.QuerierFunc(func(ctx context.Context, query *v1.QueryRequest) (*v1.QueryResponse, error) {
+				queries.Add(1)
+				return nil, tt.err
+			})
+			ipam := newTestIPAM(t, IPAMConfig{Storage: rpcdb.Open(querier)})
+			req := &v1.AllocateIPRequest{NodeID: "new-node", Subnet: "10.0.0.0/24"}
+			_, err := ipam.Allocate(ctx, req)
+			if !errors.Is(err, ErrNotConfigured) {
+				t.Fatalf("expected ErrNotConfigured, got %v", err)
+			}
+			if !ipam.disconnected {
+				t.Fatal("expected plugin to be marked disconnected")
+			}
+			// Subsequent calls should fail without touching the storage.
+			seen := queries.Load()
+			_, err = ipam.Allocate(ctx, req)
+			if !errors.Is(err, ErrNotConfigured) {
+				t.Fatalf("expected ErrNotConfigured, got %v", err)
+			}
+			_, err = ipam.Release(ctx, &v1.ReleaseIPRequest{NodeID: "new-node"})
+			if !errors.Is(err, ErrNotConfigured) {
+				t.Fatalf("expected ErrNotConfigured, got %v", err)
+			}
+			if got := queries.Load(); got != seen {
+				t.Fatalf("expected no further queries, got %d", got-seen)
+			}
+			// Attaching storage again resumes allocations.
+			db := meshdb.NewTestDB()
+			t.Cleanup(func() { _ = db.Close() })
+			ipam.SetStorage(db, nil)
+			alloc, err := ipam.Allocate(ctx, req)
+			if err != nil {
+				t.Fatalf("expected allocation to succeed after reattaching storage, got %v", err)
+			}
+			if alloc.GetIp() != "10.0.0.1/32" {
+				t.Fatalf("expected 10.0.0.1/32, got %s", alloc.GetIp())
+			}
+		})
+	}
+
+	t.Run("OtherErrors", func(t *testing.T) {
+		t.Parallel()
+		querier := rpcdb.QuerierFunc(func(ctx context.Context, query *v1.QueryRequest) (*v1.QueryResponse, error) {
+			return nil, status.Error(codes.Internal, "query failed")
+		})
+		ipam := newTestIPAM(t, IPAMConfig{Storage: rpcdb.Open(querier)})
+		_, err := ipam.Allocate(ctx, &v1.AllocateIPRequest{NodeID: "new-node", Subnet: "10.0.0.0/24"})
+		if err == nil || errors.Is(err, ErrNotConfigured) {
+			t.Fatalf("expected a query error, got %v", err)
+		}
+		if ipam.disconnected {
+			t.Fatal("expected plugin to remain configured")
+		}
+	})
+}
+
+func TestBuiltinIPAMAllocateConflict(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	// A /29 has six candidate addresses between the network and broadcast
+	// addresses, all of which are taken.
+	ipam := newTestIPAM(t, IPAMConfig{
+		StaticIPv4: map[string]string{
+			"static-1": "10.0.0.1/32",
+			"static-2": "10.0.0.2/32",
+			"static-3": "10.0.0.3/32",
+			"static-4": "10.0.0.4/32",
+		},
+	})
+	for i, addr := range []string{"10.0.0.5/32", "10.0.0.6/32"} {
+		putTestNode(t, ipam, fmt.Sprintf("node-%d", i), addr)
+	}
+	_, err := ipam.Allocate(ctx, &v1.AllocateIPRequest{NodeID: "new-node", Subnet: "10.0.0.0/29"})
+	var conflict *AllocationConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected an AllocationConflictError, got %v", err)
+	}
+	if conflict.Subnet.String() != "10.0.0.0/29" {
+		t.Errorf("expected subnet 10.0.0.0/29, got %s", conflict.Subnet)
+	}
+	if conflict.Collisions != 6 {
+		t.Errorf("expected 6 collisions, got %d", conflict.Collisions)
+	}
+	if conflict.StaticCollisions != 4 {
+		t.Errorf("expected 4 static collisions, got %d", conflict.StaticCollisions)
+	}
+}
+
+func TestBuiltinIPAMAllocatePending(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	allocate := func(t *testing.T, ipam *BuiltinIPAM, node string) string {
+		t.Helper()
+		alloc, err := ipam.Allocate(ctx, &v1.AllocateIPRequest{NodeID: node, Subnet: "10.0.0.0/24"})
+		if err != nil {
+			t.Fatalf("allocate %s: %v", node, err)
+		}
+		return alloc.GetIp()
+	}
+
+	t.Run("ConcurrentJoins", func(t *testing.T) {
+		t.Parallel()
+		ipam := newTestIPAM(t, IPAMConfig{})
+		// None of the joining nodes are written to storage before the others
+		// allocate, so each address is only held by the pending lease.
+		const joins = 8
+		addrs := make(chan string, joins)
+		errs := make(chan error, joins)
+		for i := 0; i < joins; i++ {
+			go func(i int) {
+				alloc, err := ipam.Allocate(ctx, &v1.AllocateIPRequest{NodeID: fmt.Sprintf("joining-%d", i), Subnet: "10.0.0.0/24"})
+				if err != nil {
+					errs <- err
+					return
+				}
+				addrs <- alloc.GetIp()
+			}(i)
+		}
+		seen := make(map[string]struct{}, joins)
+		for i := 0; i < joins; i++ {
+			select {
+			case err := <-errs:
+				t.Fatalf("unexpected error: %v", err)
+			case addr := <-addrs:
+				if _, ok := seen[addr]; ok {
+					t.Fatalf("address %s was handed out twice", addr)
+				}
+				seen[addr] = struct{}{}
+			}
+		}
+	})
+
+	t.Run("Written", func(t *testing.T) {
+		t.Parallel()
+		ipam := newTestIPAM(t, IPAMConfig{})
+		addr := allocate(t, ipam, "joining")
+		putTestNode(t, ipam, "joining", addr)
+		if got := allocate(t, ipam, "other"); got == addr {
+			t.Fatalf("expected stored address %s to stay allocated", addr)
+		}
+		if _, ok := ipam.pending["joining"]; ok {
+			t.Fatal("expected pending lease to be removed once the node was written")
+		}
+	})
+
+	t.Run("Released", func(t *testing.T) {
+		t.Parallel()
+		ipam := newTestIPAM(t, IPAMConfig{})
+		addr := allocate(t, ipam, "joining")
+		if _, err := ipam.Release(ctx, &v1.ReleaseIPRequest{NodeID: "joining", Ip: addr}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := allocate(t, ipam, "other"); got != addr {
+			t.Fatalf("expected released pending address %s, got %s", addr, got)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		t.Parallel()
+		ipam := newTestIPAM(t, IPAMConfig{})
+		addr := allocate(t, ipam, "joining")
+		lease := ipam.pending["joining"]
+		lease.expires = time.Now().Add(-time.Second)
+		ipam.pending["joining"] = lease
+		if got := allocate(t, ipam, "other"); got != addr {
+			t.Fatalf("expected expired pending address %s, got %s", addr, got)
+		}
+	})
+}
+
+func TestBuiltinIPAMAllocateWithGateway(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	const subnet = "10.0.0.0/29"
+
+	t.Run("ReservedGateway", func(t *testing.T) {
+		ipam := newTestIPAM(t, IPAMConfig{ReserveGateway: true})
+		var gateway string
+		assigned := make(map[string]struct{})
+		for i := 0; i < 5; i++ {
+			nodeID := fmt.Sprintf("node-%d", i)
+			alloc, err := ipam.AllocateWithGateway(ctx, &v1.AllocateIPRequest{NodeID: nodeID, Subnet: subnet})
+			if err != nil {
+				t.Fatalf("allocate %s: %v", nodeID, err)
+			}
+			if alloc.Gateway != "10.0.0.1" {
+				t.Fatalf("expected gateway 10.0.0.1, got %q", alloc.Gateway)
+			}
+			if gateway != "" && alloc.Gateway != gateway {
+				t.Fatalf("gateway changed between allocations: %q != %q", gateway, alloc.Gateway)
+			}
+			gateway = alloc.Gateway
+			if alloc.GetIp() == gateway+"/32" {
+				t.Fatalf("gateway %s was assigned to %s", gateway, nodeID)
+			}
+			if _, ok := assigned[alloc.GetIp()]; ok {
+				t.Fatalf("address %s assigned twice", alloc.GetIp())
+			}
+			assigned[alloc.GetIp()] = struct{}{}
+			putTestNode(t, ipam, nodeID, alloc.GetIp())
+		}
+		// The gateway and broadcast address are skipped, so the subnet is full.
+		_, err := ipam.AllocateWithGateway(ctx, &v1.AllocateIPRequest{NodeID: "node-5", Subnet: subnet})
+		var conflict *AllocationConflictError
+		if !errors.As(err, &conflict) {
+			t.Fatalf("expected an AllocationConflictError, got %v", err)
+		}
+		if conflict.Collisions != 5 {
+			t.Fatalf("expected 5 collisions, got %d", conflict.Collisions)
+		}
+		if _, ok := assigned["10.0.0.7/32"]; ok {
+			t.Fatal("broadcast address was assigned")
+		}
+	})
+
+	t.Run("NoGateway", func(t *testing.T) {
+		ipam := newTestIPAM(t, IPAMConfig{})
+		alloc, err := ipam.AllocateWithGateway(ctx, &v1.AllocateIPRequest{NodeID: "node", Subnet: subnet})
+		if err != nil {
+			t.Fatalf("allocate: %v", err)
+		}
+		if alloc.Gateway != "" {
+			t.Fatalf("expected no gateway, got %q", alloc.Gateway)
+		}
+		if alloc.GetIp() != "10.0.0.1/32" {
+			t.Fatalf("expected first host to be assignable, got %s", alloc.GetIp())
+		}
+	})
+}
+
+func TestBuiltinIPAMConfigure(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ReserveGateway", func(t *testing.T) {
+		ipam := newTestIPAM(t, IPAMConfig{ReserveGateway: true})
+		configure := func(t *testing.T, cfg map[string]any) {
+			t.Helper()
+			conf, err := structpb.NewStruct(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ipam.Configure(context.Background(), &v1.PluginConfiguration{Config: conf}); err != nil {
+				t.Fatalf("configure: %v", err)
+			}
+		}
+		// A configuration without the key leaves the setting as is.
+		configure(t, map[string]any{"static-ipv4": map[string]any{"foo": "10.0.0.10/32"}})
+		if !ipam.ReserveGateway {
+			t.Fatal("expected reserve-gateway to be kept when not configured")
+		}
+		configure(t, map[string]any{"reserve-gateway": false})
+		if ipam.ReserveGateway {
+			t.Fatal("expected reserve-gateway to be disabled")
+		}
+	})
+
+	t.Run("StaticAssignments", func(t *testing.T) {
+		ipam := newTestIPAM(t, IPAMConfig{})
+		conf, err := structpb.NewStruct(map[string]any{
+			"static-ipv4": map[string]any{
+				"foo": "10.0.0.10/32",
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = ipam.Configure(context.Background(), &v1.PluginConfiguration{Config: conf})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := ipam.StaticIPv4["foo"]; got != "10.0.0.10/32" {
+			t.Fatalf("expected static assignment to be configured, got %q", got)
+		}
+	})
+
+	t.Run("Reload", func(t *testing.T) {
+		ctx := context.Background()
+		ipam := newTestIPAM(t, IPAMConfig{})
+		configure := func(static map[string]any) error {
+			conf, err := structpb.NewStruct(map[string]any{"static-ipv4": static})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = ipam.Configure(ctx, &v1.PluginConfiguration{Config: conf})
+			return err
+		}
+		allocate := func(node string) string {
+			alloc, err := ipam.Allocate(ctx, &v1.AllocateIPRequest{NodeID: node, Subnet: "10.0.0.0/24"})
+			if err != nil {
+				t.Fatalf("allocate %s: %v", node, err)
+			}
+			return alloc.GetIp()
+		}
+		if err := configure(map[string]any{"foo": "10.0.0.10/32", "bar": "10.0.0.1/32"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := allocate("foo"); got != "10.0.0.10/32" {
+			t.Fatalf("expected first static assignment, got %s", got)
+		}
+		if got := allocate("baz"); got != "10.0.0.2/32" {
+			t.Fatalf("expected statically assigned address to be skipped, got %s", got)
+		}
+		// Reload with a different map.
+		if err := configure(map[string]any{"foo": "10.0.0.20/32"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := allocate("foo"); got != "10.0.0.20/32" {
+			t.Fatalf("expected reloaded static assignment, got %s", got)
+		}
+		if got := allocate("baz"); got != "10.0.0.1/32" {
+			t.Fatalf("expected released static address to be assignable, got %s", got)
+		}
+		// An invalid reload leaves the current assignments in place.
+		if err := configure(map[string]any{"foo": "10.0.0.30/32", "bar": "10.0.0.30/32"}); err == nil {
+			t.Fatal("expected error for duplicate static addresses")
+		}
+		if err := configure(map[string]any{"foo": "fd00::1/128"}); err == nil {
+			t.Fatal("expected error for non-IPv4 static address")
+		}
+		if err := configure(map[string]any{"foo": "10.0.0.0/24"}); err == nil {
+			t.Fatal("expected error for non-host static address")
+		}
+		if got := allocate("foo"); got != "10.0.0.20/32" {
+			t.Fatalf("expected assignments to be unchanged after invalid reload, got %s", got)
+		}
+	})
+
+	t.Run("StringForm", func(t *testing.T) {
+		fromMap, err := decodeIPAMConfig(map[string]any{
+			"static-ipv4": map[string]any{
+				"foo": "10.0.0.10/32",
+				"bar": "10.0.0.11",
+			},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error decoding map form: %v", err)
+		}
+		fromString, err := decodeIPAMConfig(map[string]any{
+			"static-ipv4": "foo=10.0.0.10/32, bar=10.0.0.11",
+		})
+		if err != nil {
+			t.Fatalf("unexpected error decoding string form: %v", err)
+		}
+		if !reflect.DeepEqual(fromMap, fromString) {
+			t.Fatalf("expected equivalent configs, got %+v and %+v", fromMap, fromString)
+		}
+		for _, invalid := range []string{
+			"foo",
+			"=10.0.0.10",
+			"foo=10.0.0.10,foo=10.0.0.11",
+			"foo=10.0.0.10,bar=10.0.0.10",
+			"foo=fd00::1",
+		} {
+			if _, err := decodeIPAMConfig(map[string]any{"static-ipv4": invalid}); err == nil {
+				t.Fatalf("expected error decoding %q", invalid)
+			}
+		}
+	})
+
+	t.Run("UnknownKey", func(t *testing.T) {
+		ipam := newTestIPAM(t, IPAMConfig{})
+		conf, err := structpb.NewStruct(map[string]any{
+			"static-ipv4":  map[string]any{},
+			"static-ipv46": map[string]any{},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = ipam.Configure(context.Background(), &v1.PluginConfiguration{Config: conf})
+		if err == nil {
+			t.Fatal("expected error for unknown configuration key")
+		}
+		if !strings.Contains(err.Error(), "unknown configuration keys: static-ipv46") {
+			t.Fatalf("expected error to name the unknown key, got %q", err.Error())
+		}
+	})
+
+	t.Run("ZonePrefixes", func(t *testing.T) {
+		ctx := context.Background()
+		ipam := newTestIPAM(t, IPAMConfig{})
+		err := ipam.Storage.MeshState().SetMeshState(ctx, types.NetworkState{
+			NetworkState: &v1.NetworkState{
+				NetworkV4: "10.0.0.0/24",
+				NetworkV6: "fd00:1234:5678::/48",
+				Domain:    "webmesh.internal",
+			},
+		})
+		if err != nil {
+			t.Fatalf("set network state: %v", err)
+		}
+		configure := func(zones map[string]any) error {
+			conf, err := structpb.NewStruct(map[string]any{"zone-prefixes": zones})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = ipam.Configure(ctx, &v1.PluginConfiguration{Config: conf})
+			return err
+		}
+		for name, zones := range map[string]map[string]any{
+			"NotIPv6":      {"zone-a": "10.0.0.0/24"},
+			"TooShort":     {"zone-a": "fd00::/32"},
+			"TooLong":      {"zone-a": "fd00:1234:5678::/120"},
+			"OutsideMesh":  {"zone-a": "fd00:aaaa:bbbb:a00::/56"},
+			"Overlapping":  {"zone-a": "fd00:1234:5678:a00::/56", "zone-b": "fd00:1234:5678:a10::/64"},
+			"EmptyZoneID":  {"": "fd00:1234:5678:a00::/56"},
+			"InvalidValue": {"zone-a": "invalid"},
+		} {
+			if err := configure(zones); err == nil {
+				t.Errorf("%s: expected error for zone prefixes %v", name, zones)
+			}
+		}
+		if len(ipam.ZonePrefixes) != 0 {
+			t.Fatalf("expected rejected zone prefixes not to be applied, got %v", ipam.ZonePrefixes)
+		}
+		if err := configure(map[string]any{"zone-a": "fd00:1234:5678:a00::/56"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := ipam.ZonePrefixes["zone-a"]; got != "fd00:1234:5678:a00::/56" {
+			t.Fatalf("expected zone prefix to be configured, got %q", got)
+		}
+	})
+}
+
+func TestBuiltinIPAMAllocateV6(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	network := netip.MustParsePrefix("fd00:1234:5678::/48")
+	zoneA := netip.MustParsePrefix("fd00:1234:5678:a00::/56")
+	zoneB := netip.MustParsePrefix("fd00:1234:5678:b00::/56")
+	ipam := newTestIPAM(t, IPAMConfig{
+		ZonePrefixes: map[string]string{
+			"zone-a":  zoneA.String(),
+			"zone-b":  zoneB.String(),
+			"outside": "fd00:aaaa:bbbb:a00::/56",
+		},
+	})
+	newKey := func(t *testing.T) crypto.PublicKey {
+		t.Helper()
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key.PublicKey()
+	}
+
+	t.Run("ZonedNode", func(t *testing.T) {
+		for zoneID, zone := range map[string]netip.Prefix{"zone-a": zoneA, "zone-b": zoneB} {
+			for i := 0; i < 10; i++ {
+				addr, err := ipam.AllocateV6(ctx, network, zoneID, newKey(t))
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if addr.Bits() != netutil.DefaultNodeBits {
+					t.Fatalf("expected a /%d, got %s", netutil.DefaultNodeBits, addr)
+				}
+				if !zone.Contains(addr.Addr()) {
+					t.Fatalf("expected address in %s for %s, got %s", zone, zoneID, addr)
+				}
+			}
+		}
+	})
+
+	t.Run("Deterministic", func(t *testing.T) {
+		key := newKey(t)
+		first, err := ipam.AllocateV6(ctx, network, "zone-a", key)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		second, err := ipam.AllocateV6(ctx, network, "zone-a", key)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if first != second {
+			t.Fatalf("expected the same address for the same key, got %s and %s", first, second)
+		}
+	})
+
+	t.Run("UnzonedNode", func(t *testing.T) {
+		for _, zoneID := range []string{"", "zone-unknown"} {
+			key := newKey(t)
+			addr, err := ipam.AllocateV6(ctx, network, zoneID, key)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := netutil.AssignToPrefix(network, key); addr != want {
+				t.Fatalf("expected %s from the mesh prefix for zone %q, got %s", want, zoneID, addr)
+			}
+		}
+	})
+
+	t.Run("ZoneOutsideMesh", func(t *testing.T) {
+		_, err := ipam.AllocateV6(ctx, network, "outside", newKey(t))
+		if err == nil {
+			t.Fatal("expected error for a zone prefix outside the mesh prefix")
+		}
+	})
+}
+
+func newTestIPAM(t *testing.T, opts IPAMConfig) *BuiltinIPAM {
+	t.Helper()
+	if opts.Storage == nil {
+		st := badgerdb.NewTestStorage(false)
+		t.Cleanup(func() { _ = st.Close() })
+		opts.Storage = meshdb.NewFromStorage(st)
+		if opts.MeshStorage == nil {
+			opts.MeshStorage = st
+		}
+	}
+	return NewBuiltinIPAM(opts)
+}
+
+func putTestNode(t *testing.T, ipam *BuiltinIPAM, id, addr string) {
+	t.Helper()
+	err := ipam.Storage.Peers().Put(context.Background(), types.MeshNode{MeshNode: &v1.MeshNode{
+		Id:          id,
+		PrivateIPv4: addr,
+	}})
+	if err != nil {
+		t.Fatalf("put node %s: %v", id, err)
+	}
+}
+
+func TestBuiltinIPAMAllocateBulk(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("DistinctAddresses", func(t *testing.T) {
+		t.Parallel()
+		ipam := newTestIPAM(t, IPAMConfig{
+			StaticIPv4: map[string]string{"static": "10.0.0.2"},
+		})
+		putTestNode(t, ipam, "node-0", "10.0.0.1/32")
+		nodeIDs := []string{"rack-0", "rack-1", "static", "rack-2", "rack-3"}
+		allocs, err := ipam.AllocateBulk(ctx, "10.0.0.0/24", nodeIDs)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(allocs) != len(nodeIDs) {
+			t.Fatalf("expected %d allocations, got %d", len(nodeIDs), len(allocs))
+		}
+		seen := make(map[string]string)
+		for i, alloc := range allocs {
+			if other, ok := seen[alloc.GetIp()]; ok {
+				t.Fatalf("address %s allocated to both %s and %s", alloc.GetIp(), other, nodeIDs[i])
+			}
+			seen[alloc.GetIp()] = nodeIDs[i]
+		}
+		if got := allocs[2].GetIp(); got != "10.0.0.2/32" {
+			t.Fatalf("expected static node to receive 10.0.0.2/32, got %s", got)
+		}
+		if _, ok := seen["10.0.0.1/32"]; ok {
+			t.Fatal("expected existing allocation to be skipped")
+		}
+		// The addresses are reserved so later allocations do not reuse them.
+		alloc, err := ipam.Allocate(ctx, &v1.AllocateIPRequest{NodeID: "new-node", Subnet: "10.0.0.0/24"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, ok := seen[alloc.GetIp()]; ok {
+			t.Fatalf("expected a fresh address, got reserved %s", alloc.GetIp())
+		}
+	})
+
+	t.Run("InvalidRequests", func(t *testing.T) {
+		t.Parallel()
+		ipam := newTestIPAM(t, IPAMConfig{})
+		for _, nodeIDs := range [][]string{nil, {"a", "a"}, {""}} {
+			if _, err := ipam.AllocateBulk(ctx, "10.0.0.0/24", nodeIDs); err == nil {
+				t.Fatalf("expected error for node IDs %v", nodeIDs)
+			}
+		}
+	})
+
+	t.Run("StoredAsReservations", func(t *testing.T) {
+		t.Parallel()
+		ipam := newTestIPAM(t, IPAMConfig{})
+		putTestNode(t, ipam, "existing", "")
+		allocs, err := ipam.AllocateBulk(ctx, "10.0.0.0/24", []string{"rack-0", "existing"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// The reservations are kept out of the node records.
+		nodes, err := ipam.Storage.Peers().List(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(nodes) != 1 || nodes[0].GetPrivateIPv4() != "" {
+			t.Fatalf("expected the node records to be untouched, got %v", nodes)
+		}
+		reserved, err := storage.ListIPAMReservations(ctx, ipam.MeshStorage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[types.NodeID]netip.Prefix{
+			"rack-0":   netip.MustParsePrefix(allocs[0].GetIp()),
+			"existing": netip.MustParsePrefix(allocs[1].GetIp()),
+		}
+		if !reflect.DeepEqual(reserved, want) {
+			t.Fatalf("expected reservations %v, got %v", want, reserved)
+		}
+		// A reserved node receives its reservation when it allocates.
+		alloc, err := ipam.Allocate(ctx, &v1.AllocateIPRequest{NodeID: "rack-0", Subnet: "10.0.0.0/24"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if alloc.GetIp() != allocs[0].GetIp() {
+			t.Fatalf("expected reserved address %s, got %s", allocs[0].GetIp(), alloc.GetIp())
+		}
+		// Released reservations can be allocated again.
+		if _, err := ipam.Release(ctx, &v1.ReleaseIPRequest{NodeID: "existing"}); err != nil {
+			t.Fatalf("release: %v", err)
+		}
+		reserved, err = storage.ListIPAMReservations(ctx, ipam.MeshStorage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := reserved["existing"]; ok {
+			t.Fatal("expected the released reservation to be removed")
+		}
+		// Once a node is stored with its reservation, the reservation is removed.
+		putTestNode(t, ipam, "rack-0", allocs[0].GetIp())
+		if _, err := ipam.AllocateBulk(ctx, "10.0.0.0/24", []string{"rack-1"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		reserved, err = storage.ListIPAMReservations(ctx, ipam.MeshStorage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := reserved["rack-0"]; ok || len(reserved) != 1 {
+			t.Fatalf("expected only the reservation for rack-1 to remain, got %v", reserved)
+		}
+	})
+
+	t.Run("RollbackWithoutTransactions", func(t *testing.T) {
+		t.Parallel()
+		st := badgerdb.NewTestStorage(false)
+		t.Cleanup(func() { _ = st.Close() })
+		failing := &failingPutStorage{MeshStorage: st, failOn: 3}
+		ipam := newTestIPAM(t, IPAMConfig{
+			Storage:     meshdb.NewFromStorage(st),
+			MeshStorage: failing,
+		})
+		if _, err := ipam.AllocateBulk(ctx, "10.0.0.0/24", []string{"existing"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		before, err := storage.ListIPAMReservations(ctx, st)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The batch fails reserving rack-1 after replacing the reservation
+		// for existing.
+		_, err = ipam.AllocateBulk(ctx, "10.0.0.0/24", []string{"existing", "rack-1", "rack-2"})
+		if !errors.Is(err, errPutFailed) {
+			t.Fatalf("expected put failure, got %v", err)
+		}
+		after, err := storage.ListIPAMReservations(ctx, st)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(before, after) {
+			t.Fatalf("expected reservations %v to be restored, got %v", before, after)
+		}
+	})
+}
+
+var errPutFailed = errors.New("put failed")
+
+// failingPutStorage fails the failOn-th PutValue. It does not support
+// transactions, so the IPAM plugin writes reservations one at a time.
+type failingPutStorage struct {
+	storage.MeshStorage
+	failOn int
+	puts   atomic.Int32
+}
+
+func (f *failingPutStorage) PutValue(ctx context.Context, key, value []byte, ttl time.Duration) error {
+	if int(f.puts.Add(1)) == f.failOn {
+		return errPutFailed
+	}
+	return f.MeshStorage.PutValue(ctx, key, value, ttl)
+}
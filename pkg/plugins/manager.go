@@ -23,6 +23,8 @@ import (
 	"log/slog"
 	"net/netip"
 	"strings"
+	"sync"
+	"time"
 
 	v1 "github.com/webmeshproj/api/go/v1"
 	"google.golang.org/grpc"
@@ -34,6 +36,7 @@ import (
 
 	"github.com/webmeshproj/webmesh/pkg/context"
 	"github.com/webmeshproj/webmesh/pkg/crypto"
+	"github.com/webmeshproj/webmesh/pkg/meshnet/netutil"
 	"github.com/webmeshproj/webmesh/pkg/plugins/clients"
 	"github.com/webmeshproj/webmesh/pkg/storage"
 	"github.com/webmeshproj/webmesh/pkg/storage/rpcsrv"
@@ -58,6 +61,9 @@ type Options struct {
 	DisableDefaultIPAM bool
 	// DefaultIPAMStaticIPv4 is a map of node names to IPv4 addresses.
 	DefaultIPAMStaticIPv4 map[string]string
+	// DefaultIPAMZonePrefixes is a map of zone awareness IDs to the IPv6
+	// prefixes the default IPAM assigns addresses from for nodes in them.
+	DefaultIPAMZonePrefixes map[string]string
 }
 
 // NodeConfig is the configuration of the node to pass to each plugin.
@@ -121,6 +127,10 @@ type Manager interface {
 	// ReleaseIP calls the configured IPAM plugin to release an IP address for the given request.
 	// If no IPAM plugin is configured, ErrUnsupported is returned.
 	ReleaseIP(ctx context.Context, req *v1.ReleaseIPRequest) error
+	// AllocateIPv6 assigns an IPv6 address to a node from the mesh prefix using its public key.
+	// If the default IPAM is in use and has a prefix for the node's zone, the address is
+	// assigned from the zone's prefix instead.
+	AllocateIPv6(ctx context.Context, network netip.Prefix, zoneID string, publicKey crypto.PublicKey) (netip.Prefix, error)
 	// Emit emits an event to all watch plugins.
 	Emit(ctx context.Context, ev *v1.Event) error
 	// Close closes all plugins.
@@ -146,8 +156,12 @@ func NewManager(ctx context.Context, opts Options) (Manager, error) {
 		log.Debug("Plugin info", slog.Any("info", resp))
 		plugin.capabilities = resp.GetCapabilities()
 		plugin.name = resp.GetName()
+		if injector, ok := plugin.Client.(clients.ConsensusInjector); ok && opts.Storage != nil {
+			injector.InjectConsensus(opts.Storage.Consensus())
+		}
 		// Configure the plugin
-		conf, err := structpb.NewStruct(plugin.Config)
+		granted := grantCapabilities(plugin.capabilities, opts)
+		conf, err := structpb.NewStruct(withGrantedCapabilities(plugin.Config, granted))
 		if err != nil {
 			return nil, fmt.Errorf("convert plugin config to structpb: %w", err)
 		}
@@ -201,8 +215,10 @@ func NewManager(ctx context.Context, opts Options) (Manager, error) {
 	// If we didn't find any IPAM plugins, register the default one
 	if ipamv4 == nil && !opts.DisableDefaultIPAM {
 		ipamv4 = NewBuiltinIPAM(IPAMConfig{
-			Storage:    opts.Storage.MeshDB(),
-			StaticIPv4: opts.DefaultIPAMStaticIPv4,
+			Storage:      opts.Storage.MeshDB(),
+			MeshStorage:  opts.Storage.MeshStorage(),
+			StaticIPv4:   opts.DefaultIPAMStaticIPv4,
+			ZonePrefixes: opts.DefaultIPAMZonePrefixes,
 		})
 	}
 	m := &manager{
@@ -211,6 +227,7 @@ func NewManager(ctx context.Context, opts Options) (Manager, error) {
 		auth:    auth,
 		ipamv4:  ipamv4,
 		log:     log,
+		closec:  make(chan struct{}),
 	}
 	go m.handleQueries(opts.Storage)
 	return m, nil
@@ -233,13 +250,19 @@ type IPAMPlugin interface {
 }
 
 type manager struct {
-	storage storage.Provider
-	plugins map[string]*Plugin
-	auth    *Plugin
-	ipamv4  IPAMPlugin
-	log     context.Logger
+	storage   storage.Provider
+	plugins   map[string]*Plugin
+	auth      *Plugin
+	ipamv4    IPAMPlugin
+	log       context.Logger
+	closec    chan struct{}
+	closeOnce sync.Once
 }
 
+// QueryStreamRetryInterval is how long the manager waits before opening a new
+// query stream to a plugin after the previous one failed.
+const QueryStreamRetryInterval = time.Second
+
 // Get returns the plugin with the given name.
 func (m *manager) Get(name string) (clients.PluginClient, bool) {
 	p, ok := m.plugins[name]
@@ -348,6 +371,16 @@ func (m *manager) ReleaseIP(ctx context.Context, req *v1.ReleaseIPRequest) error
 	return err
 }
 
+// AllocateIPv6 assigns an IPv6 address to a node from the mesh prefix using its public key.
+// If the default IPAM is in use and has a prefix for the node's zone, the address is
+// assigned from the zone's prefix instead.
+func (m *manager) AllocateIPv6(ctx context.Context, network netip.Prefix, zoneID string, publicKey crypto.PublicKey) (netip.Prefix, error) {
+	if ipam, ok := m.ipamv4.(*BuiltinIPAM); ok {
+		return ipam.AllocateV6(ctx, network, zoneID, publicKey)
+	}
+	return netutil.AssignToPrefix(network, publicKey), nil
+}
+
 // Emit emits an event to all watch plugins.
 func (m *manager) Emit(ctx context.Context, ev *v1.Event) error {
 	errs := make([]error, 0)
@@ -368,6 +401,12 @@ func (m *manager) Emit(ctx context.Context, ev *v1.Event) error {
 
 // Close closes all plugins.
 func (m *manager) Close() error {
+	// Stop reopening query streams before the plugins close them.
+	m.closeOnce.Do(func() {
+		if m.closec != nil {
+			close(m.closec)
+		}
+	})
 	errs := make([]error, 0)
 	for _, p := range m.plugins {
 		_, err := p.Client.Close(context.Background(), &emptypb.Empty{})
@@ -401,15 +440,46 @@ func (m *manager) handleQueries(db storage.Provider) {
 			m.log.Error("Start query stream", "plugin", plugin, "error", err)
 			return
 		}
-		go m.handleQueryClient(plugin, db, q)
+		go m.handleQueryClient(plugin, client, db, q)
 	}
 }
 
-// handleQueryClient handles a query client.
-func (m *manager) handleQueryClient(plugin string, db storage.Provider, queries v1.StorageQuerierPlugin_InjectQuerierClient) {
-	err := rpcsrv.Serve(context.WithLogger(context.Background(), m.log), db, queries)
-	if err != nil {
+// handleQueryClient handles a query client. A stream that fails cannot be used
+// again, so a new one is opened and injected into the plugin until the manager
+// is closed.
+func (m *manager) handleQueryClient(plugin string, client *Plugin, db storage.Provider, queries v1.StorageQuerierPlugin_InjectQuerierClient) {
+	for {
+		err := rpcsrv.Serve(context.WithLogger(context.Background(), m.log), db, queries)
+		if err == nil {
+			return
+		}
 		m.log.Error("Error handling query stream", "plugin", plugin, "error", err)
+		for {
+			select {
+			case <-m.closec:
+				return
+			case <-time.After(QueryStreamRetryInterval):
+			}
+			if m.isClosed() {
+				return
+			}
+			m.log.Info("Reopening plugin query stream", "plugin", plugin)
+			queries, err = client.Client.Storage().InjectQuerier(context.Background())
+			if err == nil {
+				break
+			}
+			m.log.Error("Reopen query stream", "plugin", plugin, "error", err)
+		}
+	}
+}
+
+// isClosed returns true if the manager has been closed.
+func (m *manager) isClosed() bool {
+	select {
+	case <-m.closec:
+		return true
+	default:
+		return false
 	}
 }
 
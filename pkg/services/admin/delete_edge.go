@@ -37,7 +37,7 @@ var deleteEdgeAction = rbac.Actions{
 
 func (s *Server) DeleteEdge(ctx context.Context, edge *v1.MeshEdge) (*emptypb.Empty, error) {
 	if !s.storage.Consensus().IsLeader() {
-		return nil, status.Error(codes.FailedPrecondition, "not the leader")
+		return nil, notLeaderError(ctx, s.storage.Consensus())
 	}
 	if edge.GetSource() == "" {
 		return nil, status.Error(codes.InvalidArgument, "edge source is required")
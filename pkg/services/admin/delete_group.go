@@ -37,7 +37,7 @@ var deleteGroupAction = rbac.Actions{
 
 func (s *Server) DeleteGroup(ctx context.Context, group *v1.Group) (*emptypb.Empty, error) {
 	if !s.storage.Consensus().IsLeader() {
-		return nil, status.Error(codes.FailedPrecondition, "not the leader")
+		return nil, notLeaderError(ctx, s.storage.Consensus())
 	}
 	if group.GetName() == "" {
 		return nil, status.Error(codes.InvalidArgument, "group name is required")
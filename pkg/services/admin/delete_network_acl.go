@@ -36,8 +36,14 @@ var deleteNetworkACLAction = rbac.Actions{
 
 func (s *Server) DeleteNetworkACL(ctx context.Context, acl *v1.NetworkACL) (*emptypb.Empty, error) {
 	if !s.storage.Consensus().IsLeader() {
-		return nil, status.Error(codes.FailedPrecondition, "not the leader")
+		return nil, notLeaderError(ctx, s.storage.Consensus())
 	}
+	resp, err := s.deleteNetworkACL(ctx, acl)
+	recordNetworkACLOperation("delete", err)
+	return resp, err
+}
+
+func (s *Server) deleteNetworkACL(ctx context.Context, acl *v1.NetworkACL) (*emptypb.Empty, error) {
 	if acl.GetName() == "" {
 		return nil, status.Error(codes.InvalidArgument, "acl name is required")
 	}
@@ -0,0 +1,116 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package admin provides the admin gRPC server.
+package admin
+
+import (
+	"log/slog"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/services/rbac"
+	"github.com/webmeshproj/webmesh/pkg/storage"
+	"github.com/webmeshproj/webmesh/pkg/storage/errors"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+var deleteNodeAction = rbac.Actions{
+	{
+		Resource: v1.RuleResource_RESOURCE_ALL,
+		Verb:     v1.RuleVerb_VERB_DELETE,
+	},
+}
+
+// DeleteNodeRequest is a request to forcibly remove a node from the mesh.
+type DeleteNodeRequest struct {
+	// Id is the ID of the node to remove.
+	Id string `json:"id"`
+}
+
+// GetId returns the ID of the node to remove.
+func (r *DeleteNodeRequest) GetId() string {
+	if r == nil {
+		return ""
+	}
+	return r.Id
+}
+
+// DeleteNode forcibly removes a node from the mesh. Unlike a Leave, it is
+// issued by an administrator on behalf of the node and does not require the
+// node to be reachable. The node is removed from storage consensus if it is
+// a member and its peer record and heartbeat are deleted.
+func (s *Server) DeleteNode(ctx context.Context, req *DeleteNodeRequest) (*emptypb.Empty, error) {
+	if !s.storage.Consensus().IsLeader() {
+		return nil, notLeaderError(ctx, s.storage.Consensus())
+	}
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "node id is required")
+	}
+	if !types.IsValidNodeID(req.GetId()) {
+		return nil, status.Error(codes.InvalidArgument, "invalid node id")
+	}
+	if ok, err := s.rbacEval.Evaluate(ctx, deleteNodeAction.For(req.GetId())); !ok {
+		if err != nil {
+			context.LoggerFrom(ctx).Error("failed to evaluate delete node action", "error", err)
+		}
+		return nil, status.Error(codes.PermissionDenied, "caller does not have permission to delete nodes")
+	}
+	leader, err := s.storage.Consensus().GetLeader(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if leader.GetId() == req.GetId() {
+		return nil, status.Error(codes.FailedPrecondition, "cannot delete the current leader")
+	}
+	var found bool
+	_, err = s.storage.Consensus().GetPeer(ctx, req.GetId())
+	switch {
+	case err == nil:
+		found = true
+		context.LoggerFrom(ctx).Info("Removing node from storage consensus", slog.String("id", req.GetId()))
+		err = s.storage.Consensus().RemovePeer(ctx, types.StoragePeer{StoragePeer: &v1.StoragePeer{Id: req.GetId()}}, false)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to remove storage peer: %v", err)
+		}
+	case !errors.IsNodeNotFound(err):
+		return nil, status.Errorf(codes.Internal, "failed to get storage peer: %v", err)
+	}
+	_, err = s.db.Peers().Get(ctx, types.NodeID(req.GetId()))
+	switch {
+	case err == nil:
+		found = true
+		context.LoggerFrom(ctx).Info("Removing node from peers DB", slog.String("id", req.GetId()))
+		err = s.db.Peers().Delete(ctx, types.NodeID(req.GetId()))
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to delete peer: %v", err)
+		}
+	case !errors.IsNodeNotFound(err):
+		return nil, status.Errorf(codes.Internal, "failed to get peer: %v", err)
+	}
+	if !found {
+		return nil, status.Errorf(codes.NotFound, "node %q not found", req.GetId())
+	}
+	err = storage.DeleteHeartbeat(ctx, s.storage.MeshStorage(), types.NodeID(req.GetId()))
+	if err != nil {
+		context.LoggerFrom(ctx).Warn("Failed to delete heartbeat of node", slog.String("id", req.GetId()), slog.String("error", err.Error()))
+	}
+	return &emptypb.Empty{}, nil
+}
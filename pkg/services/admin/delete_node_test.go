@@ -0,0 +1,135 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc/codes"
+
+	"github.com/webmeshproj/webmesh/pkg/meshnode"
+	"github.com/webmeshproj/webmesh/pkg/storage"
+	"github.com/webmeshproj/webmesh/pkg/storage/errors"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+func TestDeleteNode(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	server := newTestServer(t)
+	leader, err := server.storage.Consensus().GetLeader(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A raft member that is not reachable.
+	err = server.db.Peers().Put(ctx, types.MeshNode{MeshNode: &v1.MeshNode{Id: "raft-member", PublicKey: newEncodedPubKey(t)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.storage.Consensus().AddObserver(ctx, types.StoragePeer{StoragePeer: &v1.StoragePeer{
+		Id:      "raft-member",
+		Address: "127.0.0.1:1",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A peer that never joined consensus.
+	err = server.db.Peers().Put(ctx, types.MeshNode{MeshNode: &v1.MeshNode{Id: "peer", PublicKey: newEncodedPubKey(t)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.PutHeartbeat(ctx, server.storage.MeshStorage(), "peer", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc := []testCase[DeleteNodeRequest]{
+		{
+			name: "no node id",
+			code: codes.InvalidArgument,
+			req:  &DeleteNodeRequest{},
+		},
+		{
+			name: "invalid node id",
+			code: codes.InvalidArgument,
+			req:  &DeleteNodeRequest{Id: "invalid/id"},
+		},
+		{
+			name: "current leader",
+			code: codes.FailedPrecondition,
+			req:  &DeleteNodeRequest{Id: leader.GetId()},
+		},
+		{
+			name: "non-existent node",
+			code: codes.NotFound,
+			req:  &DeleteNodeRequest{Id: "unknown"},
+		},
+		{
+			name: "raft member",
+			code: codes.OK,
+			req:  &DeleteNodeRequest{Id: "raft-member"},
+			tval: func(t *testing.T) {
+				_, err := server.storage.Consensus().GetPeer(ctx, "raft-member")
+				if !errors.IsNodeNotFound(err) {
+					t.Errorf("expected raft member to be removed, got %v", err)
+				}
+				_, err = server.db.Peers().Get(ctx, "raft-member")
+				if !errors.IsNodeNotFound(err) {
+					t.Errorf("expected peer to be removed, got %v", err)
+				}
+			},
+		},
+		{
+			name: "non-member peer",
+			code: codes.OK,
+			req:  &DeleteNodeRequest{Id: "peer"},
+			tval: func(t *testing.T) {
+				_, err := server.db.Peers().Get(ctx, "peer")
+				if !errors.IsNodeNotFound(err) {
+					t.Errorf("expected peer to be removed, got %v", err)
+				}
+				seen, err := storage.GetLastSeen(ctx, server.storage.MeshStorage(), "peer")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !seen.IsZero() {
+					t.Errorf("expected heartbeat to be removed, got %s", seen)
+				}
+			},
+		},
+	}
+
+	runTestCases(t, tc, server.DeleteNode)
+
+	t.Run("permission denied", func(t *testing.T) {
+		store, err := meshnode.NewSingleNodeTestMesh(ctx)
+		if err != nil {
+			t.Fatalf("error creating test store: %v", err)
+		}
+		t.Cleanup(func() { store.Close(ctx) })
+		server := NewServer(store.Storage(), denyEvaluator{})
+		runTestCase(t, testCase[DeleteNodeRequest]{
+			name: "rbac denied",
+			code: codes.PermissionDenied,
+			req:  &DeleteNodeRequest{Id: "peer"},
+		}, server.DeleteNode)
+	})
+}
@@ -37,7 +37,7 @@ var deleteRoleBindingAction = rbac.Actions{
 
 func (s *Server) DeleteRoleBinding(ctx context.Context, rb *v1.RoleBinding) (*emptypb.Empty, error) {
 	if !s.storage.Consensus().IsLeader() {
-		return nil, status.Error(codes.FailedPrecondition, "not the leader")
+		return nil, notLeaderError(ctx, s.storage.Consensus())
 	}
 	if rb.GetName() == "" {
 		return nil, status.Error(codes.InvalidArgument, "name is required")
@@ -0,0 +1,68 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package admin provides the admin gRPC server.
+package admin
+
+import (
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/storage"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+// NetworkACLEvaluation is the result of evaluating a flow against the NetworkACLs.
+type NetworkACLEvaluation struct {
+	// Action is the action applied to the flow.
+	Action v1.ACLAction `json:"action"`
+	// ACL is the name of the NetworkACL that matched the flow. It is empty
+	// when no ACL matched and the default action was applied.
+	ACL string `json:"acl,omitempty"`
+}
+
+// EvaluateNetworkACL evaluates the given flow against the NetworkACLs in the
+// mesh and returns the resulting action. Group references are expanded and node
+// patterns are matched against the nodes in the flow. If no ACL matches, the
+// default network ACL action is returned.
+func (s *Server) EvaluateNetworkACL(ctx context.Context, req *v1.NetworkAction) (*NetworkACLEvaluation, error) {
+	if req.GetSrcNode() == "" && req.GetSrcCIDR() == "" {
+		return nil, status.Error(codes.InvalidArgument, "one of source node or source cidr is required")
+	}
+	if req.GetDstNode() == "" && req.GetDstCIDR() == "" {
+		return nil, status.Error(codes.InvalidArgument, "one of destination node or destination cidr is required")
+	}
+	acls, err := s.db.Networking().ListNetworkACLs(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	err = storage.ExpandACLs(ctx, s.db.RBAC(), acls)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	acls.Sort(types.SortDescending)
+	action := types.NetworkAction{NetworkAction: req}
+	if acl, ok := acls.Match(ctx, action); ok {
+		return &NetworkACLEvaluation{Action: acl.GetAction(), ACL: acl.GetName()}, nil
+	}
+	defaultAction, err := storage.GetDefaultNetworkACLAction(ctx, s.storage.MeshStorage())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &NetworkACLEvaluation{Action: defaultAction}, nil
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admin
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestEvaluateNetworkACL(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	server := newTestServer(t)
+	// Remove the accept-all ACL created at bootstrap so flows can go unmatched.
+	if err := server.db.Networking().DeleteNetworkACL(ctx, "default-accept"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := server.PutNetworkACL(ctx, &v1.NetworkACL{
+		Name:             "allow-web",
+		Priority:         10,
+		Action:           v1.ACLAction_ACTION_ACCEPT,
+		SourceNodes:      []string{"client"},
+		DestinationNodes: []string{"web"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	evaluate := func(t *testing.T, src, dst string) *NetworkACLEvaluation {
+		t.Helper()
+		res, err := server.EvaluateNetworkACL(ctx, &v1.NetworkAction{SrcNode: src, DstNode: dst})
+		if err != nil {
+			t.Fatalf("evaluate network acl: %v", err)
+		}
+		return res
+	}
+
+	t.Run("MatchedFlow", func(t *testing.T) {
+		res := evaluate(t, "client", "web")
+		if res.Action != v1.ACLAction_ACTION_ACCEPT || res.ACL != "allow-web" {
+			t.Fatalf("expected accept by allow-web, got %s by %q", res.Action, res.ACL)
+		}
+	})
+
+	t.Run("PatternFlow", func(t *testing.T) {
+		_, err := server.PutNetworkACL(ctx, &v1.NetworkACL{
+			Name:             "allow-workers",
+			Priority:         5,
+			Action:           v1.ACLAction_ACTION_ACCEPT,
+			SourceNodes:      []string{"worker-*"},
+			DestinationNodes: []string{"web"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := evaluate(t, "worker-1", "web")
+		if res.Action != v1.ACLAction_ACTION_ACCEPT || res.ACL != "allow-workers" {
+			t.Fatalf("expected accept by allow-workers, got %s by %q", res.Action, res.ACL)
+		}
+		res = evaluate(t, "builder-1", "web")
+		if res.Action != v1.ACLAction_ACTION_DENY || res.ACL != "" {
+			t.Fatalf("expected default deny, got %s by %q", res.Action, res.ACL)
+		}
+	})
+
+	t.Run("UnmatchedFlowDefaultsToDeny", func(t *testing.T) {
+		def, err := server.GetDefaultNetworkACLAction(ctx, &emptypb.Empty{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if def.Action != v1.ACLAction_ACTION_DENY {
+			t.Fatalf("expected default action deny, got %s", def.Action)
+		}
+		res := evaluate(t, "client", "db")
+		if res.Action != v1.ACLAction_ACTION_DENY || res.ACL != "" {
+			t.Fatalf("expected default deny, got %s by %q", res.Action, res.ACL)
+		}
+	})
+
+	t.Run("ChangeDefault", func(t *testing.T) {
+		runTestCases(t, []testCase[DefaultNetworkACLAction]{
+			{
+				name: "unknown action",
+				code: codes.InvalidArgument,
+				req:  &DefaultNetworkACLAction{Action: v1.ACLAction_ACTION_UNKNOWN},
+			},
+			{
+				name: "invalid action",
+				code: codes.InvalidArgument,
+				req:  &DefaultNetworkACLAction{Action: -1},
+			},
+			{
+				name: "accept",
+				code: codes.OK,
+				req:  &DefaultNetworkACLAction{Action: v1.ACLAction_ACTION_ACCEPT},
+			},
+		}, server.PutDefaultNetworkACLAction)
+		res := evaluate(t, "client", "db")
+		if res.Action != v1.ACLAction_ACTION_ACCEPT || res.ACL != "" {
+			t.Fatalf("expected default accept, got %s by %q", res.Action, res.ACL)
+		}
+		// Matching ACLs still take precedence over the default.
+		_, err := server.PutNetworkACL(ctx, &v1.NetworkACL{
+			Name:             "deny-db",
+			Priority:         10,
+			Action:           v1.ACLAction_ACTION_DENY,
+			SourceNodes:      []string{"*"},
+			DestinationNodes: []string{"db"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res = evaluate(t, "client", "db")
+		if res.Action != v1.ACLAction_ACTION_DENY || res.ACL != "deny-db" {
+			t.Fatalf("expected deny by deny-db, got %s by %q", res.Action, res.ACL)
+		}
+	})
+
+	t.Run("InvalidFlow", func(t *testing.T) {
+		runTestCases(t, []testCase[v1.NetworkAction]{
+			{name: "no source", code: codes.InvalidArgument, req: &v1.NetworkAction{DstNode: "web"}},
+			{name: "no destination", code: codes.InvalidArgument, req: &v1.NetworkAction{SrcNode: "client"}},
+		}, server.EvaluateNetworkACL)
+	})
+}
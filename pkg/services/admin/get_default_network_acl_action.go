@@ -0,0 +1,37 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package admin provides the admin gRPC server.
+package admin
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/storage"
+)
+
+// GetDefaultNetworkACLAction returns the action applied to flows that no
+// NetworkACL matches. It is ACTION_DENY unless configured otherwise.
+func (s *Server) GetDefaultNetworkACLAction(ctx context.Context, _ *emptypb.Empty) (*DefaultNetworkACLAction, error) {
+	action, err := storage.GetDefaultNetworkACLAction(ctx, s.storage.MeshStorage())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &DefaultNetworkACLAction{Action: action}, nil
+}
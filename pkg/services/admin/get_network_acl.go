@@ -27,6 +27,12 @@ import (
 )
 
 func (s *Server) GetNetworkACL(ctx context.Context, acl *v1.NetworkACL) (*v1.NetworkACL, error) {
+	resp, err := s.getNetworkACL(ctx, acl)
+	recordNetworkACLOperation("get", err)
+	return resp, err
+}
+
+func (s *Server) getNetworkACL(ctx context.Context, acl *v1.NetworkACL) (*v1.NetworkACL, error) {
 	if acl.GetName() == "" {
 		return nil, status.Error(codes.InvalidArgument, "acl name is required")
 	}
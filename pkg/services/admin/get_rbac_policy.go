@@ -0,0 +1,78 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package admin provides the admin gRPC server.
+package admin
+
+import (
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/services/rbac"
+)
+
+var getRBACPolicyAction = rbac.Actions{
+	{
+		Resource: v1.RuleResource_RESOURCE_ROLES,
+		Verb:     v1.RuleVerb_VERB_GET,
+	},
+	{
+		Resource: v1.RuleResource_RESOURCE_ROLE_BINDINGS,
+		Verb:     v1.RuleVerb_VERB_GET,
+	},
+}
+
+// RBACPolicy is the full set of RBAC roles and role bindings in the mesh.
+type RBACPolicy struct {
+	// Roles are all roles in the mesh.
+	Roles []*v1.Role `json:"roles"`
+	// RoleBindings are all role bindings in the mesh.
+	RoleBindings []*v1.RoleBinding `json:"roleBindings"`
+}
+
+// GetRBACPolicy returns all roles and role bindings in the mesh so operators
+// can audit permissions. The caller must be allowed to get both roles and
+// role bindings.
+func (s *Server) GetRBACPolicy(ctx context.Context, _ *emptypb.Empty) (*RBACPolicy, error) {
+	if ok, err := s.rbacEval.Evaluate(ctx, getRBACPolicyAction); !ok {
+		if err != nil {
+			context.LoggerFrom(ctx).Error("failed to evaluate get rbac policy action", "error", err)
+		}
+		return nil, status.Error(codes.PermissionDenied, "caller does not have permission to get the rbac policy")
+	}
+	roles, err := s.db.RBAC().ListRoles(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	rbs, err := s.db.RBAC().ListRoleBindings(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	policy := &RBACPolicy{
+		Roles:        make([]*v1.Role, len(roles)),
+		RoleBindings: make([]*v1.RoleBinding, len(rbs)),
+	}
+	for i, r := range roles {
+		policy.Roles[i] = r.Proto()
+	}
+	for i, rb := range rbs {
+		policy.RoleBindings[i] = rb.Proto()
+	}
+	return policy, nil
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admin
+
+import (
+	"testing"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/services/rbac"
+)
+
+func TestGetRBACPolicy(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server := newTestServer(t)
+
+	_, err := server.PutRole(ctx, &v1.Role{
+		Name: "auditor",
+		Rules: []*v1.Rule{
+			{
+				Resources: []v1.RuleResource{v1.RuleResource_RESOURCE_ROLES, v1.RuleResource_RESOURCE_ROLE_BINDINGS},
+				Verbs:     []v1.RuleVerb{v1.RuleVerb_VERB_GET},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = server.PutRole(ctx, &v1.Role{
+		Name: "route-reader",
+		Rules: []*v1.Rule{
+			{
+				Resources: []v1.RuleResource{v1.RuleResource_RESOURCE_ROUTES},
+				Verbs:     []v1.RuleVerb{v1.RuleVerb_VERB_GET},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, role := range map[string]string{"auditors": "auditor", "route-readers": "route-reader"} {
+		_, err = server.PutRoleBinding(ctx, &v1.RoleBinding{
+			Name: name,
+			Role: role,
+			Subjects: []*v1.Subject{
+				{Name: name, Type: v1.SubjectType_SUBJECT_USER},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("FullPolicy", func(t *testing.T) {
+		policy, err := server.GetRBACPolicy(ctx, &emptypb.Empty{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		roles, err := server.db.RBAC().ListRoles(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rbs, err := server.db.RBAC().ListRoleBindings(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(policy.Roles) != len(roles) {
+			t.Fatalf("expected %d roles, got %d", len(roles), len(policy.Roles))
+		}
+		if len(policy.RoleBindings) != len(rbs) {
+			t.Fatalf("expected %d role bindings, got %d", len(rbs), len(policy.RoleBindings))
+		}
+		for _, want := range []string{"auditor", "route-reader"} {
+			if !containsName(policy.Roles, want) {
+				t.Errorf("expected role %q in policy", want)
+			}
+		}
+		for _, want := range []string{"auditors", "route-readers"} {
+			if !containsName(policy.RoleBindings, want) {
+				t.Errorf("expected role binding %q in policy", want)
+			}
+		}
+	})
+
+	t.Run("Permissions", func(t *testing.T) {
+		secured := NewServer(server.storage, rbac.NewStoreEvaluator(server.db))
+		tc := []struct {
+			name   string
+			caller string
+			code   codes.Code
+		}{
+			{name: "auditor", caller: "auditors", code: codes.OK},
+			{name: "without read verb", caller: "route-readers", code: codes.PermissionDenied},
+			{name: "unknown caller", caller: "nobody", code: codes.PermissionDenied},
+		}
+		for _, tt := range tc {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.WithAuthenticatedCaller(ctx, tt.caller)
+				_, err := secured.GetRBACPolicy(ctx, &emptypb.Empty{})
+				if status.Code(err) != tt.code {
+					t.Fatalf("expected %s, got %v", tt.code, err)
+				}
+			})
+		}
+	})
+}
+
+func containsName[T interface{ GetName() string }](items []T, name string) bool {
+	for _, item := range items {
+		if item.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admin
+
+import (
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/services/leaderproxy"
+	"github.com/webmeshproj/webmesh/pkg/storage"
+)
+
+// LeaderUnaryServerInterceptor returns a unary server interceptor that rejects
+// admin mutations when the node is not the leader. Mutations are the methods
+// with a RequireLeader policy in the leaderproxy.MethodPolicyMap. Rejected
+// requests fail with FailedPrecondition and carry the current leader in an
+// error detail, see leaderproxy.LeaderFromError, and in the
+// leaderproxy.LeaderIDMeta and leaderproxy.LeaderAddressMeta trailers so clients
+// can redirect. It should be chained after the leader proxy, if any. The
+// handlers check leadership as well, so mutations are still rejected on
+// followers when the interceptor is not installed.
+func LeaderUnaryServerInterceptor(consensus storage.Consensus) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !isMutation(info.FullMethod) || consensus.IsLeader() {
+			return handler(ctx, req)
+		}
+		return nil, notLeaderError(ctx, consensus)
+	}
+}
+
+// isMutation returns true if the given method is an admin method that must
+// be handled by the leader.
+func isMutation(fullMethod string) bool {
+	if !strings.HasPrefix(fullMethod, methodPrefix) {
+		return false
+	}
+	policy, ok := leaderproxy.MethodPolicyMap[fullMethod]
+	return ok && policy == leaderproxy.RequireLeader
+}
+
+// notLeaderError returns the error for a mutation received by a follower. The
+// current leader is attached as an error detail and to the trailers when it is
+// known.
+func notLeaderError(ctx context.Context, consensus storage.Consensus) error {
+	err := leaderproxy.NewNotLeaderError(ctx, consensus)
+	id, addr, ok := leaderproxy.LeaderFromError(err)
+	if !ok {
+		return err
+	}
+	md := metadata.Pairs(
+		leaderproxy.LeaderIDMeta, id,
+		leaderproxy.LeaderAddressMeta, addr,
+	)
+	if err := grpc.SetTrailer(ctx, md); err != nil {
+		context.LoggerFrom(ctx).Debug("Failed to set leader trailer", "error", err.Error())
+	}
+	return err
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admin
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/services/leaderproxy"
+	"github.com/webmeshproj/webmesh/pkg/storage"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+// fakeConsensus is a consensus with a fixed leader.
+type fakeConsensus struct {
+	storage.Consensus
+	isLeader bool
+	leader   *v1.StoragePeer
+}
+
+func (f *fakeConsensus) IsLeader() bool { return f.isLeader }
+
+func (f *fakeConsensus) GetLeader(context.Context) (types.StoragePeer, error) {
+	if f.leader == nil {
+		return types.StoragePeer{}, errors.New("no leader")
+	}
+	return types.StoragePeer{StoragePeer: f.leader}, nil
+}
+
+// trailerStream is a grpc.ServerTransportStream that records trailers.
+type trailerStream struct {
+	grpc.ServerTransportStream
+	trailer metadata.MD
+}
+
+func (s *trailerStream) SetTrailer(md metadata.MD) error {
+	s.trailer = metadata.Join(s.trailer, md)
+	return nil
+}
+
+func TestLeaderUnaryServerInterceptor(t *testing.T) {
+	t.Parallel()
+	leader := &v1.StoragePeer{Id: "leader", Address: "10.0.0.1:9000"}
+	tc := []struct {
+		name      string
+		consensus *fakeConsensus
+		method    string
+		handled   bool
+		message   string
+		trailer   map[string]string
+	}{
+		{
+			name:      "MutationOnLeader",
+			consensus: &fakeConsensus{isLeader: true, leader: leader},
+			method:    v1.Admin_PutNetworkACL_FullMethodName,
+			handled:   true,
+		},
+		{
+			name:      "ReadOnFollower",
+			consensus: &fakeConsensus{leader: leader},
+			method:    v1.Admin_GetNetworkACL_FullMethodName,
+			handled:   true,
+		},
+		{
+			name:      "OtherServiceOnFollower",
+			consensus: &fakeConsensus{leader: leader},
+			method:    v1.Membership_Join_FullMethodName,
+			handled:   true,
+		},
+		{
+			name:      "MutationOnFollower",
+			consensus: &fakeConsensus{leader: leader},
+			method:    v1.Admin_PutNetworkACL_FullMethodName,
+			message:   "10.0.0.1:9000",
+			trailer: map[string]string{
+				leaderproxy.LeaderIDMeta:      "leader",
+				leaderproxy.LeaderAddressMeta: "10.0.0.1:9000",
+			},
+		},
+		{
+			name:      "MutationOnFollowerNoLeader",
+			consensus: &fakeConsensus{},
+			method:    v1.Admin_DeleteRole_FullMethodName,
+			message:   "not the leader",
+		},
+	}
+	for _, tt := range tc {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			stream := &trailerStream{}
+			ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+			var handled bool
+			handler := func(ctx context.Context, req any) (any, error) {
+				handled = true
+				return nil, nil
+			}
+			icep := LeaderUnaryServerInterceptor(tt.consensus)
+			_, err := icep(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if handled != tt.handled {
+				t.Fatalf("expected handled to be %v, got %v", tt.handled, handled)
+			}
+			if tt.handled {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if status.Code(err) != codes.FailedPrecondition {
+				t.Fatalf("expected FailedPrecondition, got %v", err)
+			}
+			if !strings.Contains(status.Convert(err).Message(), tt.message) {
+				t.Fatalf("expected error message to contain %q, got %q", tt.message, status.Convert(err).Message())
+			}
+			if len(stream.trailer) != len(tt.trailer) {
+				t.Fatalf("expected trailer %v, got %v", tt.trailer, stream.trailer)
+			}
+			for key, want := range tt.trailer {
+				if got := stream.trailer.Get(key); len(got) != 1 || got[0] != want {
+					t.Fatalf("expected trailer %s to be %q, got %v", key, want, got)
+				}
+			}
+			id, addr, ok := leaderproxy.LeaderFromError(err)
+			if ok != (tt.trailer != nil) {
+				t.Fatalf("expected leader detail to be present: %v, got %v", tt.trailer != nil, ok)
+			}
+			if ok && (id != tt.trailer[leaderproxy.LeaderIDMeta] || addr != tt.trailer[leaderproxy.LeaderAddressMeta]) {
+				t.Fatalf("expected leader detail %s at %s, got %s at %s", tt.trailer[leaderproxy.LeaderIDMeta], tt.trailer[leaderproxy.LeaderAddressMeta], id, addr)
+			}
+		})
+	}
+}
+
+// followerStorage is a storage provider whose consensus is never the leader.
+type followerStorage struct {
+	storage.Provider
+	consensus *fakeConsensus
+}
+
+func (f *followerStorage) Consensus() storage.Consensus { return f.consensus }
+
+func (f *followerStorage) MeshDB() storage.MeshDB { return nil }
+
+func TestMutationsRequireLeader(t *testing.T) {
+	t.Parallel()
+	// Handlers must reject mutations on followers even when the
+	// interceptor is not installed.
+	server := NewServer(&followerStorage{consensus: &fakeConsensus{}}, nil)
+	ctx := context.Background()
+	tc := map[string]func() error{
+		"PutRole":           func() error { _, err := server.PutRole(ctx, &v1.Role{}); return err },
+		"DeleteRole":        func() error { _, err := server.DeleteRole(ctx, &v1.Role{}); return err },
+		"PutRoleBinding":    func() error { _, err := server.PutRoleBinding(ctx, &v1.RoleBinding{}); return err },
+		"DeleteRoleBinding": func() error { _, err := server.DeleteRoleBinding(ctx, &v1.RoleBinding{}); return err },
+		"PutGroup":          func() error { _, err := server.PutGroup(ctx, &v1.Group{}); return err },
+		"DeleteGroup":       func() error { _, err := server.DeleteGroup(ctx, &v1.Group{}); return err },
+		"PutNetworkACL":     func() error { _, err := server.PutNetworkACL(ctx, &v1.NetworkACL{}); return err },
+		"DeleteNetworkACL":  func() error { _, err := server.DeleteNetworkACL(ctx, &v1.NetworkACL{}); return err },
+		"PutRoute":          func() error { _, err := server.PutRoute(ctx, &v1.Route{}); return err },
+		"DeleteRoute":       func() error { _, err := server.DeleteRoute(ctx, &v1.Route{}); return err },
+		"PutEdge":           func() error { _, err := server.PutEdge(ctx, &v1.MeshEdge{}); return err },
+		"DeleteEdge":        func() error { _, err := server.DeleteEdge(ctx, &v1.MeshEdge{}); return err },
+	}
+	for name, call := range tc {
+		name, call := name, call
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			err := call()
+			if status.Code(err) != codes.FailedPrecondition {
+				t.Fatalf("expected FailedPrecondition, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package admin provides the admin gRPC server.
+package admin
+
+import (
+	"net/netip"
+	"sort"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/services/rbac"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+var listIPAMLeasesAction = rbac.Actions{
+	{
+		Resource: v1.RuleResource_RESOURCE_ALL,
+		Verb:     v1.RuleVerb_VERB_GET,
+	},
+}
+
+// ListIPAMLeasesRequest is a request to list the addresses allocated to nodes.
+type ListIPAMLeasesRequest struct {
+	// Subnet optionally restricts the listing to addresses within the given
+	// IPv4 or IPv6 subnet in CIDR notation.
+	Subnet string `json:"subnet,omitempty"`
+}
+
+// GetSubnet returns the subnet to restrict the listing to.
+func (r *ListIPAMLeasesRequest) GetSubnet() string {
+	if r == nil {
+		return ""
+	}
+	return r.Subnet
+}
+
+// IPAMLease is an address allocated to a node.
+type IPAMLease struct {
+	// NodeID is the ID of the node the address is allocated to.
+	NodeID string `json:"nodeID"`
+	// Address is the allocated address in CIDR notation.
+	Address string `json:"address"`
+}
+
+// IPAMLeases is a list of addresses allocated to nodes.
+type IPAMLeases struct {
+	// Leases are the allocated addresses, sorted by node ID with IPv4
+	// addresses before IPv6 addresses.
+	Leases []IPAMLease `json:"leases"`
+}
+
+// ListIPAMLeases returns the IPv4 and IPv6 addresses currently allocated to
+// nodes in the mesh. When a subnet is given only addresses within it are
+// returned.
+func (s *Server) ListIPAMLeases(ctx context.Context, req *ListIPAMLeasesRequest) (*IPAMLeases, error) {
+	var subnet netip.Prefix
+	if req.GetSubnet() != "" {
+		var err error
+		subnet, err = netip.ParsePrefix(req.GetSubnet())
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid subnet: %v", err)
+		}
+		subnet = types.UnmapPrefix(subnet).Masked()
+		if !subnet.IsValid() {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid subnet: %s", req.GetSubnet())
+		}
+	}
+	if ok, err := s.rbacEval.Evaluate(ctx, listIPAMLeasesAction); !ok {
+		if err != nil {
+			context.LoggerFrom(ctx).Error("failed to evaluate list ipam leases action", "error", err)
+		}
+		return nil, status.Error(codes.PermissionDenied, "caller does not have permission to list ipam leases")
+	}
+	nodes, err := s.db.Peers().List(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].GetId() < nodes[j].GetId()
+	})
+	out := &IPAMLeases{Leases: []IPAMLease{}}
+	for _, node := range nodes {
+		for _, addr := range []netip.Prefix{node.PrivateAddrV4(), node.PrivateAddrV6()} {
+			if !addr.IsValid() {
+				continue
+			}
+			if subnet.IsValid() && !subnet.Contains(addr.Addr()) {
+				continue
+			}
+			out.Leases = append(out.Leases, IPAMLease{
+				NodeID:  node.GetId(),
+				Address: addr.String(),
+			})
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admin
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/webmeshproj/webmesh/pkg/meshnode"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+func TestListIPAMLeases(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	server := newTestServer(t)
+	// Start from the addresses of the test mesh itself so the seeded nodes
+	// can be asserted on in isolation.
+	existing, err := server.ListIPAMLeases(ctx, &ListIPAMLeasesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range []*v1.MeshNode{
+		{Id: "lease-a", PublicKey: newEncodedPubKey(t), PrivateIPv4: "10.10.0.1/32", PrivateIPv6: "fd10::a/128"},
+		{Id: "lease-b", PublicKey: newEncodedPubKey(t), PrivateIPv4: "10.20.0.1/32"},
+		{Id: "lease-c", PublicKey: newEncodedPubKey(t), PrivateIPv6: "fd20::c/128"},
+		{Id: "lease-d", PublicKey: newEncodedPubKey(t)},
+	} {
+		if err := server.db.Peers().Put(ctx, types.MeshNode{MeshNode: node}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seeded := []IPAMLease{
+		{NodeID: "lease-a", Address: "10.10.0.1/32"},
+		{NodeID: "lease-a", Address: "fd10::a/128"},
+		{NodeID: "lease-b", Address: "10.20.0.1/32"},
+		{NodeID: "lease-c", Address: "fd20::c/128"},
+	}
+
+	t.Run("unfiltered", func(t *testing.T) {
+		leases, err := server.ListIPAMLeases(ctx, &ListIPAMLeasesRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(leases.Leases) != len(existing.Leases)+len(seeded) {
+			t.Fatalf("expected %d leases, got %v", len(existing.Leases)+len(seeded), leases.Leases)
+		}
+		var got []IPAMLease
+		for _, lease := range leases.Leases {
+			if lease.NodeID >= "lease-a" && lease.NodeID <= "lease-d" {
+				got = append(got, lease)
+			}
+		}
+		if !reflect.DeepEqual(got, seeded) {
+			t.Fatalf("expected leases %v, got %v", seeded, got)
+		}
+	})
+
+	tc := []struct {
+		name   string
+		subnet string
+		want   []IPAMLease
+	}{
+		{
+			name:   "ipv4 subnet",
+			subnet: "10.10.0.0/16",
+			want:   []IPAMLease{{NodeID: "lease-a", Address: "10.10.0.1/32"}},
+		},
+		{
+			name:   "ipv6 subnet",
+			subnet: "fd20::/64",
+			want:   []IPAMLease{{NodeID: "lease-c", Address: "fd20::c/128"}},
+		},
+		{
+			name:   "mapped ipv4 subnet",
+			subnet: "::ffff:10.20.0.0/112",
+			want:   []IPAMLease{{NodeID: "lease-b", Address: "10.20.0.1/32"}},
+		},
+		{
+			name:   "empty subnet",
+			subnet: "192.0.2.0/24",
+			want:   []IPAMLease{},
+		},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			leases, err := server.ListIPAMLeases(ctx, &ListIPAMLeasesRequest{Subnet: tt.subnet})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(leases.Leases, tt.want) {
+				t.Fatalf("expected leases %v, got %v", tt.want, leases.Leases)
+			}
+		})
+	}
+
+	t.Run("invalid subnet", func(t *testing.T) {
+		_, err := server.ListIPAMLeases(ctx, &ListIPAMLeasesRequest{Subnet: "not-a-subnet"})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("expected InvalidArgument, got %v", err)
+		}
+	})
+
+	t.Run("permission denied", func(t *testing.T) {
+		store, err := meshnode.NewSingleNodeTestMesh(ctx)
+		if err != nil {
+			t.Fatalf("error creating test store: %v", err)
+		}
+		t.Cleanup(func() { store.Close(ctx) })
+		server := NewServer(store.Storage(), denyEvaluator{})
+		_, err = server.ListIPAMLeases(ctx, &ListIPAMLeasesRequest{})
+		if status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("expected PermissionDenied, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admin
+
+import (
+	"log/slog"
+	"path"
+	"strings"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/services/leaderproxy"
+)
+
+// methodPrefix is the prefix of all full method names served by the admin service.
+var methodPrefix = "/" + v1.Admin_ServiceDesc.ServiceName + "/"
+
+// UnaryServerInterceptor returns a unary server interceptor that enriches the
+// context logger of admin requests with the RPC name and the caller. Requests
+// for other services are passed through untouched. It should be chained after
+// any authentication interceptors so the caller is known.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !strings.HasPrefix(info.FullMethod, methodPrefix) {
+			return handler(ctx, req)
+		}
+		return handler(withRequestLogger(ctx, info.FullMethod), req)
+	}
+}
+
+// withRequestLogger returns a context with the logger enriched with the method
+// and caller of the request.
+func withRequestLogger(ctx context.Context, fullMethod string) context.Context {
+	attrs := []any{slog.String("method", path.Base(fullMethod))}
+	if caller, ok := callerFrom(ctx); ok {
+		attrs = append(attrs, slog.String("caller", caller))
+	}
+	return context.WithLogger(ctx, context.LoggerFrom(ctx).With(attrs...))
+}
+
+// callerFrom returns the caller of the request in the same order of precedence
+// used for RBAC evaluation.
+func callerFrom(ctx context.Context) (string, bool) {
+	if proxiedFor, ok := leaderproxy.ProxiedFor(ctx); ok {
+		return proxiedFor, true
+	}
+	caller, ok := context.AuthenticatedCallerFrom(ctx)
+	return caller, ok && caller != ""
+}
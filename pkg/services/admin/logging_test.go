@@ -0,0 +1,103 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admin
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/services/leaderproxy"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	t.Parallel()
+	tc := []struct {
+		name       string
+		ctx        func(context.Context) context.Context
+		fullMethod string
+		want       []string
+		notWant    []string
+	}{
+		{
+			name:       "AuthenticatedCaller",
+			ctx:        func(ctx context.Context) context.Context { return context.WithAuthenticatedCaller(ctx, "admin-user") },
+			fullMethod: "/v1.Admin/PutRole",
+			want:       []string{"method=PutRole", "caller=admin-user"},
+		},
+		{
+			name: "ProxiedCaller",
+			ctx: func(ctx context.Context) context.Context {
+				ctx = context.WithAuthenticatedCaller(ctx, "follower")
+				return metadata.NewIncomingContext(ctx, metadata.Pairs(leaderproxy.ProxiedForMeta, "admin-user"))
+			},
+			fullMethod: "/v1.Admin/DeleteRole",
+			want:       []string{"method=DeleteRole", "caller=admin-user"},
+			notWant:    []string{"caller=follower"},
+		},
+		{
+			name:       "UnknownCaller",
+			fullMethod: "/v1.Admin/GetRole",
+			want:       []string{"method=GetRole"},
+			notWant:    []string{"caller="},
+		},
+		{
+			name:       "OtherService",
+			ctx:        func(ctx context.Context) context.Context { return context.WithAuthenticatedCaller(ctx, "admin-user") },
+			fullMethod: "/v1.Membership/Join",
+			notWant:    []string{"method=", "caller="},
+		},
+	}
+	for _, tt := range tc {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			ctx := context.WithLogger(context.Background(), slog.New(slog.NewTextHandler(&buf, nil)))
+			if tt.ctx != nil {
+				ctx = tt.ctx(ctx)
+			}
+			handler := func(ctx context.Context, req any) (any, error) {
+				context.LoggerFrom(ctx).Info("handling request")
+				return nil, nil
+			}
+			_, err := UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.fullMethod}, handler)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			line := buf.String()
+			if !strings.Contains(line, "handling request") {
+				t.Fatalf("expected handler log line, got %q", line)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(line, want) {
+					t.Errorf("expected log line to contain %q, got %q", want, line)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(line, notWant) {
+					t.Errorf("expected log line to not contain %q, got %q", notWant, line)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package admin provides the admin gRPC server.
+package admin
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc/status"
+)
+
+// NetworkACL Metrics
+var (
+	// NetworkACLOperationsTotal tracks network ACL operations handled by the
+	// admin service by operation and resulting status code.
+	NetworkACLOperationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "webmesh",
+		Name:      "admin_network_acl_operations_total",
+		Help:      "Total network ACL operations handled by the admin service.",
+	}, []string{"operation", "code"})
+)
+
+// recordNetworkACLOperation increments the network ACL operations counter
+// for the given operation and the status code of the given error.
+func recordNetworkACLOperation(operation string, err error) {
+	NetworkACLOperationsTotal.WithLabelValues(operation, status.Code(err).String()).Inc()
+}
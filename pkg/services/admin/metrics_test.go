@@ -0,0 +1,102 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc/codes"
+
+	"github.com/webmeshproj/webmesh/pkg/meshnode"
+	"github.com/webmeshproj/webmesh/pkg/services/rbac"
+)
+
+type denyEvaluator struct{}
+
+func (denyEvaluator) Evaluate(context.Context, rbac.Actions) (bool, error) { return false, nil }
+
+func (denyEvaluator) IsSecure() bool { return true }
+
+func TestNetworkACLMetrics(t *testing.T) {
+	// Not parallel, other tests in the package also touch the counters.
+	ctx := context.Background()
+	acl := &v1.NetworkACL{
+		Name:        "metrics-acl",
+		Action:      v1.ACLAction_ACTION_ACCEPT,
+		SourceNodes: []string{"foo"},
+	}
+
+	t.Run("PutSuccess", func(t *testing.T) {
+		server := newTestServer(t)
+		counter := NetworkACLOperationsTotal.WithLabelValues("put", codes.OK.String())
+		before := testutil.ToFloat64(counter)
+		_, err := server.PutNetworkACL(ctx, acl)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if after := testutil.ToFloat64(counter); after < before+1 {
+			t.Errorf("expected put counter to increment from %v, got %v", before, after)
+		}
+	})
+
+	t.Run("PutPermissionDenied", func(t *testing.T) {
+		store, err := meshnode.NewSingleNodeTestMesh(ctx)
+		if err != nil {
+			t.Fatalf("error creating test store: %v", err)
+		}
+		t.Cleanup(func() { store.Close(ctx) })
+		server := NewServer(store.Storage(), denyEvaluator{})
+		counter := NetworkACLOperationsTotal.WithLabelValues("put", codes.PermissionDenied.String())
+		before := testutil.ToFloat64(counter)
+		_, err = server.PutNetworkACL(ctx, acl)
+		if err == nil {
+			t.Fatal("expected permission denied error")
+		}
+		if after := testutil.ToFloat64(counter); after < before+1 {
+			t.Errorf("expected permission denied counter to increment from %v, got %v", before, after)
+		}
+	})
+
+	t.Run("PutBatchSuccess", func(t *testing.T) {
+		server := newTestServer(t)
+		counter := NetworkACLOperationsTotal.WithLabelValues("put_batch", codes.OK.String())
+		before := testutil.ToFloat64(counter)
+		_, err := server.PutNetworkACLs(ctx, &v1.NetworkACLs{Items: []*v1.NetworkACL{acl}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if after := testutil.ToFloat64(counter); after < before+1 {
+			t.Errorf("expected put batch counter to increment from %v, got %v", before, after)
+		}
+	})
+
+	t.Run("PutBatchInvalidArgument", func(t *testing.T) {
+		server := newTestServer(t)
+		counter := NetworkACLOperationsTotal.WithLabelValues("put_batch", codes.InvalidArgument.String())
+		before := testutil.ToFloat64(counter)
+		_, err := server.PutNetworkACLs(ctx, &v1.NetworkACLs{})
+		if err == nil {
+			t.Fatal("expected invalid argument error")
+		}
+		if after := testutil.ToFloat64(counter); after < before+1 {
+			t.Errorf("expected invalid argument counter to increment from %v, got %v", before, after)
+		}
+	})
+}
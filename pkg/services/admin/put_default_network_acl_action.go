@@ -0,0 +1,71 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package admin provides the admin gRPC server.
+package admin
+
+import (
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/services/rbac"
+	"github.com/webmeshproj/webmesh/pkg/storage"
+)
+
+var putDefaultNetworkACLAction = rbac.Actions{
+	{
+		Resource: v1.RuleResource_RESOURCE_NETWORK_ACLS,
+		Verb:     v1.RuleVerb_VERB_PUT,
+	},
+}
+
+// DefaultNetworkACLAction is the action applied to flows that no NetworkACL matches.
+type DefaultNetworkACLAction struct {
+	// Action is the default action. Only ACTION_ACCEPT and ACTION_DENY are valid.
+	Action v1.ACLAction `json:"action"`
+}
+
+// GetAction returns the default action.
+func (d *DefaultNetworkACLAction) GetAction() v1.ACLAction {
+	if d == nil {
+		return v1.ACLAction_ACTION_UNKNOWN
+	}
+	return d.Action
+}
+
+// PutDefaultNetworkACLAction sets the action applied to flows that no NetworkACL matches.
+func (s *Server) PutDefaultNetworkACLAction(ctx context.Context, req *DefaultNetworkACLAction) (*emptypb.Empty, error) {
+	if !s.storage.Consensus().IsLeader() {
+		return nil, notLeaderError(ctx, s.storage.Consensus())
+	}
+	if req.GetAction() != v1.ACLAction_ACTION_ACCEPT && req.GetAction() != v1.ACLAction_ACTION_DENY {
+		return nil, status.Error(codes.InvalidArgument, "default action must be accept or deny")
+	}
+	if ok, err := s.rbacEval.Evaluate(ctx, putDefaultNetworkACLAction); !ok {
+		if err != nil {
+			context.LoggerFrom(ctx).Error("failed to evaluate put default network acl action", "error", err)
+		}
+		return nil, status.Error(codes.PermissionDenied, "caller does not have permission to put network acls")
+	}
+	err := storage.PutDefaultNetworkACLAction(ctx, s.storage.MeshStorage(), req.GetAction())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &emptypb.Empty{}, nil
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package admin provides the admin gRPC server.
+package admin
+
+import (
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/services/rbac"
+	"github.com/webmeshproj/webmesh/pkg/storage"
+)
+
+var putIPAMAllocationStateAction = rbac.Actions{
+	{
+		Resource: v1.RuleResource_RESOURCE_ALL,
+		Verb:     v1.RuleVerb_VERB_PUT,
+	},
+}
+
+// IPAMAllocationState is the state of new allocations by the built-in IPAM plugin.
+type IPAMAllocationState struct {
+	// Paused is true if new allocations are paused.
+	Paused bool `json:"paused"`
+}
+
+// GetPaused returns true if new allocations are paused.
+func (s *IPAMAllocationState) GetPaused() bool {
+	if s == nil {
+		return false
+	}
+	return s.Paused
+}
+
+// PutIPAMAllocationState pauses or resumes new allocations by the built-in IPAM
+// plugin. This is useful during maintenance, such as re-numbering the mesh.
+// Existing allocations are unaffected.
+func (s *Server) PutIPAMAllocationState(ctx context.Context, req *IPAMAllocationState) (*emptypb.Empty, error) {
+	if !s.storage.Consensus().IsLeader() {
+		return nil, notLeaderError(ctx, s.storage.Consensus())
+	}
+	if ok, err := s.rbacEval.Evaluate(ctx, putIPAMAllocationStateAction); !ok {
+		if err != nil {
+			context.LoggerFrom(ctx).Error("failed to evaluate put ipam allocation state action", "error", err)
+		}
+		return nil, status.Error(codes.PermissionDenied, "caller does not have permission to put ipam allocation state")
+	}
+	err := storage.SetIPAMPaused(ctx, s.storage.MeshStorage(), req.GetPaused())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &emptypb.Empty{}, nil
+}
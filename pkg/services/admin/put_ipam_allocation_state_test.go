@@ -0,0 +1,64 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admin
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestPutIPAMAllocationState(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	ctx := context.Background()
+
+	paused := func(t *testing.T) bool {
+		t.Helper()
+		state, err := server.GetIPAMAllocationState(ctx, &emptypb.Empty{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return state.GetPaused()
+	}
+	if paused(t) {
+		t.Fatal("expected allocations to not be paused by default")
+	}
+	runTestCases(t, []testCase[IPAMAllocationState]{
+		{
+			name: "pause",
+			code: codes.OK,
+			req:  &IPAMAllocationState{Paused: true},
+		},
+	}, server.PutIPAMAllocationState)
+	if !paused(t) {
+		t.Fatal("expected allocations to be paused")
+	}
+	runTestCases(t, []testCase[IPAMAllocationState]{
+		{
+			name: "resume",
+			code: codes.OK,
+			req:  &IPAMAllocationState{Paused: false},
+		},
+	}, server.PutIPAMAllocationState)
+	if paused(t) {
+		t.Fatal("expected allocations to be resumed")
+	}
+}
@@ -37,8 +37,14 @@ var putNetworkACLAction = rbac.Actions{
 
 func (s *Server) PutNetworkACL(ctx context.Context, acl *v1.NetworkACL) (*emptypb.Empty, error) {
 	if !s.storage.Consensus().IsLeader() {
-		return nil, status.Error(codes.FailedPrecondition, "not the leader")
+		return nil, notLeaderError(ctx, s.storage.Consensus())
 	}
+	resp, err := s.putNetworkACL(ctx, acl)
+	recordNetworkACLOperation("put", err)
+	return resp, err
+}
+
+func (s *Server) putNetworkACL(ctx context.Context, acl *v1.NetworkACL) (*emptypb.Empty, error) {
 	if acl.GetName() == "" {
 		return nil, status.Error(codes.InvalidArgument, "acl name is required")
 	}
@@ -51,22 +57,39 @@ func (s *Server) PutNetworkACL(ctx context.Context, acl *v1.NetworkACL) (*emptyp
 		}
 		return nil, status.Error(codes.PermissionDenied, "caller does not have permission to put network acls")
 	}
+	nacl, err := validateNetworkACL(acl, s.limits)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.Networking().PutNetworkACL(ctx, nacl)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// validateNetworkACL validates the contents of a network ACL. The name is
+// expected to have been validated already. Size limits are checked first so
+// oversized requests are rejected before any per-entry work.
+func validateNetworkACL(acl *v1.NetworkACL, limits Limits) (types.NetworkACL, error) {
+	if n := len(acl.GetSourceCIDRs()) + len(acl.GetDestinationCIDRs()); n > limits.MaxACLCIDRs {
+		return types.NetworkACL{}, status.Errorf(codes.InvalidArgument, "acl has %d cidrs, exceeding the limit of %d (max-acl-cidrs)", n, limits.MaxACLCIDRs)
+	}
+	if n := len(acl.GetSourceNodes()) + len(acl.GetDestinationNodes()); n > limits.MaxACLNodes {
+		return types.NetworkACL{}, status.Errorf(codes.InvalidArgument, "acl has %d nodes, exceeding the limit of %d (max-acl-nodes)", n, limits.MaxACLNodes)
+	}
 	if _, ok := v1.ACLAction_name[int32(acl.GetAction())]; !ok {
-		return nil, status.Error(codes.InvalidArgument, "invalid acl action")
+		return types.NetworkACL{}, status.Error(codes.InvalidArgument, "invalid acl action")
 	}
 	if allEmpty([][]string{acl.GetDestinationCIDRs(), acl.GetSourceCIDRs(), acl.GetSourceNodes(), acl.GetDestinationNodes()}) {
-		return nil, status.Error(codes.InvalidArgument, "at least one of destination_cidrs, source_cidrs, source_nodes, or destination_nodes must be set")
+		return types.NetworkACL{}, status.Error(codes.InvalidArgument, "at least one of destination_cidrs, source_cidrs, source_nodes, or destination_nodes must be set")
 	}
 	nacl := types.NetworkACL{NetworkACL: acl}
 	err := types.ValidateACL(nacl)
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, err.Error())
+		return types.NetworkACL{}, status.Error(codes.InvalidArgument, err.Error())
 	}
-	err = s.db.Networking().PutNetworkACL(ctx, nacl)
-	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
-	}
-	return &emptypb.Empty{}, nil
+	return nacl, nil
 }
 
 func allEmpty(ss [][]string) bool {
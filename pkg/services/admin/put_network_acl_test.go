@@ -18,10 +18,15 @@ package admin
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
 
 	v1 "github.com/webmeshproj/api/go/v1"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/webmeshproj/webmesh/pkg/services/rbac"
 )
 
 func TestPutNetworkACL(t *testing.T) {
@@ -94,6 +99,26 @@ func TestPutNetworkACL(t *testing.T) {
 				DestinationCIDRs: []string{"0.0.0.0/0"},
 			},
 		},
+		{
+			name: "invalid node pattern",
+			code: codes.InvalidArgument,
+			req: &v1.NetworkACL{
+				Name:             "foo",
+				Action:           v1.ACLAction_ACTION_ACCEPT,
+				SourceNodes:      []string{"worker-?-*"},
+				DestinationCIDRs: []string{"0.0.0.0/0"},
+			},
+		},
+		{
+			name: "valid node pattern",
+			code: codes.OK,
+			req: &v1.NetworkACL{
+				Name:             "workers",
+				Action:           v1.ACLAction_ACTION_ACCEPT,
+				SourceNodes:      []string{"worker-*"},
+				DestinationNodes: []string{"*-db"},
+			},
+		},
 		{
 			name: "valid acl",
 			code: codes.OK,
@@ -132,3 +157,74 @@ func TestPutNetworkACL(t *testing.T) {
 
 	runTestCases(t, tt, server.PutNetworkACL)
 }
+
+func TestPutNetworkACLLimits(t *testing.T) {
+	t.Parallel()
+
+	server := NewServerWithLimits(newTestServer(t).storage, rbac.NewNoopEvaluator(), Limits{
+		MaxACLCIDRs: 4,
+		MaxACLNodes: 2,
+	})
+	cidrs := func(n int) []string {
+		out := make([]string, n)
+		for i := range out {
+			out[i] = fmt.Sprintf("10.0.%d.0/24", i)
+		}
+		return out
+	}
+
+	tt := []testCase[v1.NetworkACL]{
+		{
+			name: "cidrs over limit",
+			code: codes.InvalidArgument,
+			req: &v1.NetworkACL{
+				Name:             "too-many-cidrs",
+				Action:           v1.ACLAction_ACTION_ACCEPT,
+				SourceCIDRs:      cidrs(3),
+				DestinationCIDRs: cidrs(2),
+			},
+		},
+		{
+			name: "nodes over limit",
+			code: codes.InvalidArgument,
+			req: &v1.NetworkACL{
+				Name:             "too-many-nodes",
+				Action:           v1.ACLAction_ACTION_ACCEPT,
+				SourceNodes:      []string{"foo", "bar"},
+				DestinationNodes: []string{"baz"},
+			},
+		},
+		{
+			name: "within limits",
+			code: codes.OK,
+			req: &v1.NetworkACL{
+				Name:             "within-limits",
+				Action:           v1.ACLAction_ACTION_ACCEPT,
+				SourceNodes:      []string{"foo"},
+				DestinationNodes: []string{"bar"},
+				SourceCIDRs:      cidrs(2),
+				DestinationCIDRs: cidrs(2),
+			},
+		},
+	}
+
+	runTestCases(t, tt, server.PutNetworkACL)
+
+	t.Run("error names limit", func(t *testing.T) {
+		_, err := server.PutNetworkACL(context.Background(), &v1.NetworkACL{
+			Name:        "too-many-cidrs",
+			Action:      v1.ACLAction_ACTION_ACCEPT,
+			SourceCIDRs: cidrs(5),
+		})
+		if msg := status.Convert(err).Message(); !strings.Contains(msg, "max-acl-cidrs") {
+			t.Errorf("expected error to name the exceeded limit, got %q", msg)
+		}
+	})
+
+	t.Run("default limits", func(t *testing.T) {
+		limits := Limits{}.Default()
+		if limits.MaxACLCIDRs != DefaultMaxACLCIDRs || limits.MaxACLNodes != DefaultMaxACLNodes {
+			t.Errorf("expected default limits, got %+v", limits)
+		}
+	})
+}
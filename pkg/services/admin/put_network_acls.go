@@ -0,0 +1,158 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admin
+
+import (
+	"fmt"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/storage"
+	"github.com/webmeshproj/webmesh/pkg/storage/errors"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+// PutNetworkACLs creates or updates multiple network ACLs together. Every ACL
+// is validated before any are written and the whole batch is rejected if any
+// entry is invalid. The batch is written in a single storage transaction so
+// readers never observe part of it.
+func (s *Server) PutNetworkACLs(ctx context.Context, acls *v1.NetworkACLs) (*emptypb.Empty, error) {
+	if !s.storage.Consensus().IsLeader() {
+		return nil, notLeaderError(ctx, s.storage.Consensus())
+	}
+	resp, err := s.putNetworkACLs(ctx, acls)
+	recordNetworkACLOperation("put_batch", err)
+	return resp, err
+}
+
+func (s *Server) putNetworkACLs(ctx context.Context, acls *v1.NetworkACLs) (*emptypb.Empty, error) {
+	if len(acls.GetItems()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "at least one acl is required")
+	}
+	batch := make([]types.NetworkACL, 0, len(acls.GetItems()))
+	seen := make(map[string]struct{}, len(acls.GetItems()))
+	for _, acl := range acls.GetItems() {
+		if acl.GetName() == "" {
+			return nil, status.Error(codes.InvalidArgument, "acl name is required")
+		}
+		if !types.IsValidID(acl.GetName()) {
+			return nil, status.Errorf(codes.InvalidArgument, "acl name %q must be a valid ID", acl.GetName())
+		}
+		if _, ok := seen[acl.GetName()]; ok {
+			return nil, status.Errorf(codes.InvalidArgument, "acl %q appears more than once", acl.GetName())
+		}
+		seen[acl.GetName()] = struct{}{}
+		if ok, err := s.rbacEval.Evaluate(ctx, putNetworkACLAction.For(acl.GetName())); !ok {
+			if err != nil {
+				context.LoggerFrom(ctx).Error("failed to evaluate put network acl action", "error", err)
+			}
+			return nil, status.Errorf(codes.PermissionDenied, "caller does not have permission to put network acl %q", acl.GetName())
+		}
+		nacl, err := validateNetworkACL(acl, s.limits)
+		if err != nil {
+			return nil, status.Errorf(status.Code(err), "acl %q: %s", acl.GetName(), status.Convert(err).Message())
+		}
+		batch = append(batch, nacl)
+	}
+	if err := writeNetworkACLs(ctx, s.storage.MeshStorage(), s.db.Networking(), batch); err != nil {
+		return nil, status.Errorf(codes.Internal, "put network acls: %v", err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// writeNetworkACLs writes the ACLs in a single transaction. Storage that cannot
+// use transactions gets each ACL written in order, and any already written are
+// rolled back to their previous state if a later write fails.
+func writeNetworkACLs(ctx context.Context, st storage.MeshStorage, nw storage.Networking, batch []types.NetworkACL) error {
+	txn, err := storage.Begin(ctx, st)
+	if err != nil {
+		if !errors.Is(err, errors.ErrNotImplemented) {
+			return fmt.Errorf("begin transaction: %w", err)
+		}
+		return putNetworkACLsInOrder(ctx, nw, batch)
+	}
+	for _, acl := range batch {
+		data, err := acl.MarshalProtoJSON()
+		if err != nil {
+			_ = txn.Rollback()
+			return fmt.Errorf("marshal network acl %q: %w", acl.GetName(), err)
+		}
+		if err := txn.PutValue(storage.NetworkACLsPrefix.For([]byte(acl.GetName())), data, 0); err != nil {
+			_ = txn.Rollback()
+			return fmt.Errorf("put network acl %q: %w", acl.GetName(), err)
+		}
+	}
+	if err := txn.Commit(ctx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+// putNetworkACLsInOrder writes the ACLs one at a time, restoring any already
+// written if a later write fails.
+func putNetworkACLsInOrder(ctx context.Context, nw storage.Networking, batch []types.NetworkACL) error {
+	// Capture the current state of each ACL for rolling back.
+	previous := make([]*types.NetworkACL, len(batch))
+	for i, acl := range batch {
+		current, err := nw.GetNetworkACL(ctx, acl.GetName())
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("get network acl %q: %w", acl.GetName(), err)
+		}
+		previous[i] = &current
+	}
+	for i, acl := range batch {
+		err := nw.PutNetworkACL(ctx, acl)
+		if err == nil {
+			continue
+		}
+		// Roll back on a fresh context so a cancelled request still cleans up.
+		rctx := context.WithLogger(context.Background(), context.LoggerFrom(ctx))
+		if rerr := rollbackNetworkACLs(rctx, nw, batch[:i], previous[:i]); rerr != nil {
+			context.LoggerFrom(ctx).Error("failed to roll back network acls", "error", rerr)
+		}
+		return fmt.Errorf("put network acl %q: %w", acl.GetName(), err)
+	}
+	return nil
+}
+
+// rollbackNetworkACLs restores the written ACLs to their previous state,
+// deleting any that did not exist before.
+func rollbackNetworkACLs(ctx context.Context, nw storage.Networking, written []types.NetworkACL, previous []*types.NetworkACL) error {
+	var errs []error
+	for i := len(written) - 1; i >= 0; i-- {
+		var err error
+		if previous[i] != nil {
+			err = nw.PutNetworkACL(ctx, *previous[i])
+		} else {
+			err = nw.DeleteNetworkACL(ctx, written[i].GetName())
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", written[i].GetName(), err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("rollback: %v", errs)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/webmeshproj/webmesh/pkg/storage"
+	"github.com/webmeshproj/webmesh/pkg/storage/errors"
+	"github.com/webmeshproj/webmesh/pkg/storage/meshdb"
+	"github.com/webmeshproj/webmesh/pkg/storage/providers/backends/badgerdb"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+func TestPutNetworkACLs(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+
+	tt := []testCase[v1.NetworkACLs]{
+		{
+			name: "empty batch",
+			code: codes.InvalidArgument,
+			req:  &v1.NetworkACLs{},
+		},
+		{
+			name: "duplicate names",
+			code: codes.InvalidArgument,
+			req: &v1.NetworkACLs{Items: []*v1.NetworkACL{
+				{Name: "dup", Action: v1.ACLAction_ACTION_ACCEPT, SourceNodes: []string{"foo"}},
+				{Name: "dup", Action: v1.ACLAction_ACTION_DENY, SourceNodes: []string{"bar"}},
+			}},
+		},
+		{
+			name: "one invalid acl",
+			code: codes.InvalidArgument,
+			req: &v1.NetworkACLs{Items: []*v1.NetworkACL{
+				{Name: "valid-first", Action: v1.ACLAction_ACTION_ACCEPT, SourceNodes: []string{"foo"}},
+				{Name: "invalid-second", Action: v1.ACLAction_ACTION_ACCEPT, SourceCIDRs: []string{"foo"}},
+			}},
+			tval: func(t *testing.T) {
+				for _, name := range []string{"valid-first", "invalid-second"} {
+					_, err := server.GetNetworkACL(context.Background(), &v1.NetworkACL{Name: name})
+					if status.Code(err) != codes.NotFound {
+						t.Errorf("expected acl %q not to be committed, got: %v", name, err)
+					}
+				}
+			},
+		},
+		{
+			name: "all valid",
+			code: codes.OK,
+			req: &v1.NetworkACLs{Items: []*v1.NetworkACL{
+				{Name: "batch-accept", Action: v1.ACLAction_ACTION_ACCEPT, SourceNodes: []string{"foo"}, DestinationCIDRs: []string{"10.0.0.0/8"}},
+				{Name: "batch-deny", Action: v1.ACLAction_ACTION_DENY, SourceNodes: []string{"bar"}, DestinationCIDRs: []string{"0.0.0.0/0"}},
+			}},
+			tval: func(t *testing.T) {
+				expected := map[string]v1.ACLAction{
+					"batch-accept": v1.ACLAction_ACTION_ACCEPT,
+					"batch-deny":   v1.ACLAction_ACTION_DENY,
+				}
+				for name, action := range expected {
+					acl, err := server.GetNetworkACL(context.Background(), &v1.NetworkACL{Name: name})
+					if err != nil {
+						t.Errorf("expected acl %q to be committed: %v", name, err)
+						continue
+					}
+					if acl.GetAction() != action {
+						t.Errorf("expected acl %q action to be %s, got %s", name, action, acl.GetAction())
+					}
+				}
+			},
+		},
+	}
+
+	runTestCases(t, tt, server.PutNetworkACLs)
+}
+
+func TestWriteNetworkACLs(t *testing.T) {
+	t.Parallel()
+	newBatch := func(names ...string) []types.NetworkACL {
+		var batch []types.NetworkACL
+		for _, name := range names {
+			batch = append(batch, types.NetworkACL{NetworkACL: &v1.NetworkACL{
+				Name:        name,
+				Action:      v1.ACLAction_ACTION_ACCEPT,
+				SourceNodes: []string{"foo"},
+			}})
+		}
+		return batch
+	}
+	newStorage := func(t *testing.T) storage.MeshStorage {
+		t.Helper()
+		db, err := badgerdb.NewInMemory(badgerdb.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = db.Close() })
+		return db
+	}
+
+	t.Run("Transaction", func(t *testing.T) {
+		st := &failingACLStorage{MeshStorage: newStorage(t), failKey: []byte("none")}
+		ctx := context.Background()
+		err := writeNetworkACLs(ctx, &txnACLStorage{st}, meshdb.NewFromStorage(st).Networking(), newBatch("txn-a", "txn-b"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if st.puts != 0 {
+			t.Fatalf("expected no individual writes, got %d", st.puts)
+		}
+		for _, name := range []string{"txn-a", "txn-b"} {
+			if _, err := meshdb.NewFromStorage(st).Networking().GetNetworkACL(ctx, name); err != nil {
+				t.Fatalf("expected acl %q to be written: %v", name, err)
+			}
+		}
+	})
+
+	t.Run("RollbackOnCancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		st := &failingACLStorage{
+			MeshStorage: newStorage(t),
+			failKey:     storage.NetworkACLsPrefix.For([]byte("cancel-b")),
+			onFail:      cancel,
+		}
+		nw := meshdb.NewFromStorage(st).Networking()
+		err := writeNetworkACLs(ctx, st, nw, newBatch("cancel-a", "cancel-b"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		_, err = nw.GetNetworkACL(context.Background(), "cancel-a")
+		if !errors.IsNotFound(err) {
+			t.Fatalf("expected acl to be rolled back after the request was cancelled, got %v", err)
+		}
+	})
+}
+
+// failingACLStorage is storage without transaction support that fails writes
+// to failKey and honors context cancellation.
+type failingACLStorage struct {
+	storage.MeshStorage
+	failKey []byte
+	onFail  func()
+	puts    int
+}
+
+func (f *failingACLStorage) PutValue(ctx context.Context, key, value []byte, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if bytes.Equal(key, f.failKey) {
+		if f.onFail != nil {
+			f.onFail()
+		}
+		return fmt.Errorf("injected failure")
+	}
+	f.puts++
+	return f.MeshStorage.PutValue(ctx, key, value, ttl)
+}
+
+func (f *failingACLStorage) Delete(ctx context.Context, key []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return f.MeshStorage.Delete(ctx, key)
+}
+
+// txnACLStorage exposes the transactions of the storage wrapped by a
+// failingACLStorage.
+type txnACLStorage struct {
+	*failingACLStorage
+}
+
+func (t *txnACLStorage) Begin(ctx context.Context) (storage.Txn, error) {
+	return storage.Begin(ctx, t.failingACLStorage.MeshStorage)
+}
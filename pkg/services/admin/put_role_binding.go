@@ -38,7 +38,7 @@ var putRoleBindingAction = rbac.Actions{
 
 func (s *Server) PutRoleBinding(ctx context.Context, rb *v1.RoleBinding) (*emptypb.Empty, error) {
 	if !s.storage.Consensus().IsLeader() {
-		return nil, status.Error(codes.FailedPrecondition, "not the leader")
+		return nil, notLeaderError(ctx, s.storage.Consensus())
 	}
 	if rb.GetName() == "" {
 		return nil, status.Error(codes.InvalidArgument, "rolebinding name cannot be empty")
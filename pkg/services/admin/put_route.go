@@ -37,7 +37,7 @@ var putRouteAction = rbac.Actions{
 
 func (s *Server) PutRoute(ctx context.Context, route *v1.Route) (*emptypb.Empty, error) {
 	if !s.storage.Consensus().IsLeader() {
-		return nil, status.Error(codes.FailedPrecondition, "not the leader")
+		return nil, notLeaderError(ctx, s.storage.Consensus())
 	}
 	rt := types.Route{Route: route}
 	err := types.ValidateRoute(rt)
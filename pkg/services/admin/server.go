@@ -31,13 +31,48 @@ type Server struct {
 	storage  storage.Provider
 	db       storage.MeshDB
 	rbacEval rbac.Evaluator
+	limits   Limits
+}
+
+const (
+	// DefaultMaxACLCIDRs is the default maximum number of CIDRs in a network ACL.
+	DefaultMaxACLCIDRs = 1024
+	// DefaultMaxACLNodes is the default maximum number of nodes in a network ACL.
+	DefaultMaxACLNodes = 1024
+)
+
+// Limits are limits on the size of requests accepted by the admin server.
+type Limits struct {
+	// MaxACLCIDRs is the maximum number of source and destination CIDRs
+	// combined in a network ACL. Zero uses DefaultMaxACLCIDRs.
+	MaxACLCIDRs int
+	// MaxACLNodes is the maximum number of source and destination nodes
+	// combined in a network ACL. Zero uses DefaultMaxACLNodes.
+	MaxACLNodes int
+}
+
+// Default returns a copy of the limits with zero values set to their defaults.
+func (l Limits) Default() Limits {
+	if l.MaxACLCIDRs <= 0 {
+		l.MaxACLCIDRs = DefaultMaxACLCIDRs
+	}
+	if l.MaxACLNodes <= 0 {
+		l.MaxACLNodes = DefaultMaxACLNodes
+	}
+	return l
 }
 
 // New creates a new admin server.
 func NewServer(storage storage.Provider, rbac rbac.Evaluator) *Server {
+	return NewServerWithLimits(storage, rbac, Limits{})
+}
+
+// NewServerWithLimits creates a new admin server with the given request limits.
+func NewServerWithLimits(storage storage.Provider, rbac rbac.Evaluator, limits Limits) *Server {
 	return &Server{
 		storage:  storage,
 		db:       storage.MeshDB(),
 		rbacEval: rbac,
+		limits:   limits.Default(),
 	}
 }
@@ -0,0 +1,100 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package admin provides the admin gRPC server.
+package admin
+
+import (
+	"log/slog"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/services/rbac"
+	"github.com/webmeshproj/webmesh/pkg/storage/errors"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+var setNodeZoneAction = rbac.Actions{
+	{
+		Resource: v1.RuleResource_RESOURCE_ALL,
+		Verb:     v1.RuleVerb_VERB_PUT,
+	},
+}
+
+// SetNodeZoneRequest is a request to change the zone awareness ID of a node.
+type SetNodeZoneRequest struct {
+	// Id is the ID of the node to update.
+	Id string `json:"id"`
+	// ZoneAwarenessID is the new zone awareness ID of the node. An empty
+	// value removes the node from any zone.
+	ZoneAwarenessID string `json:"zoneAwarenessID"`
+}
+
+// GetId returns the ID of the node to update.
+func (r *SetNodeZoneRequest) GetId() string {
+	if r == nil {
+		return ""
+	}
+	return r.Id
+}
+
+// GetZoneAwarenessID returns the new zone awareness ID of the node.
+func (r *SetNodeZoneRequest) GetZoneAwarenessID() string {
+	if r == nil {
+		return ""
+	}
+	return r.ZoneAwarenessID
+}
+
+// SetNodeZone changes the zone awareness ID of a node after it has joined.
+// The peer record is updated atomically so concurrent changes to other
+// fields of the node are not lost.
+func (s *Server) SetNodeZone(ctx context.Context, req *SetNodeZoneRequest) (*emptypb.Empty, error) {
+	if !s.storage.Consensus().IsLeader() {
+		return nil, notLeaderError(ctx, s.storage.Consensus())
+	}
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "node id is required")
+	}
+	if !types.IsValidNodeID(req.GetId()) {
+		return nil, status.Error(codes.InvalidArgument, "invalid node id")
+	}
+	if ok, err := s.rbacEval.Evaluate(ctx, setNodeZoneAction.For(req.GetId())); !ok {
+		if err != nil {
+			context.LoggerFrom(ctx).Error("failed to evaluate set node zone action", "error", err)
+		}
+		return nil, status.Error(codes.PermissionDenied, "caller does not have permission to update nodes")
+	}
+	context.LoggerFrom(ctx).Info("Updating node zone",
+		slog.String("id", req.GetId()),
+		slog.String("zone", req.GetZoneAwarenessID()),
+	)
+	err := s.db.Peers().Update(ctx, types.NodeID(req.GetId()), func(node *types.MeshNode) error {
+		node.ZoneAwarenessID = req.GetZoneAwarenessID()
+		return nil
+	})
+	if err != nil {
+		if errors.IsNodeNotFound(err) {
+			return nil, status.Errorf(codes.NotFound, "node %q not found", req.GetId())
+		}
+		return nil, status.Errorf(codes.Internal, "failed to update node: %v", err)
+	}
+	return &emptypb.Empty{}, nil
+}
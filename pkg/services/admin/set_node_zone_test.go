@@ -0,0 +1,109 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admin
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc/codes"
+
+	"github.com/webmeshproj/webmesh/pkg/meshnode"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+func TestSetNodeZone(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	server := newTestServer(t)
+	err := server.db.Peers().Put(ctx, types.MeshNode{MeshNode: &v1.MeshNode{
+		Id:              "peer",
+		PublicKey:       newEncodedPubKey(t),
+		PrivateIPv4:     "172.16.0.10/32",
+		ZoneAwarenessID: "zone-a",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc := []testCase[SetNodeZoneRequest]{
+		{
+			name: "no node id",
+			code: codes.InvalidArgument,
+			req:  &SetNodeZoneRequest{ZoneAwarenessID: "zone-b"},
+		},
+		{
+			name: "invalid node id",
+			code: codes.InvalidArgument,
+			req:  &SetNodeZoneRequest{Id: "invalid/id", ZoneAwarenessID: "zone-b"},
+		},
+		{
+			name: "non-existent node",
+			code: codes.NotFound,
+			req:  &SetNodeZoneRequest{Id: "unknown", ZoneAwarenessID: "zone-b"},
+		},
+		{
+			name: "existing node",
+			code: codes.OK,
+			req:  &SetNodeZoneRequest{Id: "peer", ZoneAwarenessID: "zone-b"},
+			tval: func(t *testing.T) {
+				node, err := server.db.Peers().Get(ctx, "peer")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if node.GetZoneAwarenessID() != "zone-b" {
+					t.Errorf("expected zone zone-b, got %q", node.GetZoneAwarenessID())
+				}
+				if node.GetPrivateIPv4() != "172.16.0.10/32" {
+					t.Errorf("expected other fields to be preserved, got private IPv4 %q", node.GetPrivateIPv4())
+				}
+			},
+		},
+		{
+			name: "clear zone",
+			code: codes.OK,
+			req:  &SetNodeZoneRequest{Id: "peer"},
+			tval: func(t *testing.T) {
+				node, err := server.db.Peers().Get(ctx, "peer")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if node.GetZoneAwarenessID() != "" {
+					t.Errorf("expected zone to be cleared, got %q", node.GetZoneAwarenessID())
+				}
+			},
+		},
+	}
+
+	runTestCases(t, tc, server.SetNodeZone)
+
+	t.Run("permission denied", func(t *testing.T) {
+		store, err := meshnode.NewSingleNodeTestMesh(ctx)
+		if err != nil {
+			t.Fatalf("error creating test store: %v", err)
+		}
+		t.Cleanup(func() { store.Close(ctx) })
+		server := NewServer(store.Storage(), denyEvaluator{})
+		runTestCase(t, testCase[SetNodeZoneRequest]{
+			name: "rbac denied",
+			code: codes.PermissionDenied,
+			req:  &SetNodeZoneRequest{Id: "peer", ZoneAwarenessID: "zone-b"},
+		}, server.SetNodeZone)
+	})
+}
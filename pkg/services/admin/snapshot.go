@@ -0,0 +1,81 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package admin provides the admin gRPC server.
+package admin
+
+import (
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/services/rbac"
+	"github.com/webmeshproj/webmesh/pkg/storage/providers/raftstorage"
+)
+
+var snapshotAction = rbac.Actions{
+	{
+		Resource: v1.RuleResource_RESOURCE_ALL,
+		Verb:     v1.RuleVerb_VERB_ALL,
+	},
+}
+
+// Snapshotter is implemented by storage providers that can take a
+// snapshot on demand.
+type Snapshotter interface {
+	// Snapshot forces a snapshot and returns its metadata.
+	Snapshot(ctx context.Context) (*raftstorage.SnapshotMeta, error)
+}
+
+// SnapshotMetadata is the metadata of a snapshot taken by the Snapshot RPC.
+type SnapshotMetadata struct {
+	// ID is the opaque ID of the snapshot.
+	ID string `json:"id"`
+	// Index is the raft index included in the snapshot.
+	Index uint64 `json:"index"`
+	// Term is the raft term of the index.
+	Term uint64 `json:"term"`
+	// Size is the size of the snapshot in bytes.
+	Size int64 `json:"size"`
+}
+
+func (s *Server) Snapshot(ctx context.Context, _ *emptypb.Empty) (*SnapshotMetadata, error) {
+	if !s.storage.Consensus().IsLeader() {
+		return nil, notLeaderError(ctx, s.storage.Consensus())
+	}
+	if ok, err := s.rbacEval.Evaluate(ctx, snapshotAction); !ok {
+		if err != nil {
+			context.LoggerFrom(ctx).Error("failed to evaluate snapshot action", "error", err)
+		}
+		return nil, status.Error(codes.PermissionDenied, "caller does not have permission to take snapshots")
+	}
+	snapshotter, ok := s.storage.(Snapshotter)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "storage provider does not support snapshots")
+	}
+	meta, err := snapshotter.Snapshot(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &SnapshotMetadata{
+		ID:    meta.ID,
+		Index: meta.Index,
+		Term:  meta.Term,
+		Size:  meta.Size,
+	}, nil
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admin
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestSnapshot(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	meta, err := server.Snapshot(context.Background(), &emptypb.Empty{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if meta.ID == "" {
+		t.Error("expected snapshot ID to be set")
+	}
+	if meta.Index == 0 {
+		t.Error("expected snapshot index to be greater than zero")
+	}
+	if meta.Term == 0 {
+		t.Error("expected snapshot term to be greater than zero")
+	}
+}
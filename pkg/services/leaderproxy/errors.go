@@ -0,0 +1,87 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package leaderproxy
+
+import (
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/storage"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+const (
+	// ErrorDomain is the domain of the ErrorInfo details attached to errors.
+	ErrorDomain = "webmesh.io"
+	// NotLeaderReason is the reason of the ErrorInfo detail attached to errors
+	// for requests that must be served by the leader but reached a follower.
+	NotLeaderReason = "NOT_LEADER"
+	// LeaderIDDetail is the ErrorInfo metadata key for the leader's ID.
+	LeaderIDDetail = "leader-id"
+	// LeaderAddressDetail is the ErrorInfo metadata key for the leader's address.
+	LeaderAddressDetail = "leader-address"
+)
+
+// NewNotLeaderError returns the FailedPrecondition error for a request that
+// must be served by the leader. When the current leader is known, its ID and
+// address are attached as an ErrorInfo detail so clients can retry against it.
+// Use LeaderFromError to read them back.
+func NewNotLeaderError(ctx context.Context, consensus storage.Consensus) error {
+	leader, err := consensus.GetLeader(ctx)
+	if err != nil {
+		context.LoggerFrom(ctx).Warn("Failed to lookup current leader", "error", err.Error())
+		return status.Error(codes.FailedPrecondition, "not the leader")
+	}
+	return notLeaderError(leader)
+}
+
+func notLeaderError(leader types.StoragePeer) error {
+	st := status.Newf(codes.FailedPrecondition, "not the leader, current leader is %s at %s", leader.GetId(), leader.GetAddress())
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason: NotLeaderReason,
+		Domain: ErrorDomain,
+		Metadata: map[string]string{
+			LeaderIDDetail:      leader.GetId(),
+			LeaderAddressDetail: leader.GetAddress(),
+		},
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// LeaderFromError returns the leader ID and address attached to an error
+// returned by NewNotLeaderError. It returns false if the error does not carry
+// the current leader.
+func LeaderFromError(err error) (id, address string, ok bool) {
+	st, isStatus := status.FromError(err)
+	if !isStatus || st.Code() != codes.FailedPrecondition {
+		return "", "", false
+	}
+	for _, detail := range st.Details() {
+		info, isInfo := detail.(*errdetails.ErrorInfo)
+		if !isInfo || info.GetDomain() != ErrorDomain || info.GetReason() != NotLeaderReason {
+			continue
+		}
+		id, address = info.GetMetadata()[LeaderIDDetail], info.GetMetadata()[LeaderAddressDetail]
+		return id, address, id != ""
+	}
+	return "", "", false
+}
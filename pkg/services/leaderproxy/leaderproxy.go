@@ -20,6 +20,8 @@ package leaderproxy
 import (
 	"io"
 	"log/slog"
+	"net/netip"
+	"sync"
 
 	v1 "github.com/webmeshproj/api/go/v1"
 	"google.golang.org/grpc"
@@ -40,6 +42,8 @@ type Interceptor struct {
 	consensus storage.Consensus
 	dialer    Dialer
 	network   context.Network
+	rpcaddrs  *storage.PublicRPCAddressCache
+	subscribe sync.Once
 }
 
 // Dialer is the interface required for the leader proxy interceptor.
@@ -48,14 +52,20 @@ type Dialer interface {
 	transport.NodeDialer
 }
 
-// New returns a new leader proxy interceptor.
-func New(nodeID types.NodeID, consensus storage.Consensus, dialer Dialer, network context.Network) *Interceptor {
-	return &Interceptor{
+// New returns a new leader proxy interceptor. If peers is not nil, proxied
+// requests carry the public gRPC address of the leader in the
+// LeaderRPCAddressMeta header when it has one.
+func New(nodeID types.NodeID, consensus storage.Consensus, dialer Dialer, network context.Network, peers storage.Peers) *Interceptor {
+	i := &Interceptor{
 		nodeID:    nodeID,
 		consensus: consensus,
 		dialer:    dialer,
 		network:   network,
 	}
+	if peers != nil {
+		i.rpcaddrs = storage.NewPublicRPCAddressCache(peers, storage.DefaultPublicRPCAddressCacheTTL)
+	}
+	return i
 }
 
 // UnaryInterceptor returns a gRPC unary interceptor that proxies requests to the leader node.
@@ -129,6 +139,11 @@ func (i *Interceptor) StreamInterceptor() grpc.StreamServerInterceptor {
 }
 
 func (i *Interceptor) proxyUnaryToLeader(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if md, ok := i.leaderRPCAddressHeader(ctx); ok {
+		if err := grpc.SetHeader(ctx, md); err != nil {
+			context.LoggerFrom(ctx).Debug("Failed to set leader rpc address header", slog.String("error", err.Error()))
+		}
+	}
 	conn, err := i.dialer.DialLeader(ctx)
 	if err != nil {
 		return nil, err
@@ -230,6 +245,11 @@ func (i *Interceptor) proxyUnaryToLeader(ctx context.Context, req any, info *grp
 }
 
 func (i *Interceptor) proxyStreamToLeader(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if md, ok := i.leaderRPCAddressHeader(ss.Context()); ok {
+		if err := ss.SetHeader(md); err != nil {
+			context.LoggerFrom(ss.Context()).Debug("Failed to set leader rpc address header", slog.String("error", err.Error()))
+		}
+	}
 	conn, err := i.dialer.DialLeader(ss.Context())
 	if err != nil {
 		return err
@@ -290,6 +310,44 @@ func (i *Interceptor) proxyStreamToLeader(srv any, ss grpc.ServerStream, info *g
 	}
 }
 
+// leaderRPCAddressHeader returns the LeaderRPCAddressMeta header for a proxied
+// request. It returns false if the leader has no known public gRPC address.
+func (i *Interceptor) leaderRPCAddressHeader(ctx context.Context) (metadata.MD, bool) {
+	addr, ok := i.leaderRPCAddress(ctx)
+	if !ok {
+		return nil, false
+	}
+	return metadata.Pairs(LeaderRPCAddressMeta, addr.String()), true
+}
+
+// leaderRPCAddress returns the public gRPC address of the current leader. The
+// addresses are served from a cache that is invalidated whenever the peers change.
+func (i *Interceptor) leaderRPCAddress(ctx context.Context) (netip.AddrPort, bool) {
+	if i.rpcaddrs == nil {
+		return netip.AddrPort{}, false
+	}
+	log := context.LoggerFrom(ctx)
+	i.subscribe.Do(func() {
+		// The subscription lasts as long as the underlying storage.
+		_, err := i.rpcaddrs.Subscribe(context.Background())
+		if err != nil {
+			log.Debug("Failed to subscribe to peer changes, public rpc addresses will refresh on expiry", slog.String("error", err.Error()))
+		}
+	})
+	leader, err := i.consensus.GetLeader(ctx)
+	if err != nil {
+		log.Debug("Failed to lookup current leader", slog.String("error", err.Error()))
+		return netip.AddrPort{}, false
+	}
+	addrs, err := i.rpcaddrs.List(ctx)
+	if err != nil {
+		log.Debug("Failed to list public rpc addresses", slog.String("error", err.Error()))
+		return netip.AddrPort{}, false
+	}
+	addr, ok := addrs[leader.GetId()]
+	return addr, ok
+}
+
 func proxyStream[S, R any](ctx context.Context, ss grpc.ServerStream, cs grpc.ClientStream) error {
 	defer func() {
 		if err := cs.CloseSend(); err != nil {
@@ -0,0 +1,114 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package leaderproxy
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/meshnet/transport"
+	"github.com/webmeshproj/webmesh/pkg/storage"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+// followerConsensus is a consensus that is never the leader.
+type followerConsensus struct {
+	storage.Consensus
+	leader string
+}
+
+func (f *followerConsensus) IsLeader() bool { return false }
+
+func (f *followerConsensus) GetLeader(context.Context) (types.StoragePeer, error) {
+	return types.StoragePeer{StoragePeer: &v1.StoragePeer{Id: f.leader}}, nil
+}
+
+// testPeers serves a fixed list of nodes and counts scans and subscriptions.
+type testPeers struct {
+	storage.Peers
+	nodes      []types.MeshNode
+	scans      atomic.Int32
+	subscribed atomic.Int32
+}
+
+func (p *testPeers) ListByFeature(ctx context.Context, feature v1.Feature) ([]types.MeshNode, error) {
+	p.scans.Add(1)
+	return p.nodes, nil
+}
+
+func (p *testPeers) Subscribe(ctx context.Context, fn storage.PeerSubscribeFunc) (context.CancelFunc, error) {
+	p.subscribed.Add(1)
+	return func() {}, nil
+}
+
+// failingDialer fails every dial.
+type failingDialer struct {
+	transport.NodeDialer
+}
+
+var errDial = errors.New("dial failed")
+
+func (failingDialer) DialLeader(context.Context) (transport.RPCClientConn, error) {
+	return nil, errDial
+}
+
+// headerStream is a grpc.ServerTransportStream that records headers.
+type headerStream struct {
+	grpc.ServerTransportStream
+	header metadata.MD
+}
+
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestLeaderRPCAddressHeader(t *testing.T) {
+	t.Parallel()
+	peers := &testPeers{nodes: []types.MeshNode{
+		{MeshNode: &v1.MeshNode{
+			Id:              "leader",
+			PrimaryEndpoint: "10.0.0.1",
+			Features:        []*v1.FeaturePort{{Feature: v1.Feature_NODES, Port: 8443}},
+		}},
+	}}
+	icep := New("follower", &followerConsensus{leader: "leader"}, failingDialer{}, nil, peers)
+	unary := icep.UnaryInterceptor()
+	for i := 0; i < 3; i++ {
+		stream := &headerStream{}
+		ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+		_, err := unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: v1.Admin_PutRole_FullMethodName}, nil)
+		if !errors.Is(err, errDial) {
+			t.Fatalf("expected dial error, got %v", err)
+		}
+		if got := stream.header.Get(LeaderRPCAddressMeta); len(got) != 1 || got[0] != "10.0.0.1:8443" {
+			t.Fatalf("expected leader rpc address header 10.0.0.1:8443, got %v", got)
+		}
+	}
+	if scans := peers.scans.Load(); scans != 1 {
+		t.Fatalf("expected proxied requests to share 1 scan, got %d", scans)
+	}
+	if subs := peers.subscribed.Load(); subs != 1 {
+		t.Fatalf("expected 1 peer subscription, got %d", subs)
+	}
+}
@@ -29,6 +29,16 @@ const (
 	ProxiedFromMeta = "x-webmesh-proxied-from"
 	// ProxiedForMeta is the metadata key for the Proxied-For header.
 	ProxiedForMeta = "x-webmesh-proxied-for"
+	// LeaderIDMeta is the metadata key for the Leader-ID trailer set when a
+	// request is rejected because the node is not the leader.
+	LeaderIDMeta = "x-webmesh-leader-id"
+	// LeaderAddressMeta is the metadata key for the Leader-Address trailer set
+	// when a request is rejected because the node is not the leader.
+	LeaderAddressMeta = "x-webmesh-leader-address"
+	// LeaderRPCAddressMeta is the metadata key for the Leader-RPC-Address header
+	// set on proxied requests when the leader exposes a public gRPC address.
+	// Clients can use it to send further requests to the leader directly.
+	LeaderRPCAddressMeta = "x-webmesh-leader-rpc-address"
 )
 
 // HasPreferLeaderMeta returns true if the context has the Prefer-Leader header set to true.
@@ -28,7 +28,9 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/services/leaderproxy"
 	"github.com/webmeshproj/webmesh/pkg/storage/providers/raftstorage"
+	"github.com/webmeshproj/webmesh/pkg/storage/providers/raftstorage/raftlogs"
 )
 
 func (s *Server) Apply(ctx context.Context, log *v1.RaftLogEntry) (*v1.RaftApplyResponse, error) {
@@ -43,7 +45,7 @@ func (s *Server) Apply(ctx context.Context, log *v1.RaftLogEntry) (*v1.RaftApply
 		return nil, status.Errorf(codes.FailedPrecondition, "storage provider is not a raftstorage provider")
 	}
 	if !provider.Consensus().IsLeader() {
-		return nil, status.Errorf(codes.FailedPrecondition, "not leader")
+		return nil, leaderproxy.NewNotLeaderError(ctx, provider.Consensus())
 	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -58,7 +60,9 @@ func (s *Server) Apply(ctx context.Context, log *v1.RaftLogEntry) (*v1.RaftApply
 			return nil, status.Errorf(codes.FailedPrecondition, "invalid peer address")
 		}
 		if strings.HasPrefix(string(server.Address), host) {
-			if server.Suffrage != raft.Voter {
+			// Non-voters apply the log as well, so they may advertise that
+			// they support batch entries, but make no other writes.
+			if server.Suffrage != raft.Voter && !raftlogs.IsBatchSupportEntry(log, string(server.ID)) {
 				return nil, status.Errorf(codes.FailedPrecondition, "peer is not a voter")
 			}
 			found = true
@@ -31,7 +31,6 @@ import (
 	"github.com/webmeshproj/webmesh/pkg/context"
 	"github.com/webmeshproj/webmesh/pkg/crypto"
 	"github.com/webmeshproj/webmesh/pkg/meshnet"
-	"github.com/webmeshproj/webmesh/pkg/meshnet/netutil"
 	"github.com/webmeshproj/webmesh/pkg/services/leaderproxy"
 	"github.com/webmeshproj/webmesh/pkg/services/rbac"
 	"github.com/webmeshproj/webmesh/pkg/storage"
@@ -61,7 +60,7 @@ var canPutEdgeAction = &rbac.Action{
 
 func (s *Server) Join(ctx context.Context, req *v1.JoinRequest) (*v1.JoinResponse, error) {
 	if !s.storage.Consensus().IsLeader() {
-		return nil, status.Errorf(codes.FailedPrecondition, "not leader")
+		return nil, leaderproxy.NewNotLeaderError(ctx, s.storage.Consensus())
 	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -174,8 +173,12 @@ func (s *Server) Join(ctx context.Context, req *v1.JoinRequest) (*v1.JoinRespons
 	}
 
 	var leasev4, leasev6 netip.Prefix
-	// We always generate an IPv6 address for the peer from their public key
-	leasev6 = netutil.AssignToPrefix(s.ipv6Prefix, publicKey)
+	// We always generate an IPv6 address for the peer from their public key,
+	// within their zone's prefix if the IPAM has one for it.
+	leasev6, err = s.plugins.AllocateIPv6(ctx, s.ipv6Prefix, req.GetZoneAwarenessID(), publicKey)
+	if err != nil {
+		return nil, handleErr(status.Errorf(codes.Internal, "failed to allocate IPv6 address: %v", err))
+	}
 	log.Debug("Assigned IPv6 address to peer", slog.String("ipv6", leasev6.String()))
 	// Acquire an IPv4 address for the peer only if requested
 	if req.GetAssignIPv4() {
@@ -443,6 +446,10 @@ func (s *Server) Join(ctx context.Context, req *v1.JoinRequest) (*v1.JoinRespons
 		}
 	}()
 
+	err = storage.PutHeartbeat(ctx, s.storage.MeshStorage(), types.NodeID(req.GetId()), time.Now())
+	if err != nil {
+		log.Warn("Failed to record heartbeat", slog.String("error", err.Error()))
+	}
 	log.Debug("Sending join response", slog.Any("response", resp))
 	return resp, nil
 }
@@ -25,6 +25,7 @@ import (
 
 	"github.com/webmeshproj/webmesh/pkg/context"
 	"github.com/webmeshproj/webmesh/pkg/services/leaderproxy"
+	"github.com/webmeshproj/webmesh/pkg/storage"
 	"github.com/webmeshproj/webmesh/pkg/storage/errors"
 	"github.com/webmeshproj/webmesh/pkg/storage/types"
 )
@@ -42,7 +43,7 @@ func (s *Server) Leave(ctx context.Context, req *v1.LeaveRequest) (*v1.LeaveResp
 		return nil, status.Error(codes.InvalidArgument, "invalid node id")
 	}
 	if !s.storage.Consensus().IsLeader() {
-		return nil, status.Errorf(codes.FailedPrecondition, "not leader")
+		return nil, leaderproxy.NewNotLeaderError(ctx, s.storage.Consensus())
 	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -83,11 +84,27 @@ func (s *Server) Leave(ctx context.Context, req *v1.LeaveRequest) (*v1.LeaveResp
 		}
 	}
 
+	if s.plugins != nil && leaving.PrivateAddrV4().IsValid() {
+		s.log.Info("Releasing IPv4 address of mesh node", "id", req.GetId(), "address", leaving.PrivateAddrV4().String())
+		err = s.plugins.ReleaseIP(ctx, &v1.ReleaseIPRequest{
+			NodeID: req.GetId(),
+			Ip:     leaving.PrivateAddrV4().String(),
+		})
+		if err != nil && status.Code(err) != codes.Unimplemented {
+			// The address is freed with the peer anyway, so don't fail the leave.
+			s.log.Warn("Failed to release IPv4 address of mesh node", "id", req.GetId(), "error", err.Error())
+		}
+	}
+
 	s.log.Info("Removing mesh node from peers DB", "id", req.GetId())
 	err = s.storage.MeshDB().Peers().Delete(ctx, types.NodeID(req.GetId()))
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to delete peer: %v", err)
 	}
+	err = storage.DeleteHeartbeat(ctx, s.storage.MeshStorage(), types.NodeID(req.GetId()))
+	if err != nil {
+		s.log.Warn("Failed to delete heartbeat of mesh node", "id", req.GetId(), "error", err.Error())
+	}
 
 	go func() {
 		// Notify any watching plugins
@@ -0,0 +1,163 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package membership
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/crypto"
+	"github.com/webmeshproj/webmesh/pkg/meshnet"
+	"github.com/webmeshproj/webmesh/pkg/meshnode"
+	"github.com/webmeshproj/webmesh/pkg/plugins"
+	"github.com/webmeshproj/webmesh/pkg/services/leaderproxy"
+	"github.com/webmeshproj/webmesh/pkg/services/rbac"
+	"github.com/webmeshproj/webmesh/pkg/storage"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+// testMeshnet is a meshnet.Manager that only reports the mesh networks.
+type testMeshnet struct {
+	meshnet.Manager
+	networkV4 netip.Prefix
+}
+
+func (t *testMeshnet) NetworkV4() netip.Prefix { return t.networkV4 }
+func (t *testMeshnet) NetworkV6() netip.Prefix { return netip.Prefix{} }
+
+func TestLeaveReleasesAddress(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store, err := meshnode.NewSingleNodeTestMesh(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close(ctx) })
+	state, err := store.Storage().MeshDB().MeshState().GetMeshState(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager, err := plugins.NewManager(ctx, plugins.Options{
+		Storage: store.Storage(),
+		Node: plugins.NodeConfig{
+			NodeID: store.ID(),
+			Key:    crypto.MustGenerateKey(),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = manager.Close() })
+	srv := NewServer(ctx, Options{
+		NodeID:  store.ID(),
+		Storage: store.Storage(),
+		Plugins: manager,
+		RBAC:    rbac.NewNoopEvaluator(),
+		Meshnet: &testMeshnet{networkV4: state.NetworkV4()},
+	})
+
+	allocate := func(t *testing.T, node string) netip.Prefix {
+		t.Helper()
+		addr, err := manager.AllocateIP(ctx, &v1.AllocateIPRequest{NodeID: node, Subnet: state.NetworkV4().String()})
+		if err != nil {
+			t.Fatalf("allocate address for %s: %v", node, err)
+		}
+		return addr
+	}
+	addr := allocate(t, "leaving-node")
+	err = store.Storage().MeshDB().Peers().Put(ctx, types.MeshNode{MeshNode: &v1.MeshNode{
+		Id:          "leaving-node",
+		PrivateIPv4: addr.String(),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := allocate(t, "new-node"); next == addr {
+		t.Fatalf("expected %s to be allocated before leaving", addr)
+	}
+	err = storage.PutHeartbeat(ctx, store.Storage().MeshStorage(), "leaving-node", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaveCtx := peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: addr.Addr().AsSlice(), Port: 8443}})
+	if _, err := srv.Leave(leaveCtx, &v1.LeaveRequest{Id: "leaving-node"}); err != nil {
+		t.Fatalf("leave: %v", err)
+	}
+	if next := allocate(t, "new-node"); next != addr {
+		t.Fatalf("expected released address %s to be allocatable again, got %s", addr, next)
+	}
+	seen, err := storage.GetLastSeen(ctx, store.Storage().MeshStorage(), "leaving-node")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !seen.IsZero() {
+		t.Fatalf("expected heartbeat to be removed on leave, got %s", seen)
+	}
+}
+
+// followerStorage is a storage provider whose consensus is never the leader.
+type followerStorage struct {
+	storage.Provider
+	leader *v1.StoragePeer
+}
+
+func (f *followerStorage) Consensus() storage.Consensus {
+	return &followerConsensus{leader: f.leader}
+}
+
+type followerConsensus struct {
+	storage.Consensus
+	leader *v1.StoragePeer
+}
+
+func (f *followerConsensus) IsLeader() bool { return false }
+
+func (f *followerConsensus) GetLeader(context.Context) (types.StoragePeer, error) {
+	return types.StoragePeer{StoragePeer: f.leader}, nil
+}
+
+func TestLeaveNotLeader(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	srv := NewServer(ctx, Options{
+		NodeID:  "follower",
+		Storage: &followerStorage{leader: &v1.StoragePeer{Id: "leader", Address: "172.16.0.1:9444"}},
+		RBAC:    rbac.NewNoopEvaluator(),
+		Meshnet: &testMeshnet{networkV4: netip.MustParsePrefix("172.16.0.0/12")},
+	})
+	leaveCtx := peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("172.16.0.2"), Port: 8443}})
+	_, err := srv.Leave(leaveCtx, &v1.LeaveRequest{Id: "leaving-node"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", err)
+	}
+	id, addr, ok := leaderproxy.LeaderFromError(err)
+	if !ok {
+		t.Fatalf("expected the leader to be attached to %v", err)
+	}
+	if id != "leader" || addr != "172.16.0.1:9444" {
+		t.Fatalf("expected leader leader at 172.16.0.1:9444, got %s at %s", id, addr)
+	}
+}
@@ -19,7 +19,9 @@ package membership
 import (
 	"log/slog"
 	"net/netip"
+	"slices"
 	"sort"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	v1 "github.com/webmeshproj/api/go/v1"
@@ -28,7 +30,9 @@ import (
 
 	"github.com/webmeshproj/webmesh/pkg/context"
 	"github.com/webmeshproj/webmesh/pkg/crypto"
+	"github.com/webmeshproj/webmesh/pkg/services/leaderproxy"
 	"github.com/webmeshproj/webmesh/pkg/services/rbac"
+	"github.com/webmeshproj/webmesh/pkg/storage"
 	"github.com/webmeshproj/webmesh/pkg/storage/errors"
 	"github.com/webmeshproj/webmesh/pkg/storage/types"
 )
@@ -40,7 +44,7 @@ func (s *Server) Update(ctx context.Context, req *v1.UpdateRequest) (*v1.UpdateR
 		return nil, status.Errorf(codes.PermissionDenied, "request is not in-network")
 	}
 	if !s.storage.Consensus().IsLeader() {
-		return nil, status.Errorf(codes.FailedPrecondition, "not leader")
+		return nil, leaderproxy.NewNotLeaderError(ctx, s.storage.Consensus())
 	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -134,60 +138,52 @@ func (s *Server) Update(ctx context.Context, req *v1.UpdateRequest) (*v1.UpdateR
 		return nil, status.Errorf(codes.Internal, "failed to ensure peer routes: %v", err)
 	}
 	// Overwrite any provided fields
-	var hasChanges bool
-	toUpdate := peer
-	// Check the public key
-
+	var encodedKey string
 	if publicKey != nil {
-		encoded, err := publicKey.Encode()
+		encodedKey, err = publicKey.Encode()
 		if err != nil {
 			return nil, status.Errorf(codes.Internal, "failed to encode public key: %v", err)
 		}
-		if encoded != peer.PublicKey {
-			toUpdate.PublicKey = encoded
-			hasChanges = true
-		}
-	}
-	// Check endpoints
-	if req.GetPrimaryEndpoint() != "" && req.GetPrimaryEndpoint() != peer.PrimaryEndpoint {
-		toUpdate.PrimaryEndpoint = req.GetPrimaryEndpoint()
-		hasChanges = true
 	}
-	if len(req.GetWireguardEndpoints()) > 0 {
-		sort.Strings(req.GetWireguardEndpoints())
-		sort.Strings(peer.WireguardEndpoints)
-		if !cmp.Equal(req.GetWireguardEndpoints(), peer.WireguardEndpoints) {
-			toUpdate.WireguardEndpoints = req.GetWireguardEndpoints()
-			hasChanges = true
+	err = p.Update(ctx, peer.NodeID(), func(toUpdate *types.MeshNode) error {
+		// Check the public key
+		if encodedKey != "" {
+			toUpdate.PublicKey = encodedKey
 		}
-	}
-	// Zone awareness
-	if req.GetZoneAwarenessID() != "" && req.GetZoneAwarenessID() != peer.GetZoneAwarenessID() {
-		toUpdate.ZoneAwarenessID = req.GetZoneAwarenessID()
-		hasChanges = true
-	}
-	// Multiaddrs
-	if len(req.GetMultiaddrs()) > 0 {
-		sort.Strings(req.GetMultiaddrs())
-		sort.Strings(peer.Multiaddrs)
-		if !cmp.Equal(req.GetMultiaddrs(), peer.Multiaddrs) {
-			toUpdate.Multiaddrs = req.GetMultiaddrs()
-			hasChanges = true
+		// Check endpoints
+		if req.GetPrimaryEndpoint() != "" {
+			toUpdate.PrimaryEndpoint = req.GetPrimaryEndpoint()
 		}
-	}
-	// Features
-	if len(req.GetFeatures()) > 0 {
-		toUpdate.Features = req.GetFeatures()
-		hasChanges = true
-	}
-
-	// Apply any node changes
-	if hasChanges {
-		log.Debug("Updating peer", slog.Any("peer", toUpdate))
-		err = p.Put(ctx, toUpdate)
-		if err != nil {
-			return nil, status.Errorf(codes.Internal, "failed to update peer: %v", err)
+		if len(req.GetWireguardEndpoints()) > 0 {
+			sort.Strings(req.GetWireguardEndpoints())
+			current := slices.Clone(toUpdate.WireguardEndpoints)
+			sort.Strings(current)
+			if !cmp.Equal(req.GetWireguardEndpoints(), current) {
+				toUpdate.WireguardEndpoints = req.GetWireguardEndpoints()
+			}
+		}
+		// Zone awareness
+		if req.GetZoneAwarenessID() != "" {
+			toUpdate.ZoneAwarenessID = req.GetZoneAwarenessID()
+		}
+		// Multiaddrs
+		if len(req.GetMultiaddrs()) > 0 {
+			sort.Strings(req.GetMultiaddrs())
+			current := slices.Clone(toUpdate.Multiaddrs)
+			sort.Strings(current)
+			if !cmp.Equal(req.GetMultiaddrs(), current) {
+				toUpdate.Multiaddrs = req.GetMultiaddrs()
+			}
+		}
+		// Features
+		if len(req.GetFeatures()) > 0 {
+			toUpdate.Features = req.GetFeatures()
 		}
+		log.Debug("Updating peer", slog.Any("peer", toUpdate))
+		return nil
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to update peer: %v", err)
 	}
 
 	// Change to voter if requested and not already
@@ -204,5 +200,9 @@ func (s *Server) Update(ctx context.Context, req *v1.UpdateRequest) (*v1.UpdateR
 			return nil, status.Errorf(codes.Internal, "failed to promote to voter: %v", err)
 		}
 	}
+	err = storage.PutHeartbeat(ctx, s.storage.MeshStorage(), peer.NodeID(), time.Now())
+	if err != nil {
+		log.Warn("Failed to record heartbeat", slog.String("error", err.Error()))
+	}
 	return &v1.UpdateResponse{}, nil
 }
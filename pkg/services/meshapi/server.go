@@ -25,7 +25,9 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/webmeshproj/webmesh/pkg/crypto"
 	"github.com/webmeshproj/webmesh/pkg/storage"
 	"github.com/webmeshproj/webmesh/pkg/storage/errors"
 	"github.com/webmeshproj/webmesh/pkg/storage/types"
@@ -36,24 +38,92 @@ type Server struct {
 	v1.UnimplementedMeshServer
 
 	storage storage.MeshDB
+	kv      storage.MeshStorage
 }
 
-// NewServer returns a new Server.
-func NewServer(storage storage.MeshDB) *Server {
-	return &Server{storage: storage}
+// NewServer returns a new Server. The raw key/value storage is used to look
+// up node heartbeats.
+func NewServer(db storage.MeshDB, kv storage.MeshStorage) *Server {
+	return &Server{storage: db, kv: kv}
 }
 
+// NodeWithLastSeen is a mesh node along with the last time it sent a heartbeat.
+type NodeWithLastSeen struct {
+	*v1.MeshNode
+	// LastSeen is the last time the node sent a heartbeat. It is nil if the
+	// node has never sent one.
+	LastSeen *timestamppb.Timestamp `json:"lastSeen,omitempty"`
+}
+
+// GetNode returns the node with the given ID. Surrounding whitespace is
+// trimmed from the ID and malformed IDs are rejected with InvalidArgument.
 func (s *Server) GetNode(ctx context.Context, req *v1.GetNodeRequest) (*v1.MeshNode, error) {
-	node, err := s.storage.Peers().Get(ctx, types.NodeID(req.GetId()))
+	id, ok := types.NormalizeNodeID(req.GetId())
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid node id %q", req.GetId())
+	}
+	node, err := s.storage.Peers().Get(ctx, id)
 	if err != nil {
 		if errors.IsNodeNotFound(err) {
-			return nil, status.Errorf(codes.NotFound, "node %s not found", req.GetId())
+			return nil, status.Errorf(codes.NotFound, "node %s not found", id)
 		}
 		return nil, status.Errorf(codes.Internal, "failed to get node: %v", err)
 	}
 	return node.MeshNode, nil
 }
 
+// GetNodeByPublicKeyRequest is a request to look up a node by its public key.
+type GetNodeByPublicKeyRequest struct {
+	// PublicKey is the encoded public key of the node.
+	PublicKey string `json:"publicKey"`
+}
+
+// GetPublicKey returns the encoded public key of the node.
+func (r *GetNodeByPublicKeyRequest) GetPublicKey() string {
+	if r == nil {
+		return ""
+	}
+	return r.PublicKey
+}
+
+// GetNodeByPublicKey returns the node with the given public key. This is useful
+// when the key is known from a handshake but the node ID is not.
+func (s *Server) GetNodeByPublicKey(ctx context.Context, req *GetNodeByPublicKeyRequest) (*v1.MeshNode, error) {
+	if req.GetPublicKey() == "" {
+		return nil, status.Error(codes.InvalidArgument, "public key is required")
+	}
+	key, err := crypto.DecodePublicKey(req.GetPublicKey())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid public key: %v", err)
+	}
+	node, err := s.storage.Peers().GetByPubKey(ctx, key)
+	if err != nil {
+		if errors.IsNodeNotFound(err) {
+			return nil, status.Error(codes.NotFound, "no node with the given public key")
+		}
+		return nil, status.Errorf(codes.Internal, "failed to get node: %v", err)
+	}
+	return node.MeshNode, nil
+}
+
+// GetNodeWithLastSeen returns the node with the given ID along with the last
+// time it sent a heartbeat to the membership service.
+func (s *Server) GetNodeWithLastSeen(ctx context.Context, req *v1.GetNodeRequest) (*NodeWithLastSeen, error) {
+	node, err := s.GetNode(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	out := &NodeWithLastSeen{MeshNode: node}
+	seen, err := storage.GetLastSeen(ctx, s.kv, types.NodeID(node.GetId()))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get last seen time: %v", err)
+	}
+	if !seen.IsZero() {
+		out.LastSeen = timestamppb.New(seen)
+	}
+	return out, nil
+}
+
 func (s *Server) ListNodes(ctx context.Context, req *emptypb.Empty) (*v1.NodeList, error) {
 	nodes, err := s.storage.Peers().List(ctx)
 	if err != nil {
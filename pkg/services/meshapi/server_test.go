@@ -0,0 +1,164 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package meshapi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/webmeshproj/webmesh/pkg/crypto"
+	"github.com/webmeshproj/webmesh/pkg/storage"
+	"github.com/webmeshproj/webmesh/pkg/storage/meshdb"
+	"github.com/webmeshproj/webmesh/pkg/storage/providers/backends/badgerdb"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+func TestGetNodeWithLastSeen(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	kv := badgerdb.NewTestStorage(false)
+	t.Cleanup(func() { _ = kv.Close() })
+	db := meshdb.NewFromStorage(kv)
+	for _, id := range []string{"beating-node", "silent-node"} {
+		err := db.Peers().Put(ctx, types.MeshNode{MeshNode: &v1.MeshNode{Id: id}})
+		if err != nil {
+			t.Fatalf("put node %s: %v", id, err)
+		}
+	}
+	seen := time.Now().Add(-time.Minute).UTC()
+	if err := storage.PutHeartbeat(ctx, kv, "beating-node", seen); err != nil {
+		t.Fatalf("put heartbeat: %v", err)
+	}
+	server := NewServer(db, kv)
+
+	t.Run("AfterHeartbeat", func(t *testing.T) {
+		node, err := server.GetNodeWithLastSeen(ctx, &v1.GetNodeRequest{Id: "beating-node"})
+		if err != nil {
+			t.Fatalf("get node: %v", err)
+		}
+		if node.LastSeen == nil {
+			t.Fatal("expected last seen to be populated")
+		}
+		if !node.LastSeen.AsTime().Equal(seen) {
+			t.Fatalf("expected last seen %s, got %s", seen, node.LastSeen.AsTime())
+		}
+	})
+
+	t.Run("NeverBeat", func(t *testing.T) {
+		node, err := server.GetNodeWithLastSeen(ctx, &v1.GetNodeRequest{Id: "silent-node"})
+		if err != nil {
+			t.Fatalf("get node: %v", err)
+		}
+		if node.GetId() != "silent-node" {
+			t.Fatalf("expected silent-node, got %q", node.GetId())
+		}
+		if node.LastSeen != nil {
+			t.Fatalf("expected no last seen time, got %s", node.LastSeen.AsTime())
+		}
+	})
+}
+
+func TestGetNodeByPublicKey(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	db := meshdb.NewTestDB()
+	t.Cleanup(func() { _ = db.Close() })
+	keys := make(map[string]string)
+	for _, id := range []string{"node-a", "node-b"} {
+		encoded, err := crypto.MustGenerateKey().PublicKey().Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[id] = encoded
+		err = db.Peers().Put(ctx, types.MeshNode{MeshNode: &v1.MeshNode{Id: id, PublicKey: encoded}})
+		if err != nil {
+			t.Fatalf("put node %s: %v", id, err)
+		}
+	}
+	unknown, err := crypto.MustGenerateKey().PublicKey().Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(db, nil)
+
+	for _, id := range []string{"node-a", "node-b"} {
+		node, err := server.GetNodeByPublicKey(ctx, &GetNodeByPublicKeyRequest{PublicKey: keys[id]})
+		if err != nil {
+			t.Fatalf("get node by public key: %v", err)
+		}
+		if node.GetId() != id {
+			t.Fatalf("expected %s, got %s", id, node.GetId())
+		}
+	}
+	tc := []struct {
+		name string
+		key  string
+		code codes.Code
+	}{
+		{"UnknownKey", unknown, codes.NotFound},
+		{"InvalidKey", "not-a-key", codes.InvalidArgument},
+		{"EmptyKey", "", codes.InvalidArgument},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := server.GetNodeByPublicKey(ctx, &GetNodeByPublicKeyRequest{PublicKey: tt.key})
+			if status.Code(err) != tt.code {
+				t.Fatalf("expected code %s, got %v", tt.code, err)
+			}
+		})
+	}
+}
+
+func TestGetNodeValidatesID(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	db := meshdb.NewTestDB()
+	t.Cleanup(func() { _ = db.Close() })
+	err := db.Peers().Put(ctx, types.MeshNode{MeshNode: &v1.MeshNode{Id: "node-a"}})
+	if err != nil {
+		t.Fatalf("put node: %v", err)
+	}
+	server := NewServer(db, nil)
+	tc := []struct {
+		name string
+		id   string
+		code codes.Code
+	}{
+		{"Valid", "node-a", codes.OK},
+		{"WhitespacePadded", "  node-a\t\n", codes.OK},
+		{"InvalidCharacter", "node/a", codes.InvalidArgument},
+		{"InnerWhitespace", "node a", codes.InvalidArgument},
+		{"Empty", "   ", codes.InvalidArgument},
+		{"Unknown", "node-b", codes.NotFound},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := server.GetNode(ctx, &v1.GetNodeRequest{Id: tt.id})
+			if status.Code(err) != tt.code {
+				t.Fatalf("expected code %s, got %v", tt.code, err)
+			}
+			if tt.code == codes.OK && node.GetId() != "node-a" {
+				t.Fatalf("expected node-a, got %q", node.GetId())
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"context"
+	"sort"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/webmeshproj/webmesh/pkg/storage/errors"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+// NodeMetrics are the WireGuard transfer statistics for the peers of a node
+// as seen by the local WireGuard interface.
+type NodeMetrics struct {
+	// NodeID is the ID of the requested node.
+	NodeID string `json:"nodeID"`
+	// Peers are the metrics for each peer, sorted by ID.
+	Peers []PeerMetrics `json:"peers"`
+}
+
+// PeerMetrics are the WireGuard transfer statistics for a single peer.
+type PeerMetrics struct {
+	// ID is the ID of the peer.
+	ID string `json:"id"`
+	// PublicKey is the WireGuard public key of the peer.
+	PublicKey string `json:"publicKey"`
+	// ReceiveBytes is the bytes received from the peer.
+	ReceiveBytes uint64 `json:"receiveBytes"`
+	// TransmitBytes is the bytes transmitted to the peer.
+	TransmitBytes uint64 `json:"transmitBytes"`
+	// LastHandshakeTime is the last handshake time with the peer.
+	LastHandshakeTime string `json:"lastHandshakeTime"`
+}
+
+// GetNodeMetrics returns the WireGuard transfer statistics for the peers of the
+// requested node. For the local node, or when no ID is given, statistics for all
+// peers on the local interface are returned. For any other node only the link
+// between it and the local node is known, so at most a single peer is returned.
+func (s *Server) GetNodeMetrics(ctx context.Context, req *v1.GetNodeRequest) (*NodeMetrics, error) {
+	nodeID := s.NodeID
+	if req.GetId() != "" {
+		nodeID = types.NodeID(req.GetId())
+	}
+	local := nodeID == s.NodeID
+	if !local {
+		_, err := s.Storage.MeshDB().Peers().Get(ctx, nodeID)
+		if err != nil {
+			if errors.IsNodeNotFound(err) {
+				return nil, status.Errorf(codes.NotFound, "node %s not found", nodeID)
+			}
+			return nil, status.Errorf(codes.Internal, "get node: %v", err)
+		}
+	}
+	wg := s.Meshnet.WireGuard()
+	if wg == nil {
+		return nil, status.Error(codes.Unavailable, "wireguard interface is not available")
+	}
+	ifaceMetrics, err := wg.Metrics()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "get interface metrics: %v", err)
+	}
+	// Map the WireGuard keys on the interface back to peer IDs.
+	peerIDs := make(map[string]string)
+	for id, peer := range wg.Peers() {
+		if peer.PublicKey == nil {
+			continue
+		}
+		peerIDs[peer.PublicKey.WireGuardKey().String()] = id
+	}
+	out := &NodeMetrics{NodeID: nodeID.String(), Peers: []PeerMetrics{}}
+	for _, peer := range ifaceMetrics.GetPeers() {
+		id := peerIDs[peer.GetPublicKey()]
+		if !local && id != nodeID.String() {
+			continue
+		}
+		out.Peers = append(out.Peers, PeerMetrics{
+			ID:                id,
+			PublicKey:         peer.GetPublicKey(),
+			ReceiveBytes:      peer.GetReceiveBytes(),
+			TransmitBytes:     peer.GetTransmitBytes(),
+			LastHandshakeTime: peer.GetLastHandshakeTime(),
+		})
+	}
+	sort.Slice(out.Peers, func(i, j int) bool {
+		return out.Peers[i].ID < out.Peers[j].ID
+	})
+	return out, nil
+}
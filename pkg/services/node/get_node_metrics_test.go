@@ -0,0 +1,120 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/webmeshproj/webmesh/pkg/crypto"
+	"github.com/webmeshproj/webmesh/pkg/meshnet"
+	"github.com/webmeshproj/webmesh/pkg/meshnet/wireguard"
+	"github.com/webmeshproj/webmesh/pkg/storage"
+	"github.com/webmeshproj/webmesh/pkg/storage/meshdb"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+type fakeStorage struct {
+	storage.Provider
+	db storage.MeshDB
+}
+
+func (f *fakeStorage) MeshDB() storage.MeshDB { return f.db }
+
+type fakeMeshnet struct {
+	meshnet.Manager
+	wg wireguard.Interface
+}
+
+func (f *fakeMeshnet) WireGuard() wireguard.Interface { return f.wg }
+
+type fakeWireGuard struct {
+	wireguard.Interface
+	peers   map[string]wireguard.Peer
+	metrics *v1.InterfaceMetrics
+}
+
+func (f *fakeWireGuard) Peers() map[string]wireguard.Peer { return f.peers }
+
+func (f *fakeWireGuard) Metrics() (*v1.InterfaceMetrics, error) { return f.metrics, nil }
+
+func TestGetNodeMetrics(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	db := meshdb.NewTestDB()
+	t.Cleanup(func() { _ = db.Close() })
+	for _, id := range []string{"local-node", "peer-a", "peer-b", "not-a-peer"} {
+		err := db.Peers().Put(ctx, types.MeshNode{MeshNode: &v1.MeshNode{Id: id}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	keyA, keyB := crypto.MustGenerateKey().PublicKey(), crypto.MustGenerateKey().PublicKey()
+	wgKeyA, wgKeyB := keyA.WireGuardKey().String(), keyB.WireGuardKey().String()
+	wg := &fakeWireGuard{
+		peers: map[string]wireguard.Peer{
+			"peer-a": {ID: "peer-a", PublicKey: keyA},
+			"peer-b": {ID: "peer-b", PublicKey: keyB},
+		},
+		metrics: &v1.InterfaceMetrics{
+			Peers: []*v1.PeerMetrics{
+				{PublicKey: wgKeyB, ReceiveBytes: 30, TransmitBytes: 40, LastHandshakeTime: "2023-10-01T00:00:10Z"},
+				{PublicKey: wgKeyA, ReceiveBytes: 10, TransmitBytes: 20, LastHandshakeTime: "2023-10-01T00:00:00Z"},
+			},
+		},
+	}
+	server := NewServer(ctx, Options{
+		NodeID:  "local-node",
+		Storage: &fakeStorage{db: db},
+		Meshnet: &fakeMeshnet{wg: wg},
+	})
+	peerA := PeerMetrics{ID: "peer-a", PublicKey: wgKeyA, ReceiveBytes: 10, TransmitBytes: 20, LastHandshakeTime: "2023-10-01T00:00:00Z"}
+	peerB := PeerMetrics{ID: "peer-b", PublicKey: wgKeyB, ReceiveBytes: 30, TransmitBytes: 40, LastHandshakeTime: "2023-10-01T00:00:10Z"}
+
+	tc := []struct {
+		name string
+		id   string
+		code codes.Code
+		want *NodeMetrics
+	}{
+		{name: "LocalNodeByDefault", id: "", want: &NodeMetrics{NodeID: "local-node", Peers: []PeerMetrics{peerA, peerB}}},
+		{name: "LocalNode", id: "local-node", want: &NodeMetrics{NodeID: "local-node", Peers: []PeerMetrics{peerA, peerB}}},
+		{name: "RemotePeer", id: "peer-b", want: &NodeMetrics{NodeID: "peer-b", Peers: []PeerMetrics{peerB}}},
+		{name: "RemoteNotPeered", id: "not-a-peer", want: &NodeMetrics{NodeID: "not-a-peer", Peers: []PeerMetrics{}}},
+		{name: "UnknownNode", id: "unknown-node", code: codes.NotFound},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := server.GetNodeMetrics(ctx, &v1.GetNodeRequest{Id: tt.id})
+			if status.Code(err) != tt.code {
+				t.Fatalf("expected code %s, got %v", tt.code, err)
+			}
+			if tt.want == nil {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
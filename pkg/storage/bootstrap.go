@@ -55,6 +55,11 @@ type BootstrapOptions struct {
 	// IPv6Network is the IPv6 prefix. If left unset,
 	// one will be generated.
 	IPv6Network string
+	// ULAExists is an optional function used when generating the IPv6 network.
+	// It should report whether the prefix is already used by another mesh, such
+	// as when joining a federation, and may record it in a shared store. The
+	// prefix is regenerated on conflict up to a retry limit.
+	ULAExists func(netip.Prefix) (bool, error)
 	// Admin is the admin node ID.
 	Admin string
 	// DefaultNetworkPolicy is the default network policy.
@@ -125,6 +130,12 @@ func Bootstrap(ctx context.Context, db MeshDB, opts *BootstrapOptions) (results
 			err = fmt.Errorf("IPv6 network must be /%d", netutil.DefaultULABits)
 			return
 		}
+	} else if opts.ULAExists != nil {
+		results.NetworkV6, err = netutil.GenerateUniqueULA(ctx, opts.ULAExists)
+		if err != nil {
+			err = fmt.Errorf("generate unique ULA: %w", err)
+			return
+		}
 	} else {
 		results.NetworkV6, err = netutil.GenerateULA()
 		if err != nil {
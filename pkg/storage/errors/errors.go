@@ -27,6 +27,9 @@ import (
 // Is is a shortcut for errors.Is.
 var Is = errors.Is
 
+// Join is a shortcut for errors.Join.
+var Join = errors.Join
+
 // Common errors for storage providers to use.
 var (
 	// ErrNodeNotFound is returned when a node is not found.
@@ -81,8 +84,17 @@ var (
 	ErrEmptyNodeID = errors.New("node ID must not be empty")
 	// ErrInvalidNodeID is returned when a node ID is invalid.
 	ErrInvalidNodeID = errors.New("node ID is invalid")
+	// ErrUpdateConflict is returned when a node keeps changing while an
+	// update to it is being applied.
+	ErrUpdateConflict = errors.New("node changed during update")
 	// ErrInvalidQuery is returned when a query is invalid.
 	ErrInvalidQuery = errors.New("invalid query")
+	// ErrTxnDone is returned when a transaction is used after it was
+	// committed or rolled back.
+	ErrTxnDone = errors.New("transaction already committed or rolled back")
+	// ErrTxnConflict is returned when a transaction is committed and a key it
+	// checked no longer holds the expected value.
+	ErrTxnConflict = errors.New("transaction conflict")
 )
 
 // NewKeyNotFoundError returns a new ErrKeyNotFound error.
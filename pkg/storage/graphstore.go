@@ -31,6 +31,8 @@ type GraphStore interface {
 
 	// Subscribe subscribes to changes to nodes and edges.
 	Subscribe(ctx context.Context, fn PeerSubscribeFunc) (context.CancelFunc, error)
+	// SubscribeNode subscribes to changes to a single node.
+	SubscribeNode(ctx context.Context, id types.NodeID, fn NodeWatchFunc) (context.CancelFunc, error)
 }
 
 // NewGraphWithStore creates a new Graph instance with the given graph storage implementation.
@@ -0,0 +1,76 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/webmeshproj/webmesh/pkg/storage/errors"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+// HeartbeatsPrefix is the prefix for node heartbeat keys.
+var HeartbeatsPrefix = []byte("/registry/heartbeats")
+
+// HeartbeatKey returns the key for the heartbeat of the given node.
+func HeartbeatKey(id types.NodeID) []byte {
+	return append(append([]byte{}, HeartbeatsPrefix...), []byte("/"+id.String())...)
+}
+
+// PutHeartbeat records that the given node was seen at the given time.
+func PutHeartbeat(ctx context.Context, st MeshStorage, id types.NodeID, seen time.Time) error {
+	if id == "" {
+		return errors.ErrEmptyNodeID
+	}
+	err := st.PutValue(ctx, HeartbeatKey(id), []byte(seen.UTC().Format(time.RFC3339Nano)), 0)
+	if err != nil {
+		return fmt.Errorf("put heartbeat: %w", err)
+	}
+	return nil
+}
+
+// GetLastSeen returns the last time the given node sent a heartbeat. If the
+// node has never sent one, the zero time is returned without an error.
+func GetLastSeen(ctx context.Context, st MeshStorage, id types.NodeID) (time.Time, error) {
+	val, err := st.GetValue(ctx, HeartbeatKey(id))
+	if err != nil {
+		if errors.IsKeyNotFound(err) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("get heartbeat: %w", err)
+	}
+	seen, err := time.Parse(time.RFC3339Nano, string(val))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse heartbeat: %w", err)
+	}
+	return seen, nil
+}
+
+// DeleteHeartbeat removes the heartbeat of the given node. It should be called
+// when a node is removed from the mesh.
+func DeleteHeartbeat(ctx context.Context, st MeshStorage, id types.NodeID) error {
+	if id == "" {
+		return errors.ErrEmptyNodeID
+	}
+	err := st.Delete(ctx, HeartbeatKey(id))
+	if err != nil && !errors.IsKeyNotFound(err) {
+		return fmt.Errorf("delete heartbeat: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/webmeshproj/webmesh/pkg/storage/errors"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+// IPAMPausedKey is where the flag for pausing new IPAM allocations is stored.
+var IPAMPausedKey = types.RegistryPrefix.For([]byte("ipam-paused"))
+
+// IsIPAMPaused returns true if new IPAM allocations are paused. If the flag
+// has never been set, allocations are not paused.
+func IsIPAMPaused(ctx context.Context, st MeshStorage) (bool, error) {
+	val, err := st.GetValue(ctx, IPAMPausedKey)
+	if err != nil {
+		if errors.IsKeyNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("get ipam paused: %w", err)
+	}
+	paused, err := strconv.ParseBool(string(val))
+	if err != nil {
+		return false, fmt.Errorf("invalid ipam paused value: %q", val)
+	}
+	return paused, nil
+}
+
+// SetIPAMPaused pauses or resumes new IPAM allocations.
+func SetIPAMPaused(ctx context.Context, st MeshStorage, paused bool) error {
+	err := st.PutValue(ctx, IPAMPausedKey, []byte(strconv.FormatBool(paused)), 0)
+	if err != nil {
+		return fmt.Errorf("put ipam paused: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+
+	"github.com/webmeshproj/webmesh/pkg/storage/errors"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+// IPAMReservationsPrefix is the prefix for addresses reserved for nodes by the
+// built-in IPAM plugin before the nodes are stored with them.
+var IPAMReservationsPrefix = types.RegistryPrefix.For([]byte("ipam-reservations"))
+
+// IPAMReservationKey returns the key for the address reserved for the given node.
+func IPAMReservationKey(id types.NodeID) []byte {
+	return IPAMReservationsPrefix.For([]byte(id))
+}
+
+// ListIPAMReservations returns the reserved addresses keyed by node ID.
+func ListIPAMReservations(ctx context.Context, st MeshStorage) (map[types.NodeID]netip.Prefix, error) {
+	out := make(map[types.NodeID]netip.Prefix)
+	err := st.IterPrefix(ctx, IPAMReservationsPrefix.For(nil), func(key, value []byte) error {
+		prefix, err := netip.ParsePrefix(string(value))
+		if err != nil {
+			return fmt.Errorf("invalid ipam reservation %q: %w", key, err)
+		}
+		out[types.NodeID(IPAMReservationsPrefix.TrimFrom(key))] = prefix
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list ipam reservations: %w", err)
+	}
+	return out, nil
+}
+
+// DeleteIPAMReservation removes the address reserved for the given node.
+func DeleteIPAMReservation(ctx context.Context, st MeshStorage, id types.NodeID) error {
+	if id == "" {
+		return errors.ErrEmptyNodeID
+	}
+	err := st.Delete(ctx, IPAMReservationKey(id))
+	if err != nil && !errors.IsKeyNotFound(err) {
+		return fmt.Errorf("delete ipam reservation: %w", err)
+	}
+	return nil
+}
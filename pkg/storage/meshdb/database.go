@@ -22,9 +22,11 @@ import (
 	"context"
 	"fmt"
 	"net/netip"
+	"sync"
 
 	"github.com/dominikbraun/graph"
 	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/webmeshproj/webmesh/pkg/crypto"
 	"github.com/webmeshproj/webmesh/pkg/storage"
@@ -42,6 +44,12 @@ import (
 // this is redundant. Note that certain write operations will call into read
 // methods to perform validation. So any locks used internally must be reentrant.
 func New(db storage.MeshDataStore) storage.MeshDB {
+	return newDatabase(db, nil)
+}
+
+// newDatabase returns a new MeshDB instance using the given MeshDataStore. If the
+// MeshStorage it is built on is given, node updates are made compare-and-set on it.
+func newDatabase(db storage.MeshDataStore, st storage.MeshStorage) *Database {
 	graphStore := &ValidatingGraphStore{db.GraphStore()}
 	return &Database{
 		db:         db,
@@ -49,6 +57,7 @@ func New(db storage.MeshDataStore) storage.MeshDB {
 		peers: &ValidatingPeerStore{
 			graph:      storage.NewGraphWithStore(graphStore),
 			graphStore: graphStore,
+			storage:    st,
 		},
 		rbac:    &ValidatingRBACStore{db.RBAC()},
 		state:   &ValidatingMeshStateStore{db.MeshState()},
@@ -59,12 +68,12 @@ func New(db storage.MeshDataStore) storage.MeshDB {
 // NewFromStorage creates a new MeshDB instance from the given MeshStorage. The same
 // information applies as for New.
 func NewFromStorage(st storage.MeshStorage) storage.MeshDB {
-	return New(&MeshDataStore{
+	return newDatabase(&MeshDataStore{
 		graph:   graphstore.NewStore(st),
 		rbac:    rbac.New(st),
 		mesh:    state.New(st),
 		network: networking.New(st),
-	})
+	}, st)
 }
 
 // MeshDataStore is a data store using an underlying MeshStorage instance.
@@ -173,8 +182,16 @@ func (v *ValidatingMeshStateStore) GetMeshState(ctx context.Context) (types.Netw
 type ValidatingPeerStore struct {
 	graph      types.PeerGraph
 	graphStore storage.GraphStore
+	// storage is the storage the graph is kept in, when known. It is used to
+	// make updates compare-and-set.
+	storage  storage.MeshStorage
+	updateMu sync.Mutex
 }
 
+// maxUpdateAttempts is the number of times Update re-applies its function
+// when the node is written by someone else in the meantime.
+const maxUpdateAttempts = 5
+
 // Graph returns the underlying graph.
 func (p *ValidatingPeerStore) Graph() types.PeerGraph {
 	return p.graph
@@ -185,6 +202,12 @@ func (p *ValidatingPeerStore) Subscribe(ctx context.Context, fn storage.PeerSubs
 	return p.graphStore.Subscribe(ctx, fn)
 }
 
+// WatchNode subscribes to changes to the node with the given ID from the underlying
+// graph storage.
+func (p *ValidatingPeerStore) WatchNode(ctx context.Context, id types.NodeID, fn storage.NodeWatchFunc) (context.CancelFunc, error) {
+	return p.graphStore.SubscribeNode(ctx, id, fn)
+}
+
 // Put validates the node and then saves it to the underlying graph storage.
 func (p *ValidatingPeerStore) Put(ctx context.Context, node types.MeshNode) error {
 	validated, err := types.ValidateMeshNode(node)
@@ -198,6 +221,128 @@ func (p *ValidatingPeerStore) Put(ctx context.Context, node types.MeshNode) erro
 	return nil
 }
 
+// Update applies fn to a copy of the node and saves the result only if the node
+// was not changed in the meantime. If it was, fn is applied again to the latest
+// version of the node. Updates through the same store are serialized. When the
+// store was created from a MeshStorage that can use transactions, the check and
+// the write are made in a single transaction, so this also holds against every
+// other writer of the storage. Otherwise the node is only re-read before saving,
+// which narrows but does not close the window for writes made by other means.
+func (p *ValidatingPeerStore) Update(ctx context.Context, id types.NodeID, fn storage.PeerUpdateFunc) error {
+	if !id.IsValid() {
+		return fmt.Errorf("%w: %s", errors.ErrInvalidNodeID, id)
+	}
+	p.updateMu.Lock()
+	defer p.updateMu.Unlock()
+	if p.storage != nil {
+		err := p.compareAndSwap(ctx, id, fn)
+		if !errors.Is(err, errors.ErrNotImplemented) {
+			return err
+		}
+	}
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		current, err := p.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		updated, changed, err := applyPeerUpdate(current, fn)
+		if err != nil || !changed {
+			return err
+		}
+		latest, err := p.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !proto.Equal(latest.MeshNode, current.MeshNode) {
+			continue
+		}
+		return p.Put(ctx, updated)
+	}
+	return fmt.Errorf("%w: %s", errors.ErrUpdateConflict, id)
+}
+
+// compareAndSwap applies fn to the stored node and writes the result in a
+// transaction that fails if the stored node changed since it was read,
+// retrying on conflict. ErrNotImplemented is returned if the storage cannot
+// use transactions.
+func (p *ValidatingPeerStore) compareAndSwap(ctx context.Context, id types.NodeID, fn storage.PeerUpdateFunc) error {
+	key := storage.NodesPrefix.For(id.Bytes())
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		txn, err := storage.Begin(ctx, p.storage)
+		if err != nil {
+			return err
+		}
+		err = p.stageUpdate(ctx, txn, key, fn)
+		if err != nil {
+			_ = txn.Rollback()
+			if errors.Is(err, errNoChange) {
+				return nil
+			}
+			return err
+		}
+		err = txn.Commit(ctx)
+		if errors.Is(err, errors.ErrTxnConflict) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("put node: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %s", errors.ErrUpdateConflict, id)
+}
+
+// errNoChange is returned by stageUpdate when fn left the node unchanged.
+var errNoChange = fmt.Errorf("node unchanged")
+
+// stageUpdate reads the node stored at key, applies fn to it, and buffers the
+// write of the result in txn guarded by a check of the value that was read.
+func (p *ValidatingPeerStore) stageUpdate(ctx context.Context, txn storage.Txn, key []byte, fn storage.PeerUpdateFunc) error {
+	raw, err := p.storage.GetValue(ctx, key)
+	if err != nil {
+		if errors.IsKeyNotFound(err) {
+			return errors.ErrNodeNotFound
+		}
+		return fmt.Errorf("get node: %w", err)
+	}
+	var current types.MeshNode
+	if err := current.UnmarshalProtoJSON(raw); err != nil {
+		return fmt.Errorf("unmarshal node: %w", err)
+	}
+	updated, changed, err := applyPeerUpdate(current, fn)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return errNoChange
+	}
+	validated, err := types.ValidateMeshNode(updated)
+	if err != nil {
+		return fmt.Errorf("validate node: %w", err)
+	}
+	data, err := validated.MarshalProtoJSON()
+	if err != nil {
+		return fmt.Errorf("marshal node: %w", err)
+	}
+	if err := txn.Check(key, raw); err != nil {
+		return err
+	}
+	return txn.PutValue(key, data, 0)
+}
+
+// applyPeerUpdate applies fn to a copy of the node and reports whether it
+// changed it.
+func applyPeerUpdate(current types.MeshNode, fn storage.PeerUpdateFunc) (types.MeshNode, bool, error) {
+	updated := types.MeshNode{MeshNode: proto.Clone(current.MeshNode).(*v1.MeshNode)}
+	if err := fn(&updated); err != nil {
+		return updated, false, err
+	}
+	if updated.GetId() != current.GetId() {
+		return updated, false, fmt.Errorf("update must not change the node ID")
+	}
+	return updated, !proto.Equal(updated.MeshNode, current.MeshNode), nil
+}
+
 // Get validates the node ID and then retrieves it from the underlying graph storage.
 func (p *ValidatingPeerStore) Get(ctx context.Context, id types.NodeID) (types.MeshNode, error) {
 	if !id.IsValid() {
@@ -221,11 +366,11 @@ func (p *ValidatingPeerStore) GetByPubKey(ctx context.Context, key crypto.Public
 	}
 	for _, node := range nodes {
 		if node.GetPublicKey() != "" {
-			key, err := crypto.DecodePublicKey(node.GetPublicKey())
+			nodeKey, err := crypto.DecodePublicKey(node.GetPublicKey())
 			if err != nil {
 				return types.MeshNode{}, fmt.Errorf("parse host public key: %w", err)
 			}
-			if key.Equals(key) {
+			if nodeKey.Equals(key) {
 				return node, nil
 			}
 		}
@@ -267,14 +412,28 @@ func (p *ValidatingPeerStore) List(ctx context.Context, filters ...storage.PeerF
 	if err != nil {
 		return nil, fmt.Errorf("list vertices: %w", err)
 	}
+	match := storage.PeerFilters(filters)
 	for _, vertex := range verticies {
 		node, _, err := p.graphStore.Vertex(vertex)
 		if err != nil {
 			return nil, fmt.Errorf("get vertex: %w", err)
 		}
-		out = append(out, node)
+		if match.Match(node) {
+			out = append(out, node)
+		}
 	}
-	return storage.PeerFilters(filters).Filter(out), nil
+	return out, nil
+}
+
+// ListByFeature lists all nodes that advertise the given feature.
+func (p *ValidatingPeerStore) ListByFeature(ctx context.Context, feature v1.Feature) ([]types.MeshNode, error) {
+	return p.List(ctx, storage.FilterByFeature(feature))
+}
+
+// ListEndpoints returns the public endpoint of every node that advertises
+// the given feature, keyed by node ID.
+func (p *ValidatingPeerStore) ListEndpoints(ctx context.Context, feature v1.Feature) (map[string]netip.AddrPort, error) {
+	return storage.ListFeatureEndpoints(ctx, p, feature)
 }
 
 // ListIDs returns all node IDs in the graph.
@@ -282,6 +441,11 @@ func (p *ValidatingPeerStore) ListIDs(ctx context.Context) ([]types.NodeID, erro
 	return p.graphStore.ListVertices()
 }
 
+// Count returns the number of nodes in the graph.
+func (p *ValidatingPeerStore) Count(ctx context.Context) (int, error) {
+	return p.graphStore.VertexCount()
+}
+
 // PutEdge validates the edge and then calls the underlying storage.Peers PutEdge method.
 func (p *ValidatingPeerStore) PutEdge(ctx context.Context, edge types.MeshEdge) error {
 	if edge.Source == edge.Target {
@@ -344,6 +508,15 @@ func (g *ValidatingGraphStore) Subscribe(ctx context.Context, fn storage.PeerSub
 	return g.GraphStore.Subscribe(ctx, fn)
 }
 
+// SubscribeNode validates the node ID and then subscribes to changes to the node
+// from the underlying graph storage.
+func (g *ValidatingGraphStore) SubscribeNode(ctx context.Context, id types.NodeID, fn storage.NodeWatchFunc) (context.CancelFunc, error) {
+	if !id.IsValid() {
+		return func() {}, fmt.Errorf("%w: %s", errors.ErrInvalidNodeID, id)
+	}
+	return g.GraphStore.SubscribeNode(ctx, id, fn)
+}
+
 // AddVertex should add the given vertex with the given hash value and vertex properties to the
 // graph. If the vertex already exists, it is up to you whether ErrVertexAlreadyExists or no
 // error should be returned.
@@ -0,0 +1,309 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package meshdb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+
+	"github.com/webmeshproj/webmesh/pkg/crypto"
+	"github.com/webmeshproj/webmesh/pkg/storage"
+	"github.com/webmeshproj/webmesh/pkg/storage/errors"
+	"github.com/webmeshproj/webmesh/pkg/storage/providers/backends/badgerdb"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+// spyStorage records the number of value reads made against the storage.
+type spyStorage struct {
+	storage.MeshStorage
+	reads atomic.Int32
+}
+
+func (s *spyStorage) GetValue(ctx context.Context, key []byte) ([]byte, error) {
+	s.reads.Add(1)
+	return s.MeshStorage.GetValue(ctx, key)
+}
+
+func (s *spyStorage) IterPrefix(ctx context.Context, prefix []byte, fn storage.PrefixIterator) error {
+	s.reads.Add(1)
+	return s.MeshStorage.IterPrefix(ctx, prefix, fn)
+}
+
+// spyKeyIterStorage is a spyStorage that also supports iterating keys.
+type spyKeyIterStorage struct {
+	*spyStorage
+	iter storage.KeyIterStorage
+}
+
+func (s *spyKeyIterStorage) IterKeys(ctx context.Context, prefix []byte, fn storage.KeyIterator) error {
+	return s.iter.IterKeys(ctx, prefix, fn)
+}
+
+func TestPeersCount(t *testing.T) {
+	t.Parallel()
+	const numNodes = 10
+
+	tc := []struct {
+		name string
+		wrap func(*spyStorage, storage.MeshStorage) storage.MeshStorage
+	}{
+		{
+			name: "ListKeys",
+			wrap: func(spy *spyStorage, _ storage.MeshStorage) storage.MeshStorage { return spy },
+		},
+		{
+			name: "IterKeys",
+			wrap: func(spy *spyStorage, st storage.MeshStorage) storage.MeshStorage {
+				return &spyKeyIterStorage{spyStorage: spy, iter: st.(storage.KeyIterStorage)}
+			},
+		},
+	}
+	for _, tt := range tc {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			st := badgerdb.NewTestStorage(false)
+			t.Cleanup(func() { _ = st.Close() })
+			spy := &spyStorage{MeshStorage: st}
+			db := NewFromStorage(tt.wrap(spy, st))
+
+			count, err := db.Peers().Count(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != 0 {
+				t.Fatalf("expected no nodes, got %d", count)
+			}
+			for i := 0; i < numNodes; i++ {
+				encoded, err := crypto.MustGenerateKey().PublicKey().Encode()
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = db.Peers().Put(ctx, types.MeshNode{MeshNode: &v1.MeshNode{
+					Id:        fmt.Sprintf("node-%d", i),
+					PublicKey: encoded,
+				}})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			spy.reads.Store(0)
+			count, err = db.Peers().Count(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != numNodes {
+				t.Fatalf("expected %d nodes, got %d", numNodes, count)
+			}
+			if reads := spy.reads.Load(); reads != 0 {
+				t.Fatalf("expected count to not read any node values, got %d reads", reads)
+			}
+		})
+	}
+}
+
+func TestPeersUpdate(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	newDB := func(t *testing.T) storage.MeshDB {
+		t.Helper()
+		st := badgerdb.NewTestStorage(false)
+		t.Cleanup(func() { _ = st.Close() })
+		db := NewFromStorage(st)
+		err := db.Peers().Put(ctx, types.MeshNode{MeshNode: &v1.MeshNode{Id: "node"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}
+
+	t.Run("ConcurrentUpdates", func(t *testing.T) {
+		t.Parallel()
+		db := newDB(t)
+		var wg sync.WaitGroup
+		errs := make(chan error, 2)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs <- db.Peers().Update(ctx, "node", func(node *types.MeshNode) error {
+				node.ZoneAwarenessID = "zone-a"
+				return nil
+			})
+		}()
+		go func() {
+			defer wg.Done()
+			errs <- db.Peers().Update(ctx, "node", func(node *types.MeshNode) error {
+				node.PrimaryEndpoint = "10.0.0.1"
+				return nil
+			})
+		}()
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		node, err := db.Peers().Get(ctx, "node")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if node.GetZoneAwarenessID() != "zone-a" || node.GetPrimaryEndpoint() != "10.0.0.1" {
+			t.Fatalf("expected both updates to apply, got %+v", node.MeshNode)
+		}
+	})
+
+	t.Run("ManyConcurrentUpdates", func(t *testing.T) {
+		t.Parallel()
+		db := newDB(t)
+		const numUpdates = 20
+		var wg sync.WaitGroup
+		for i := 0; i < numUpdates; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				err := db.Peers().Update(ctx, "node", func(node *types.MeshNode) error {
+					node.Multiaddrs = append(node.Multiaddrs, fmt.Sprintf("/ip4/10.0.0.%d/tcp/8443", i))
+					return nil
+				})
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			}(i)
+		}
+		wg.Wait()
+		node, err := db.Peers().Get(ctx, "node")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(node.GetMultiaddrs()) != numUpdates {
+			t.Fatalf("expected %d multiaddrs, got %d", numUpdates, len(node.GetMultiaddrs()))
+		}
+	})
+
+	t.Run("RetriesOnConflict", func(t *testing.T) {
+		t.Parallel()
+		db := newDB(t)
+		var calls int
+		err := db.Peers().Update(ctx, "node", func(node *types.MeshNode) error {
+			calls++
+			if calls == 1 {
+				// Simulate a write that does not go through Update.
+				err := db.Peers().Put(ctx, types.MeshNode{MeshNode: &v1.MeshNode{Id: "node", ZoneAwarenessID: "zone-b"}})
+				if err != nil {
+					return err
+				}
+			}
+			node.PrimaryEndpoint = "10.0.0.2"
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calls != 2 {
+			t.Fatalf("expected update to be retried once, got %d calls", calls)
+		}
+		node, err := db.Peers().Get(ctx, "node")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if node.GetZoneAwarenessID() != "zone-b" || node.GetPrimaryEndpoint() != "10.0.0.2" {
+			t.Fatalf("expected conflicting write to be preserved, got %+v", node.MeshNode)
+		}
+	})
+
+	t.Run("ConflictAtCommit", func(t *testing.T) {
+		t.Parallel()
+		st := badgerdb.NewTestStorage(false)
+		t.Cleanup(func() { _ = st.Close() })
+		// Another writer changes the node between the read and the commit.
+		racing := &racingTxnStorage{MeshStorage: st, race: func() error {
+			return NewFromStorage(st).Peers().Put(ctx, types.MeshNode{MeshNode: &v1.MeshNode{Id: "node", ZoneAwarenessID: "zone-c"}})
+		}}
+		db := NewFromStorage(racing)
+		err := db.Peers().Put(ctx, types.MeshNode{MeshNode: &v1.MeshNode{Id: "node"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var calls int
+		err = db.Peers().Update(ctx, "node", func(node *types.MeshNode) error {
+			calls++
+			node.PrimaryEndpoint = "10.0.0.3"
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calls != 2 {
+			t.Fatalf("expected update to be retried once, got %d calls", calls)
+		}
+		node, err := db.Peers().Get(ctx, "node")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if node.GetZoneAwarenessID() != "zone-c" || node.GetPrimaryEndpoint() != "10.0.0.3" {
+			t.Fatalf("expected conflicting write to be preserved, got %+v", node.MeshNode)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		t.Parallel()
+		db := newDB(t)
+		err := db.Peers().Update(ctx, "missing", func(*types.MeshNode) error { return nil })
+		if !errors.IsNodeNotFound(err) {
+			t.Fatalf("expected node not found, got %v", err)
+		}
+	})
+}
+
+// racingTxnStorage runs race once, right before the first transaction it
+// started is committed.
+type racingTxnStorage struct {
+	storage.MeshStorage
+	race  func() error
+	raced bool
+}
+
+func (r *racingTxnStorage) Begin(ctx context.Context) (storage.Txn, error) {
+	txn, err := storage.Begin(ctx, r.MeshStorage)
+	if err != nil {
+		return nil, err
+	}
+	return &racingTxn{Txn: txn, storage: r}, nil
+}
+
+type racingTxn struct {
+	storage.Txn
+	storage *racingTxnStorage
+}
+
+func (r *racingTxn) Commit(ctx context.Context) error {
+	if !r.storage.raced {
+		r.storage.raced = true
+		if err := r.storage.race(); err != nil {
+			return err
+		}
+	}
+	return r.Txn.Commit(ctx)
+}
@@ -169,11 +169,29 @@ func (g *GraphStore) ListVertices() ([]types.NodeID, error) {
 func (g *GraphStore) VertexCount() (int, error) {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
-	ids, err := g.ListVertices()
+	ctx := context.Background()
+	var count int
+	countKey := func(key []byte) error {
+		if !bytes.Equal(key, storage.NodesPrefix) {
+			count++
+		}
+		return nil
+	}
+	// Count the keys as they are iterated when the storage supports it.
+	if iter, ok := g.MeshStorage.(storage.KeyIterStorage); ok {
+		if err := iter.IterKeys(ctx, storage.NodesPrefix, countKey); err != nil {
+			return 0, fmt.Errorf("iterate nodes: %w", err)
+		}
+		return count, nil
+	}
+	keys, err := g.ListKeys(ctx, storage.NodesPrefix)
 	if err != nil {
-		return 0, fmt.Errorf("list vertices: %w", err)
+		return 0, fmt.Errorf("list nodes: %w", err)
 	}
-	return len(ids), nil
+	for _, key := range keys {
+		_ = countKey(key)
+	}
+	return count, nil
 }
 
 // AddEdge should add an edge between the vertices with the given source and target hashes.
@@ -392,6 +410,29 @@ func (g *GraphStore) Subscribe(ctx context.Context, fn storage.PeerSubscribeFunc
 	})
 }
 
+// SubscribeNode subscribes to changes to a single node.
+func (g *GraphStore) SubscribeNode(ctx context.Context, id types.NodeID, fn storage.NodeWatchFunc) (context.CancelFunc, error) {
+	log := context.LoggerFrom(ctx)
+	nodeKey := storage.NodesPrefix.For(id.Bytes())
+	return g.MeshStorage.Subscribe(ctx, nodeKey, func(key, value []byte) {
+		// The subscription is by prefix, so ignore nodes that share it.
+		if !bytes.Equal(key, nodeKey) {
+			return
+		}
+		if len(value) == 0 {
+			fn(nil, true)
+			return
+		}
+		var node types.MeshNode
+		err := node.UnmarshalProtoJSON(value)
+		if err != nil {
+			log.Error("Failed to unmarshal node", "error", err.Error())
+			return
+		}
+		fn(&node, false)
+	})
+}
+
 func newEdgeKey(source, target types.NodeID) []byte {
 	return storage.EdgesPrefix.For(source.Bytes()).For(target.Bytes())
 }
@@ -22,6 +22,8 @@ import (
 	"fmt"
 	"net/netip"
 
+	v1 "github.com/webmeshproj/api/go/v1"
+
 	"github.com/webmeshproj/webmesh/pkg/context"
 	"github.com/webmeshproj/webmesh/pkg/storage"
 	"github.com/webmeshproj/webmesh/pkg/storage/errors"
@@ -103,6 +105,12 @@ func (n *networking) ListNetworkACLs(ctx context.Context) (types.NetworkACLs, er
 	return out, err
 }
 
+// GetDefaultNetworkACLAction returns the action applied to flows that no
+// NetworkACL matches.
+func (n *networking) GetDefaultNetworkACLAction(ctx context.Context) (v1.ACLAction, error) {
+	return storage.GetDefaultNetworkACLAction(ctx, n.MeshStorage)
+}
+
 // PutRoute creates or updates a Route.
 func (n *networking) PutRoute(ctx context.Context, route types.Route) error {
 	err := types.ValidateRoute(route)
@@ -19,11 +19,15 @@ package state
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/netip"
+	"strings"
 
 	v1 "github.com/webmeshproj/api/go/v1"
 
 	"github.com/webmeshproj/webmesh/pkg/storage"
+	"github.com/webmeshproj/webmesh/pkg/storage/errors"
 	"github.com/webmeshproj/webmesh/pkg/storage/types"
 )
 
@@ -38,6 +42,8 @@ var (
 	IPv4PrefixKey = append(MeshStatePrefix, []byte("/ipv4prefix")...)
 	// MeshDomainKey is the key for the mesh domain.
 	MeshDomainKey = append(MeshStatePrefix, []byte("/meshdomain")...)
+	// MeshSearchDomainsKey is the key for the mesh search domains.
+	MeshSearchDomainsKey = append(MeshStatePrefix, []byte("/searchdomains")...)
 )
 
 type state struct {
@@ -97,6 +103,62 @@ func (s *state) SetMeshDomain(ctx context.Context, domain string) error {
 	return nil
 }
 
+// ListMeshState returns all keys and values stored under MeshStatePrefix. It is
+// intended for backups and inspection, and includes keys not known to this package.
+func (s *state) ListMeshState(ctx context.Context) (map[string]string, error) {
+	out := make(map[string]string)
+	// Include the separator so keys that merely share the prefix are skipped.
+	prefix := []byte(string(MeshStatePrefix) + "/")
+	err := s.IterPrefix(ctx, prefix, func(key, value []byte) error {
+		out[string(key)] = string(value)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("iterate mesh state: %w", err)
+	}
+	return out, nil
+}
+
+// GetMeshSearchDomains returns the search domains of the mesh. These are used
+// in addition to the primary mesh domain. An empty slice is returned if none
+// are set.
+func (s *state) GetMeshSearchDomains(ctx context.Context) ([]string, error) {
+	data, err := s.GetValue(ctx, MeshSearchDomainsKey)
+	if err != nil {
+		if errors.IsKeyNotFound(err) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("get mesh search domains: %w", err)
+	}
+	domains := []string{}
+	if err := json.Unmarshal(data, &domains); err != nil {
+		return nil, fmt.Errorf("unmarshal mesh search domains: %w", err)
+	}
+	return domains, nil
+}
+
+// SetMeshSearchDomains sets the search domains of the mesh, replacing any
+// previously set. An empty list clears them.
+func (s *state) SetMeshSearchDomains(ctx context.Context, domains []string) error {
+	for _, domain := range domains {
+		if strings.TrimSpace(domain) == "" {
+			return fmt.Errorf("search domains must not be empty")
+		}
+	}
+	if len(domains) == 0 {
+		err := s.Delete(ctx, MeshSearchDomainsKey)
+		if err != nil && !errors.IsKeyNotFound(err) {
+			return fmt.Errorf("delete mesh search domains: %w", err)
+		}
+		return nil
+	}
+	data, err := json.Marshal(domains)
+	if err != nil {
+		return fmt.Errorf("marshal mesh search domains: %w", err)
+	}
+	return s.PutValue(ctx, MeshSearchDomainsKey, data, 0)
+}
+
 func (s *state) SetMeshState(ctx context.Context, state types.NetworkState) error {
 	if state.NetworkV4().IsValid() {
 		err := s.SetIPv4Prefix(ctx, state.NetworkV4())
@@ -0,0 +1,136 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/webmeshproj/webmesh/pkg/storage/providers/backends/badgerdb"
+)
+
+func TestListMeshState(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	db := badgerdb.NewTestStorage(false)
+	t.Cleanup(func() { _ = db.Close() })
+
+	st := New(db).(*state)
+	if err := st.SetIPv4Prefix(ctx, netip.MustParsePrefix("172.16.0.0/12")); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetIPv6Prefix(ctx, netip.MustParsePrefix("fd00:dead:beef::/48")); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetMeshDomain(ctx, "webmesh.internal."); err != nil {
+		t.Fatal(err)
+	}
+	extraKey := append(append([]byte{}, MeshStatePrefix...), []byte("/extra")...)
+	if err := db.PutValue(ctx, extraKey, []byte("value"), 0); err != nil {
+		t.Fatal(err)
+	}
+	// Keys outside of the mesh state should not be returned.
+	if err := db.PutValue(ctx, []byte("/registry/meshstatefoo"), []byte("ignored"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.PutValue(ctx, []byte("/registry/other"), []byte("ignored"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := st.ListMeshState(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		string(IPv4PrefixKey): "172.16.0.0/12",
+		string(IPv6PrefixKey): "fd00:dead:beef::/48",
+		string(MeshDomainKey): "webmesh.internal.",
+		string(extraKey):      "value",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d keys, got %d: %v", len(want), len(got), got)
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Fatalf("expected %s to be %q, got %q", key, value, got[key])
+		}
+	}
+}
+
+func TestMeshSearchDomains(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	db := badgerdb.NewTestStorage(false)
+	t.Cleanup(func() { _ = db.Close() })
+	st := New(db).(*state)
+
+	t.Run("Unset", func(t *testing.T) {
+		got, err := st.GetMeshSearchDomains(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got == nil || len(got) != 0 {
+			t.Fatalf("expected an empty slice, got %#v", got)
+		}
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		if err := st.SetMeshDomain(ctx, "webmesh.internal."); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"east.webmesh.internal.", "west.webmesh.internal."}
+		if err := st.SetMeshSearchDomains(ctx, want); err != nil {
+			t.Fatal(err)
+		}
+		got, err := st.GetMeshSearchDomains(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+		// The primary domain is unaffected.
+		domain, err := st.GetMeshDomain(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if domain != "webmesh.internal." {
+			t.Fatalf("expected primary domain webmesh.internal., got %s", domain)
+		}
+	})
+
+	t.Run("InvalidDomain", func(t *testing.T) {
+		if err := st.SetMeshSearchDomains(ctx, []string{"east.webmesh.internal.", " "}); err == nil {
+			t.Fatal("expected error for empty search domain")
+		}
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		if err := st.SetMeshSearchDomains(ctx, nil); err != nil {
+			t.Fatal(err)
+		}
+		got, err := st.GetMeshSearchDomains(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got == nil || len(got) != 0 {
+			t.Fatalf("expected an empty slice, got %#v", got)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+
+	"github.com/webmeshproj/webmesh/pkg/storage/errors"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+// DefaultNetworkACLActionKey is where the action applied when no NetworkACL
+// matches a flow is stored.
+var DefaultNetworkACLActionKey = types.RegistryPrefix.For([]byte("default-network-acl-action"))
+
+// GetDefaultNetworkACLAction returns the action to apply when no NetworkACL
+// matches a flow. If none has been configured, ACTION_DENY is returned.
+func GetDefaultNetworkACLAction(ctx context.Context, st MeshStorage) (v1.ACLAction, error) {
+	val, err := st.GetValue(ctx, DefaultNetworkACLActionKey)
+	if err != nil {
+		if errors.IsKeyNotFound(err) {
+			return v1.ACLAction_ACTION_DENY, nil
+		}
+		return v1.ACLAction_ACTION_DENY, fmt.Errorf("get default network acl action: %w", err)
+	}
+	return ParseDefaultNetworkACLAction(val)
+}
+
+// ParseDefaultNetworkACLAction parses a default action as it is stored at
+// DefaultNetworkACLActionKey.
+func ParseDefaultNetworkACLAction(val []byte) (v1.ACLAction, error) {
+	action, ok := v1.ACLAction_value[string(val)]
+	if !ok {
+		return v1.ACLAction_ACTION_DENY, fmt.Errorf("invalid default network acl action: %q", val)
+	}
+	return v1.ACLAction(action), nil
+}
+
+// PutDefaultNetworkACLAction sets the action to apply when no NetworkACL
+// matches a flow. Only ACTION_ACCEPT and ACTION_DENY are allowed.
+func PutDefaultNetworkACLAction(ctx context.Context, st MeshStorage, action v1.ACLAction) error {
+	if action != v1.ACLAction_ACTION_ACCEPT && action != v1.ACLAction_ACTION_DENY {
+		return fmt.Errorf("invalid default network acl action: %s", action)
+	}
+	err := st.PutValue(ctx, DefaultNetworkACLActionKey, []byte(action.String()), 0)
+	if err != nil {
+		return fmt.Errorf("put default network acl action: %w", err)
+	}
+	return nil
+}
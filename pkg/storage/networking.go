@@ -21,6 +21,8 @@ import (
 	"slices"
 	"strings"
 
+	v1 "github.com/webmeshproj/api/go/v1"
+
 	"github.com/webmeshproj/webmesh/pkg/context"
 	"github.com/webmeshproj/webmesh/pkg/storage/errors"
 	"github.com/webmeshproj/webmesh/pkg/storage/types"
@@ -45,6 +47,9 @@ type Networking interface {
 	DeleteNetworkACL(ctx context.Context, name string) error
 	// ListNetworkACLs returns a list of NetworkACLs.
 	ListNetworkACLs(ctx context.Context) (types.NetworkACLs, error)
+	// GetDefaultNetworkACLAction returns the action applied to flows that no
+	// NetworkACL matches. ACTION_DENY is returned if none has been configured.
+	GetDefaultNetworkACLAction(ctx context.Context) (v1.ACLAction, error)
 	// PutRoute creates or updates a Route.
 	PutRoute(ctx context.Context, route types.Route) error
 	// GetRoute returns a Route by name.
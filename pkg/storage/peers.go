@@ -51,14 +51,29 @@ type Peers interface {
 	Get(ctx context.Context, id types.NodeID) (types.MeshNode, error)
 	// GetByPubKey gets a node by their public key.
 	GetByPubKey(ctx context.Context, key crypto.PublicKey) (types.MeshNode, error)
+	// Update reads the node with the given ID, applies fn to it, and saves the
+	// result only if the node was not changed in the meantime, applying fn again
+	// to the latest version on conflict. The check and the write are atomic when
+	// the storage supports transactions. Nothing is written if fn returns an
+	// error or leaves the node unchanged.
+	Update(ctx context.Context, id types.NodeID, fn PeerUpdateFunc) error
 	// Delete deletes a node.
 	Delete(ctx context.Context, id types.NodeID) error
 	// List lists all nodes.
 	List(ctx context.Context, filters ...PeerFilter) ([]types.MeshNode, error)
+	// ListByFeature lists all nodes that advertise the given feature.
+	ListByFeature(ctx context.Context, feature v1.Feature) ([]types.MeshNode, error)
+	// ListEndpoints returns the public endpoint of every node that advertises
+	// the given feature, keyed by node ID.
+	ListEndpoints(ctx context.Context, feature v1.Feature) (map[string]netip.AddrPort, error)
 	// ListIDs lists all node IDs.
 	ListIDs(ctx context.Context) ([]types.NodeID, error)
+	// Count returns the number of nodes without loading them.
+	Count(ctx context.Context) (int, error)
 	// Subscribe subscribes to node changes.
 	Subscribe(ctx context.Context, fn PeerSubscribeFunc) (context.CancelFunc, error)
+	// WatchNode subscribes to changes to the node with the given ID.
+	WatchNode(ctx context.Context, id types.NodeID, fn NodeWatchFunc) (context.CancelFunc, error)
 	// AddEdge adds an edge between two nodes.
 	PutEdge(ctx context.Context, edge types.MeshEdge) error
 	// GetEdge gets an edge between two nodes.
@@ -67,6 +82,14 @@ type Peers interface {
 	RemoveEdge(ctx context.Context, from, to types.NodeID) error
 }
 
+// NodeWatchFunc is the function signature for watching a single node. It is called
+// with the new state of the node when it is put, or with a nil node and deleted
+// set to true when it is removed.
+type NodeWatchFunc func(node *types.MeshNode, deleted bool)
+
+// PeerUpdateFunc modifies a node in place during an update.
+type PeerUpdateFunc func(*types.MeshNode) error
+
 // PeerFilter is a filter for nodes.
 type PeerFilter func(types.MeshNode) bool
 
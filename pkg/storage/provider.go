@@ -89,6 +89,9 @@ type Consensus interface {
 	IsMember() bool
 	// StepDown should be called to relinquish leadership of the storage group.
 	StepDown(context.Context) error
+	// TransferLeadership transfers leadership of the storage group to the
+	// voter with the given ID and waits for it to take over.
+	TransferLeadership(ctx context.Context, targetID string) error
 	// GetPeer returns the peer with the given ID.
 	GetPeer(context.Context, string) (types.StoragePeer, error)
 	// GetPeers returns the peers of the storage group.
@@ -104,6 +107,30 @@ type Consensus interface {
 	// RemovePeer removes a peer from the consensus group. If wait
 	// is true, the function will wait for the peer to be removed.
 	RemovePeer(ctx context.Context, peer types.StoragePeer, wait bool) error
+	// AppliedIndex returns the index and term of the last log entry applied
+	// to the local state. Both are zero if the node does not apply log entries.
+	AppliedIndex() (index uint64, term uint64)
+	// AwaitIndex blocks until the local state has applied at least the given
+	// index or the context is done. Providers that do not apply log entries
+	// return ErrNotImplemented.
+	AwaitIndex(ctx context.Context, index uint64) error
+	// SubscribeApplied returns a channel of notifications for the log entries
+	// applied to the local state from now on, in index order. The channel is
+	// closed when the context is done or the subscriber falls too far behind.
+	// Providers that do not apply log entries return ErrNotImplemented.
+	SubscribeApplied(ctx context.Context) (<-chan AppliedLog, error)
+}
+
+// AppliedLog is a notification that a log entry was applied to the local state.
+type AppliedLog struct {
+	// Index is the index of the log entry.
+	Index uint64
+	// Term is the term of the log entry.
+	Term uint64
+	// Type is the command type of the log entry.
+	Type v1.RaftCommandType
+	// Key is the key the entry wrote to. It is empty for batch entries.
+	Key []byte
 }
 
 // KVSubscribeFunc is the function signature for subscribing to changes to a key.
@@ -112,6 +139,19 @@ type KVSubscribeFunc func(key, value []byte)
 // PrefixIterator is the function signature for iterating over all keys with a given prefix.
 type PrefixIterator func(key, value []byte) error
 
+// KeyIterator is the function signature for iterating over keys with a given prefix
+// without their values.
+type KeyIterator func(key []byte) error
+
+// KeyIterStorage is implemented by storage that can iterate over keys without
+// collecting them in memory first. It is optional and callers should fall back
+// to ListKeys when it is not implemented.
+type KeyIterStorage interface {
+	// IterKeys iterates over all keys with a given prefix. The same restrictions
+	// as IterPrefix apply. The iteration will stop if the iterator returns an error.
+	IterKeys(ctx context.Context, prefix []byte, fn KeyIterator) error
+}
+
 // ErrStopIteration is a special error that can be returned by PrefixIterator to stop iteration.
 var ErrStopIteration = fmt.Errorf("stop iteration")
 
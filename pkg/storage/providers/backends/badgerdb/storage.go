@@ -73,6 +73,9 @@ type badgerDB struct {
 	db                *badger.DB
 	firstIdx, lastIdx atomic.Uint64
 	mu                sync.Mutex
+	// closemu is held for reading by long-running read-only iterations that
+	// do not take mu, so that Close waits for them to finish.
+	closemu sync.RWMutex
 }
 
 // New creates a new BadgerDB storage.
@@ -170,6 +173,10 @@ func (t *TempDiskStorage) Close() error {
 	return os.RemoveAll(t.tmp)
 }
 
+func (t *TempDiskStorage) Begin(ctx context.Context) (storage.Txn, error) {
+	return storage.Begin(ctx, t.DualStorage)
+}
+
 // DropAll deletes all keys.
 func (db *badgerDB) DropAll(ctx context.Context) error {
 	db.mu.Lock()
@@ -228,6 +235,59 @@ func (db *badgerDB) Delete(ctx context.Context, key []byte) error {
 	return nil
 }
 
+// Begin starts a new transaction. The buffered writes are applied in a
+// single BadgerDB transaction on commit.
+func (db *badgerDB) Begin(ctx context.Context) (storage.Txn, error) {
+	return storage.NewTxn(db.commitTxn), nil
+}
+
+func (db *badgerDB) commitTxn(ctx context.Context, ops []storage.TxnOp) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.db.Update(func(txn *badger.Txn) error {
+		for _, op := range ops {
+			switch op.Type {
+			case storage.TxnOpPut:
+				entry := badger.NewEntry(op.Key, op.Value)
+				if op.TTL > 0 {
+					entry = entry.WithTTL(op.TTL)
+				}
+				if err := txn.SetEntry(entry); err != nil {
+					return err
+				}
+			case storage.TxnOpDelete:
+				if err := txn.Delete(op.Key); err != nil {
+					return err
+				}
+			case storage.TxnOpCheck, storage.TxnOpCheckAbsent:
+				item, err := txn.Get(op.Key)
+				if err != nil {
+					if errors.Is(err, badger.ErrKeyNotFound) {
+						if op.Type == storage.TxnOpCheckAbsent {
+							continue
+						}
+						return errors.ErrTxnConflict
+					}
+					return err
+				}
+				if op.Type == storage.TxnOpCheckAbsent {
+					return errors.ErrTxnConflict
+				}
+				value, err := item.ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(value, op.Value) {
+					return errors.ErrTxnConflict
+				}
+			default:
+				return fmt.Errorf("unknown transaction op type: %d", op.Type)
+			}
+		}
+		return nil
+	})
+}
+
 // ListKeys returns all keys with a given prefix.
 func (db *badgerDB) ListKeys(ctx context.Context, prefix []byte) ([][]byte, error) {
 	db.mu.Lock()
@@ -297,6 +357,42 @@ func (db *badgerDB) IterPrefix(ctx context.Context, prefix []byte, fn storage.Pr
 	return err
 }
 
+// IterKeys iterates over all keys with a given prefix without fetching their values.
+// The iteration runs in a read-only transaction without holding the storage lock,
+// so other reads and writes may proceed while it is in progress. The keys observed
+// are those of the snapshot at the start of the iteration. The iteration will stop
+// if the iterator returns an error.
+func (db *badgerDB) IterKeys(ctx context.Context, prefix []byte, fn storage.KeyIterator) error {
+	db.closemu.RLock()
+	defer db.closemu.RUnlock()
+	err := db.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			k := it.Item().Key()
+			key := make([]byte, len(k))
+			copy(key, k)
+			if err := fn(key); err != nil {
+				if errors.Is(err, storage.ErrStopIteration) {
+					return nil
+				}
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+		return nil
+	}
+	return err
+}
+
 // Subscribe will call the given function whenever a key with the given prefix is changed.
 // The returned function can be called to unsubscribe.
 func (db *badgerDB) Subscribe(ctx context.Context, prefix []byte, fn storage.KVSubscribeFunc) (context.CancelFunc, error) {
@@ -420,6 +516,8 @@ func (db *badgerDB) Restore(ctx context.Context, r io.Reader) error {
 
 // Close closes the storage.
 func (db *badgerDB) Close() error {
+	db.closemu.Lock()
+	defer db.closemu.Unlock()
 	db.mu.Lock()
 	defer db.mu.Unlock()
 	return db.db.Close()
@@ -338,6 +338,29 @@ func (ext *Consensus) DemoteVoter(ctx context.Context, peer types.StoragePeer) e
 	return nil
 }
 
+// TransferLeadership is not supported by the external storage plugin API.
+func (ext *Consensus) TransferLeadership(ctx context.Context, targetID string) error {
+	return errors.ErrNotImplemented
+}
+
+// AppliedIndex returns zero values, the external storage plugin API does not
+// expose the state of its log.
+func (ext *Consensus) AppliedIndex() (index uint64, term uint64) {
+	return 0, 0
+}
+
+// SubscribeApplied returns ErrNotImplemented, the external storage plugin API
+// does not expose the state of its log.
+func (ext *Consensus) SubscribeApplied(ctx context.Context) (<-chan storage.AppliedLog, error) {
+	return nil, errors.ErrNotImplemented
+}
+
+// AwaitIndex returns ErrNotImplemented, the external storage plugin API
+// does not expose the state of its log.
+func (ext *Consensus) AwaitIndex(ctx context.Context, index uint64) error {
+	return errors.ErrNotImplemented
+}
+
 // RemovePeer removes a peer from the consensus group. If wait
 // is true, the function will wait for the peer to be removed.
 func (ext *Consensus) RemovePeer(ctx context.Context, peer types.StoragePeer, wait bool) error {
@@ -255,6 +255,11 @@ func (g *GraphStore) Subscribe(ctx context.Context, fn storage.PeerSubscribeFunc
 	return func() {}, errors.ErrNotStorageNode
 }
 
+func (g *GraphStore) SubscribeNode(ctx context.Context, id types.NodeID, fn storage.NodeWatchFunc) (context.CancelFunc, error) {
+	// Like Subscribe, this is not supported over passthrough storage.
+	return func() {}, errors.ErrNotStorageNode
+}
+
 // RBACStore is a passthrough RBAC store that uses the storage API to field
 // read requests.
 type RBACStore struct {
@@ -582,6 +587,30 @@ func (nw *NetworkingStore) ListNetworkACLs(ctx context.Context) (types.NetworkAC
 	return out, nil
 }
 
+func (nw *NetworkingStore) GetDefaultNetworkACLAction(ctx context.Context) (v1.ACLAction, error) {
+	err := nw.dial(ctx)
+	if err != nil {
+		return v1.ACLAction_ACTION_DENY, err
+	}
+	// Value lookups only accept plain IDs, so the key is listed as a prefix.
+	// No other key starts with it.
+	resp, err := nw.cli.Query(ctx, &v1.QueryRequest{
+		Command: v1.QueryRequest_LIST,
+		Type:    v1.QueryRequest_VALUE,
+		Query:   types.NewQueryFilters().WithID(string(storage.DefaultNetworkACLActionKey)).Encode(),
+	})
+	if err != nil {
+		return v1.ACLAction_ACTION_DENY, err
+	}
+	if resp.GetError() != "" {
+		return v1.ACLAction_ACTION_DENY, fmt.Errorf(resp.GetError())
+	}
+	if len(resp.GetItems()) == 0 {
+		return v1.ACLAction_ACTION_DENY, nil
+	}
+	return storage.ParseDefaultNetworkACLAction(resp.GetItems()[0])
+}
+
 func (nw *NetworkingStore) PutRoute(ctx context.Context, route types.Route) error {
 	return errors.ErrNotStorageNode
 }
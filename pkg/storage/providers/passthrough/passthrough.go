@@ -244,6 +244,26 @@ func (p *Consensus) RemovePeer(ctx context.Context, peer types.StoragePeer, wait
 	return errors.ErrNotStorageNode
 }
 
+// TransferLeadership transfers leadership to the voter with the given ID.
+func (p *Consensus) TransferLeadership(ctx context.Context, targetID string) error {
+	return errors.ErrNotStorageNode
+}
+
+// AppliedIndex returns zero values, passthrough nodes do not apply log entries.
+func (p *Consensus) AppliedIndex() (index uint64, term uint64) {
+	return 0, 0
+}
+
+// SubscribeApplied returns ErrNotImplemented, passthrough nodes do not apply log entries.
+func (p *Consensus) SubscribeApplied(ctx context.Context) (<-chan storage.AppliedLog, error) {
+	return nil, errors.ErrNotImplemented
+}
+
+// AwaitIndex returns ErrNotImplemented, passthrough nodes do not apply log entries.
+func (p *Consensus) AwaitIndex(ctx context.Context, index uint64) error {
+	return errors.ErrNotImplemented
+}
+
 type Storage struct {
 	*Provider
 }
@@ -0,0 +1,113 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package raftstorage
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/storage/errors"
+	"github.com/webmeshproj/webmesh/pkg/storage/providers/raftstorage/raftlogs"
+)
+
+// batchSupportInterval is how often a node tries to advertise that it can
+// apply batch entries until it succeeds.
+const batchSupportInterval = time.Second
+
+// batchSupported reports whether every server in the raft configuration has
+// advertised that it can apply batch entries. Batches must not be proposed
+// otherwise, as servers that predate them would fail to apply them and diverge
+// from the rest of the cluster.
+func (r *Provider) batchSupported(ctx context.Context) (bool, error) {
+	config := r.GetRaftConfiguration()
+	if len(config.Servers) == 0 {
+		return false, nil
+	}
+	for _, server := range config.Servers {
+		_, err := r.raftStorage.storage.GetValue(ctx, raftlogs.BatchSupportKey(string(server.ID)))
+		if err != nil {
+			if errors.IsKeyNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// advertiseBatchSupport writes the key advertising that this node can apply
+// batch entries once it is part of the raft configuration. It retries until
+// the key is written or stop is closed.
+func (r *Provider) advertiseBatchSupport(stop <-chan struct{}) {
+	t := time.NewTicker(batchSupportInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+		done, err := r.tryAdvertiseBatchSupport()
+		if err != nil {
+			r.log.Debug("Failed to advertise batch support", slog.String("error", err.Error()))
+			continue
+		}
+		if done {
+			return
+		}
+	}
+}
+
+func (r *Provider) tryAdvertiseBatchSupport() (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.Options.ApplyTimeout)
+	defer cancel()
+	_, err := r.raftStorage.storage.GetValue(ctx, raftlogs.BatchSupportKey(string(r.nodeID)))
+	if err == nil {
+		return true, nil
+	}
+	if !errors.IsKeyNotFound(err) {
+		return false, err
+	}
+	var member bool
+	for _, server := range r.GetRaftConfiguration().Servers {
+		if server.ID == r.nodeID {
+			member = true
+			break
+		}
+	}
+	if !member {
+		return false, nil
+	}
+	entry := raftlogs.NewBatchSupportEntry(string(r.nodeID))
+	if r.Consensus().IsLeader() {
+		res, err := r.ApplyRaftLog(ctx, entry)
+		if err != nil {
+			return false, err
+		}
+		if res.GetError() != "" {
+			return false, fmt.Errorf("apply log entry data: %s", res.GetError())
+		}
+		return false, nil
+	}
+	if _, id := r.raft.LeaderWithID(); id == "" {
+		return false, nil
+	}
+	// Wait for the write to replicate back before considering it done.
+	return false, r.raftStorage.sendLogToLeader(ctx, entry)
+}
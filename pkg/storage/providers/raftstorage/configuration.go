@@ -0,0 +1,46 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package raftstorage
+
+import (
+	"github.com/hashicorp/raft"
+)
+
+// DiffConfigurations returns the servers that were added and removed between
+// the old and new raft configurations. A server that is present in both but
+// with a different suffrage or address is returned in added with its new
+// configuration, since adding it again is how raft applies such a change.
+// Servers are returned in the order they appear in their configuration.
+func DiffConfigurations(old, new raft.Configuration) (added, removed []raft.Server) {
+	oldServers := make(map[raft.ServerID]raft.Server, len(old.Servers))
+	for _, server := range old.Servers {
+		oldServers[server.ID] = server
+	}
+	newServers := make(map[raft.ServerID]struct{}, len(new.Servers))
+	for _, server := range new.Servers {
+		newServers[server.ID] = struct{}{}
+		if prev, ok := oldServers[server.ID]; !ok || prev != server {
+			added = append(added, server)
+		}
+	}
+	for _, server := range old.Servers {
+		if _, ok := newServers[server.ID]; !ok {
+			removed = append(removed, server)
+		}
+	}
+	return added, removed
+}
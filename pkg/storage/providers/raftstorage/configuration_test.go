@@ -0,0 +1,104 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package raftstorage
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/hashicorp/raft"
+)
+
+func TestDiffConfigurations(t *testing.T) {
+	t.Parallel()
+	voter := func(id string) raft.Server {
+		return raft.Server{Suffrage: raft.Voter, ID: raft.ServerID(id), Address: raft.ServerAddress(id + ":9000")}
+	}
+	nonvoter := func(id string) raft.Server {
+		return raft.Server{Suffrage: raft.Nonvoter, ID: raft.ServerID(id), Address: raft.ServerAddress(id + ":9000")}
+	}
+	config := func(servers ...raft.Server) raft.Configuration {
+		return raft.Configuration{Servers: servers}
+	}
+	tc := []struct {
+		name        string
+		old, new    raft.Configuration
+		wantAdded   []raft.Server
+		wantRemoved []raft.Server
+	}{
+		{
+			name: "Empty",
+		},
+		{
+			name: "Unchanged",
+			old:  config(voter("a"), nonvoter("b")),
+			new:  config(voter("a"), nonvoter("b")),
+		},
+		{
+			name:      "Additions",
+			old:       config(voter("a")),
+			new:       config(voter("a"), voter("b"), nonvoter("c")),
+			wantAdded: []raft.Server{voter("b"), nonvoter("c")},
+		},
+		{
+			name:        "Removals",
+			old:         config(voter("a"), voter("b"), nonvoter("c")),
+			new:         config(voter("a")),
+			wantRemoved: []raft.Server{voter("b"), nonvoter("c")},
+		},
+		{
+			name:      "Promotion",
+			old:       config(voter("a"), nonvoter("b")),
+			new:       config(voter("a"), voter("b")),
+			wantAdded: []raft.Server{voter("b")},
+		},
+		{
+			name:      "Demotion",
+			old:       config(voter("a"), voter("b")),
+			new:       config(voter("a"), nonvoter("b")),
+			wantAdded: []raft.Server{nonvoter("b")},
+		},
+		{
+			name: "AddressChange",
+			old:  config(voter("a")),
+			new: config(raft.Server{
+				Suffrage: raft.Voter,
+				ID:       "a",
+				Address:  "a:9001",
+			}),
+			wantAdded: []raft.Server{{Suffrage: raft.Voter, ID: "a", Address: "a:9001"}},
+		},
+		{
+			name:        "Mixed",
+			old:         config(voter("a"), nonvoter("b"), voter("c")),
+			new:         config(voter("a"), voter("b"), nonvoter("d")),
+			wantAdded:   []raft.Server{voter("b"), nonvoter("d")},
+			wantRemoved: []raft.Server{voter("c")},
+		},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed := DiffConfigurations(tt.old, tt.new)
+			if !slices.Equal(added, tt.wantAdded) {
+				t.Errorf("expected added %v, got %v", tt.wantAdded, added)
+			}
+			if !slices.Equal(removed, tt.wantRemoved) {
+				t.Errorf("expected removed %v, got %v", tt.wantRemoved, removed)
+			}
+		})
+	}
+}
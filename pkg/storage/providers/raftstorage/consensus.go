@@ -17,6 +17,7 @@ limitations under the License.
 package raftstorage
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/hashicorp/raft"
@@ -58,6 +59,83 @@ func (r *Consensus) StepDown(ctx context.Context) error {
 	return r.raft.LeadershipTransfer().Error()
 }
 
+// TransferLeadership transfers leadership to the voter with the given ID. It
+// waits until the target is observed as the leader, the context is done, or
+// the apply timeout elapses.
+func (r *Consensus) TransferLeadership(ctx context.Context, targetID string) error {
+	target, err := r.startLeadershipTransfer(targetID)
+	if err != nil || target == r.nodeID {
+		return err
+	}
+	// The lock is not held while waiting so that other consensus operations
+	// can proceed during the election.
+	timeout := r.Options.ApplyTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(time.Millisecond * 50)
+	defer ticker.Stop()
+	for {
+		if _, leaderID := r.raft.LeaderWithID(); leaderID == target {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("wait for %s to become leader: %w", targetID, ctx.Err())
+		case <-timer.C:
+			return fmt.Errorf("timed out waiting for %s to become leader", targetID)
+		case <-ticker.C:
+		}
+	}
+}
+
+// startLeadershipTransfer validates the target and asks raft to transfer
+// leadership to it. It returns the ID of the target server.
+func (r *Consensus) startLeadershipTransfer(targetID string) (raft.ServerID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.started.Load() {
+		return "", errors.ErrClosed
+	}
+	if r.raft.State() != raft.Leader {
+		return "", errors.ErrNotLeader
+	}
+	if targetID == string(r.nodeID) {
+		return r.nodeID, nil
+	}
+	var target *raft.Server
+	for _, srv := range r.GetRaftConfiguration().Servers {
+		if string(srv.ID) == targetID {
+			target = &srv
+			break
+		}
+	}
+	if target == nil {
+		return "", errors.ErrNodeNotFound
+	}
+	if target.Suffrage != raft.Voter {
+		return "", fmt.Errorf("transfer leadership to %s: %w", targetID, errors.ErrNotVoter)
+	}
+	r.log.Debug("Transferring leadership", "target", targetID)
+	err := r.raft.LeadershipTransferToServer(target.ID, target.Address).Error()
+	if err != nil {
+		return "", fmt.Errorf("transfer leadership to %s: %w", targetID, err)
+	}
+	return target.ID, nil
+}
+
+// AppliedIndex returns the index and term of the last log entry applied to the FSM.
+func (r *Consensus) AppliedIndex() (index uint64, term uint64) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if !r.started.Load() {
+		return 0, 0
+	}
+	return r.fsm.LastAppliedIndex(), r.fsm.CurrentTerm()
+}
+
 // GetPeers returns the peers of the cluster.
 func (r *Consensus) GetPeers(ctx context.Context) ([]types.StoragePeer, error) {
 	r.mu.RLock()
@@ -48,6 +48,9 @@ type RaftFSM struct {
 	snapshotter      snapshots.Snapshotter
 	log              *slog.Logger
 	mu               sync.Mutex
+	subs             map[*subscriber]struct{}
+	appliedc         chan struct{}
+	submu            sync.Mutex
 }
 
 // Options are options for the FSM.
@@ -142,7 +145,10 @@ func (r *RaftFSM) applyLog(l *raft.Log) (cmd *v1.RaftLogEntry, res *v1.RaftApply
 		}
 	}
 
-	defer r.lastAppliedIndex.Store(l.Index)
+	defer func() {
+		r.lastAppliedIndex.Store(l.Index)
+		r.notifyApplied()
+	}()
 	defer r.currentTerm.Store(l.Term)
 
 	if l.Type != raft.LogCommand {
@@ -176,7 +182,16 @@ func (r *RaftFSM) applyLog(l *raft.Log) (cmd *v1.RaftLogEntry, res *v1.RaftApply
 	ctx = context.WithLogger(ctx, log)
 
 	// Apply the log entry to the database.
-	return cmd, raftlogs.Apply(ctx, r.store, cmd)
+	res = raftlogs.Apply(ctx, r.store, cmd)
+	if res.GetError() == "" {
+		r.publish(storage.AppliedLog{
+			Index: l.Index,
+			Term:  l.Term,
+			Type:  cmd.GetType(),
+			Key:   cmd.GetKey(),
+		})
+	}
+	return cmd, res
 }
 
 // MarshalLogEntry marshals a RaftLogEntry.
@@ -0,0 +1,158 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fsm
+
+import (
+	"sync"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/storage"
+)
+
+// MaxQueuedNotifications is the number of notifications queued for a
+// subscriber before it is considered too slow and its subscription is closed.
+const MaxQueuedNotifications = 4096
+
+// subscriber queues notifications for a single subscription so a slow
+// reader never holds up the FSM.
+type subscriber struct {
+	mu        sync.Mutex
+	queue     []storage.AppliedLog
+	notify    chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (s *subscriber) close() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+// Subscribe returns a channel of notifications for the log entries applied
+// successfully after the call, in index order. Notifications are queued until
+// they are read. The channel is closed and the subscription removed when the
+// context is done, when more than MaxQueuedNotifications are left unread, or
+// when CloseSubscriptions is called.
+func (r *RaftFSM) Subscribe(ctx context.Context) <-chan storage.AppliedLog {
+	sub := &subscriber{
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	r.submu.Lock()
+	if r.subs == nil {
+		r.subs = make(map[*subscriber]struct{})
+	}
+	r.subs[sub] = struct{}{}
+	r.submu.Unlock()
+	out := make(chan storage.AppliedLog)
+	go func() {
+		defer close(out)
+		defer func() {
+			r.submu.Lock()
+			delete(r.subs, sub)
+			r.submu.Unlock()
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sub.done:
+				return
+			case <-sub.notify:
+			}
+			sub.mu.Lock()
+			queue := sub.queue
+			sub.queue = nil
+			sub.mu.Unlock()
+			for _, applied := range queue {
+				select {
+				case out <- applied:
+				case <-ctx.Done():
+					return
+				case <-sub.done:
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// AppliedIndexChanged returns a channel that is closed the next time the last
+// applied index changes or CloseSubscriptions is called. Unlike Subscribe, it
+// fires for every log entry the FSM processes, including ones that are not
+// commands or that failed to apply.
+func (r *RaftFSM) AppliedIndexChanged() <-chan struct{} {
+	r.submu.Lock()
+	defer r.submu.Unlock()
+	if r.appliedc == nil {
+		r.appliedc = make(chan struct{})
+	}
+	return r.appliedc
+}
+
+// CloseSubscriptions closes every active subscription and wakes anyone
+// waiting on AppliedIndexChanged.
+func (r *RaftFSM) CloseSubscriptions() {
+	r.submu.Lock()
+	defer r.submu.Unlock()
+	r.closeApplied()
+	for sub := range r.subs {
+		sub.close()
+		delete(r.subs, sub)
+	}
+}
+
+// notifyApplied wakes anyone waiting on AppliedIndexChanged.
+func (r *RaftFSM) notifyApplied() {
+	r.submu.Lock()
+	defer r.submu.Unlock()
+	r.closeApplied()
+}
+
+// closeApplied closes the current applied index channel. The caller must
+// hold submu.
+func (r *RaftFSM) closeApplied() {
+	if r.appliedc != nil {
+		close(r.appliedc)
+		r.appliedc = nil
+	}
+}
+
+// publish queues the notification for every subscriber. Subscribers that
+// have too many unread notifications are closed.
+func (r *RaftFSM) publish(applied storage.AppliedLog) {
+	r.submu.Lock()
+	defer r.submu.Unlock()
+	for sub := range r.subs {
+		sub.mu.Lock()
+		full := len(sub.queue) >= MaxQueuedNotifications
+		if !full {
+			sub.queue = append(sub.queue, applied)
+		}
+		sub.mu.Unlock()
+		if full {
+			r.log.Warn("Closing slow applied log subscription", "queued", MaxQueuedNotifications)
+			sub.close()
+			delete(r.subs, sub)
+			continue
+		}
+		select {
+		case sub.notify <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fsm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/storage"
+	"github.com/webmeshproj/webmesh/pkg/storage/providers/backends/badgerdb"
+)
+
+func TestSubscribe(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	newFSM := func(t *testing.T) *RaftFSM {
+		st := badgerdb.NewTestStorage(false)
+		t.Cleanup(func() { _ = st.Close() })
+		return New(ctx, st, Options{})
+	}
+	awaitClosed := func(t *testing.T, ch <-chan storage.AppliedLog) {
+		t.Helper()
+		timeout := time.After(time.Second * 5)
+		for {
+			select {
+			case _, ok := <-ch:
+				if !ok {
+					return
+				}
+			case <-timeout:
+				t.Fatal("subscription was not closed")
+			}
+		}
+	}
+
+	t.Run("SlowSubscriber", func(t *testing.T) {
+		t.Parallel()
+		r := newFSM(t)
+		slow := r.Subscribe(ctx)
+		// Take the first notification and stop reading.
+		r.publish(storage.AppliedLog{Index: 1})
+		select {
+		case <-slow:
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out waiting for first notification")
+		}
+		for i := 0; i <= MaxQueuedNotifications+1; i++ {
+			r.publish(storage.AppliedLog{Index: uint64(i + 2)})
+		}
+		r.submu.Lock()
+		subs := len(r.subs)
+		r.submu.Unlock()
+		if subs != 0 {
+			t.Fatalf("expected slow subscriber to be removed, got %d subscribers", subs)
+		}
+		awaitClosed(t, slow)
+	})
+
+	t.Run("CloseSubscriptions", func(t *testing.T) {
+		t.Parallel()
+		r := newFSM(t)
+		subs := []<-chan storage.AppliedLog{r.Subscribe(ctx), r.Subscribe(ctx)}
+		r.CloseSubscriptions()
+		for _, sub := range subs {
+			awaitClosed(t, sub)
+		}
+	})
+}
+
+func TestAppliedIndexChanged(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	st := badgerdb.NewTestStorage(false)
+	t.Cleanup(func() { _ = st.Close() })
+	r := New(ctx, st, Options{})
+	awaitChanged := func(t *testing.T, ch <-chan struct{}) {
+		t.Helper()
+		select {
+		case <-ch:
+		case <-time.After(time.Second * 5):
+			t.Fatal("applied index change was not signaled")
+		}
+	}
+
+	t.Run("NonCommandLog", func(t *testing.T) {
+		changed := r.AppliedIndexChanged()
+		r.Apply(&raft.Log{Index: 1, Term: 1, Type: raft.LogNoop})
+		awaitChanged(t, changed)
+		if index := r.LastAppliedIndex(); index != 1 {
+			t.Fatalf("expected last applied index 1, got %d", index)
+		}
+	})
+
+	t.Run("CloseSubscriptions", func(t *testing.T) {
+		changed := r.AppliedIndexChanged()
+		r.CloseSubscriptions()
+		awaitChanged(t, changed)
+	})
+}
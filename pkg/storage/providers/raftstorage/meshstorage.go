@@ -19,6 +19,7 @@ package raftstorage
 import (
 	"fmt"
 	"log/slog"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -29,11 +30,13 @@ import (
 	"github.com/webmeshproj/webmesh/pkg/context"
 	"github.com/webmeshproj/webmesh/pkg/storage"
 	"github.com/webmeshproj/webmesh/pkg/storage/errors"
+	"github.com/webmeshproj/webmesh/pkg/storage/providers/raftstorage/raftlogs"
 	"github.com/webmeshproj/webmesh/pkg/storage/types"
 )
 
-// Ensure we satisfy the MeshStorage interface.
+// Ensure we satisfy the MeshStorage and TxnStorage interfaces.
 var _ storage.MeshStorage = &RaftStorage{}
+var _ storage.TxnStorage = &RaftStorage{}
 
 // RaftStorage wraps the storage.Storage interface to force write operations through the Raft log.
 type RaftStorage struct {
@@ -77,6 +80,29 @@ func (rs *RaftStorage) IterPrefix(ctx context.Context, prefix []byte, fn storage
 	return rs.storage.IterPrefix(ctx, prefix, fn)
 }
 
+// IterKeys iterates over all keys with a given prefix without fetching their values.
+func (rs *RaftStorage) IterKeys(ctx context.Context, prefix []byte, fn storage.KeyIterator) error {
+	if !rs.raft.started.Load() {
+		return errors.ErrClosed
+	}
+	if iter, ok := rs.storage.(storage.KeyIterStorage); ok {
+		return iter.IterKeys(ctx, prefix, fn)
+	}
+	keys, err := rs.storage.ListKeys(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := fn(key); err != nil {
+			if errors.Is(err, storage.ErrStopIteration) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
 // Subscribe subscribes to changes to a prefix.
 func (rs *RaftStorage) Subscribe(ctx context.Context, prefix []byte, fn storage.KVSubscribeFunc) (context.CancelFunc, error) {
 	if !rs.raft.started.Load() {
@@ -133,6 +159,61 @@ func (rs *RaftStorage) Delete(ctx context.Context, key []byte) error {
 	return rs.sendLogToLeader(ctx, &logEntry)
 }
 
+// Begin starts a new transaction. On commit the buffered writes are sent
+// through the Raft log as a single entry, so they are applied on every node
+// at the same index and ordered against all other writes. ErrNotImplemented
+// is returned until every server in the cluster can apply such entries.
+func (rs *RaftStorage) Begin(ctx context.Context) (storage.Txn, error) {
+	if !rs.raft.started.Load() {
+		return nil, errors.ErrClosed
+	}
+	if err := rs.checkBatchSupport(ctx); err != nil {
+		return nil, err
+	}
+	return storage.NewTxn(rs.commitTxn), nil
+}
+
+// checkBatchSupport returns ErrNotImplemented if not every server in the
+// cluster can apply batch entries.
+func (rs *RaftStorage) checkBatchSupport(ctx context.Context) error {
+	supported, err := rs.raft.batchSupported(ctx)
+	if err != nil {
+		return fmt.Errorf("check batch support: %w", err)
+	}
+	if !supported {
+		return errors.ErrNotImplemented
+	}
+	return nil
+}
+
+func (rs *RaftStorage) commitTxn(ctx context.Context, ops []storage.TxnOp) error {
+	if !rs.raft.started.Load() {
+		return errors.ErrClosed
+	}
+	for _, op := range ops {
+		if !types.IsValidPathID(string(op.Key)) {
+			return fmt.Errorf("%w: %s", errors.ErrInvalidKey, string(op.Key))
+		}
+	}
+	if !rs.raft.isVoter() {
+		return errors.ErrNotVoter
+	}
+	// The cluster may have changed since the transaction began.
+	if err := rs.checkBatchSupport(ctx); err != nil {
+		return err
+	}
+	logEntry, err := raftlogs.NewBatchEntry(ops)
+	if err != nil {
+		return err
+	}
+	if rs.raft.Consensus().IsLeader() {
+		// lock is taken in the FSM
+		return rs.applyLog(ctx, logEntry)
+	}
+	// We need to forward the request to the leader.
+	return rs.sendLogToLeader(ctx, logEntry)
+}
+
 func (rs *RaftStorage) sendLogToLeader(ctx context.Context, logEntry *v1.RaftLogEntry) error {
 	log := context.LoggerFrom(ctx)
 	log.Debug("sending log to leader")
@@ -148,7 +229,7 @@ func (rs *RaftStorage) sendLogToLeader(ctx context.Context, logEntry *v1.RaftLog
 	}
 	log.Debug("applied log entry", slog.String("time", resp.GetTime()))
 	if resp.GetError() != "" {
-		return fmt.Errorf("apply log entry: %s", resp.GetError())
+		return applyResponseError(resp.GetError())
 	}
 	return nil
 }
@@ -171,7 +252,17 @@ func (rs *RaftStorage) applyLog(ctx context.Context, logEntry *v1.RaftLogEntry)
 		return fmt.Errorf("apply log entry: %w", err)
 	}
 	if res.GetError() != "" {
-		return fmt.Errorf("apply log entry data: %s", res.GetError())
+		return applyResponseError(res.GetError())
 	}
 	return nil
 }
+
+// applyResponseError returns the error for a failed apply response. Apply
+// responses only carry the error message, so transaction conflicts are
+// recognized by it and returned as ErrTxnConflict for callers to retry.
+func applyResponseError(msg string) error {
+	if strings.Contains(msg, errors.ErrTxnConflict.Error()) {
+		return fmt.Errorf("apply log entry data: %w", errors.ErrTxnConflict)
+	}
+	return fmt.Errorf("apply log entry data: %s", msg)
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package raftstorage
 
 import (
+	"fmt"
 	"runtime"
 	"time"
 
@@ -45,6 +46,9 @@ const (
 	// DefaultBarrierThreshold is the threshold for sending a barrier after
 	// a write operation.
 	DefaultBarrierThreshold = 10
+	// DefaultTrailingLogs is the default number of logs retained after a
+	// snapshot. This matches the hashicorp/raft default.
+	DefaultTrailingLogs = 10240
 )
 
 // Options are the raft options.
@@ -60,9 +64,10 @@ type Options struct {
 	ClearDataDir bool
 	// InMemory is if the store should be in memory. This should only be used for testing and ephemeral nodes.
 	InMemory bool
-	// ConnectionPoolCount is the number of connections to pool. If 0, no connection pooling is used.
+	// ConnectionPoolCount is the number of connections to pool. If 0, no connection pooling
+	// is used and a new connection is dialed for every RPC to a peer. It must not be negative.
 	ConnectionPoolCount int
-	// ConnectionTimeout is the timeout for connections.
+	// ConnectionTimeout is the timeout for connections. It must be greater than zero.
 	ConnectionTimeout time.Duration
 	// HeartbeatTimeout is the timeout for heartbeats.
 	HeartbeatTimeout time.Duration
@@ -82,6 +87,9 @@ type Options struct {
 	SnapshotThreshold uint64
 	// SnapshotRetention is the number of snapshots to retain.
 	SnapshotRetention uint64
+	// TrailingLogs is the number of logs to retain after a snapshot so
+	// lagging followers can catch up without a full snapshot transfer.
+	TrailingLogs uint64
 	// ObserverChanBuffer is the buffer size for the observer channel.
 	ObserverChanBuffer int
 	// BarrierThreshold is the threshold for sending a barrier after a write operation.
@@ -108,12 +116,30 @@ func NewOptions(nodeID types.NodeID, transport transport.RaftTransport) Options
 		SnapshotThreshold:  5,
 		MaxAppendEntries:   15,
 		SnapshotRetention:  3,
+		TrailingLogs:       DefaultTrailingLogs,
 		ObserverChanBuffer: 100,
 		BarrierThreshold:   DefaultBarrierThreshold,
 		LogLevel:           "info",
 	}
 }
 
+// Validate validates the options.
+func (o *Options) Validate() error {
+	if o.NodeID == "" {
+		return fmt.Errorf("node ID is required")
+	}
+	if o.Transport == nil {
+		return fmt.Errorf("transport is required")
+	}
+	if o.ConnectionPoolCount < 0 {
+		return fmt.Errorf("connection pool count must not be negative")
+	}
+	if o.ConnectionTimeout <= 0 {
+		return fmt.Errorf("connection timeout must be greater than zero")
+	}
+	return nil
+}
+
 // RaftConfig builds a raft config.
 func (o *Options) RaftConfig(ctx context.Context, nodeID string) *raft.Config {
 	config := raft.DefaultConfig()
@@ -140,6 +166,9 @@ func (o *Options) RaftConfig(ctx context.Context, nodeID string) *raft.Config {
 	if o.SnapshotThreshold != 0 {
 		config.SnapshotThreshold = o.SnapshotThreshold
 	}
+	if o.TrailingLogs != 0 {
+		config.TrailingLogs = o.TrailingLogs
+	}
 	if o.BarrierThreshold <= 0 {
 		o.BarrierThreshold = DefaultBarrierThreshold
 	}
@@ -0,0 +1,94 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package raftstorage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+
+	"github.com/webmeshproj/webmesh/pkg/meshnet/transport"
+)
+
+func TestRaftConfigTrailingLogs(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("Default", func(t *testing.T) {
+		opts := NewOptions("node", nil)
+		config := opts.RaftConfig(ctx, "node")
+		if config.TrailingLogs != raft.DefaultConfig().TrailingLogs {
+			t.Fatalf("expected trailing logs %d, got %d", raft.DefaultConfig().TrailingLogs, config.TrailingLogs)
+		}
+	})
+
+	t.Run("Configured", func(t *testing.T) {
+		opts := NewOptions("node", nil)
+		opts.TrailingLogs = 50000
+		config := opts.RaftConfig(ctx, "node")
+		if config.TrailingLogs != 50000 {
+			t.Fatalf("expected trailing logs %d, got %d", 50000, config.TrailingLogs)
+		}
+	})
+
+	t.Run("Unset", func(t *testing.T) {
+		opts := Options{}
+		config := opts.RaftConfig(ctx, "node")
+		if config.TrailingLogs != raft.DefaultConfig().TrailingLogs {
+			t.Fatalf("expected trailing logs %d, got %d", raft.DefaultConfig().TrailingLogs, config.TrailingLogs)
+		}
+	})
+}
+
+// fakeRaftTransport is a raft transport that is only used for validation.
+type fakeRaftTransport struct {
+	transport.RaftTransport
+}
+
+func TestOptionsValidate(t *testing.T) {
+	t.Parallel()
+	tc := []struct {
+		name    string
+		modify  func(*Options)
+		wantErr bool
+	}{
+		{name: "Valid", modify: func(o *Options) {}},
+		{name: "PoolingDisabled", modify: func(o *Options) { o.ConnectionPoolCount = 0 }},
+		{name: "NegativePoolCount", modify: func(o *Options) { o.ConnectionPoolCount = -1 }, wantErr: true},
+		{name: "ZeroConnectionTimeout", modify: func(o *Options) { o.ConnectionTimeout = 0 }, wantErr: true},
+		{name: "NegativeConnectionTimeout", modify: func(o *Options) { o.ConnectionTimeout = -time.Second }, wantErr: true},
+		{name: "NoNodeID", modify: func(o *Options) { o.NodeID = "" }, wantErr: true},
+		{name: "NoTransport", modify: func(o *Options) { o.Transport = nil }, wantErr: true},
+	}
+	for _, tt := range tc {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			opts := newTestOptions(&fakeRaftTransport{})
+			tt.modify(&opts)
+			err := opts.Validate()
+			if tt.wantErr && err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -38,6 +38,7 @@ import (
 	"github.com/webmeshproj/webmesh/pkg/storage/meshdb"
 	"github.com/webmeshproj/webmesh/pkg/storage/providers/backends/badgerdb"
 	"github.com/webmeshproj/webmesh/pkg/storage/providers/raftstorage/fsm"
+	"github.com/webmeshproj/webmesh/pkg/storage/providers/raftstorage/raftlogs"
 )
 
 // Ensure we satisfy the provider interface.
@@ -92,6 +93,7 @@ type Provider struct {
 	nodeID                      raft.ServerID
 	started                     atomic.Bool
 	raft                        *raft.Raft
+	fsm                         *fsm.RaftFSM
 	raftStorage                 *RaftStorage
 	meshDB                      storage.MeshDB
 	consensus                   *Consensus
@@ -99,6 +101,7 @@ type Provider struct {
 	observerChan                chan raft.Observation
 	observerClose, observerDone chan struct{}
 	observerCbs                 []ObservationCallback
+	batchSupportStop            chan struct{}
 	log                         *slog.Logger
 	mu                          sync.RWMutex
 }
@@ -150,6 +153,9 @@ func (r *Provider) Start(ctx context.Context) error {
 	if r.started.Load() {
 		return errors.ErrStarted
 	}
+	if err := r.Options.Validate(); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
 	r.log.Debug("Starting raft storage provider")
 	storage, err := r.createStorage()
 	if err != nil {
@@ -162,11 +168,12 @@ func (r *Provider) Start(ctx context.Context) error {
 		return fmt.Errorf("create snapshot storage: %w", err)
 	}
 	r.log.Debug("Starting raft instance", slog.String("listen-addr", string(r.Options.Transport.LocalAddr())))
+	r.fsm = fsm.New(ctx, storage, fsm.Options{
+		ApplyTimeout: r.Options.ApplyTimeout,
+	})
 	r.raft, err = raft.NewRaft(
 		r.Options.RaftConfig(ctx, string(r.nodeID)),
-		fsm.New(ctx, storage, fsm.Options{
-			ApplyTimeout: r.Options.ApplyTimeout,
-		}),
+		r.fsm,
 		&MonotonicLogStore{storage},
 		storage,
 		snapshots,
@@ -182,6 +189,8 @@ func (r *Provider) Start(ctx context.Context) error {
 	})
 	r.raft.RegisterObserver(r.observer)
 	r.observerClose, r.observerDone = r.observe()
+	r.batchSupportStop = make(chan struct{})
+	go r.advertiseBatchSupport(r.batchSupportStop)
 	// We're done here.
 	r.started.Store(true)
 	return nil
@@ -320,6 +329,8 @@ func (r *Provider) Close() error {
 	defer r.started.Store(false)
 	defer r.raftStorage.Close()
 	defer r.Options.Transport.Close()
+	close(r.batchSupportStop)
+	r.fsm.CloseSubscriptions()
 	// If we were not running in memory, force a snapshot.
 	if !r.Options.InMemory {
 		r.log.Debug("Taking raft storage snapshot")
@@ -342,6 +353,88 @@ func (r *Provider) GetRaftConfiguration() raft.Configuration {
 	return r.raft.GetConfiguration().Configuration()
 }
 
+// Snapshot forces a snapshot of the current state and returns the metadata
+// of the resulting snapshot.
+func (r *Provider) Snapshot(ctx context.Context) (*SnapshotMeta, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.started.Load() {
+		return nil, errors.ErrClosed
+	}
+	f := r.raft.Snapshot()
+	if err := f.Error(); err != nil {
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+	meta, rdr, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("open snapshot: %w", err)
+	}
+	defer rdr.Close()
+	return meta, nil
+}
+
+// LastIndex returns the last index in stable storage, either from the last
+// log entry or from the last snapshot.
+func (r *Provider) LastIndex() (uint64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if !r.started.Load() {
+		return 0, errors.ErrClosed
+	}
+	return r.raft.LastIndex(), nil
+}
+
+// AwaitIndex blocks until the node has applied at least the given index to
+// its state machine or the context is done. This can be used to ensure a
+// follower has caught up with a write made on the leader before reading.
+// Waiters are woken by the FSM as it applies entries. Raft does not hand noop
+// and barrier entries to the FSM, so an index belonging to one of those is
+// only observed once the FSM applies a later entry.
+func (r *Provider) AwaitIndex(ctx context.Context, index uint64) error {
+	for {
+		// Take the channel before checking the index so an apply in between
+		// is not missed.
+		changed := r.fsm.AppliedIndexChanged()
+		applied, err := r.appliedIndex()
+		if err != nil {
+			return err
+		}
+		if applied >= index {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("await index %d (applied %d): %w", index, applied, ctx.Err())
+		case <-changed:
+		}
+	}
+}
+
+// SubscribeApplied returns a channel of notifications for the log entries this
+// node applies to its state machine from now on, in index order. It can be used
+// to observe changes as they are applied, for example to build change data
+// capture. The channel is closed when the context is done, when the subscriber
+// falls too far behind, or when the provider is closed.
+func (r *Provider) SubscribeApplied(ctx context.Context) (<-chan storage.AppliedLog, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if !r.started.Load() {
+		return nil, errors.ErrClosed
+	}
+	return r.fsm.Subscribe(ctx), nil
+}
+
+func (r *Provider) appliedIndex() (uint64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if !r.started.Load() {
+		return 0, errors.ErrClosed
+	}
+	// Raft does not hand noop and barrier entries to the FSM, so its own
+	// applied index may be ahead of the FSM's.
+	return max(r.raft.AppliedIndex(), r.fsm.LastAppliedIndex()), nil
+}
+
 // ApplyRaftLog applies a raft log entry.
 func (r *Provider) ApplyRaftLog(ctx context.Context, log *v1.RaftLogEntry) (*v1.RaftApplyResponse, error) {
 	r.mu.Lock()
@@ -352,6 +445,15 @@ func (r *Provider) ApplyRaftLog(ctx context.Context, log *v1.RaftLogEntry) (*v1.
 	if !r.Consensus().IsLeader() {
 		return nil, errors.ErrNotLeader
 	}
+	if log.GetType() == raftlogs.RaftCommandTypeBatch {
+		supported, err := r.batchSupported(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("check batch support: %w", err)
+		}
+		if !supported {
+			return nil, fmt.Errorf("apply batch: %w", errors.ErrNotImplemented)
+		}
+	}
 	var timeout time.Duration
 	if deadline, ok := ctx.Deadline(); ok {
 		timeout = time.Until(deadline)
@@ -17,15 +17,19 @@ limitations under the License.
 package raftstorage
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	v1 "github.com/webmeshproj/api/go/v1"
 
 	"github.com/webmeshproj/webmesh/pkg/context"
 	"github.com/webmeshproj/webmesh/pkg/meshnet/transport"
 	"github.com/webmeshproj/webmesh/pkg/meshnet/transport/tcp"
 	"github.com/webmeshproj/webmesh/pkg/storage"
+	"github.com/webmeshproj/webmesh/pkg/storage/errors"
+	"github.com/webmeshproj/webmesh/pkg/storage/providers/raftstorage/raftlogs"
 	"github.com/webmeshproj/webmesh/pkg/storage/testutil"
 	"github.com/webmeshproj/webmesh/pkg/storage/types"
 )
@@ -74,3 +78,446 @@ func newTestOptions(transport transport.RaftTransport) Options {
 		LogLevel:           "",
 	}
 }
+
+func TestAwaitIndex(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	provider := (&builder{}).newProviders(t, 1)[0].(*Provider)
+	testutil.MustStartProvider(ctx, t, provider)
+	t.Cleanup(func() { _ = provider.Close() })
+	testutil.MustBootstrapProvider(ctx, t, provider)
+	ok := testutil.Eventually[bool](func() bool {
+		return provider.Consensus().IsLeader()
+	}).ShouldEqual(time.Second*10, time.Millisecond*100, true)
+	if !ok {
+		t.Fatal("provider never became leader")
+	}
+
+	t.Run("AfterWrite", func(t *testing.T) {
+		err := provider.MeshStorage().PutValue(ctx, []byte("/test/await-index"), []byte("value"), 0)
+		if err != nil {
+			t.Fatalf("failed to put value: %v", err)
+		}
+		index, err := provider.LastIndex()
+		if err != nil {
+			t.Fatalf("failed to get last index: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+		defer cancel()
+		if err := provider.AwaitIndex(ctx, index); err != nil {
+			t.Fatalf("failed to await index %d: %v", index, err)
+		}
+	})
+
+	t.Run("FutureIndex", func(t *testing.T) {
+		index, err := provider.LastIndex()
+		if err != nil {
+			t.Fatalf("failed to get last index: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(ctx, time.Millisecond*200)
+		defer cancel()
+		err = provider.AwaitIndex(ctx, index+100)
+		if ctx.Err() == nil || !errors.Is(err, ctx.Err()) {
+			t.Fatalf("expected deadline exceeded, got %v", err)
+		}
+	})
+}
+
+func TestAppliedIndex(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	provider := (&builder{}).newProviders(t, 1)[0].(*Provider)
+	if index, term := provider.Consensus().AppliedIndex(); index != 0 || term != 0 {
+		t.Fatalf("expected zero applied index and term before start, got %d/%d", index, term)
+	}
+	testutil.MustStartProvider(ctx, t, provider)
+	t.Cleanup(func() { _ = provider.Close() })
+	testutil.MustBootstrapProvider(ctx, t, provider)
+	ok := testutil.Eventually[bool](func() bool {
+		return provider.Consensus().IsLeader()
+	}).ShouldEqual(time.Second*10, time.Millisecond*100, true)
+	if !ok {
+		t.Fatal("provider never became leader")
+	}
+	before, _ := provider.Consensus().AppliedIndex()
+	for i := 0; i < 3; i++ {
+		err := provider.MeshStorage().PutValue(ctx, []byte(fmt.Sprintf("/test/applied-index/%d", i)), []byte("value"), 0)
+		if err != nil {
+			t.Fatalf("failed to put value: %v", err)
+		}
+	}
+	after, term := provider.Consensus().AppliedIndex()
+	if after < before+3 {
+		t.Fatalf("expected applied index to advance by at least 3 from %d, got %d", before, after)
+	}
+	if term == 0 {
+		t.Fatal("expected a non-zero applied term")
+	}
+}
+
+func TestSubscribeApplied(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	provider := (&builder{}).newProviders(t, 1)[0].(*Provider)
+	if _, err := provider.SubscribeApplied(ctx); !errors.Is(err, errors.ErrClosed) {
+		t.Fatalf("expected ErrClosed before start, got %v", err)
+	}
+	testutil.MustStartProvider(ctx, t, provider)
+	t.Cleanup(func() { _ = provider.Close() })
+	testutil.MustBootstrapProvider(ctx, t, provider)
+	ok := testutil.Eventually[bool](func() bool {
+		return provider.Consensus().IsLeader()
+	}).ShouldEqual(time.Second*10, time.Millisecond*100, true)
+	if !ok {
+		t.Fatal("provider never became leader")
+	}
+	// Let the node advertise batch support first so it is not seen below.
+	mustAwaitBatchSupport(t, provider)
+	subctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	applied, err := provider.SubscribeApplied(subctx)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	st := provider.MeshStorage()
+	want := []struct {
+		typ v1.RaftCommandType
+		key string
+	}{
+		{v1.RaftCommandType_PUT, "/test/subscribe-applied/0"},
+		{v1.RaftCommandType_PUT, "/test/subscribe-applied/1"},
+		{v1.RaftCommandType_PUT, "/test/subscribe-applied/2"},
+		{v1.RaftCommandType_DELETE, "/test/subscribe-applied/1"},
+	}
+	for _, w := range want {
+		switch w.typ {
+		case v1.RaftCommandType_PUT:
+			err = st.PutValue(ctx, []byte(w.key), []byte("value"), 0)
+		case v1.RaftCommandType_DELETE:
+			err = st.Delete(ctx, []byte(w.key))
+		}
+		if err != nil {
+			t.Fatalf("failed to apply %s %s: %v", w.typ, w.key, err)
+		}
+	}
+	var last uint64
+	for _, w := range want {
+		select {
+		case got, ok := <-applied:
+			if !ok {
+				t.Fatal("notification channel closed early")
+			}
+			if got.Index <= last {
+				t.Fatalf("expected index greater than %d, got %d", last, got.Index)
+			}
+			last = got.Index
+			if got.Type != w.typ || string(got.Key) != w.key {
+				t.Fatalf("expected %s %s, got %s %s", w.typ, w.key, got.Type, got.Key)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("timed out waiting for %s %s", w.typ, w.key)
+		}
+	}
+	cancel()
+	select {
+	case _, ok := <-applied:
+		if ok {
+			t.Fatal("expected no further notifications after cancel")
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("notification channel was not closed after cancel")
+	}
+	// Closing the provider closes any remaining subscriptions.
+	applied, err = provider.SubscribeApplied(ctx)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	if err := provider.Close(); err != nil {
+		t.Fatalf("failed to close provider: %v", err)
+	}
+	select {
+	case _, ok := <-applied:
+		if ok {
+			t.Fatal("expected no further notifications after close")
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("notification channel was not closed when the provider was closed")
+	}
+}
+
+func TestTransferLeadership(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	providers := (&builder{}).newProviders(t, 3)
+	for _, provider := range providers {
+		testutil.MustStartProvider(ctx, t, provider)
+		t.Cleanup(func() { _ = provider.Close() })
+	}
+	leader, voter, observer := providers[0], providers[1], providers[2]
+	testutil.MustBootstrapProvider(ctx, t, leader)
+	ok := testutil.Eventually[bool](func() bool {
+		return leader.Consensus().IsLeader()
+	}).ShouldEqual(time.Second*10, time.Millisecond*100, true)
+	if !ok {
+		t.Fatal("provider never became leader")
+	}
+	voterID := voter.Status().GetPeers()[0].GetId()
+	observerID := observer.Status().GetPeers()[0].GetId()
+	testutil.MustAddVoter(ctx, t, leader, voter)
+	testutil.MustAddObserver(ctx, t, leader, observer)
+
+	t.Run("NotVoter", func(t *testing.T) {
+		err := leader.Consensus().TransferLeadership(ctx, observerID)
+		if !errors.Is(err, errors.ErrNotVoter) {
+			t.Fatalf("expected not voter error, got %v", err)
+		}
+	})
+
+	t.Run("UnknownNode", func(t *testing.T) {
+		err := leader.Consensus().TransferLeadership(ctx, "unknown-node")
+		if !errors.Is(err, errors.ErrNodeNotFound) {
+			t.Fatalf("expected node not found error, got %v", err)
+		}
+	})
+
+	t.Run("NotLeader", func(t *testing.T) {
+		err := voter.Consensus().TransferLeadership(ctx, voterID)
+		if !errors.Is(err, errors.ErrNotLeader) {
+			t.Fatalf("expected not leader error, got %v", err)
+		}
+	})
+
+	t.Run("ToVoter", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+		defer cancel()
+		err := leader.Consensus().TransferLeadership(ctx, voterID)
+		if err != nil {
+			t.Fatalf("failed to transfer leadership: %v", err)
+		}
+		ok := testutil.Eventually[bool](func() bool {
+			return voter.Consensus().IsLeader()
+		}).ShouldEqual(time.Second*10, time.Millisecond*100, true)
+		if !ok {
+			t.Fatal("target never became leader")
+		}
+		if leader.Consensus().IsLeader() {
+			t.Fatal("expected previous leader to have stepped down")
+		}
+	})
+}
+
+func TestTransaction(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	provider := (&builder{}).newProviders(t, 1)[0].(*Provider)
+	testutil.MustStartProvider(ctx, t, provider)
+	t.Cleanup(func() { _ = provider.Close() })
+	testutil.MustBootstrapProvider(ctx, t, provider)
+	ok := testutil.Eventually[bool](func() bool {
+		return provider.Consensus().IsLeader()
+	}).ShouldEqual(time.Second*10, time.Millisecond*100, true)
+	if !ok {
+		t.Fatal("provider never became leader")
+	}
+	mustAwaitBatchSupport(t, provider)
+	st := provider.MeshStorage()
+
+	t.Run("Commit", func(t *testing.T) {
+		prefix := []byte("/test/txn/commit/")
+		key := func(s string) []byte { return append(append([]byte(nil), prefix...), s...) }
+		if err := st.PutValue(ctx, key("c"), []byte("old"), 0); err != nil {
+			t.Fatalf("failed to put value: %v", err)
+		}
+		snapshot := func() (map[string]string, error) {
+			kv := make(map[string]string)
+			err := st.IterPrefix(ctx, prefix, func(k, v []byte) error {
+				kv[string(k[len(prefix):])] = string(v)
+				return nil
+			})
+			return kv, err
+		}
+		// Read the prefix in a loop while the transaction commits. Every read
+		// must observe either none or all of its writes.
+		before := fmt.Sprint(map[string]string{"c": "old"})
+		after := fmt.Sprint(map[string]string{"a": "a2", "b": "b"})
+		stop := make(chan struct{})
+		errs := make(chan error, 1)
+		go func() {
+			defer close(errs)
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				kv, err := snapshot()
+				if err != nil {
+					errs <- err
+					return
+				}
+				if got := fmt.Sprint(kv); got != before && got != after {
+					errs <- fmt.Errorf("observed partial transaction: %s", got)
+					return
+				}
+			}
+		}()
+		txn, err := storage.Begin(ctx, st)
+		if err != nil {
+			t.Fatalf("failed to begin transaction: %v", err)
+		}
+		for _, err := range []error{
+			txn.PutValue(key("a"), []byte("a1"), 0),
+			txn.PutValue(key("b"), []byte("b"), 0),
+			txn.Delete(key("c")),
+			txn.PutValue(key("a"), []byte("a2"), 0),
+		} {
+			if err != nil {
+				t.Fatalf("failed to buffer write: %v", err)
+			}
+		}
+		if _, err := st.GetValue(ctx, key("a")); !errors.IsKeyNotFound(err) {
+			t.Fatalf("expected buffered write to be invisible before commit, got %v", err)
+		}
+		if err := txn.Commit(ctx); err != nil {
+			t.Fatalf("failed to commit transaction: %v", err)
+		}
+		close(stop)
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+		kv, err := snapshot()
+		if err != nil {
+			t.Fatalf("failed to read prefix: %v", err)
+		}
+		if got := fmt.Sprint(kv); got != after {
+			t.Fatalf("expected %s after commit, got %s", after, got)
+		}
+		if err := txn.Commit(ctx); !errors.Is(err, errors.ErrTxnDone) {
+			t.Fatalf("expected ErrTxnDone committing twice, got %v", err)
+		}
+	})
+
+	t.Run("Check", func(t *testing.T) {
+		key := []byte("/test/txn/check")
+		commit := func(expected []byte) error {
+			txn, err := storage.Begin(ctx, st)
+			if err != nil {
+				t.Fatalf("failed to begin transaction: %v", err)
+			}
+			if err := txn.Check(key, expected); err != nil {
+				t.Fatalf("failed to buffer check: %v", err)
+			}
+			if err := txn.PutValue([]byte("/test/txn/check-write"), expected, 0); err != nil {
+				t.Fatalf("failed to buffer write: %v", err)
+			}
+			return txn.Commit(ctx)
+		}
+		if err := commit([]byte("v1")); !errors.Is(err, errors.ErrTxnConflict) {
+			t.Fatalf("expected ErrTxnConflict checking a missing key, got %v", err)
+		}
+		if _, err := st.GetValue(ctx, []byte("/test/txn/check-write")); !errors.IsKeyNotFound(err) {
+			t.Fatalf("expected conflicting transaction not to write, got %v", err)
+		}
+		if err := commit(nil); err != nil {
+			t.Fatalf("expected check for a missing key to pass, got %v", err)
+		}
+		if err := st.PutValue(ctx, key, []byte("v1"), 0); err != nil {
+			t.Fatalf("failed to put value: %v", err)
+		}
+		if err := commit(nil); !errors.Is(err, errors.ErrTxnConflict) {
+			t.Fatalf("expected ErrTxnConflict checking that an existing key is absent, got %v", err)
+		}
+		if err := commit([]byte("v1")); err != nil {
+			t.Fatalf("expected check for the current value to pass, got %v", err)
+		}
+		value, err := st.GetValue(ctx, []byte("/test/txn/check-write"))
+		if err != nil || string(value) != "v1" {
+			t.Fatalf("expected write guarded by a passing check, got %q: %v", value, err)
+		}
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		txn, err := storage.Begin(ctx, st)
+		if err != nil {
+			t.Fatalf("failed to begin transaction: %v", err)
+		}
+		if err := txn.PutValue([]byte("/test/txn/rollback"), []byte("value"), 0); err != nil {
+			t.Fatalf("failed to buffer write: %v", err)
+		}
+		if err := txn.Rollback(); err != nil {
+			t.Fatalf("failed to roll back transaction: %v", err)
+		}
+		if err := txn.Commit(ctx); !errors.Is(err, errors.ErrTxnDone) {
+			t.Fatalf("expected ErrTxnDone committing after rollback, got %v", err)
+		}
+		if _, err := st.GetValue(ctx, []byte("/test/txn/rollback")); !errors.IsKeyNotFound(err) {
+			t.Fatalf("expected rolled back write to be discarded, got %v", err)
+		}
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		txn, err := storage.Begin(ctx, st)
+		if err != nil {
+			t.Fatalf("failed to begin transaction: %v", err)
+		}
+		if err := txn.PutValue([]byte("/test/txn/valid"), []byte("value"), 0); err != nil {
+			t.Fatalf("failed to buffer write: %v", err)
+		}
+		if err := txn.Delete([]byte("invalid key")); err != nil {
+			t.Fatalf("failed to buffer write: %v", err)
+		}
+		if err := txn.Commit(ctx); !errors.Is(err, errors.ErrInvalidKey) {
+			t.Fatalf("expected ErrInvalidKey, got %v", err)
+		}
+		if _, err := st.GetValue(ctx, []byte("/test/txn/valid")); !errors.IsKeyNotFound(err) {
+			t.Fatalf("expected no writes from a failed transaction, got %v", err)
+		}
+	})
+}
+
+func TestBatchSupport(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	provider := (&builder{}).newProviders(t, 1)[0].(*Provider)
+	testutil.MustStartProvider(ctx, t, provider)
+	t.Cleanup(func() { _ = provider.Close() })
+	testutil.MustBootstrapProvider(ctx, t, provider)
+	ok := testutil.Eventually[bool](func() bool {
+		return provider.Consensus().IsLeader()
+	}).ShouldEqual(time.Second*10, time.Millisecond*100, true)
+	if !ok {
+		t.Fatal("provider never became leader")
+	}
+	mustAwaitBatchSupport(t, provider)
+	// Removing the advertisement stands in for a server that predates batches.
+	st := provider.MeshStorage()
+	if err := st.Delete(ctx, raftlogs.BatchSupportKey(provider.Options.NodeID.String())); err != nil {
+		t.Fatalf("failed to delete batch support key: %v", err)
+	}
+	if _, err := storage.Begin(ctx, st); !errors.Is(err, errors.ErrNotImplemented) {
+		t.Fatalf("expected ErrNotImplemented from Begin, got %v", err)
+	}
+	entry, err := raftlogs.NewBatchEntry([]storage.TxnOp{{Type: storage.TxnOpPut, Key: []byte("/test/batch-support"), Value: []byte("value")}})
+	if err != nil {
+		t.Fatalf("failed to create batch entry: %v", err)
+	}
+	if _, err := provider.ApplyRaftLog(ctx, entry); !errors.Is(err, errors.ErrNotImplemented) {
+		t.Fatalf("expected ErrNotImplemented applying a batch, got %v", err)
+	}
+	if _, err := st.GetValue(ctx, []byte("/test/batch-support")); !errors.IsKeyNotFound(err) {
+		t.Fatalf("expected the batch not to be applied, got %v", err)
+	}
+}
+
+// mustAwaitBatchSupport waits until every server in the provider's cluster
+// has advertised batch support, so transactions can be used.
+func mustAwaitBatchSupport(t *testing.T, provider *Provider) {
+	t.Helper()
+	ok := testutil.Eventually[bool](func() bool {
+		supported, err := provider.batchSupported(context.Background())
+		return err == nil && supported
+	}).ShouldEqual(time.Second*10, time.Millisecond*100, true)
+	if !ok {
+		t.Fatal("cluster never advertised batch support")
+	}
+}
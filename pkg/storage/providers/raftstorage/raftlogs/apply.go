@@ -58,6 +58,15 @@ func Apply(ctx context.Context, db storage.MeshStorage, logEntry *v1.RaftLogEntr
 		}
 		res.Time = time.Since(start).String()
 		return res
+	case RaftCommandTypeBatch:
+		log.Debug("Applying batch", slog.Int("size", len(logEntry.GetValue())))
+		err := applyBatch(ctx, db, logEntry)
+		res := &v1.RaftApplyResponse{}
+		if err != nil {
+			res.Error = err.Error()
+		}
+		res.Time = time.Since(start).String()
+		return res
 	default:
 		return &v1.RaftApplyResponse{
 			Error: fmt.Sprintf("unknown command type: %v", logEntry.GetType()),
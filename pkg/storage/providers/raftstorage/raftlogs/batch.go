@@ -0,0 +1,184 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package raftlogs
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/webmeshproj/webmesh/pkg/context"
+	"github.com/webmeshproj/webmesh/pkg/storage"
+)
+
+// RaftCommandTypeBatch is the command type for a log entry that carries a
+// transaction. The entry's value holds the operations of the transaction in
+// order, each encoded as a one byte operation tag followed by a log entry with
+// its key and value, prefixed with its length as a uvarint. The whole batch is
+// applied as one log entry. The type is not part of the API enum and is kept
+// well above its values to stay clear of future additions.
+//
+// Nodes that predate batches fail to apply the entry, so a batch must only be
+// proposed once every server in the raft configuration has written its
+// BatchSupportKey.
+const RaftCommandTypeBatch v1.RaftCommandType = 1 << 24
+
+// Operation tags in a batch entry.
+const (
+	batchOpPut         byte = 'p'
+	batchOpDelete      byte = 'd'
+	batchOpCheck       byte = 'c'
+	batchOpCheckAbsent byte = 'a'
+)
+
+// BatchSupportPrefix is the prefix of the keys nodes write to advertise that
+// they can apply batch entries.
+const BatchSupportPrefix = "/registry/raft-batch-support/"
+
+// BatchSupportKey returns the key the given node writes to advertise that it
+// can apply batch entries.
+func BatchSupportKey(nodeID string) []byte {
+	return []byte(BatchSupportPrefix + nodeID)
+}
+
+// NewBatchSupportEntry returns the log entry the given node applies to
+// advertise that it can apply batch entries.
+func NewBatchSupportEntry(nodeID string) *v1.RaftLogEntry {
+	return &v1.RaftLogEntry{
+		Type:  v1.RaftCommandType_PUT,
+		Key:   BatchSupportKey(nodeID),
+		Value: []byte("true"),
+	}
+}
+
+// IsBatchSupportEntry reports whether the log entry only advertises that the
+// given node can apply batch entries.
+func IsBatchSupportEntry(logEntry *v1.RaftLogEntry, nodeID string) bool {
+	return logEntry.GetType() == v1.RaftCommandType_PUT &&
+		string(logEntry.GetKey()) == string(BatchSupportKey(nodeID)) &&
+		logEntry.GetTtl().AsDuration() == 0
+}
+
+// NewBatchEntry encodes the given transaction operations into a single log entry.
+func NewBatchEntry(ops []storage.TxnOp) (*v1.RaftLogEntry, error) {
+	var value []byte
+	for _, op := range ops {
+		entry := &v1.RaftLogEntry{Key: op.Key}
+		var tag byte
+		switch op.Type {
+		case storage.TxnOpPut:
+			tag = batchOpPut
+			entry.Value = op.Value
+			entry.Ttl = durationpb.New(op.TTL)
+		case storage.TxnOpDelete:
+			tag = batchOpDelete
+		case storage.TxnOpCheck:
+			tag = batchOpCheck
+			entry.Value = op.Value
+		case storage.TxnOpCheckAbsent:
+			tag = batchOpCheckAbsent
+		default:
+			return nil, fmt.Errorf("unknown transaction op type: %d", op.Type)
+		}
+		data, err := proto.Marshal(entry)
+		if err != nil {
+			return nil, fmt.Errorf("marshal batch entry: %w", err)
+		}
+		value = append(value, tag)
+		value = binary.AppendUvarint(value, uint64(len(data)))
+		value = append(value, data...)
+	}
+	return &v1.RaftLogEntry{
+		Type:  RaftCommandTypeBatch,
+		Value: value,
+	}, nil
+}
+
+// DecodeBatchEntry decodes the transaction operations from a batch log entry.
+func DecodeBatchEntry(logEntry *v1.RaftLogEntry) ([]storage.TxnOp, error) {
+	if logEntry.GetType() != RaftCommandTypeBatch {
+		return nil, fmt.Errorf("not a batch entry: %v", logEntry.GetType())
+	}
+	var ops []storage.TxnOp
+	data := logEntry.GetValue()
+	for len(data) > 0 {
+		tag := data[0]
+		data = data[1:]
+		size, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < size {
+			return nil, fmt.Errorf("decode batch entry: truncated data")
+		}
+		var entry v1.RaftLogEntry
+		if err := proto.Unmarshal(data[n:n+int(size)], &entry); err != nil {
+			return nil, fmt.Errorf("unmarshal batch entry: %w", err)
+		}
+		data = data[n+int(size):]
+		op := storage.TxnOp{Key: entry.GetKey()}
+		switch tag {
+		case batchOpPut:
+			op.Type = storage.TxnOpPut
+			op.Value = entry.GetValue()
+			op.TTL = entry.GetTtl().AsDuration()
+		case batchOpDelete:
+			op.Type = storage.TxnOpDelete
+		case batchOpCheck:
+			op.Type = storage.TxnOpCheck
+			// An empty expected value is not the same as an absent key.
+			op.Value = append([]byte{}, entry.GetValue()...)
+		case batchOpCheckAbsent:
+			op.Type = storage.TxnOpCheckAbsent
+		default:
+			return nil, fmt.Errorf("unknown operation in batch: %q", tag)
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// applyBatch applies the operations of a batch entry in a single transaction.
+// Storage without transaction support cannot apply a batch without exposing
+// partial writes, so an error is returned for it instead.
+func applyBatch(ctx context.Context, db storage.MeshStorage, logEntry *v1.RaftLogEntry) error {
+	ops, err := DecodeBatchEntry(logEntry)
+	if err != nil {
+		return err
+	}
+	txn, err := storage.Begin(ctx, db)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	for _, op := range ops {
+		switch op.Type {
+		case storage.TxnOpPut:
+			err = txn.PutValue(op.Key, op.Value, op.TTL)
+		case storage.TxnOpDelete:
+			err = txn.Delete(op.Key)
+		case storage.TxnOpCheck:
+			err = txn.Check(op.Key, op.Value)
+		case storage.TxnOpCheckAbsent:
+			err = txn.Check(op.Key, nil)
+		}
+		if err != nil {
+			_ = txn.Rollback()
+			return err
+		}
+	}
+	return txn.Commit(ctx)
+}
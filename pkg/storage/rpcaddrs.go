@@ -0,0 +1,240 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+// ListPublicRPCAddresses returns the public gRPC addresses of all nodes in the
+// mesh that expose one. The map key is the node ID. Nodes whose primary
+// endpoint is not an IP address are omitted, see ListPublicRPCEndpoints for
+// a variant that includes them.
+func ListPublicRPCAddresses(ctx context.Context, peers Peers) (map[string]netip.AddrPort, error) {
+	return ListFeatureEndpoints(ctx, peers, v1.Feature_NODES)
+}
+
+// ListFeatureEndpoints returns the public endpoint for the given feature of
+// every node in the mesh that advertises it. The map key is the node ID. Nodes
+// whose primary endpoint is not an IP address are omitted. It is the shared
+// implementation of Peers.ListEndpoints.
+func ListFeatureEndpoints(ctx context.Context, peers Peers, feature v1.Feature) (map[string]netip.AddrPort, error) {
+	nodes, err := peers.ListByFeature(ctx, feature)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]netip.AddrPort, len(nodes))
+	for _, node := range (PeerFilters{FilterByIsPublic()}).Filter(nodes) {
+		if addr := node.PublicAddrFor(feature); addr.IsValid() {
+			out[node.GetId()] = addr
+		}
+	}
+	return out, nil
+}
+
+// maxRPCHealthChecks is the maximum number of concurrent dials performed
+// by ListHealthyPublicRPCAddresses.
+const maxRPCHealthChecks = 16
+
+// ListHealthyPublicRPCAddresses is like ListPublicRPCAddresses but only returns
+// addresses that accept a TCP connection within the given timeout. Addresses
+// are dialed in parallel with bounded concurrency.
+func ListHealthyPublicRPCAddresses(ctx context.Context, peers Peers, timeout time.Duration) (map[string]netip.AddrPort, error) {
+	addrs, err := ListPublicRPCAddresses(ctx, peers)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]netip.AddrPort, len(addrs))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxRPCHealthChecks)
+	for id, addr := range addrs {
+		wg.Add(1)
+		go func(id string, addr netip.AddrPort) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+			dialCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			var d net.Dialer
+			conn, err := d.DialContext(dialCtx, "tcp", addr.String())
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+			mu.Lock()
+			out[id] = addr
+			mu.Unlock()
+		}(id, addr)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListPublicRPCEndpoints returns the public gRPC endpoints of all nodes in the
+// mesh as host:port strings suitable for dialing. Unlike ListPublicRPCAddresses,
+// nodes that advertise a DNS name as their primary endpoint are included.
+// The map key is the node ID.
+func ListPublicRPCEndpoints(ctx context.Context, peers Peers) (map[string]string, error) {
+	nodes, err := listPublicRPCNodes(ctx, peers)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(nodes))
+	for _, node := range nodes {
+		port := node.RPCPort()
+		if port == 0 {
+			continue
+		}
+		if addr := node.PublicRPCAddr(); addr.IsValid() {
+			out[node.GetId()] = addr.String()
+			continue
+		}
+		out[node.GetId()] = net.JoinHostPort(node.GetPrimaryEndpoint(), strconv.Itoa(int(port)))
+	}
+	return out, nil
+}
+
+// ListPeerPrivateRPCAddresses returns the private gRPC addresses of all nodes
+// in the mesh that expose one, excluding the node with the given ID. The IPv4
+// mesh address of a node is preferred, falling back to its IPv6 address. The
+// map key is the node ID.
+func ListPeerPrivateRPCAddresses(ctx context.Context, peers Peers, nodeID types.NodeID) (map[string]netip.AddrPort, error) {
+	addrs, err := ListAllPrivateRPCAddresses(ctx, peers)
+	if err != nil {
+		return nil, err
+	}
+	delete(addrs, nodeID.String())
+	return addrs, nil
+}
+
+// ListAllPrivateRPCAddresses is like ListPeerPrivateRPCAddresses but does not
+// exclude any node. It is useful for diagnostics that want the full set of
+// addresses including the querying node.
+func ListAllPrivateRPCAddresses(ctx context.Context, peers Peers) (map[string]netip.AddrPort, error) {
+	nodes, err := peers.ListByFeature(ctx, v1.Feature_NODES)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]netip.AddrPort, len(nodes))
+	for _, node := range nodes {
+		if addr := privateRPCAddr(node); addr.IsValid() {
+			out[node.GetId()] = addr
+		}
+	}
+	return out, nil
+}
+
+// privateRPCAddr returns the private gRPC address of the node, preferring
+// IPv4 and falling back to IPv6. The returned address is invalid if the node
+// has neither.
+func privateRPCAddr(node types.MeshNode) netip.AddrPort {
+	if addr := node.PrivateRPCAddrV4(); addr.IsValid() {
+		return addr
+	}
+	return node.PrivateRPCAddrV6()
+}
+
+// ErrNoRPCAddress is returned by DialTarget when a node advertises no usable
+// gRPC address.
+var ErrNoRPCAddress = errors.New("node has no usable rpc address")
+
+// DialTarget returns a host:port string for dialing the gRPC API of the given
+// node. When preferPrivate is true the node's mesh addresses are tried before
+// its public endpoint, otherwise the public endpoint is tried first. IPv4 mesh
+// addresses are preferred over IPv6. ErrNoRPCAddress is returned if the node
+// does not expose the gRPC API or has no address to reach it on.
+func DialTarget(node types.MeshNode, preferPrivate bool) (string, error) {
+	port := node.RPCPort()
+	if port == 0 {
+		return "", fmt.Errorf("%w: %s does not expose the rpc api", ErrNoRPCAddress, node.GetId())
+	}
+	var public string
+	if addr := node.PublicRPCAddr(); addr.IsValid() {
+		public = addr.String()
+	} else if node.GetPrimaryEndpoint() != "" {
+		public = net.JoinHostPort(node.GetPrimaryEndpoint(), strconv.Itoa(int(port)))
+	}
+	var private string
+	if addr := privateRPCAddr(node); addr.IsValid() {
+		private = addr.String()
+	}
+	candidates := []string{public, private}
+	if preferPrivate {
+		candidates = []string{private, public}
+	}
+	for _, target := range candidates {
+		if target != "" {
+			return target, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrNoRPCAddress, node.GetId())
+}
+
+// RPCAddrWithPort returns the gRPC address of the node with the given port in
+// place of the one it advertises. It is useful for tests and topologies where
+// the API is reached on a different port, so the node does not need to expose
+// the gRPC API itself. When private is true the node's IPv4 mesh address is
+// returned, falling back to its IPv6 mesh address, otherwise its primary
+// endpoint is used. ErrNoRPCAddress is returned if the node has no such IP
+// address.
+func RPCAddrWithPort(node types.MeshNode, port int, private bool) (netip.AddrPort, error) {
+	if port <= 0 || port > 65535 {
+		return netip.AddrPort{}, fmt.Errorf("invalid rpc port %d for %s", port, node.GetId())
+	}
+	if private {
+		if addr := node.PrivateAddrV4(); addr.IsValid() {
+			return netip.AddrPortFrom(addr.Addr(), uint16(port)), nil
+		}
+		if addr := node.PrivateAddrV6(); addr.IsValid() {
+			return netip.AddrPortFrom(addr.Addr(), uint16(port)), nil
+		}
+		return netip.AddrPort{}, fmt.Errorf("%w: %s has no private address", ErrNoRPCAddress, node.GetId())
+	}
+	addr, err := netip.ParseAddr(node.GetPrimaryEndpoint())
+	if err != nil {
+		return netip.AddrPort{}, fmt.Errorf("%w: %s has no public ip address", ErrNoRPCAddress, node.GetId())
+	}
+	return netip.AddrPortFrom(addr.Unmap(), uint16(port)), nil
+}
+
+// listPublicRPCNodes returns the public nodes that expose the gRPC API.
+func listPublicRPCNodes(ctx context.Context, peers Peers) ([]types.MeshNode, error) {
+	nodes, err := peers.ListByFeature(ctx, v1.Feature_NODES)
+	if err != nil {
+		return nil, err
+	}
+	return PeerFilters{FilterByIsPublic()}.Filter(nodes), nil
+}
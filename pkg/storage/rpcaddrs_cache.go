@@ -0,0 +1,115 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"maps"
+	"net/netip"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+// DefaultPublicRPCAddressCacheTTL is the default TTL of a PublicRPCAddressCache.
+const DefaultPublicRPCAddressCacheTTL = 2 * time.Second
+
+// PublicRPCAddressScanTimeout is the timeout of a scan shared by the callers
+// of a PublicRPCAddressCache.
+const PublicRPCAddressScanTimeout = 10 * time.Second
+
+// PublicRPCAddressCache caches the results of ListPublicRPCAddresses for a short
+// TTL. It is intended for hot paths where scanning all public nodes on every call
+// is too expensive. Concurrent callers on a cache miss share a single scan. The
+// scan runs on a context detached from the callers' with its own timeout, so a
+// caller giving up does not fail the scan for the others.
+type PublicRPCAddressCache struct {
+	peers   Peers
+	ttl     time.Duration
+	group   singleflight.Group
+	mu      sync.Mutex
+	addrs   map[string]netip.AddrPort
+	expires time.Time
+	gen     uint64
+}
+
+// NewPublicRPCAddressCache returns a new cache over the given peers. If ttl is
+// less than or equal to zero, DefaultPublicRPCAddressCacheTTL is used.
+func NewPublicRPCAddressCache(peers Peers, ttl time.Duration) *PublicRPCAddressCache {
+	if ttl <= 0 {
+		ttl = DefaultPublicRPCAddressCacheTTL
+	}
+	return &PublicRPCAddressCache{peers: peers, ttl: ttl}
+}
+
+// List returns the public gRPC addresses of all nodes in the mesh, as returned
+// by ListPublicRPCAddresses. Results are served from the cache until they expire.
+// The returned map is a copy and may be modified by the caller.
+func (c *PublicRPCAddressCache) List(ctx context.Context) (map[string]netip.AddrPort, error) {
+	c.mu.Lock()
+	if c.addrs != nil && time.Now().Before(c.expires) {
+		defer c.mu.Unlock()
+		return maps.Clone(c.addrs), nil
+	}
+	gen := c.gen
+	c.mu.Unlock()
+	ch := c.group.DoChan("list", func() (any, error) {
+		scanctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), PublicRPCAddressScanTimeout)
+		defer cancel()
+		addrs, err := ListPublicRPCAddresses(scanctx, c.peers)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		// Don't cache the results if we were invalidated during the scan.
+		if c.gen == gen {
+			c.addrs = addrs
+			c.expires = time.Now().Add(c.ttl)
+		}
+		return addrs, nil
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return maps.Clone(res.Val.(map[string]netip.AddrPort)), nil
+	}
+}
+
+// Invalidate clears the cache, forcing the next call to List to scan the peers.
+func (c *PublicRPCAddressCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.addrs = nil
+	c.group.Forget("list")
+}
+
+// Subscribe invalidates the cache whenever the peers change. The returned
+// function cancels the subscription.
+func (c *PublicRPCAddressCache) Subscribe(ctx context.Context) (context.CancelFunc, error) {
+	return c.peers.Subscribe(ctx, func([]types.MeshNode) {
+		c.Invalidate()
+	})
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage_test
+
+import (
+	"context"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+
+	"github.com/webmeshproj/webmesh/pkg/storage"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+// countingPeers counts the number of scans performed for public RPC addresses.
+type countingPeers struct {
+	storage.Peers
+	scans atomic.Int32
+}
+
+func (c *countingPeers) ListByFeature(ctx context.Context, feature v1.Feature) ([]types.MeshNode, error) {
+	c.scans.Add(1)
+	return c.Peers.ListByFeature(ctx, feature)
+}
+
+func TestPublicRPCAddressCache(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	db := newTestDB(t)
+	putTestNodes(t, db,
+		newTestNode("ip-node", "10.10.10.10", &v1.FeaturePort{Feature: v1.Feature_NODES, Port: 8443}),
+	)
+	peers := &countingPeers{Peers: db.Peers()}
+	const ttl = 250 * time.Millisecond
+	cache := storage.NewPublicRPCAddressCache(peers, ttl)
+
+	list := func(t *testing.T) map[string]string {
+		t.Helper()
+		addrs, err := cache.List(ctx)
+		if err != nil {
+			t.Fatalf("list public rpc addresses: %v", err)
+		}
+		out := make(map[string]string, len(addrs))
+		for id, addr := range addrs {
+			out[id] = addr.String()
+		}
+		return out
+	}
+
+	if got := list(t); got["ip-node"] != "10.10.10.10:8443" {
+		t.Fatalf("unexpected addresses: %v", got)
+	}
+	if got := list(t); got["ip-node"] != "10.10.10.10:8443" {
+		t.Fatalf("unexpected addresses: %v", got)
+	}
+	if scans := peers.scans.Load(); scans != 1 {
+		t.Fatalf("expected 1 scan within the TTL, got %d", scans)
+	}
+
+	// Expiry triggers a fresh scan.
+	time.Sleep(ttl + 50*time.Millisecond)
+	if got := list(t); got["ip-node"] != "10.10.10.10:8443" {
+		t.Fatalf("unexpected addresses: %v", got)
+	}
+	if scans := peers.scans.Load(); scans != 2 {
+		t.Fatalf("expected expiry to trigger a fresh scan, got %d total scans", scans)
+	}
+
+	// Invalidation forces a fresh scan that sees new nodes.
+	scans := peers.scans.Load()
+	putTestNodes(t, db,
+		newTestNode("new-node", "10.10.10.11", &v1.FeaturePort{Feature: v1.Feature_NODES, Port: 8443}),
+	)
+	cache.Invalidate()
+	if got := list(t); got["new-node"] != "10.10.10.11:8443" {
+		t.Fatalf("expected new node after invalidation, got %v", got)
+	}
+	if got := peers.scans.Load(); got != scans+1 {
+		t.Fatalf("expected invalidation to trigger 1 scan, got %d", got-scans)
+	}
+}
+
+// blockingPeers blocks scans until released.
+type blockingPeers struct {
+	storage.Peers
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingPeers) ListByFeature(ctx context.Context, feature v1.Feature) ([]types.MeshNode, error) {
+	b.started <- struct{}{}
+	select {
+	case <-b.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return b.Peers.ListByFeature(ctx, feature)
+}
+
+func TestPublicRPCAddressCacheCallerCancel(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	db := newTestDB(t)
+	putTestNodes(t, db,
+		newTestNode("ip-node", "10.10.10.10", &v1.FeaturePort{Feature: v1.Feature_NODES, Port: 8443}),
+	)
+	peers := &blockingPeers{Peers: db.Peers(), started: make(chan struct{}, 1), release: make(chan struct{})}
+	cache := storage.NewPublicRPCAddressCache(peers, time.Minute)
+
+	// The first caller starts the scan and gives up.
+	firstctx, cancel := context.WithCancel(ctx)
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := cache.List(firstctx)
+		firstErr <- err
+	}()
+	<-peers.started
+	// A second caller joins the same scan.
+	second := make(chan map[string]netip.AddrPort, 1)
+	go func() {
+		addrs, err := cache.List(ctx)
+		if err != nil {
+			t.Errorf("list public rpc addresses: %v", err)
+		}
+		second <- addrs
+	}()
+	cancel()
+	if err := <-firstErr; err != context.Canceled {
+		t.Fatalf("expected first caller to return context.Canceled, got %v", err)
+	}
+	close(peers.release)
+	select {
+	case addrs := <-second:
+		if addrs["ip-node"].String() != "10.10.10.10:8443" {
+			t.Fatalf("unexpected addresses: %v", addrs)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for second caller")
+	}
+}
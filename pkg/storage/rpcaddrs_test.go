@@ -0,0 +1,369 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+
+	"github.com/webmeshproj/webmesh/pkg/storage"
+	"github.com/webmeshproj/webmesh/pkg/storage/meshdb"
+	"github.com/webmeshproj/webmesh/pkg/storage/types"
+)
+
+func TestListPublicRPCEndpoints(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	db := newTestDB(t)
+	putTestNodes(t, db,
+		newTestNode("ip-node", "10.10.10.10", &v1.FeaturePort{Feature: v1.Feature_NODES, Port: 8443}),
+		newTestNode("dns-node", "node.example.com", &v1.FeaturePort{Feature: v1.Feature_NODES, Port: 8443}),
+		newTestNode("private-node", "", &v1.FeaturePort{Feature: v1.Feature_NODES, Port: 8443}),
+		newTestNode("no-rpc-node", "10.10.10.11"),
+	)
+
+	addrs, err := storage.ListPublicRPCAddresses(ctx, db.Peers())
+	if err != nil {
+		t.Fatalf("list public rpc addresses: %v", err)
+	}
+	if len(addrs) != 1 {
+		t.Fatalf("expected 1 public rpc address, got %d: %v", len(addrs), addrs)
+	}
+	if addr := addrs["ip-node"]; addr.String() != "10.10.10.10:8443" {
+		t.Errorf("expected ip-node address 10.10.10.10:8443, got %s", addr)
+	}
+
+	endpoints, err := storage.ListPublicRPCEndpoints(ctx, db.Peers())
+	if err != nil {
+		t.Fatalf("list public rpc endpoints: %v", err)
+	}
+	expected := map[string]string{
+		"ip-node":  "10.10.10.10:8443",
+		"dns-node": "node.example.com:8443",
+	}
+	if len(endpoints) != len(expected) {
+		t.Fatalf("expected %d public rpc endpoints, got %d: %v", len(expected), len(endpoints), endpoints)
+	}
+	for id, want := range expected {
+		if got := endpoints[id]; got != want {
+			t.Errorf("expected endpoint %q for %s, got %q", want, id, got)
+		}
+	}
+}
+
+func TestListEndpoints(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	db := newTestDB(t)
+	putTestNodes(t, db,
+		newTestNode("metrics-node", "10.10.10.10",
+			&v1.FeaturePort{Feature: v1.Feature_NODES, Port: 8443},
+			&v1.FeaturePort{Feature: v1.Feature_METRICS, Port: 8080},
+		),
+		newTestNode("storage-node", "10.10.10.11",
+			&v1.FeaturePort{Feature: v1.Feature_STORAGE_PROVIDER, Port: 9000},
+		),
+		newTestNode("dns-node", "node.example.com",
+			&v1.FeaturePort{Feature: v1.Feature_METRICS, Port: 8080},
+		),
+		newTestNode("private-node", "",
+			&v1.FeaturePort{Feature: v1.Feature_METRICS, Port: 8080},
+			&v1.FeaturePort{Feature: v1.Feature_STORAGE_PROVIDER, Port: 9000},
+		),
+	)
+	tc := []struct {
+		feature v1.Feature
+		want    map[string]string
+	}{
+		{feature: v1.Feature_NODES, want: map[string]string{"metrics-node": "10.10.10.10:8443"}},
+		{feature: v1.Feature_METRICS, want: map[string]string{"metrics-node": "10.10.10.10:8080"}},
+		{feature: v1.Feature_STORAGE_PROVIDER, want: map[string]string{"storage-node": "10.10.10.11:9000"}},
+		{feature: v1.Feature_TURN_SERVER, want: map[string]string{}},
+	}
+	for _, tt := range tc {
+		endpoints, err := db.Peers().ListEndpoints(ctx, tt.feature)
+		if err != nil {
+			t.Fatalf("list %s endpoints: %v", tt.feature, err)
+		}
+		if len(endpoints) != len(tt.want) {
+			t.Fatalf("expected %d %s endpoints, got %d: %v", len(tt.want), tt.feature, len(endpoints), endpoints)
+		}
+		for id, want := range tt.want {
+			if got := endpoints[id]; got.String() != want {
+				t.Errorf("expected %s endpoint %s for %s, got %s", tt.feature, want, id, got)
+			}
+		}
+	}
+}
+
+func TestListHealthyPublicRPCAddresses(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	// One address with a listener and one that will refuse connections.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	refused, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refusedPort := refused.Addr().(*net.TCPAddr).Port
+	_ = refused.Close()
+
+	db := newTestDB(t)
+	putTestNodes(t, db,
+		newTestNode("reachable", "127.0.0.1", &v1.FeaturePort{Feature: v1.Feature_NODES, Port: int32(ln.Addr().(*net.TCPAddr).Port)}),
+		newTestNode("refused", "127.0.0.1", &v1.FeaturePort{Feature: v1.Feature_NODES, Port: int32(refusedPort)}),
+	)
+	addrs, err := storage.ListHealthyPublicRPCAddresses(ctx, db.Peers(), time.Second)
+	if err != nil {
+		t.Fatalf("list healthy public rpc addresses: %v", err)
+	}
+	if len(addrs) != 1 {
+		t.Fatalf("expected 1 healthy address, got %d: %v", len(addrs), addrs)
+	}
+	if addr, ok := addrs["reachable"]; !ok || addr.String() != ln.Addr().String() {
+		t.Errorf("expected reachable node at %s, got %v", ln.Addr(), addrs)
+	}
+}
+
+func TestListPrivateRPCAddresses(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	rpc := &v1.FeaturePort{Feature: v1.Feature_NODES, Port: 8443}
+	db := newTestDB(t)
+	putTestNodes(t, db,
+		types.MeshNode{MeshNode: &v1.MeshNode{Id: "local-node", PrivateIPv4: "172.16.0.1/32", PrivateIPv6: "fd00::1/128", Features: []*v1.FeaturePort{rpc}}},
+		types.MeshNode{MeshNode: &v1.MeshNode{Id: "dual-stack", PrivateIPv4: "172.16.0.2/32", PrivateIPv6: "fd00::2/128", Features: []*v1.FeaturePort{rpc}}},
+		types.MeshNode{MeshNode: &v1.MeshNode{Id: "v6-only", PrivateIPv6: "fd00::3/128", Features: []*v1.FeaturePort{rpc}}},
+		types.MeshNode{MeshNode: &v1.MeshNode{Id: "no-rpc", PrivateIPv4: "172.16.0.4/32"}},
+		types.MeshNode{MeshNode: &v1.MeshNode{Id: "v4-mapped", PrivateIPv4: "::ffff:172.16.0.5/128", Features: []*v1.FeaturePort{rpc}}},
+		types.MeshNode{MeshNode: &v1.MeshNode{Id: "v4-mapped-v6-field", PrivateIPv6: "::ffff:172.16.0.6/128", Features: []*v1.FeaturePort{rpc}}},
+	)
+	expected := map[string]string{
+		"local-node": "172.16.0.1:8443",
+		"dual-stack": "172.16.0.2:8443",
+		"v6-only":    "[fd00::3]:8443",
+		"v4-mapped":  "172.16.0.5:8443",
+	}
+
+	all, err := storage.ListAllPrivateRPCAddresses(ctx, db.Peers())
+	if err != nil {
+		t.Fatalf("list all private rpc addresses: %v", err)
+	}
+	if len(all) != len(expected) {
+		t.Fatalf("expected %d private rpc addresses, got %d: %v", len(expected), len(all), all)
+	}
+	for id, want := range expected {
+		if got := all[id]; got.String() != want {
+			t.Errorf("expected address %s for %s, got %s", want, id, got)
+		}
+	}
+
+	peers, err := storage.ListPeerPrivateRPCAddresses(ctx, db.Peers(), "local-node")
+	if err != nil {
+		t.Fatalf("list peer private rpc addresses: %v", err)
+	}
+	if _, ok := peers["local-node"]; ok {
+		t.Errorf("expected the local node to be excluded, got %v", peers)
+	}
+	if len(peers) != len(expected)-1 {
+		t.Fatalf("expected %d peer private rpc addresses, got %d: %v", len(expected)-1, len(peers), peers)
+	}
+}
+
+func newTestDB(t *testing.T) storage.MeshDB {
+	t.Helper()
+	db := meshdb.NewTestDB()
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func newTestNode(id, primaryEndpoint string, features ...*v1.FeaturePort) types.MeshNode {
+	return types.MeshNode{MeshNode: &v1.MeshNode{
+		Id:              id,
+		PrimaryEndpoint: primaryEndpoint,
+		Features:        features,
+	}}
+}
+
+func putTestNodes(t *testing.T, db storage.MeshDB, nodes ...types.MeshNode) {
+	t.Helper()
+	for _, node := range nodes {
+		if err := db.Peers().Put(context.Background(), node); err != nil {
+			t.Fatalf("put node %s: %v", node.GetId(), err)
+		}
+	}
+}
+
+func TestDialTarget(t *testing.T) {
+	t.Parallel()
+	rpc := &v1.FeaturePort{Feature: v1.Feature_NODES, Port: 8443}
+	tc := []struct {
+		name          string
+		node          *v1.MeshNode
+		preferPrivate bool
+		want          string
+		wantErr       bool
+	}{
+		{
+			name: "PublicOnly",
+			node: &v1.MeshNode{Id: "node", PrimaryEndpoint: "10.10.10.10", Features: []*v1.FeaturePort{rpc}},
+			want: "10.10.10.10:8443",
+		},
+		{
+			name:          "PublicOnlyPreferPrivate",
+			node:          &v1.MeshNode{Id: "node", PrimaryEndpoint: "node.example.com", Features: []*v1.FeaturePort{rpc}},
+			preferPrivate: true,
+			want:          "node.example.com:8443",
+		},
+		{
+			name: "PrivateOnly",
+			node: &v1.MeshNode{Id: "node", PrivateIPv4: "172.16.0.2/32", Features: []*v1.FeaturePort{rpc}},
+			want: "172.16.0.2:8443",
+		},
+		{
+			name:          "PrivateOnlyV6",
+			node:          &v1.MeshNode{Id: "node", PrivateIPv6: "fd00::2/128", Features: []*v1.FeaturePort{rpc}},
+			preferPrivate: true,
+			want:          "[fd00::2]:8443",
+		},
+		{
+			name: "BothPreferPublic",
+			node: &v1.MeshNode{Id: "node", PrimaryEndpoint: "10.10.10.10", PrivateIPv4: "172.16.0.2/32", Features: []*v1.FeaturePort{rpc}},
+			want: "10.10.10.10:8443",
+		},
+		{
+			name:          "BothPreferPrivate",
+			node:          &v1.MeshNode{Id: "node", PrimaryEndpoint: "10.10.10.10", PrivateIPv4: "172.16.0.2/32", PrivateIPv6: "fd00::2/128", Features: []*v1.FeaturePort{rpc}},
+			preferPrivate: true,
+			want:          "172.16.0.2:8443",
+		},
+		{
+			name:    "NoAddress",
+			node:    &v1.MeshNode{Id: "node", Features: []*v1.FeaturePort{rpc}},
+			wantErr: true,
+		},
+		{
+			name:    "NoRPC",
+			node:    &v1.MeshNode{Id: "node", PrimaryEndpoint: "10.10.10.10", PrivateIPv4: "172.16.0.2/32"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := storage.DialTarget(types.MeshNode{MeshNode: tt.node}, tt.preferPrivate)
+			if tt.wantErr {
+				if !errors.Is(err, storage.ErrNoRPCAddress) {
+					t.Fatalf("expected ErrNoRPCAddress, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRPCAddrWithPort(t *testing.T) {
+	t.Parallel()
+	rpc := &v1.FeaturePort{Feature: v1.Feature_NODES, Port: 8443}
+	tc := []struct {
+		name    string
+		node    *v1.MeshNode
+		port    int
+		private bool
+		want    string
+		wantErr error
+	}{
+		{
+			name: "Public",
+			node: &v1.MeshNode{Id: "node", PrimaryEndpoint: "10.10.10.10", PrivateIPv4: "172.16.0.2/32", Features: []*v1.FeaturePort{rpc}},
+			port: 9443,
+			want: "10.10.10.10:9443",
+		},
+		{
+			name: "PublicWithoutRPC",
+			node: &v1.MeshNode{Id: "node", PrimaryEndpoint: "2001:db8::1"},
+			port: 9443,
+			want: "[2001:db8::1]:9443",
+		},
+		{
+			name:    "PrivateV4",
+			node:    &v1.MeshNode{Id: "node", PrimaryEndpoint: "10.10.10.10", PrivateIPv4: "172.16.0.2/32", PrivateIPv6: "fd00::2/128", Features: []*v1.FeaturePort{rpc}},
+			port:    9443,
+			private: true,
+			want:    "172.16.0.2:9443",
+		},
+		{
+			name:    "PrivateV6",
+			node:    &v1.MeshNode{Id: "node", PrimaryEndpoint: "10.10.10.10", PrivateIPv6: "fd00::2/128", Features: []*v1.FeaturePort{rpc}},
+			port:    9443,
+			private: true,
+			want:    "[fd00::2]:9443",
+		},
+		{
+			name:    "NoPrivateAddress",
+			node:    &v1.MeshNode{Id: "node", PrimaryEndpoint: "10.10.10.10", Features: []*v1.FeaturePort{rpc}},
+			port:    9443,
+			private: true,
+			wantErr: storage.ErrNoRPCAddress,
+		},
+		{
+			name:    "PublicHostname",
+			node:    &v1.MeshNode{Id: "node", PrimaryEndpoint: "node.example.com", Features: []*v1.FeaturePort{rpc}},
+			port:    9443,
+			wantErr: storage.ErrNoRPCAddress,
+		},
+		{
+			name: "InvalidPort",
+			node: &v1.MeshNode{Id: "node", PrimaryEndpoint: "10.10.10.10", Features: []*v1.FeaturePort{rpc}},
+			port: 70000,
+		},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := storage.RPCAddrWithPort(types.MeshNode{MeshNode: tt.node}, tt.port, tt.private)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("expected error, got %s", got)
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
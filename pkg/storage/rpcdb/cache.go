@@ -0,0 +1,138 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rpcdb
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/webmeshproj/webmesh/pkg/storage"
+)
+
+// OpenCached opens a new mesh database over a Querier interface with a
+// read-through cache of the given TTL in front of it.
+func OpenCached(q Querier, ttl time.Duration) storage.MeshDB {
+	return Open(NewCachingQuerier(q, ttl))
+}
+
+// OpenCachedServer opens a new mesh database over a QueryServer interface with
+// a read-through cache of the given TTL in front of it.
+func OpenCachedServer(s QueryServer, ttl time.Duration) storage.MeshDB {
+	return OpenCached(QuerierFromServer(s), ttl)
+}
+
+// OpenCachedStreams opens a new mesh database over the streams injected into a
+// plugin with a read-through cache of the given TTL in front of it. Queries
+// failing with a transient RPC error are retried on the next stream.
+func OpenCachedStreams(s *QueryStreams, ttl time.Duration) storage.MeshDB {
+	return OpenCached(s.Querier(), ttl)
+}
+
+// CachingQuerier is a Querier that caches the results of read queries for
+// a fixed TTL. Any write query that passes through it invalidates the cache.
+// Expired entries are removed when they are looked up and whenever a new
+// entry is stored.
+type CachingQuerier struct {
+	Querier
+	ttl   time.Duration
+	cache map[cacheKey]cacheEntry
+	mu    sync.Mutex
+}
+
+type cacheKey struct {
+	command v1.QueryRequest_QueryCommand
+	typ     v1.QueryRequest_QueryType
+	query   string
+}
+
+type cacheEntry struct {
+	resp    *v1.QueryResponse
+	expires time.Time
+}
+
+// NewCachingQuerier returns a new CachingQuerier wrapping the given Querier.
+func NewCachingQuerier(q Querier, ttl time.Duration) *CachingQuerier {
+	return &CachingQuerier{
+		Querier: q,
+		ttl:     ttl,
+		cache:   make(map[cacheKey]cacheEntry),
+	}
+}
+
+// Query invokes the query RPC, serving read queries from the cache when possible.
+func (c *CachingQuerier) Query(ctx context.Context, query *v1.QueryRequest) (*v1.QueryResponse, error) {
+	switch query.GetCommand() {
+	case v1.QueryRequest_GET, v1.QueryRequest_LIST:
+	default:
+		// Invalidate before and after the write so that readers racing
+		// with it do not repopulate the cache with stale data.
+		c.Invalidate()
+		defer c.Invalidate()
+		return c.Querier.Query(ctx, query)
+	}
+	key := cacheKey{
+		command: query.GetCommand(),
+		typ:     query.GetType(),
+		query:   query.GetQuery(),
+	}
+	c.mu.Lock()
+	entry, ok := c.cache[key]
+	if ok && !time.Now().Before(entry.expires) {
+		delete(c.cache, key)
+		ok = false
+	}
+	c.mu.Unlock()
+	if ok {
+		return proto.Clone(entry.resp).(*v1.QueryResponse), nil
+	}
+	resp, err := c.Querier.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetError() == "" {
+		now := time.Now()
+		c.mu.Lock()
+		c.evictExpired(now)
+		c.cache[key] = cacheEntry{
+			resp:    proto.Clone(resp).(*v1.QueryResponse),
+			expires: now.Add(c.ttl),
+		}
+		c.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// Invalidate clears all cached responses.
+func (c *CachingQuerier) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.cache)
+}
+
+// evictExpired removes every entry that has expired by the given time so keys
+// that are never looked up again do not accumulate. The caller must hold mu.
+func (c *CachingQuerier) evictExpired(now time.Time) {
+	for key, entry := range c.cache {
+		if !now.Before(entry.expires) {
+			delete(c.cache, key)
+		}
+	}
+}
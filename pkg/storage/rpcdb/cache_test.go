@@ -0,0 +1,118 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rpcdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+)
+
+func TestCachingQuerier(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	var calls int
+	q := NewCachingQuerier(QuerierFunc(func(ctx context.Context, query *v1.QueryRequest) (*v1.QueryResponse, error) {
+		calls++
+		return &v1.QueryResponse{Items: [][]byte{[]byte("value")}}, nil
+	}), time.Minute)
+	kv := OpenKV(q)
+
+	for i := 0; i < 2; i++ {
+		val, err := kv.GetValue(ctx, []byte("/registry/key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(val) != "value" {
+			t.Fatalf("expected value, got %q", val)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 call to the underlying querier, got %d", calls)
+	}
+
+	// A write should invalidate the cache
+	if err := kv.PutValue(ctx, []byte("/registry/key"), []byte("value"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kv.GetValue(ctx, []byte("/registry/key")); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls to the underlying querier after write, got %d", calls)
+	}
+}
+
+func TestCachingQuerierEviction(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	var fail bool
+	q := NewCachingQuerier(QuerierFunc(func(ctx context.Context, query *v1.QueryRequest) (*v1.QueryResponse, error) {
+		if fail {
+			return nil, context.Canceled
+		}
+		return &v1.QueryResponse{Items: [][]byte{[]byte("value")}}, nil
+	}), time.Millisecond*10)
+	kv := OpenKV(q)
+	cached := func() int {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return len(q.cache)
+	}
+
+	t.Run("OnLookup", func(t *testing.T) {
+		fail = false
+		if _, err := kv.GetValue(ctx, []byte("lookup-key")); err != nil {
+			t.Fatal(err)
+		}
+		if n := cached(); n != 1 {
+			t.Fatalf("expected 1 cached entry, got %d", n)
+		}
+		time.Sleep(time.Millisecond * 20)
+		// The refresh fails, so nothing new is stored in place of the expired entry.
+		fail = true
+		if _, err := kv.GetValue(ctx, []byte("lookup-key")); err == nil {
+			t.Fatal("expected error from the underlying querier")
+		}
+		if n := cached(); n != 0 {
+			t.Fatalf("expected expired entry to be removed, got %d cached entries", n)
+		}
+	})
+
+	t.Run("OnStore", func(t *testing.T) {
+		fail = false
+		if _, err := kv.GetValue(ctx, []byte("stale-key")); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond * 20)
+		if _, err := kv.GetValue(ctx, []byte("fresh-key")); err != nil {
+			t.Fatal(err)
+		}
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if len(q.cache) != 1 {
+			t.Fatalf("expected only the fresh entry to be cached, got %d entries", len(q.cache))
+		}
+		for key := range q.cache {
+			if key.query != "id=fresh-key" {
+				t.Fatalf("expected fresh-key to be cached, got %q", key.query)
+			}
+		}
+	})
+}
@@ -77,7 +77,7 @@ func (p *KVStorage) GetValue(ctx context.Context, key []byte) ([]byte, error) {
 		return nil, err
 	}
 	if resp.GetError() != "" {
-		if strings.Contains(err.Error(), "not found") {
+		if strings.Contains(resp.GetError(), "not found") {
 			return nil, errors.ErrKeyNotFound
 		}
 		return nil, fmt.Errorf(resp.GetError())
@@ -148,6 +148,48 @@ func (p *KVStorage) IterPrefix(ctx context.Context, prefix []byte, fn storage.Pr
 	return nil
 }
 
+// KeysPageSize is the number of keys requested at a time by IterKeys.
+const KeysPageSize = 1000
+
+// IterKeys iterates over all keys with a given prefix without fetching their values.
+// Keys are requested from the remote storage a page at a time so that the full key
+// set is never held in memory.
+func (p *KVStorage) IterKeys(ctx context.Context, prefix []byte, fn storage.KeyIterator) error {
+	var after string
+	for {
+		filters := types.NewQueryFilters().WithID(string(prefix)).WithLimit(KeysPageSize)
+		if after != "" {
+			filters = filters.WithAfter(after)
+		}
+		resp, err := p.Query(ctx, &v1.QueryRequest{
+			Command: v1.QueryRequest_LIST,
+			Type:    v1.QueryRequest_KEYS,
+			Query:   filters.Encode(),
+		})
+		if err != nil {
+			return err
+		}
+		if resp.GetError() != "" {
+			return fmt.Errorf(resp.GetError())
+		}
+		keys := resp.GetItems()
+		for _, key := range keys {
+			if err := fn(key); err != nil {
+				if errors.Is(err, storage.ErrStopIteration) {
+					return nil
+				}
+				return err
+			}
+		}
+		// A short page ends the listing. Servers that do not support
+		// paging return every key at once.
+		if len(keys) != KeysPageSize {
+			return nil
+		}
+		after = string(keys[len(keys)-1])
+	}
+}
+
 func (p *KVStorage) Subscribe(ctx context.Context, prefix []byte, fn storage.KVSubscribeFunc) (context.CancelFunc, error) {
 	return func() {}, errors.ErrNotStorageNode
 }
@@ -333,6 +375,11 @@ func (g *GraphStore) Subscribe(ctx context.Context, fn storage.PeerSubscribeFunc
 	return func() {}, errors.ErrNotStorageNode
 }
 
+func (g *GraphStore) SubscribeNode(ctx context.Context, id types.NodeID, fn storage.NodeWatchFunc) (context.CancelFunc, error) {
+	// Like Subscribe, this is not supported over plugin storage.
+	return func() {}, errors.ErrNotStorageNode
+}
+
 // RBACStore implements a mesh rbac store over a plugin query stream.
 type RBACStore struct {
 	*RPCDataStore
@@ -732,6 +779,26 @@ func (nw *NetworkingStore) ListNetworkACLs(ctx context.Context) (types.NetworkAC
 	return out, nil
 }
 
+func (nw *NetworkingStore) GetDefaultNetworkACLAction(ctx context.Context) (v1.ACLAction, error) {
+	// Value lookups only accept plain IDs, so the key is listed as a prefix.
+	// No other key starts with it.
+	resp, err := nw.Query(ctx, &v1.QueryRequest{
+		Command: v1.QueryRequest_LIST,
+		Type:    v1.QueryRequest_VALUE,
+		Query:   types.NewQueryFilters().WithID(string(storage.DefaultNetworkACLActionKey)).Encode(),
+	})
+	if err != nil {
+		return v1.ACLAction_ACTION_DENY, err
+	}
+	if resp.GetError() != "" {
+		return v1.ACLAction_ACTION_DENY, fmt.Errorf(resp.GetError())
+	}
+	if len(resp.GetItems()) == 0 {
+		return v1.ACLAction_ACTION_DENY, nil
+	}
+	return storage.ParseDefaultNetworkACLAction(resp.GetItems()[0])
+}
+
 func (nw *NetworkingStore) PutRoute(ctx context.Context, route types.Route) error {
 	data, err := route.MarshalProtoJSON()
 	if err != nil {
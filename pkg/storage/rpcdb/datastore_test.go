@@ -0,0 +1,183 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rpcdb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc"
+
+	"github.com/webmeshproj/webmesh/pkg/storage"
+	"github.com/webmeshproj/webmesh/pkg/storage/providers/backends/badgerdb"
+	"github.com/webmeshproj/webmesh/pkg/storage/rpcsrv"
+)
+
+// storageProvider is a storage.Provider that only serves raw key/value storage.
+type storageProvider struct {
+	storage.Provider
+	st storage.MeshStorage
+}
+
+func (p *storageProvider) MeshStorage() storage.MeshStorage { return p.st }
+
+// localQueryServer is a QueryServer that answers queries against a local provider
+// the same way a node answers queries from a plugin.
+type localQueryServer struct {
+	grpc.ServerStream
+	db       storage.Provider
+	legacy   bool
+	queries  chan *v1.QueryRequest
+	maxItems int
+	sent     int
+}
+
+func (s *localQueryServer) Send(req *v1.QueryRequest) error {
+	s.sent++
+	if s.legacy {
+		// Older nodes drop the paging filters they do not recognize.
+		req = &v1.QueryRequest{Command: req.GetCommand(), Type: req.GetType(), Query: "id=/keys/"}
+	}
+	s.queries <- req
+	return nil
+}
+
+func (s *localQueryServer) Recv() (*v1.QueryResponse, error) {
+	resp := rpcsrv.ServeQuery(context.Background(), s.db, <-s.queries)
+	s.maxItems = max(s.maxItems, len(resp.GetItems()))
+	return resp, nil
+}
+
+func TestKVStorageIterKeys(t *testing.T) {
+	t.Parallel()
+	db := badgerdb.NewTestStorage(false)
+	t.Cleanup(func() { _ = db.Close() })
+	ctx := context.Background()
+	numKeys := 2*KeysPageSize + KeysPageSize/2
+	for i := 0; i < numKeys; i++ {
+		if err := db.PutValue(ctx, []byte(fmt.Sprintf("/keys/%05d", i)), []byte("value"), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.PutValue(ctx, []byte("/other/key"), []byte("value"), 0); err != nil {
+		t.Fatal(err)
+	}
+	newServer := func(legacy bool) *localQueryServer {
+		return &localQueryServer{
+			db:      &storageProvider{st: db},
+			legacy:  legacy,
+			queries: make(chan *v1.QueryRequest, 1),
+		}
+	}
+
+	t.Run("Paged", func(t *testing.T) {
+		t.Parallel()
+		srv := newServer(false)
+		kv := OpenKVServer(srv).(storage.KeyIterStorage)
+		var i int
+		err := kv.IterKeys(ctx, []byte("/keys/"), func(key []byte) error {
+			if want := fmt.Sprintf("/keys/%05d", i); string(key) != want {
+				return fmt.Errorf("expected key %q at index %d, got %q", want, i, key)
+			}
+			i++
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i != numKeys {
+			t.Fatalf("expected %d keys, got %d", numKeys, i)
+		}
+		if srv.maxItems > KeysPageSize {
+			t.Fatalf("expected at most %d keys per response, got %d", KeysPageSize, srv.maxItems)
+		}
+		if srv.sent != 3 {
+			t.Fatalf("expected 3 queries to be sent, got %d", srv.sent)
+		}
+	})
+
+	t.Run("StopIteration", func(t *testing.T) {
+		t.Parallel()
+		srv := newServer(false)
+		kv := OpenKVServer(srv).(storage.KeyIterStorage)
+		var count int
+		err := kv.IterKeys(ctx, []byte("/keys/"), func(key []byte) error {
+			count++
+			if count == KeysPageSize+1 {
+				return storage.ErrStopIteration
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if srv.sent != 2 {
+			t.Fatalf("expected 2 queries to be sent, got %d", srv.sent)
+		}
+	})
+
+	t.Run("LegacyServer", func(t *testing.T) {
+		t.Parallel()
+		srv := newServer(true)
+		kv := OpenKVServer(srv).(storage.KeyIterStorage)
+		var count int
+		err := kv.IterKeys(ctx, []byte("/keys/"), func(key []byte) error {
+			count++
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != numKeys {
+			t.Fatalf("expected %d keys, got %d", numKeys, count)
+		}
+		if srv.sent != 1 {
+			t.Fatalf("expected 1 query to be sent, got %d", srv.sent)
+		}
+	})
+}
+
+func TestNetworkingStoreDefaultNetworkACLAction(t *testing.T) {
+	t.Parallel()
+	db := badgerdb.NewTestStorage(false)
+	t.Cleanup(func() { _ = db.Close() })
+	ctx := context.Background()
+	srv := &localQueryServer{
+		db:      &storageProvider{st: db},
+		queries: make(chan *v1.QueryRequest, 1),
+	}
+	nw := OpenServer(srv).Networking()
+	action, err := nw.GetDefaultNetworkACLAction(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if action != v1.ACLAction_ACTION_DENY {
+		t.Fatalf("expected ACTION_DENY when unset, got %s", action)
+	}
+	if err := storage.PutDefaultNetworkACLAction(ctx, db, v1.ACLAction_ACTION_ACCEPT); err != nil {
+		t.Fatal(err)
+	}
+	action, err = nw.GetDefaultNetworkACLAction(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if action != v1.ACLAction_ACTION_ACCEPT {
+		t.Fatalf("expected ACTION_ACCEPT, got %s", action)
+	}
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rpcdb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultQueryRetries is the default number of times a query is retried
+	// after a transient RPC error.
+	DefaultQueryRetries = 3
+	// DefaultQueryRetryInterval is the default interval to wait between retries.
+	DefaultQueryRetryInterval = 100 * time.Millisecond
+	// DefaultQueryReopenTimeout is the default time to wait for a new Querier
+	// after the current one failed.
+	DefaultQueryReopenTimeout = 5 * time.Second
+)
+
+// ReopenFunc returns a Querier to replace one that failed. It should block until
+// one is available or the context is done.
+type ReopenFunc func(ctx context.Context) (Querier, error)
+
+// RetryingQuerier is a Querier that retries queries failing with a transient
+// RPC error a bounded number of times before surfacing the error. A stream that
+// returned an error cannot be used again, so the failed Querier is discarded and
+// each retry runs on a new one obtained from the ReopenFunc.
+type RetryingQuerier struct {
+	reopen     ReopenFunc
+	maxRetries int
+	interval   time.Duration
+	current    Querier
+	gen        uint64
+	mu         sync.Mutex
+}
+
+// NewRetryingQuerier returns a new RetryingQuerier that obtains its Querier, and
+// the replacement for each one that fails, from reopen.
+func NewRetryingQuerier(reopen ReopenFunc, maxRetries int, interval time.Duration) *RetryingQuerier {
+	return &RetryingQuerier{
+		reopen:     reopen,
+		maxRetries: maxRetries,
+		interval:   interval,
+	}
+}
+
+// Query invokes the query RPC, retrying on transient errors.
+func (r *RetryingQuerier) Query(ctx context.Context, query *v1.QueryRequest) (*v1.QueryResponse, error) {
+	var attempt int
+	var lastErr error
+	for {
+		q, gen, err := r.querier(ctx)
+		if err != nil {
+			if lastErr != nil {
+				return nil, fmt.Errorf("%w: reopen querier: %w", lastErr, err)
+			}
+			return nil, fmt.Errorf("open querier: %w", err)
+		}
+		resp, err := q.Query(ctx, query)
+		if err == nil || !IsTransientError(err) {
+			return resp, err
+		}
+		r.discard(gen)
+		if attempt >= r.maxRetries {
+			return resp, err
+		}
+		attempt++
+		lastErr = err
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+// querier returns the current Querier and its generation, reopening one if
+// there is none.
+func (r *RetryingQuerier) querier(ctx context.Context) (Querier, uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.current != nil {
+		return r.current, r.gen, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryReopenTimeout)
+	defer cancel()
+	q, err := r.reopen(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	r.current = q
+	r.gen++
+	return q, r.gen, nil
+}
+
+// discard drops the Querier of the given generation so the next query reopens
+// one. A Querier that was already replaced is left alone.
+func (r *RetryingQuerier) discard(gen uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.gen == gen {
+		r.current = nil
+	}
+}
+
+// IsTransientError returns true if the given error is an RPC error that
+// may succeed if the query is retried.
+func IsTransientError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Aborted, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
+
+// QueryStreams tracks the query streams injected into a plugin. The plugin
+// manager opens a new stream when the previous one fails, and plugins pass
+// each stream they receive in InjectQuerier to Inject. Queriers returned by
+// Querier run on the latest stream and move to the next one injected when
+// a query fails with a transient error.
+type QueryStreams struct {
+	srv    QueryServer
+	notify chan struct{}
+	mu     sync.Mutex
+}
+
+// NewQueryStreams returns a new QueryStreams with no stream injected yet.
+func NewQueryStreams() *QueryStreams {
+	return &QueryStreams{notify: make(chan struct{})}
+}
+
+// Inject makes the given stream the latest one.
+func (s *QueryStreams) Inject(srv QueryServer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.srv = srv
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+// Next returns a Querier on the latest stream, waiting for a new one to be
+// injected if it has ended. It can be used as a ReopenFunc.
+func (s *QueryStreams) Next(ctx context.Context) (Querier, error) {
+	for {
+		s.mu.Lock()
+		srv, notify := s.srv, s.notify
+		s.mu.Unlock()
+		if srv != nil && srv.Context().Err() == nil {
+			return QuerierFromServer(srv), nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-notify:
+		}
+	}
+}
+
+// Querier returns a Querier that runs on the injected streams and retries
+// transient errors on the next stream with the default settings.
+func (s *QueryStreams) Querier() Querier {
+	return NewRetryingQuerier(s.Next, DefaultQueryRetries, DefaultQueryRetryInterval)
+}
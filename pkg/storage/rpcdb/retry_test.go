@@ -0,0 +1,175 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rpcdb
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/webmeshproj/webmesh/pkg/storage/providers/backends/badgerdb"
+)
+
+// closableQueryServer is a QueryServer that answers queries against a local
+// provider until it is closed. Like a gRPC stream, once it is closed its
+// context is done and every Send and Recv fails.
+type closableQueryServer struct {
+	grpc.ServerStream
+	ctx     context.Context
+	cancel  context.CancelFunc
+	srv     *localQueryServer
+	err     error
+	sent    int
+	closeMu sync.Mutex
+}
+
+func newClosableQueryServer(srv *localQueryServer, err error) *closableQueryServer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &closableQueryServer{ctx: ctx, cancel: cancel, srv: srv, err: err}
+}
+
+func (s *closableQueryServer) Context() context.Context { return s.ctx }
+
+func (s *closableQueryServer) Close() {
+	s.closeMu.Lock()
+	defer s.closeMu.Unlock()
+	s.cancel()
+}
+
+func (s *closableQueryServer) Send(req *v1.QueryRequest) error {
+	s.closeMu.Lock()
+	defer s.closeMu.Unlock()
+	if s.ctx.Err() != nil {
+		return s.err
+	}
+	s.sent++
+	return s.srv.Send(req)
+}
+
+func (s *closableQueryServer) Recv() (*v1.QueryResponse, error) {
+	s.closeMu.Lock()
+	defer s.closeMu.Unlock()
+	if s.ctx.Err() != nil {
+		return nil, s.err
+	}
+	return s.srv.Recv()
+}
+
+func TestRetryingQuerier(t *testing.T) {
+	t.Parallel()
+	db := badgerdb.NewTestStorage(false)
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.PutValue(context.Background(), []byte("retry-key"), []byte("value"), 0); err != nil {
+		t.Fatal(err)
+	}
+	newServer := func(err error) *closableQueryServer {
+		return newClosableQueryServer(&localQueryServer{
+			db:      &storageProvider{st: db},
+			queries: make(chan *v1.QueryRequest, 1),
+		}, err)
+	}
+
+	t.Run("Reconnect", func(t *testing.T) {
+		t.Parallel()
+		streams := NewQueryStreams()
+		first := newServer(status.Error(codes.Unavailable, "stream closed"))
+		streams.Inject(first)
+		kv := OpenKVStreams(streams)
+		if _, err := kv.GetValue(context.Background(), []byte("retry-key")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// Close the stream and re-establish it a little later, the read
+		// should wait for the new stream and succeed on it.
+		first.Close()
+		second := newServer(status.Error(codes.Unavailable, "stream closed"))
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			streams.Inject(second)
+		}()
+		val, err := kv.GetValue(context.Background(), []byte("retry-key"))
+		if err != nil {
+			t.Fatalf("expected read to succeed after reconnect, got %v", err)
+		}
+		if string(val) != "value" {
+			t.Fatalf("expected value, got %q", val)
+		}
+		if first.sent != 1 {
+			t.Fatalf("expected 1 query on the closed stream, got %d", first.sent)
+		}
+		if second.sent != 1 {
+			t.Fatalf("expected 1 query on the new stream, got %d", second.sent)
+		}
+	})
+
+	t.Run("RetriesExhausted", func(t *testing.T) {
+		t.Parallel()
+		var opened int
+		reopen := func(ctx context.Context) (Querier, error) {
+			opened++
+			srv := newServer(status.Error(codes.Unavailable, "stream down"))
+			srv.Close()
+			return QuerierFromServer(srv), nil
+		}
+		q := NewRetryingQuerier(reopen, 2, time.Millisecond)
+		_, err := q.Query(context.Background(), &v1.QueryRequest{})
+		if status.Code(err) != codes.Unavailable {
+			t.Fatalf("expected unavailable error, got %v", err)
+		}
+		if opened != 3 {
+			t.Fatalf("expected 3 streams to be opened, got %d", opened)
+		}
+	})
+
+	t.Run("PermanentError", func(t *testing.T) {
+		t.Parallel()
+		var opened int
+		reopen := func(ctx context.Context) (Querier, error) {
+			opened++
+			srv := newServer(status.Error(codes.PermissionDenied, "denied"))
+			srv.Close()
+			return QuerierFromServer(srv), nil
+		}
+		q := NewRetryingQuerier(reopen, 2, time.Millisecond)
+		_, err := q.Query(context.Background(), &v1.QueryRequest{})
+		if status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("expected permission denied error, got %v", err)
+		}
+		if opened != 1 {
+			t.Fatalf("expected 1 stream to be opened, got %d", opened)
+		}
+	})
+
+	t.Run("NoNewStream", func(t *testing.T) {
+		t.Parallel()
+		streams := NewQueryStreams()
+		srv := newServer(status.Error(codes.Unavailable, "stream closed"))
+		srv.Close()
+		streams.Inject(srv)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		_, err := streams.Querier().Query(ctx, &v1.QueryRequest{})
+		if err == nil {
+			t.Fatal("expected an error without a live stream")
+		}
+	})
+}
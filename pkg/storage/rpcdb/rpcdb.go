@@ -38,6 +38,13 @@ func OpenServer(s QueryServer) storage.MeshDB {
 	return Open(QuerierFromServer(s))
 }
 
+// OpenStreams opens a new mesh database over the streams injected into a
+// plugin. Queries failing with a transient RPC error are retried on the next
+// stream.
+func OpenStreams(s *QueryStreams) storage.MeshDB {
+	return Open(s.Querier())
+}
+
 // OpenKV opens a new key-value store connection over a Querier interface.
 func OpenKV(q Querier) storage.MeshStorage {
 	return &KVStorage{q}
@@ -48,6 +55,13 @@ func OpenKVServer(s QueryServer) storage.MeshStorage {
 	return OpenKV(QuerierFromServer(s))
 }
 
+// OpenKVStreams opens a new key-value store connection over the streams
+// injected into a plugin. Queries failing with a transient RPC error are
+// retried on the next stream.
+func OpenKVStreams(s *QueryStreams) storage.MeshStorage {
+	return OpenKV(s.Querier())
+}
+
 // Querier is an interface for invoking the query RPC.
 type Querier interface {
 	// Query invokes the query RPC.
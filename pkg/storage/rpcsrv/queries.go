@@ -17,8 +17,10 @@ limitations under the License.
 package rpcsrv
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"slices"
 
 	"github.com/dominikbraun/graph"
 	v1 "github.com/webmeshproj/api/go/v1"
@@ -313,8 +315,16 @@ func doListQuery(ctx context.Context, db storage.Provider, req types.StorageQuer
 		}
 
 	case v1.QueryRequest_KEYS:
-		// Support legacy iter queries.
 		prefix, _ := req.Filters().GetID()
+		if limit, ok := req.Filters().GetLimit(); ok {
+			after, _ := req.Filters().GetAfter()
+			res.Items, err = listKeysPage(ctx, db.MeshStorage(), []byte(prefix), []byte(after), limit)
+			if err != nil {
+				res.Error = err.Error()
+			}
+			return
+		}
+		// Support legacy iter queries.
 		var keys [][]byte
 		keys, err = db.MeshStorage().ListKeys(ctx, []byte(prefix))
 		if err != nil {
@@ -720,3 +730,38 @@ func doDeleteQuery(ctx context.Context, db storage.Provider, req types.StorageQu
 	}
 	return
 }
+
+// listKeysPage returns at most limit keys with the given prefix that sort after
+// the given key. Storage that can iterate keys is used so that the full key set
+// is never held in memory.
+func listKeysPage(ctx context.Context, st storage.MeshStorage, prefix, after []byte, limit int) ([][]byte, error) {
+	keys := make([][]byte, 0, limit)
+	collect := func(key []byte) error {
+		if len(after) > 0 && bytes.Compare(key, after) <= 0 {
+			return nil
+		}
+		keys = append(keys, key)
+		if len(keys) >= limit {
+			return storage.ErrStopIteration
+		}
+		return nil
+	}
+	if iter, ok := st.(storage.KeyIterStorage); ok {
+		err := iter.IterKeys(ctx, prefix, collect)
+		if err != nil && !errors.Is(err, storage.ErrStopIteration) {
+			return nil, err
+		}
+		return keys, nil
+	}
+	all, err := st.ListKeys(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(all, bytes.Compare)
+	for _, key := range all {
+		if err := collect(key); err != nil {
+			break
+		}
+	}
+	return keys, nil
+}
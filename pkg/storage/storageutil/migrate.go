@@ -0,0 +1,87 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package storageutil contains utilities for working with mesh storage.
+package storageutil
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/webmeshproj/webmesh/pkg/storage"
+)
+
+// TransformFunc transforms a key and value being migrated. The key is given
+// relative to the source prefix and the returned key is placed under the
+// destination prefix.
+type TransformFunc func(key, val string) (string, string, error)
+
+// MigratePrefix moves all keys under the from prefix to the to prefix, passing
+// each through transform, and deletes the old keys once every new key has been
+// written. A nil transform copies keys and values unchanged. Migrating again
+// after a successful run is a no-op, and a failed run can be safely retried.
+// TTLs are not preserved. The prefixes must not overlap.
+func MigratePrefix(ctx context.Context, s storage.MeshStorage, from, to string, transform TransformFunc) error {
+	return migratePrefix(ctx, s, from, to, transform, true)
+}
+
+// CopyPrefix is like MigratePrefix but leaves the old keys in place.
+func CopyPrefix(ctx context.Context, s storage.MeshStorage, from, to string, transform TransformFunc) error {
+	return migratePrefix(ctx, s, from, to, transform, false)
+}
+
+func migratePrefix(ctx context.Context, s storage.MeshStorage, from, to string, transform TransformFunc, deleteOld bool) error {
+	if from == "" || to == "" {
+		return fmt.Errorf("migrate prefix: prefixes must not be empty")
+	}
+	if strings.HasPrefix(from, to) || strings.HasPrefix(to, from) {
+		return fmt.Errorf("migrate prefix: %q and %q overlap", from, to)
+	}
+	if transform == nil {
+		transform = func(key, val string) (string, string, error) { return key, val, nil }
+	}
+	type kv struct{ key, val string }
+	var old []kv
+	// Writes are not allowed during iteration, so collect the values first.
+	err := s.IterPrefix(ctx, []byte(from), func(key, val []byte) error {
+		old = append(old, kv{string(key), string(val)})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("migrate prefix: iterate %q: %w", from, err)
+	}
+	for _, item := range old {
+		key, val, err := transform(strings.TrimPrefix(item.key, from), item.val)
+		if err != nil {
+			return fmt.Errorf("migrate prefix: transform %q: %w", item.key, err)
+		}
+		err = s.PutValue(ctx, []byte(to+key), []byte(val), 0)
+		if err != nil {
+			return fmt.Errorf("migrate prefix: put %q: %w", to+key, err)
+		}
+	}
+	if !deleteOld {
+		return nil
+	}
+	for _, item := range old {
+		err := s.Delete(ctx, []byte(item.key))
+		if err != nil {
+			return fmt.Errorf("migrate prefix: delete %q: %w", item.key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storageutil
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/webmeshproj/webmesh/pkg/storage"
+	"github.com/webmeshproj/webmesh/pkg/storage/providers/backends/badgerdb"
+)
+
+func TestMigratePrefix(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	seed := map[string]string{
+		"/leases/v1/a": "10.0.0.1",
+		"/leases/v1/b": "10.0.0.2",
+		"/leases/v1/c": "10.0.0.3",
+	}
+	newStorage := func(t *testing.T) storage.MeshStorage {
+		t.Helper()
+		db := badgerdb.NewTestStorage(false)
+		t.Cleanup(func() { _ = db.Close() })
+		for key, val := range seed {
+			if err := db.PutValue(ctx, []byte(key), []byte(val), 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// A key outside the migrated prefix.
+		if err := db.PutValue(ctx, []byte("/other/key"), []byte("value"), 0); err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}
+	transform := func(key, val string) (string, string, error) {
+		return "/node" + key, val + "/32", nil
+	}
+
+	t.Run("Migrate", func(t *testing.T) {
+		t.Parallel()
+		db := newStorage(t)
+		// Running twice must yield the same result.
+		for i := 0; i < 2; i++ {
+			if err := MigratePrefix(ctx, db, "/leases/v1", "/leases/v2", transform); err != nil {
+				t.Fatalf("migrate: %v", err)
+			}
+		}
+		keys, err := db.ListKeys(ctx, []byte("/leases/v1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != 0 {
+			t.Fatalf("expected old keys to be removed, got %q", keys)
+		}
+		for key, val := range seed {
+			newKey := "/leases/v2/node" + strings.TrimPrefix(key, "/leases/v1")
+			got, err := db.GetValue(ctx, []byte(newKey))
+			if err != nil {
+				t.Fatalf("get %s: %v", newKey, err)
+			}
+			if string(got) != val+"/32" {
+				t.Fatalf("expected %s to be %q, got %q", newKey, val+"/32", got)
+			}
+		}
+		if _, err := db.GetValue(ctx, []byte("/other/key")); err != nil {
+			t.Fatalf("expected unrelated key to be untouched: %v", err)
+		}
+	})
+
+	t.Run("Copy", func(t *testing.T) {
+		t.Parallel()
+		db := newStorage(t)
+		if err := CopyPrefix(ctx, db, "/leases/v1", "/leases/v2", nil); err != nil {
+			t.Fatalf("copy: %v", err)
+		}
+		for key, val := range seed {
+			for _, k := range []string{key, "/leases/v2" + strings.TrimPrefix(key, "/leases/v1")} {
+				got, err := db.GetValue(ctx, []byte(k))
+				if err != nil {
+					t.Fatalf("get %s: %v", k, err)
+				}
+				if string(got) != val {
+					t.Fatalf("expected %s to be %q, got %q", k, val, got)
+				}
+			}
+		}
+	})
+
+	t.Run("TransformError", func(t *testing.T) {
+		t.Parallel()
+		db := newStorage(t)
+		errTransform := errors.New("bad value")
+		err := MigratePrefix(ctx, db, "/leases/v1", "/leases/v2", func(key, val string) (string, string, error) {
+			return "", "", errTransform
+		})
+		if !errors.Is(err, errTransform) {
+			t.Fatalf("expected transform error, got %v", err)
+		}
+		keys, err := db.ListKeys(ctx, []byte("/leases/v1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != len(seed) {
+			t.Fatalf("expected old keys to be kept on failure, got %q", keys)
+		}
+	})
+
+	t.Run("OverlappingPrefixes", func(t *testing.T) {
+		t.Parallel()
+		db := newStorage(t)
+		if err := MigratePrefix(ctx, db, "/leases", "/leases/v2", nil); err == nil {
+			t.Fatal("expected error for overlapping prefixes")
+		}
+	})
+}
@@ -383,6 +383,13 @@ func TestPeerStorageConformance(t *testing.T, builder NewPeersFunc) {
 			if len(got) != len(nodes) {
 				t.Fatal("nodes not equal")
 			}
+			count, err := p.Count(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != len(nodes) {
+				t.Fatalf("expected count %d, got %d", len(nodes), count)
+			}
 			for _, node := range nodes {
 				found := false
 				for _, gotNode := range got {
@@ -647,6 +654,34 @@ func TestPeerStorageConformance(t *testing.T, builder NewPeersFunc) {
 					t.Fatalf("node %q not found", node.GetId())
 				}
 			}
+			// ListByFeature should return the same nodes as filtering List
+			expected := map[v1.Feature][]string{
+				v1.Feature_ADMIN_API:       {"node-a", "node-b"},
+				v1.Feature_ICE_NEGOTIATION: {"node-c"},
+				v1.Feature_MESH_DNS:        {"node-d"},
+				v1.Feature_STORAGE_QUERIER: {},
+			}
+			for feature, ids := range expected {
+				got, err := p.ListByFeature(ctx, feature)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(got) != len(ids) {
+					t.Fatalf("expected %d nodes with feature %s, got %d", len(ids), feature, len(got))
+				}
+				for _, id := range ids {
+					found := false
+					for _, gotNode := range got {
+						if gotNode.GetId() == id {
+							found = true
+							break
+						}
+					}
+					if !found {
+						t.Fatalf("node %q not found for feature %s", id, feature)
+					}
+				}
+			}
 		})
 
 		t.Run("PutAndRemoveEdge", func(t *testing.T) {
@@ -776,6 +811,92 @@ func TestPeerStorageConformance(t *testing.T, builder NewPeersFunc) {
 				}
 			}
 		})
+
+		t.Run("WatchNode", func(t *testing.T) {
+			SkipOnCI(t, "Subscription tests are flaky on CI")
+			ctx := context.Background()
+			p := builder(t)
+			type event struct {
+				zone    string
+				deleted bool
+			}
+			events := make(chan event, 100)
+			cancel, err := p.WatchNode(ctx, "node-a", func(node *types.MeshNode, deleted bool) {
+				if deleted {
+					events <- event{deleted: true}
+					return
+				}
+				events <- event{zone: node.GetZoneAwarenessID()}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cancel()
+			put := func(t *testing.T, zone string) {
+				t.Helper()
+				err := p.Put(ctx, types.MeshNode{MeshNode: &v1.MeshNode{
+					Id:              "node-a",
+					PublicKey:       mustGeneratePublicKey(t),
+					ZoneAwarenessID: zone,
+				}})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			// await waits for an event matching want, skipping any earlier events.
+			await := func(t *testing.T, want event) {
+				t.Helper()
+				timeout := time.After(time.Second * 15)
+				for {
+					select {
+					case ev := <-events:
+						if ev == want {
+							return
+						}
+					case <-timeout:
+						t.Fatalf("Timed out waiting for node watch event %+v", want)
+					}
+				}
+			}
+			// Subscriptions may become active asynchronously, so keep writing
+			// until the first update is seen.
+			ok := Eventually[bool](func() bool {
+				put(t, "zone-1")
+				select {
+				case ev := <-events:
+					return ev == event{zone: "zone-1"}
+				case <-time.After(time.Millisecond * 500):
+					return false
+				}
+			}).ShouldEqual(time.Second*15, time.Millisecond*100, true)
+			if !ok {
+				t.Fatal("Did not see put for watched node")
+			}
+			put(t, "zone-2")
+			await(t, event{zone: "zone-2"})
+			// Changes to other nodes, including ones sharing the ID as a prefix,
+			// should not be seen.
+			for _, id := range []string{"node-b", "node-aa"} {
+				err = p.Put(ctx, types.MeshNode{MeshNode: &v1.MeshNode{
+					Id:        id,
+					PublicKey: mustGeneratePublicKey(t),
+				}})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			err = p.Delete(ctx, "node-a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			await(t, event{deleted: true})
+			// Nothing about the other nodes should have been delivered.
+			select {
+			case ev := <-events:
+				t.Fatalf("Unexpected event after delete: %+v", ev)
+			case <-time.After(time.Millisecond * 250):
+			}
+		})
 	})
 }
 
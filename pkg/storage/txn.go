@@ -0,0 +1,178 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/webmeshproj/webmesh/pkg/storage/errors"
+)
+
+// TxnStorage is implemented by storage that can apply a group of writes
+// atomically. It is optional and callers should use Begin to start a
+// transaction against arbitrary MeshStorage.
+type TxnStorage interface {
+	// Begin starts a new transaction.
+	Begin(ctx context.Context) (Txn, error)
+}
+
+// Txn is a group of writes that are applied to storage as a single unit.
+//
+// Writes are buffered until Commit and are not visible to readers before
+// then, not even through the storage the transaction was started on. On
+// Commit they are applied in the order they were added, so a later write to
+// a key overrides an earlier one. Readers observe either none or all of the
+// writes in a committed transaction, and a transaction is ordered against
+// other writes to the same storage as if it were a single write. Nothing is
+// read or locked before Commit, so conflicting writes made between Begin and
+// Commit are only detected for keys guarded with Check.
+//
+// A Txn is not safe for concurrent use and cannot be used again after Commit
+// or Rollback.
+type Txn interface {
+	// PutValue buffers setting the value of a key. TTL is optional and can be set to 0.
+	PutValue(key, value []byte, ttl time.Duration) error
+	// Delete buffers removing a key.
+	Delete(key []byte) error
+	// Check makes Commit fail with ErrTxnConflict, without applying any
+	// writes, unless the key holds exactly the given value when the
+	// transaction is applied. A nil value requires the key to not exist.
+	Check(key, value []byte) error
+	// Commit applies all buffered writes atomically.
+	Commit(ctx context.Context) error
+	// Rollback discards all buffered writes.
+	Rollback() error
+}
+
+// Begin starts a transaction on the given storage. It returns ErrNotImplemented
+// if the storage does not support transactions, or cannot use them yet, such
+// as during a rolling upgrade. Callers should fall back to individual writes.
+func Begin(ctx context.Context, st MeshStorage) (Txn, error) {
+	txst, ok := st.(TxnStorage)
+	if !ok {
+		return nil, errors.ErrNotImplemented
+	}
+	return txst.Begin(ctx)
+}
+
+// TxnOpType is the type of a write in a transaction.
+type TxnOpType int
+
+const (
+	// TxnOpPut sets the value of a key.
+	TxnOpPut TxnOpType = iota
+	// TxnOpDelete removes a key.
+	TxnOpDelete
+	// TxnOpCheck requires a key to hold a value.
+	TxnOpCheck
+	// TxnOpCheckAbsent requires a key to not exist.
+	TxnOpCheckAbsent
+)
+
+// TxnOp is a single buffered write in a transaction.
+type TxnOp struct {
+	// Type is the type of the write.
+	Type TxnOpType
+	// Key is the key being written.
+	Key []byte
+	// Value is the value for a put or the expected value for a check.
+	Value []byte
+	// TTL is the time to live for a put.
+	TTL time.Duration
+}
+
+// TxnCommitFunc applies the buffered writes of a transaction atomically.
+type TxnCommitFunc func(ctx context.Context, ops []TxnOp) error
+
+// NewTxn returns a Txn that buffers writes and passes them to commit on
+// Commit. It is a helper for implementing TxnStorage.
+func NewTxn(commit TxnCommitFunc) Txn {
+	return &bufferedTxn{commit: commit}
+}
+
+type bufferedTxn struct {
+	commit TxnCommitFunc
+	ops    []TxnOp
+	done   bool
+	mu     sync.Mutex
+}
+
+func (t *bufferedTxn) PutValue(key, value []byte, ttl time.Duration) error {
+	return t.add(TxnOp{
+		Type:  TxnOpPut,
+		Key:   append([]byte(nil), key...),
+		Value: append([]byte(nil), value...),
+		TTL:   ttl,
+	})
+}
+
+func (t *bufferedTxn) Delete(key []byte) error {
+	return t.add(TxnOp{
+		Type: TxnOpDelete,
+		Key:  append([]byte(nil), key...),
+	})
+}
+
+func (t *bufferedTxn) Check(key, value []byte) error {
+	if value == nil {
+		return t.add(TxnOp{
+			Type: TxnOpCheckAbsent,
+			Key:  append([]byte(nil), key...),
+		})
+	}
+	return t.add(TxnOp{
+		Type:  TxnOpCheck,
+		Key:   append([]byte(nil), key...),
+		Value: append([]byte{}, value...),
+	})
+}
+
+func (t *bufferedTxn) add(op TxnOp) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return errors.ErrTxnDone
+	}
+	t.ops = append(t.ops, op)
+	return nil
+}
+
+func (t *bufferedTxn) Commit(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return errors.ErrTxnDone
+	}
+	t.done = true
+	if len(t.ops) == 0 {
+		return nil
+	}
+	return t.commit(ctx, t.ops)
+}
+
+func (t *bufferedTxn) Rollback() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return errors.ErrTxnDone
+	}
+	t.done = true
+	t.ops = nil
+	return nil
+}
@@ -91,6 +91,13 @@ func IsValidNodeID(id string) bool {
 	return !slices.Contains(ReservedNodeIDs, id)
 }
 
+// NormalizeNodeID trims surrounding whitespace from a node ID received from
+// a client and reports whether the result is a valid node ID.
+func NormalizeNodeID(id string) (NodeID, bool) {
+	id = strings.TrimSpace(id)
+	return NodeID(id), IsValidNodeID(id)
+}
+
 // NodeID is the type of a node ID.
 type NodeID string
 
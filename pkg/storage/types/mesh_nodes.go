@@ -175,7 +175,8 @@ func (n MeshNode) StoragePort() uint16 {
 	return n.PortFor(v1.Feature_STORAGE_PROVIDER)
 }
 
-// PrivateAddrV4 returns the node's private IPv4 address.
+// PrivateAddrV4 returns the node's private IPv4 address. An IPv4-mapped
+// IPv6 address is returned in its IPv4 form.
 // Be sure to check if the returned Addr IsValid.
 func (n MeshNode) PrivateAddrV4() netip.Prefix {
 	if n.GetPrivateIPv4() == "" {
@@ -185,10 +186,15 @@ func (n MeshNode) PrivateAddrV4() netip.Prefix {
 	if err != nil {
 		return netip.Prefix{}
 	}
+	addr = UnmapPrefix(addr)
+	if !addr.Addr().Is4() {
+		return netip.Prefix{}
+	}
 	return addr
 }
 
-// PrivateAddrV6 returns the node's private IPv6 address.
+// PrivateAddrV6 returns the node's private IPv6 address. An IPv4-mapped
+// IPv6 address is not considered an IPv6 address and is treated as invalid.
 // Be sure to check if the returned Addr IsValid.
 func (n MeshNode) PrivateAddrV6() netip.Prefix {
 	if n.GetPrivateIPv6() == "" {
@@ -198,21 +204,48 @@ func (n MeshNode) PrivateAddrV6() netip.Prefix {
 	if err != nil {
 		return netip.Prefix{}
 	}
+	addr = UnmapPrefix(addr)
+	if !addr.Addr().Is6() {
+		return netip.Prefix{}
+	}
 	return addr
 }
 
+// UnmapPrefix returns the given prefix with an IPv4-mapped IPv6 address, such
+// as ::ffff:10.0.0.1/128, converted to its IPv4 form. Other prefixes are
+// returned unchanged. A mapped prefix shorter than the mapping itself does
+// not describe an IPv4 network and an invalid prefix is returned.
+func UnmapPrefix(prefix netip.Prefix) netip.Prefix {
+	if !prefix.IsValid() || !prefix.Addr().Is4In6() {
+		return prefix
+	}
+	bits := prefix.Bits() - 96
+	if bits < 0 {
+		return netip.Prefix{}
+	}
+	return netip.PrefixFrom(prefix.Addr().Unmap(), bits)
+}
+
 // PublicRPCAddr returns the public address for the node's RPC server.
 // Be sure to check if the returned AddrPort IsValid.
 func (n MeshNode) PublicRPCAddr() netip.AddrPort {
-	rpcport := n.RPCPort()
-	if rpcport == 0 {
+	return n.PublicAddrFor(v1.Feature_NODES)
+}
+
+// PublicAddrFor returns the public address for the given feature. It is
+// invalid if the node does not advertise the feature or its primary endpoint
+// is not an IP address.
+// Be sure to check if the returned AddrPort IsValid.
+func (n MeshNode) PublicAddrFor(feature v1.Feature) netip.AddrPort {
+	port := n.PortFor(feature)
+	if port == 0 {
 		return netip.AddrPort{}
 	}
 	var addrport netip.AddrPort
 	if n.PrimaryEndpoint != "" {
 		addr, err := netip.ParseAddr(n.PrimaryEndpoint)
 		if err == nil {
-			addrport = netip.AddrPortFrom(addr, rpcport)
+			addrport = netip.AddrPortFrom(addr, port)
 		}
 	}
 	return addrport
@@ -157,6 +157,53 @@ func TestMeshNodeWrapper(t *testing.T) {
 		}
 	})
 
+	t.Run("NodePrivateAddrMapped", func(t *testing.T) {
+		t.Parallel()
+		node := MeshNode{&v1.MeshNode{}}
+		// A v4-mapped address in either field is classified as IPv4.
+		node.PrivateIPv4 = "::ffff:172.16.0.1/128"
+		want := netip.MustParsePrefix("172.16.0.1/32")
+		if got := node.PrivateAddrV4(); got != want {
+			t.Errorf("expected private addr to be %s, got %s", want, got)
+		}
+		node.PrivateIPv6 = "::ffff:172.16.0.1/128"
+		if addr := node.PrivateAddrV6(); addr.IsValid() {
+			t.Errorf("expected mapped private addr to not be IPv6, got %s", addr)
+		}
+		// An IPv6 address in the IPv4 field is not an IPv4 address.
+		node.PrivateIPv4 = "2001:db8::1/128"
+		if addr := node.PrivateAddrV4(); addr.IsValid() {
+			t.Errorf("expected private addr to be invalid, got %s", addr)
+		}
+		node.Features = append(node.Features, &v1.FeaturePort{
+			Feature: v1.Feature_NODES,
+			Port:    1,
+		})
+		node.PrivateIPv4 = "::ffff:172.16.0.1/128"
+		wantRPC := netip.MustParseAddrPort("172.16.0.1:1")
+		if got := node.PrivateRPCAddrV4(); got != wantRPC {
+			t.Errorf("expected private rpc addr to be %s, got %s", wantRPC, got)
+		}
+	})
+
+	t.Run("UnmapPrefix", func(t *testing.T) {
+		t.Parallel()
+		tc := map[string]string{
+			"::ffff:10.0.0.1/128": "10.0.0.1/32",
+			"::ffff:10.0.0.0/120": "10.0.0.0/24",
+			"10.0.0.0/24":         "10.0.0.0/24",
+			"fd00::/64":           "fd00::/64",
+		}
+		for in, want := range tc {
+			if got := UnmapPrefix(netip.MustParsePrefix(in)); got.String() != want {
+				t.Errorf("expected %s to unmap to %s, got %s", in, want, got)
+			}
+		}
+		if got := UnmapPrefix(netip.MustParsePrefix("::ffff:10.0.0.0/64")); got.IsValid() {
+			t.Errorf("expected a mapped prefix shorter than the mapping to be invalid, got %s", got)
+		}
+	})
+
 	t.Run("NodePublicRPCAddr", func(t *testing.T) {
 		t.Parallel()
 		node := MeshNode{&v1.MeshNode{}}
@@ -33,8 +33,44 @@ import (
 const (
 	// GroupReference is the prefix of a node name that indicates it is a group reference.
 	GroupReference = "group:"
+	// NodePatternWildcard is the wildcard character used in node patterns.
+	// It matches any sequence of characters in a node ID.
+	NodePatternWildcard = "*"
 )
 
+// IsNodePattern returns true if the given node reference is a pattern, such
+// as "worker-*". A bare wildcard is not considered a pattern.
+func IsNodePattern(node string) bool {
+	return node != NodePatternWildcard && strings.Contains(node, NodePatternWildcard)
+}
+
+// IsValidNodePattern returns true if the given node reference is a pattern
+// whose literal parts are valid ID characters.
+func IsValidNodePattern(pattern string) bool {
+	if !IsNodePattern(pattern) {
+		return false
+	}
+	return IsValidID(strings.ReplaceAll(pattern, NodePatternWildcard, "x"))
+}
+
+// MatchNodePattern returns true if the given node ID matches the pattern.
+func MatchNodePattern(pattern, id string) bool {
+	parts := strings.Split(pattern, NodePatternWildcard)
+	if !strings.HasPrefix(id, parts[0]) {
+		return false
+	}
+	id = id[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(id, part)
+		if idx < 0 {
+			return false
+		}
+		id = id[idx+len(part):]
+	}
+	return len(id) >= len(last) && strings.HasSuffix(id, last)
+}
+
 // ValidateACL validates a NetworkACL.
 func ValidateACL(acl NetworkACL) error {
 	if acl.GetName() == "" {
@@ -50,6 +86,12 @@ func ValidateACL(acl NetworkACL) error {
 		if node == "*" {
 			continue
 		}
+		if IsNodePattern(node) && !strings.HasPrefix(node, GroupReference) {
+			if !IsValidNodePattern(node) {
+				return fmt.Errorf("invalid node pattern: %s", node)
+			}
+			continue
+		}
 		node = strings.TrimPrefix(node, GroupReference)
 		if !IsValidID(node) {
 			return fmt.Errorf("invalid source node: %s", node)
@@ -117,7 +159,8 @@ func (a NetworkACLs) Proto() []*v1.NetworkACL {
 }
 
 // AllowNodesToCommunicate checks if the given nodes are allowed to communicate.
-func (a NetworkACLs) AllowNodesToCommunicate(ctx context.Context, nodeA, nodeB MeshNode) bool {
+// The defaultAction is applied when no ACL matches.
+func (a NetworkACLs) AllowNodesToCommunicate(ctx context.Context, nodeA, nodeB MeshNode, defaultAction v1.ACLAction) bool {
 	v4action := NetworkAction{
 		NetworkAction: &v1.NetworkAction{
 			SrcNode: nodeA.Id,
@@ -134,21 +177,36 @@ func (a NetworkACLs) AllowNodesToCommunicate(ctx context.Context, nodeA, nodeB M
 			DstCIDR: nodeB.PrivateIPv6,
 		},
 	}
-	return a.Accept(ctx, v4action) || a.Accept(ctx, v6action)
+	return a.Accept(ctx, v4action, defaultAction) || a.Accept(ctx, v6action, defaultAction)
 }
 
 // Accept evaluates an action against the ACLs in the list. It assumes the ACLs
 // are sorted by priority. The first ACL that matches the action will be used.
-// If no ACL matches, the action is denied.
-func (a NetworkACLs) Accept(ctx context.Context, action NetworkAction) bool {
+// If no ACL matches, the defaultAction is applied.
+func (a NetworkACLs) Accept(ctx context.Context, action NetworkAction, defaultAction v1.ACLAction) bool {
+	return a.Evaluate(ctx, action, defaultAction) == v1.ACLAction_ACTION_ACCEPT
+}
+
+// Evaluate returns the action of the first ACL in the list that matches the given
+// action, or defaultAction if none match. It assumes the ACLs are sorted by priority.
+func (a NetworkACLs) Evaluate(ctx context.Context, action NetworkAction, defaultAction v1.ACLAction) v1.ACLAction {
+	if acl, ok := a.Match(ctx, action); ok {
+		return acl.Action
+	}
+	context.LoggerFrom(ctx).Debug("No network ACL matches action, applying default", "action", action, "default", defaultAction.String())
+	return defaultAction
+}
+
+// Match returns the first ACL in the list that matches the given action. It
+// assumes the ACLs are sorted by priority.
+func (a NetworkACLs) Match(ctx context.Context, action NetworkAction) (NetworkACL, bool) {
 	for _, acl := range a {
 		if acl.Matches(ctx, action) {
 			context.LoggerFrom(ctx).Debug("Network ACL matches action", "action", action, "acl", acl)
-			return acl.Action == v1.ACLAction_ACTION_ACCEPT
+			return acl, true
 		}
 	}
-	context.LoggerFrom(ctx).Debug("No network ACL matches action, denying", "action", action)
-	return false
+	return NetworkACL{}, false
 }
 
 // NetworkACL is a Network ACL.
@@ -261,6 +319,9 @@ func containsOrWildcardMatch(ss []string, s string) bool {
 		if v == "*" || v == s {
 			return true
 		}
+		if IsNodePattern(v) && MatchNodePattern(v, s) {
+			return true
+		}
 	}
 	return false
 }
@@ -0,0 +1,116 @@
+/*
+Copyright 2023 Avi Zimmerman <avi.zimmerman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/webmeshproj/api/go/v1"
+)
+
+func TestNodePatterns(t *testing.T) {
+	t.Parallel()
+
+	tc := []struct {
+		pattern string
+		valid   bool
+		matches map[string]bool
+	}{
+		{pattern: "*", valid: false},
+		{pattern: "worker", valid: false},
+		{pattern: "worker-*", valid: true, matches: map[string]bool{
+			"worker-1": true, "worker-": true, "worker": false, "db-worker-1": false,
+		}},
+		{pattern: "*-db", valid: true, matches: map[string]bool{
+			"primary-db": true, "-db": true, "db": false, "primary-db-1": false,
+		}},
+		{pattern: "zone-*-worker-*", valid: true, matches: map[string]bool{
+			"zone-a-worker-1": true, "zone--worker-": true, "zone-a-db-1": false, "worker-1": false,
+		}},
+		{pattern: "a*a", valid: true, matches: map[string]bool{
+			"aa": true, "aba": true, "a": false,
+		}},
+		{pattern: "worker/*", valid: false},
+		{pattern: "worker,*", valid: false},
+		{pattern: "worker-?*", valid: false},
+	}
+	for _, tt := range tc {
+		if got := IsValidNodePattern(tt.pattern); got != tt.valid {
+			t.Errorf("IsValidNodePattern(%q) = %v, want %v", tt.pattern, got, tt.valid)
+		}
+		for id, want := range tt.matches {
+			if got := MatchNodePattern(tt.pattern, id); got != want {
+				t.Errorf("MatchNodePattern(%q, %q) = %v, want %v", tt.pattern, id, got, want)
+			}
+		}
+	}
+}
+
+func TestValidateACLNodePatterns(t *testing.T) {
+	t.Parallel()
+
+	tc := []struct {
+		name  string
+		nodes []string
+		valid bool
+	}{
+		{name: "pattern", nodes: []string{"worker-*"}, valid: true},
+		{name: "pattern with nodes", nodes: []string{"db", "worker-*", "*"}, valid: true},
+		{name: "invalid pattern", nodes: []string{"worker-?-*"}, valid: false},
+		{name: "group pattern", nodes: []string{"group:workers-*"}, valid: false},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, acl := range []NetworkACL{
+				{NetworkACL: &v1.NetworkACL{Name: "test", SourceNodes: tt.nodes}},
+				{NetworkACL: &v1.NetworkACL{Name: "test", DestinationNodes: tt.nodes}},
+			} {
+				err := ValidateACL(acl)
+				if tt.valid && err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				if !tt.valid && err == nil {
+					t.Error("expected error")
+				}
+			}
+		})
+	}
+}
+
+func TestNetworkACLMatchesNodePattern(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	acl := NetworkACL{NetworkACL: &v1.NetworkACL{
+		Name:             "workers-to-db",
+		Action:           v1.ACLAction_ACTION_ACCEPT,
+		SourceNodes:      []string{"worker-*"},
+		DestinationNodes: []string{"db"},
+	}}
+	tc := map[[2]string]bool{
+		{"worker-1", "db"}:   true,
+		{"worker-abc", "db"}: true,
+		{"client", "db"}:     false,
+		{"worker-1", "web"}:  false,
+	}
+	for flow, want := range tc {
+		action := NetworkAction{NetworkAction: &v1.NetworkAction{SrcNode: flow[0], DstNode: flow[1]}}
+		if got := acl.Matches(ctx, action); got != want {
+			t.Errorf("Matches(%s -> %s) = %v, want %v", flow[0], flow[1], got, want)
+		}
+	}
+}
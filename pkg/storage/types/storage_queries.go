@@ -19,6 +19,7 @@ package types
 import (
 	"encoding/json"
 	"net/netip"
+	"strconv"
 	"strings"
 
 	v1 "github.com/webmeshproj/api/go/v1"
@@ -65,12 +66,14 @@ const (
 	FilterTypePubKey   = "pubkey"   // Filter a node by their public key.
 	FilterTypeNodeID   = "nodeid"   // Filter an object by related node ID.
 	FilterTypeCIDR     = "cidr"     // Filter a route by CIDR.
+	FilterTypeAfter    = "after"    // Only list keys sorting after the given key.
+	FilterTypeLimit    = "limit"    // Limit the number of keys returned.
 )
 
 // IsValid returns true if the filter type is valid.
 func (f FilterType) IsValid() bool {
 	switch f {
-	case FilterTypeID, FilterTypePubKey, FilterTypeSourceID, FilterTypeTargetID, FilterTypeNodeID, FilterTypeCIDR:
+	case FilterTypeID, FilterTypePubKey, FilterTypeSourceID, FilterTypeTargetID, FilterTypeNodeID, FilterTypeCIDR, FilterTypeAfter, FilterTypeLimit:
 		return true
 	default:
 		return false
@@ -171,6 +174,20 @@ func (q QueryFilters) WithCIDR(cidr netip.Prefix) QueryFilters {
 	})
 }
 
+func (q QueryFilters) WithAfter(key string) QueryFilters {
+	return append(q, QueryFilter{
+		Type:  FilterTypeAfter,
+		Value: key,
+	})
+}
+
+func (q QueryFilters) WithLimit(limit int) QueryFilters {
+	return append(q, QueryFilter{
+		Type:  FilterTypeLimit,
+		Value: strconv.Itoa(limit),
+	})
+}
+
 func (q QueryFilters) GetID() (string, bool) {
 	for _, filter := range q {
 		if filter.Type == FilterTypeID {
@@ -229,6 +246,28 @@ func (q QueryFilters) GetCIDR() (netip.Prefix, bool) {
 	return netip.Prefix{}, false
 }
 
+func (q QueryFilters) GetAfter() (string, bool) {
+	for _, filter := range q {
+		if filter.Type == FilterTypeAfter {
+			return filter.Value, true
+		}
+	}
+	return "", false
+}
+
+func (q QueryFilters) GetLimit() (int, bool) {
+	for _, filter := range q {
+		if filter.Type == FilterTypeLimit {
+			limit, err := strconv.Atoi(filter.Value)
+			if err != nil || limit <= 0 {
+				return 0, false
+			}
+			return limit, true
+		}
+	}
+	return 0, false
+}
+
 func (q QueryFilters) GetByType(ftype FilterType) (QueryFilter, bool) {
 	for _, filter := range q {
 		if filter.Type == ftype {